@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"subscription_service/internal/i18n"
+	"subscription_service/internal/importer"
+	"subscription_service/internal/model"
+)
+
+// runImport — команда import: загружает подписки из CSV-файла в хранилище без HTTP.
+// Использование: app import [-on-conflict error|skip|update] [-batch-size N] файл.csv ("-" — стандартный ввод).
+// Хранилище выбирается так же, как при запуске сервера (STORAGE, DSN).
+// Возвращает код завершения: 0 — все строки приняты, 1 — ошибка импорта, 2 — есть отклонённые строки.
+func runImport(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	onConflict := fs.String("on-conflict", "error", "поведение при существующей подписке: error, skip или update")
+	batchSize := fs.Int("batch-size", importer.DefaultBatchSize, "число строк в одном пакете записи")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Использование: app import [флаги] файл.csv (\"-\" — стандартный ввод)")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	mode, err := model.ParseConflictMode(*onConflict)
+	if err != nil {
+		log.Printf("Неверный флаг -on-conflict: %v", err)
+		return 1
+	}
+
+	var input io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Printf("Не удалось открыть файл: %v", err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+
+	repo, closeStore := openStore(ctx)
+	defer closeStore()
+
+	lang := defaultLang()
+	catalog := i18n.NewCatalog(lang)
+	report, err := importer.Import(ctx, repo, input, importer.Options{
+		Mode:      mode,
+		BatchSize: *batchSize,
+		Message:   func(key string, args ...any) string { return catalog.Message(lang, key, args...) },
+	})
+	if report != nil {
+		printReport(os.Stdout, report)
+	}
+	if err != nil {
+		log.Printf("Импорт прерван: %v", err)
+		return 1
+	}
+	if report.Rejected > 0 {
+		return 2
+	}
+	return 0
+}
+
+// printReport — выводит отчёт об импорте: итоги и отклонённые строки с причинами
+func printReport(w io.Writer, report *importer.Report) {
+	fmt.Fprintf(w, "Принято: %d (создано %d, обновлено %d), пропущено: %d, отклонено: %d\n",
+		report.Accepted, report.Created, report.Updated, report.Skipped, report.Rejected)
+	for _, e := range report.Errors {
+		if e.Field != "" {
+			fmt.Fprintf(w, "строка %d, %s: %s\n", e.Line, e.Field, e.Reason)
+		} else {
+			fmt.Fprintf(w, "строка %d: %s\n", e.Line, e.Reason)
+		}
+	}
+	if len(report.Errors) < report.Rejected {
+		fmt.Fprintf(w, "... и ещё %d отклонённых строк\n", report.Rejected-len(report.Errors))
+	}
+}
@@ -4,12 +4,18 @@ import (
     "context"
     "log"
     "os"
+    "strconv"
+    "strings"
+    "time"
 
     "github.com/gin-gonic/gin"
 
     "subscription_service/internal/storage"
     "subscription_service/internal/repository"
     "subscription_service/internal/handler"
+    "subscription_service/internal/i18n"
+    "subscription_service/internal/model"
+    "subscription_service/internal/rates"
     "github.com/swaggo/gin-swagger"
     "github.com/swaggo/files"
     "subscription_service/docs"
@@ -19,47 +25,101 @@ import (
 func main() {
     ctx := context.Background()
 
-    // Получаем строку подключения к базе данных из переменных окружения
-    dsn := os.Getenv("DSN")
-    if dsn == "" {
-        log.Fatal("Переменная окружения DSN не установлена")
+    // Подкоманда import загружает подписки из CSV и завершает работу, не запуская сервер
+    if len(os.Args) > 1 && os.Args[1] == "import" {
+        os.Exit(runImport(ctx, os.Args[2:]))
     }
-
-    log.Println("Попытка подключения к базе данных...")
-    // Создаем пул подключений к PostgreSQL
-    db, err := storage.NewPostgres(ctx, dsn)
-    if err != nil {
-        log.Fatalf("Ошибка подключения к базе данных: %v", err)
+    // Подкоманда rates import загружает курсы валют из файлов ЦБ РФ и ЕЦБ
+    if len(os.Args) > 1 && os.Args[1] == "rates" {
+        os.Exit(runRates(ctx, os.Args[2:]))
     }
-    // Закрываем пул при завершении работы программы
-    defer func() {
-        log.Println("Закрытие подключения к базе данных")
-        db.Close()
-    }()
-    log.Println("Подключение к базе данных успешно")
 
-    // Создаем репозиторий для работы с подписками
-    repo := new(repository.SubRepository).NewSubRepository(db)
-    log.Println("Репозиторий подписок создан")
+    // Создаем хранилище подписок (PostgreSQL или память — по переменной STORAGE)
+    repo, closeStore := openStore(ctx)
+    defer closeStore()
 
     // Создаем HTTP-обработчики, передаем в них репозиторий
-    subHandler := handler.NewSubscriptionHandler(repo)
+    // При REQUIRE_IF_MATCH=true изменения подписок без заголовка If-Match отклоняются
+    var opts []handler.Option
+    if os.Getenv("REQUIRE_IF_MATCH") == "true" {
+        opts = append(opts, handler.WithRequireIfMatch())
+    }
+    // Ответы на POST /subscriptions с заголовком Idempotency-Key хранятся IDEMPOTENCY_TTL (по умолчанию 24h)
+    if store, ok := repo.(repository.IdempotencyStore); ok {
+        opts = append(opts, handler.WithIdempotency(store, idempotencyTTL()))
+        go purgeIdempotencyKeys(ctx, store)
+    }
+    // Сохранённое состояние подписок для фильтра status пересчитывается при запуске и в начале каждого месяца
+    if refresher, ok := repo.(repository.StatusRefresher); ok {
+        go refreshStatuses(ctx, refresher)
+    }
+    // MAX_BATCH_SIZE ограничивает число подписок в POST /subscriptions:batch (по умолчанию 1000)
+    if v := os.Getenv("MAX_BATCH_SIZE"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n <= 0 {
+            log.Fatalf("Неверная переменная окружения MAX_BATCH_SIZE: %q", v)
+        }
+        opts = append(opts, handler.WithMaxBatchSize(n))
+    }
+    // Курсы валют хранятся с историей версий; RATES_FILE — файлы курсов через запятую (XML ЦБ РФ, CSV ЕЦБ
+    // или CSV date,currency,rate), которые загружаются в хранилище при запуске (совпадающие курсы не дублируются)
+    rateStore, hasRates := repo.(repository.RateStore)
+    if hasRates {
+        opts = append(opts, handler.WithRates(rateStore))
+        if v := os.Getenv("RATES_FILE"); v != "" {
+            list, err := rates.Load(strings.Split(v, ",")...)
+            if err != nil {
+                log.Fatalf("Не удалось загрузить курсы валют: %v", err)
+            }
+            saved, err := rateStore.SaveRates(ctx, list)
+            if err != nil {
+                log.Fatalf("Не удалось сохранить курсы валют: %v", err)
+            }
+            log.Printf("Загружено курсов валют: %d, новых версий: %d", len(list), len(saved))
+        }
+    }
+    subHandler := handler.NewSubscriptionHandler(repo, opts...)
     log.Println("HTTP-обработчики подписок созданы")
 
     // Создаем роутер Gin — HTTP сервер
     router := gin.Default()
+    // Каждому запросу назначается идентификатор X-Request-ID; он же попадает в поле instance ошибок
+    router.Use(handler.RequestID())
+    // Язык сообщений API выбирается по Accept-Language, по умолчанию — из DEFAULT_LANG
+    router.Use(handler.Localize(i18n.NewCatalog(defaultLang())))
 
     docs.SwaggerInfo.BasePath = "/"
     // подключаем Swagger UI по пути /swagger/index.html
     router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
     // Регистрируем маршруты (HTTP эндпоинты) и связываем их с обработчиками
-    router.POST("/subscriptions", subHandler.CreateSubscription)                     // Создать новую подписку
-    router.GET("/subscriptions/:user_id/:service_name/:start_date", subHandler.GetSubscription) // Получить подписку по ключу
-    router.PUT("/subscriptions/:user_id/:service_name/:start_date", subHandler.UpdateSubscription) // Обновить подписку
-    router.DELETE("/subscriptions/:user_id/:service_name/:start_date", subHandler.DeleteSubscription) // Удалить подписку
+    router.POST("/subscriptions", subHandler.Idempotent(), subHandler.CreateSubscription) // Создать новую подписку
+    router.POST("/subscriptions/import", subHandler.ImportSubscriptions)                // Импорт подписок из CSV
+    // Действия над коллекцией (/subscriptions:batch) — один маршрут, т.к. gin не допускает ':' внутри сегмента
+    router.POST("/subscriptions:action", subHandler.Idempotent(), subHandler.SubscriptionsAction) // Пакетное создание подписок
+    router.GET("/subscriptions/:id", subHandler.GetSubscriptionByID)                 // Получить подписку по id
+    router.PUT("/subscriptions/:id", subHandler.ReplaceSubscriptionByID)             // Заменить подписку по id
+    router.PATCH("/subscriptions/:id", subHandler.PatchSubscriptionByID)             // Частично обновить подписку по id
+    router.DELETE("/subscriptions/:id", subHandler.DeleteSubscriptionByID)           // Удалить подписку по id
+    router.POST("/subscriptions/:id/cancel", subHandler.CancelSubscription)          // Отменить подписку
+    router.POST("/subscriptions/:id/pause", subHandler.PauseSubscription)            // Приостановить подписку
+    router.POST("/subscriptions/:id/resume", subHandler.ResumeSubscription)          // Возобновить подписку
+
+    // Маршруты по составному ключу (user_id, service_name, start_date) — оставлены для совместимости.
+    // Первый сегмент называется :id, т.к. gin требует одинаковых имён параметров на одной позиции пути.
+    router.GET("/subscriptions/:id/:service_name/:start_date", subHandler.GetSubscription) // Получить подписку по ключу
+    router.PUT("/subscriptions/:id/:service_name/:start_date", subHandler.UpdateSubscription) // Обновить подписку
+    router.PATCH("/subscriptions/:id/:service_name/:start_date", subHandler.PatchSubscription) // Частично обновить подписку по ключу
+    router.DELETE("/subscriptions/:id/:service_name/:start_date", subHandler.DeleteSubscription) // Удалить подписку
     router.GET("/subscriptions", subHandler.ListSubscriptions)                       // Получить список подписок с фильтрацией
     router.GET("/subscriptions/total_price", subHandler.CalculateTotalPrice)         // Подсчитать общую стоимость подписок за период
+    router.GET("/subscriptions/spend/timeline", subHandler.SpendTimeline)            // Помесячная разбивка расходов за период
+    router.GET("/users/:user_id/upcoming_charges", subHandler.UpcomingCharges)       // Календарь ожидаемых списаний пользователя
+    if hasRates {
+        router.POST("/rates", subHandler.UploadRates)                  // Загрузить курсы валют (JSON, XML ЦБ РФ, CSV ЕЦБ)
+        router.GET("/rates", subHandler.ListRates)                     // Курсы валют с историей версий
+        router.PUT("/rates/:currency/:date", subHandler.CorrectRate)   // Исправить курс валюты на дату
+    }
 
     log.Println("Запуск сервера на порту :8080")
     // Запускаем HTTP сервер на порту 8080
@@ -67,3 +127,111 @@ func main() {
         log.Fatalf("Ошибка при запуске сервера: %v", err)
     }
 }
+
+
+// defaultLang — язык сообщений API по умолчанию из переменной DEFAULT_LANG (ru, если не задана)
+func defaultLang() i18n.Lang {
+    v := os.Getenv("DEFAULT_LANG")
+    if v == "" {
+        return i18n.RU
+    }
+    lang, err := i18n.ParseLang(v)
+    if err != nil {
+        log.Fatalf("Неверная переменная окружения DEFAULT_LANG: %v", err)
+    }
+    return lang
+}
+
+// idempotencyTTL — срок хранения ответов для ключей идемпотентности из переменной IDEMPOTENCY_TTL
+func idempotencyTTL() time.Duration {
+    v := os.Getenv("IDEMPOTENCY_TTL")
+    if v == "" {
+        return handler.DefaultIdempotencyTTL
+    }
+    ttl, err := time.ParseDuration(v)
+    if err != nil || ttl <= 0 {
+        log.Fatalf("Неверная переменная окружения IDEMPOTENCY_TTL: %q", v)
+    }
+    return ttl
+}
+
+// purgeIdempotencyKeys — раз в час удаляет истёкшие ключи идемпотентности
+func purgeIdempotencyKeys(ctx context.Context, store repository.IdempotencyStore) {
+    ticker := time.NewTicker(time.Hour)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case now := <-ticker.C:
+            n, err := store.PurgeIdempotencyKeys(ctx, now)
+            if err != nil {
+                log.Printf("Ошибка при удалении истёкших ключей идемпотентности: %v", err)
+                continue
+            }
+            if n > 0 {
+                log.Printf("Удалено истёкших ключей идемпотентности: %d", n)
+            }
+        }
+    }
+}
+
+// refreshStatuses — пересчитывает состояние подписок при запуске и затем в начале каждого месяца (UTC),
+// когда оно меняется без записи подписки
+func refreshStatuses(ctx context.Context, store repository.StatusRefresher) {
+    for {
+        month := model.CurrentMonth()
+        n, err := store.RefreshStatuses(ctx, month)
+        if err != nil {
+            log.Printf("Ошибка при пересчёте состояния подписок: %v", err)
+        } else if n > 0 {
+            log.Printf("Пересчитано состояние подписок: %d", n)
+        }
+
+        // Следующий пересчёт — в начале следующего месяца; после ошибки — через минуту
+        wait := time.Until(month.ToTime().AddDate(0, 1, 0))
+        if err != nil {
+            wait = time.Minute
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(wait):
+        }
+    }
+}
+
+// openStore — создает хранилище подписок.
+// При STORAGE=memory данные хранятся в памяти процесса (удобно для разработки и демо),
+// иначе выполняется подключение к PostgreSQL по строке из переменной DSN.
+// Возвращает хранилище и функцию для его закрытия.
+func openStore(ctx context.Context) (repository.SubscriptionStore, func()) {
+    if os.Getenv("STORAGE") == "memory" {
+        log.Println("Используется хранилище подписок в памяти, данные не сохраняются между запусками")
+        return repository.NewMemoryRepository(), func() {}
+    }
+
+    // Получаем строку подключения к базе данных из переменных окружения
+    dsn := os.Getenv("DSN")
+    if dsn == "" {
+        log.Fatal("Переменная окружения DSN не установлена")
+    }
+
+    log.Println("Попытка подключения к базе данных...")
+    // Создаем пул подключений к PostgreSQL
+    db, err := storage.NewPostgres(ctx, dsn)
+    if err != nil {
+        log.Fatalf("Ошибка подключения к базе данных: %v", err)
+    }
+    log.Println("Подключение к базе данных успешно")
+
+    // Создаем репозиторий для работы с подписками
+    repo := new(repository.SubRepository).NewSubRepository(db)
+    log.Println("Репозиторий подписок создан")
+
+    // Закрываем пул при завершении работы программы
+    return repo, func() {
+        log.Println("Закрытие подключения к базе данных")
+        db.Close()
+    }
+}
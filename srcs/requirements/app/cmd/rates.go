@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/rates"
+	"subscription_service/internal/repository"
+)
+
+// runRates — команда rates import: загружает курсы валют из файлов в хранилище без HTTP.
+// Использование: app rates import [-format cbr|ecb|csv] файл... (ежедневный XML ЦБ РФ, CSV ЕЦБ
+// или CSV date,currency,rate; без -format формат определяется по содержимому каждого файла).
+// Хранилище выбирается так же, как при запуске сервера (STORAGE, DSN).
+// Курсы сохраняются новыми версиями, совпадающие с последней версией пропускаются.
+// Возвращает код завершения: 0 — курсы загружены, 1 — ошибка.
+func runRates(ctx context.Context, args []string) int {
+	if len(args) == 0 || args[0] != "import" {
+		fmt.Fprintln(os.Stderr, "Использование: app rates import [флаги] файл...")
+		return 1
+	}
+	fs := flag.NewFlagSet("rates import", flag.ContinueOnError)
+	formatFlag := fs.String("format", "", "формат файлов: cbr, ecb или csv (по умолчанию — по содержимому)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Использование: app rates import [флаги] файл...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 1
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 1
+	}
+	format, err := rates.ParseFormat(*formatFlag)
+	if err != nil {
+		log.Printf("Неверный флаг -format: %v", err)
+		return 1
+	}
+
+	var list []model.ExchangeRate
+	for _, path := range fs.Args() {
+		parsed, err := parseRatesFile(path, format)
+		if err != nil {
+			log.Printf("Файл курсов %s: %v", path, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stdout, "%s: курсов %d\n", path, len(parsed))
+		list = append(list, parsed...)
+	}
+
+	repo, closeStore := openStore(ctx)
+	defer closeStore()
+	store, ok := repo.(repository.RateStore)
+	if !ok {
+		log.Print("Хранилище не поддерживает курсы валют")
+		return 1
+	}
+	saved, err := store.SaveRates(ctx, list)
+	if err != nil {
+		log.Printf("Не удалось сохранить курсы валют: %v", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stdout, "Получено курсов: %d, сохранено новых версий: %d\n", len(list), len(saved))
+	if len(saved) > 0 {
+		fmt.Fprintf(os.Stdout, "Момент записи (для rates_as_of): %s\n", saved[0].RecordedAt.Format(time.RFC3339Nano))
+	}
+	return 0
+}
+
+// parseRatesFile — читает и проверяет курсы из файла path в формате format
+func parseRatesFile(path string, format rates.Format) ([]model.ExchangeRate, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list, err := rates.Parse(file, format)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range list {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
@@ -15,201 +15,375 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/subscriptions": {
+        "/rates": {
             "get": {
-                "description": "Обработчик GET /subscriptions с параметрами фильтрации. Возвращает список подписок с возможной фильтрацией по user_id, service_name, start_date и end_date",
+                "description": "Обработчик GET /rates. Возвращает курсы по фильтру в порядке валюты, валюты курса и даты.\nПо умолчанию — последняя версия курса на каждую дату; с as_of — версии, записанные не позже этого момента\n(курсы, по которым считались отчёты в тот момент); с history=true — все версии.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "rates"
+                ],
+                "summary": "Получить курсы валют",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Валюта курса (ISO 4217)",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Валюта, в которой выражен курс (ISO 4217)",
+                        "name": "quote",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата курса не раньше (YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата курса не позже (YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Момент, на который известны курсы (RFC 3339)",
+                        "name": "as_of",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Вернуть все версии курсов",
+                        "name": "history",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Получить список подписок",
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Курсы валют",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/model.Subscription"
-                            }
+                            "$ref": "#/definitions/handler.RatesListResponse"
                         }
                     },
                     "400": {
-                        "description": "Ошибка валидации входных параметров (например, неверный UUID или формат даты)",
+                        "description": "Ошибка запроса",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Создает новую запись о подпискена основе JSON-запроса. обработчик POST /subscriptions",
+                "description": "Обработчик POST /rates. Принимает курсы в JSON ({\"rates\": [...]}) или файлом: ежедневный XML ЦБ РФ,\nCSV ЕЦБ (eurofxref.csv, eurofxref-hist.csv) или CSV с колонками date, currency, rate — в теле запроса\nили в поле file формы multipart/form-data. Формат файла определяется по содержимому, если не задан format.\nКурсы сохраняются новыми версиями; курс, совпадающий с последней версией на ту же дату, не сохраняется.\nrecorded_at в ответе можно передать в rates_as_of отчётов, чтобы позже получить те же суммы.",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "text/csv",
+                    "text/xml",
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "rates"
                 ],
-                "summary": "Создать подписку",
+                "summary": "Загрузить курсы валют",
                 "parameters": [
                     {
-                        "description": "Данные подписки",
-                        "name": "subscription",
+                        "description": "Курсы (для application/json)",
+                        "name": "request",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.Subscription"
+                            "$ref": "#/definitions/handler.RatesUploadRequest"
                         }
+                    },
+                    {
+                        "enum": [
+                            "cbr",
+                            "ecb",
+                            "csv"
+                        ],
+                        "type": "string",
+                        "description": "Формат файла курсов",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "file",
+                        "description": "Файл курсов (для multipart/form-data)",
+                        "name": "file",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Подписка успешно создана",
+                    "200": {
+                        "description": "Итоги загрузки",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.RatesUploadResponse"
                         }
                     },
                     "400": {
-                        "description": "Ошибка запроса",
+                        "description": "Ошибка запроса или неверный файл курсов",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
             }
         },
-        "/subscriptions/total_price": {
-            "get": {
-                "description": "Обработчик GET /subscriptions/total_price.Считает стоимость подписок за период с фильтрацией по id пользователя и названию сервиса.\n/subscriptions/total_price?from_date={start_date}\u0026to_date={end_date}\u0026user_id={user_id}\u0026service_name={service_name}",
+        "/rates/{currency}/{date}": {
+            "put": {
+                "description": "Обработчик PUT /rates/:currency/:date. Сохраняет курс валюты на дату новой версией (источник manual);\nпрежние версии остаются в истории и используются отчётами с rates_as_of до момента исправления.\nЕсли курс совпадает с последней версией, новая версия не создаётся и возвращается текущая.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "rates"
                 ],
-                "summary": "Посчитать суммарную стоимость подписок",
+                "summary": "Исправить курс валюты на дату",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "UUID пользователя",
-                        "name": "user_id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Название сервиса",
-                        "name": "service_name",
-                        "in": "query"
+                        "description": "Валюта курса (ISO 4217)",
+                        "name": "currency",
+                        "in": "path",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Начало периода (MM-YYYY)",
-                        "name": "start_date",
-                        "in": "query",
+                        "description": "Дата курса (YYYY-MM-DD)",
+                        "name": "date",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Конец периода (MM-YYYY)",
-                        "name": "end_date",
-                        "in": "query",
-                        "required": true
+                        "description": "Новое значение курса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.RateCorrectionRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Общая сумма",
+                        "description": "Текущая версия курса",
                         "schema": {
-                            "$ref": "#/definitions/handler.TotalPriceResponse"
+                            "$ref": "#/definitions/model.ExchangeRate"
                         }
                     },
                     "400": {
                         "description": "Ошибка запроса",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
             }
         },
-        "/subscriptions/{user_id}/{service_name}/{start_date}": {
+        "/subscriptions": {
             "get": {
-                "description": "Обработчик GET /subscriptions/:user_id/:service_name/:start_date. Получает подписку по user_id, service_name и start_date",
+                "description": "Обработчик GET /subscriptions с параметрами фильтрации. Возвращает страницу подписок с возможной фильтрацией по user_id, service_name, датам, цене и активности\nи общее количество подходящих записей. Для перехода на следующую страницу передайте next_cursor в параметре cursor.\nС заголовком Accept: text/csv, application/x-ndjson или application/vnd.openxmlformats-officedocument.spreadsheetml.sheet\nвозвращается выгрузка всех подходящих подписок в этом формате (limit, offset и cursor не учитываются, sort — учитывается).\nCSV и XLSX начинаются строкой заголовка и заканчиваются строками итогов с суммой цен по каждой паре валюты и периода оплаты; NDJSON содержит по одной подписке на строку.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
                 ],
                 "tags": [
                     "subscriptions"
                 ],
-                "summary": "Получить подписку",
+                "summary": "Получить список подписок",
                 "parameters": [
                     {
                         "type": "string",
                         "description": "UUID пользователя",
                         "name": "user_id",
-                        "in": "path",
-                        "required": true
+                        "in": "query"
                     },
                     {
                         "type": "string",
                         "description": "Название сервиса",
                         "name": "service_name",
-                        "in": "path",
-                        "required": true
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "substring",
+                            "exact"
+                        ],
+                        "type": "string",
+                        "description": "Режим поиска по названию сервиса: substring (по умолчанию, без учёта регистра) или exact",
+                        "name": "service_name_match",
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Дата начала подписки (MM-YYYY)",
+                        "description": "Дата начала не раньше (MM-YYYY)",
                         "name": "start_date",
-                        "in": "path",
-                        "required": true
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата начала не позже (MM-YYYY)",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "199.99",
+                        "description": "Цена не меньше (сумма не более чем с двумя знаками после точки)",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "999.00",
+                        "description": "Цена не больше (сумма не более чем с двумя знаками после точки)",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Подписка активна в указанном месяце (MM-YYYY)",
+                        "name": "active_on",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата окончания раньше указанного месяца (MM-YYYY)",
+                        "name": "ends_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата окончания позже указанного месяца (MM-YYYY)",
+                        "name": "ends_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "true — только бессрочные подписки, false — только с датой окончания",
+                        "name": "open_ended",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "active",
+                            "upcoming",
+                            "expired",
+                            "paused",
+                            "cancelled"
+                        ],
+                        "type": "string",
+                        "description": "Состояние подписки в текущем месяце",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы (по умолчанию 100, максимум 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение (нельзя использовать вместе с cursor)",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор следующей страницы из next_cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "price",
+                            "-price",
+                            "start_date",
+                            "-start_date",
+                            "service_name",
+                            "-service_name"
+                        ],
+                        "type": "string",
+                        "description": "Сортировка: price, start_date, service_name; префикс '-' — по убыванию",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag ранее полученной страницы",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Subscription"
+                            "$ref": "#/definitions/handler.ListResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Слабый ETag содержимого страницы"
+                            }
                         }
                     },
-                    "404": {
-                        "description": "Подписка не найдена",
+                    "304": {
+                        "description": "Страница не изменилась"
+                    },
+                    "400": {
+                        "description": "Ошибка валидации входных параметров (например, неверный UUID или формат даты)",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Выгрузка XLSX не помещается в лист",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
             },
-            "put": {
-                "description": "Обработчик PUT /subscriptions/:user_id/:service_name/:start_date Обновляет цену и дату окончания подписки по ключу user_id + service_name + start_date",
+            "post": {
+                "description": "Создает новую запись о подписке на основе JSON-запроса. обработчик POST /subscriptions. Возвращает созданную подписку с назначенным id",
                 "consumes": [
                     "application/json"
                 ],
@@ -219,150 +393,2801 @@ const docTemplate = `{
                 "tags": [
                     "subscriptions"
                 ],
-                "summary": "Обновить подписку",
+                "summary": "Создать подписку",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "UUID пользователя",
-                        "name": "user_id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Название сервиса",
-                        "name": "service_name",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Дата начала подписки (MM-YYYY)",
-                        "name": "start_date",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Обновленные данные подписки",
+                        "description": "Данные подписки",
                         "name": "subscription",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.Subscription"
+                            "$ref": "#/definitions/handler.SubscriptionRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности: повтор запроса с тем же ключом и телом вернёт сохранённый ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Подписка успешно обновлена",
+                    "201": {
+                        "description": "Созданная подписка; адрес в заголовке Location",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия подписки"
+                            },
+                            "Idempotent-Replayed": {
+                                "type": "string",
+                                "description": "true, если ответ повторён по Idempotency-Key"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "/subscriptions/{id}"
+                            }
                         }
                     },
                     "400": {
                         "description": "Ошибка запроса",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Подписка не найдена",
+                    "409": {
+                        "description": "Подписка с таким ключом уже существует или запрос с тем же Idempotency-Key ещё выполняется",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Данные нарушают ограничения (например, отрицательная цена) или Idempotency-Key использован с другим телом",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Обработчик DELETE /subscriptions/:user_id/:service_name/:start_date.Удаляет подписку по user_id, service_name и start_date",
+            }
+        },
+        "/subscriptions/import": {
+            "post": {
+                "description": "Обработчик POST /subscriptions/import. Принимает CSV с колонками service_name, price, user_id, start_date, end_date\n(даты в формате MM-YYYY, end_date может быть пустой) в теле запроса (text/csv) или в поле file формы multipart/form-data.\nПервая строка с колонкой service_name считается заголовком, и колонки в ней могут идти в любом порядке.\nСтроки проверяются по правилам POST /subscriptions и записываются пакетами по мере чтения; неверные строки не прерывают импорт\nи перечисляются в отчёте с номером строки и причиной. При on_conflict=error существующая подписка отклоняет только свою строку.",
+                "consumes": [
+                    "text/csv",
+                    "multipart/form-data"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "subscriptions"
                 ],
-                "summary": "Удалить подписку",
+                "summary": "Импортировать подписки из CSV",
                 "parameters": [
                     {
+                        "enum": [
+                            "error",
+                            "skip",
+                            "update"
+                        ],
                         "type": "string",
-                        "description": "UUID пользователя",
-                        "name": "user_id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Название сервиса",
-                        "name": "service_name",
-                        "in": "path",
-                        "required": true
+                        "default": "error",
+                        "description": "Поведение при существующей подписке",
+                        "name": "on_conflict",
+                        "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Дата начала подписки (MM-YYYY)",
-                        "name": "start_date",
-                        "in": "path",
-                        "required": true
+                        "type": "file",
+                        "description": "CSV-файл (для multipart/form-data)",
+                        "name": "file",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Подписка удалена",
+                        "description": "Отчёт об импорте",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/importer.Report"
                         }
                     },
-                    "404": {
-                        "description": "Подписка не найдена",
+                    "400": {
+                        "description": "Неверный параметр или заголовок CSV",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
-                        "description": "Внутренняя ошибка сервера",
+                        "description": "Внутренняя ошибка сервера; часть строк могла быть записана",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handler.TotalPriceResponse": {
-            "type": "object",
-            "properties": {
-                "total_price": {
-                    "type": "integer"
-                }
-            }
         },
-        "model.Subscription": {
-            "description": "Подписка пользователя на онлайн-сервис. Используется для учёта затрат.",
+        "/subscriptions/spend/timeline": {
+            "get": {
+                "description": "Обработчик GET /subscriptions/spend/timeline. Возвращает по одному элементу на каждый календарный месяц периода\nс суммарной стоимостью подписок, активных в этом месяце. Фильтрация по user_id и service_name как в GET /subscriptions.\nПри view=charges (по умолчанию) подписка попадает в месяцы своих списаний (годовая — раз в год),\nпри view=run_rate — в каждый активный месяц с ценой, пересчитанной на месяц (monthly run-rate).\nСуммы пересчитываются в валюту currency (по умолчанию RUB) по курсу на первое число каждого месяца\n(rates_used — использованные курсы; с rates_as_of — курсы, записанные не позже этого момента).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Помесячная разбивка расходов на подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начало периода (MM-YYYY)",
+                        "name": "from_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец периода (MM-YYYY)",
+                        "name": "to_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "charges",
+                            "run_rate"
+                        ],
+                        "type": "string",
+                        "description": "Распределение стоимости: charges — списания (по умолчанию), run_rate — ежемесячный эквивалент",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Валюта результата (ISO 4217, по умолчанию RUB)",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пересчитать по курсам, известным на этот момент (RFC 3339); по умолчанию — текущие",
+                        "name": "rates_as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Помесячная разбивка расходов",
+                        "schema": {
+                            "$ref": "#/definitions/handler.TimelineResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Нет курса валюты для пересчёта",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/total_price": {
+            "get": {
+                "description": "Обработчик GET /subscriptions/total_price.Считает стоимость подписок за период с фильтрацией по id пользователя и названию сервиса.\nКаждая подписка учитывается за месяцы, в которых она активна внутри периода (с учётом end_date и приостановок):\nпри view=charges (по умолчанию) — списаниями своего периода оплаты (годовая подписка — один раз в год),\nпри view=run_rate — ценой, пересчитанной на месяц (monthly_price), за каждый активный месяц.\nСуммы пересчитываются в валюту currency (по умолчанию RUB) по курсу на первое число каждого месяца;\nsubtotals — суммы в исходных валютах подписок без пересчёта, rates_used — курсы, по которым выполнен пересчёт.\nС rates_as_of используются курсы, записанные не позже этого момента, и отчёт повторяет посчитанный тогда.\n/subscriptions/total_price?from_date={from_date}\u0026to_date={to_date}\u0026user_id={user_id}\u0026service_name={service_name}\nПри заданном group_by дополнительно возвращаются суммы по группам: по месяцу (если он в группировке), затем по убыванию суммы;\nгруппы считаются в том же проходе, что и общая сумма. Период — не более 120 месяцев.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Посчитать суммарную стоимость подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начало периода (MM-YYYY)",
+                        "name": "from_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец периода (MM-YYYY)",
+                        "name": "to_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "Группировка: service_name, user_id, month (можно комбинировать через запятую)",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "charges",
+                            "run_rate"
+                        ],
+                        "type": "string",
+                        "description": "Распределение стоимости: charges — списания (по умолчанию), run_rate — ежемесячный эквивалент",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Валюта результата (ISO 4217, по умолчанию RUB)",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пересчитать по курсам, известным на этот момент (RFC 3339); по умолчанию — текущие",
+                        "name": "rates_as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Общая сумма и детализация по подпискам",
+                        "schema": {
+                            "$ref": "#/definitions/handler.TotalPriceResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Нет курса валюты для пересчёта",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}": {
+            "get": {
+                "description": "Обработчик GET /subscriptions/:id. Получает подписку по её идентификатору",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить подписку по id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag известной клиенту версии подписки",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия подписки"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Подписка не изменилась"
+                    },
+                    "400": {
+                        "description": "Неверный id",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Обработчик PUT /subscriptions/:id. Полностью заменяет данные подписки, включая сервис, пользователя и дату начала.\nОтсутствующий end_date означает бессрочную подписку.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Заменить подписку по id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новые данные подписки",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.SubscriptionRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag изменяемой версии подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка с таким составным ключом уже существует",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменилась после получения ETag",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Данные нарушают ограничения (например, отрицательная цена)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан обязательный заголовок If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Обработчик DELETE /subscriptions/:id. Удаляет подписку по её идентификатору",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Удалить подписку по id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag удаляемой версии подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Подписка удалена"
+                    },
+                    "400": {
+                        "description": "Неверный id",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменилась после получения ETag",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан обязательный заголовок If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Обработчик PATCH /subscriptions/:id (JSON Merge Patch). Изменяются только переданные поля:\nservice_name, price, currency, billing_period, start_date, billing_day, end_date. Значение null у end_date делает подписку бессрочной.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Частично обновить подписку по id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменяемые поля",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.SubscriptionPatchRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag изменяемой версии подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка с таким составным ключом уже существует",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменилась после получения ETag",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Данные нарушают ограничения (например, отрицательная цена)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан обязательный заголовок If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/cancel": {
+            "post": {
+                "description": "Обработчик POST /subscriptions/:id/cancel. Отменяет подписку: effective_month становится её последним\nоплачиваемым месяцем (end_date). Приостановки после него отбрасываются. После effective_month подписка\nполучает состояние cancelled. Тело запроса необязательно.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Отменить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Месяц отмены",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CancelRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag изменяемой версии подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка уже отменена или месяц отмены вне подписки",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменилась после получения ETag",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан обязательный заголовок If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/pause": {
+            "post": {
+                "description": "Обработчик POST /subscriptions/:id/pause. Приостанавливает подписку с месяца from по месяц to включительно;\nбез to — до возобновления (POST /subscriptions/:id/resume). Месяцы приостановки не учитываются\nв стоимости и разбивке расходов. Тело запроса необязательно.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Приостановить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Период приостановки",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handler.PauseRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag изменяемой версии подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка отменена, период вне подписки или пересекается с другой приостановкой",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменилась после получения ETag",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан обязательный заголовок If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/resume": {
+            "post": {
+                "description": "Обработчик POST /subscriptions/:id/resume. Завершает приостановку, в которую попадает month, в предыдущем месяце.\nОтменённая подписка снова становится бессрочной; месяцы между её последним оплаченным месяцем и month\nзаписываются как приостановка. Тело запроса необязательно.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Возобновить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Месяц возобновления",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ResumeRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag изменяемой версии подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка не приостановлена и не отменена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменилась после получения ETag",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан обязательный заголовок If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{user_id}/{service_name}/{start_date}": {
+            "get": {
+                "description": "Обработчик GET /subscriptions/:user_id/:service_name/:start_date. Получает подписку по user_id, service_name и start_date",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Месяц начала подписки (MM-YYYY или YYYY-MM-DD; день не учитывается)",
+                        "name": "start_date",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag известной клиенту версии подписки",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия подписки"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Подписка не изменилась"
+                    },
+                    "400": {
+                        "description": "Неверный user_id или start_date",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Обработчик PUT /subscriptions/:user_id/:service_name/:start_date Обновляет цену и дату окончания подписки по ключу user_id + service_name + start_date.\nЦена обязательна (допускается 0). Отсутствующий end_date не изменяется, null делает подписку бессрочной.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Обновить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Месяц начала подписки (MM-YYYY или YYYY-MM-DD; день не учитывается)",
+                        "name": "start_date",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Обновленные данные подписки",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.SubscriptionUpdateRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag изменяемой версии подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Подписка успешно обновлена",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменилась после получения ETag",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Данные нарушают ограничения (например, отрицательная цена)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан обязательный заголовок If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Обработчик DELETE /subscriptions/:user_id/:service_name/:start_date.Удаляет подписку по user_id, service_name и start_date",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Удалить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Месяц начала подписки (MM-YYYY или YYYY-MM-DD; день не учитывается)",
+                        "name": "start_date",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag удаляемой версии подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Подписка удалена",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный user_id или start_date",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменилась после получения ETag",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан обязательный заголовок If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Обработчик PATCH /subscriptions/:user_id/:service_name/:start_date (JSON Merge Patch). Изменяются только переданные поля:\nservice_name, price, currency, billing_period, start_date, end_date. Значение null у end_date делает подписку бессрочной.\nСмена service_name или start_date переносит подписку на новый составной ключ в одной транзакции.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Частично обновить подписку по составному ключу",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Месяц начала подписки (MM-YYYY или YYYY-MM-DD; день не учитывается)",
+                        "name": "start_date",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменяемые поля",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.SubscriptionPatchRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag изменяемой версии подписки",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка с новым составным ключом уже существует",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменилась после получения ETag",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Данные нарушают ограничения (например, отрицательная цена)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан обязательный заголовок If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions:batch": {
+            "post": {
+                "description": "Обработчик POST /subscriptions:batch. Создает до MAX_BATCH_SIZE подписок в одной транзакции.\nКаждый элемент проверяется по тем же правилам, что и в POST /subscriptions; при ошибке валидации любого элемента ничего не сохраняется.\nПараметр on_conflict задает поведение, если подписка с тем же user_id, service_name и start_date уже существует:\nerror — отменить весь пакет (409), skip — пропустить элемент, update — обновить цену, период оплаты и дату окончания.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Создать пакет подписок",
+                "parameters": [
+                    {
+                        "description": "Подписки",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.BatchCreateRequest"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "error",
+                            "skip",
+                            "update"
+                        ],
+                        "type": "string",
+                        "default": "error",
+                        "description": "Поведение при конфликте ключа",
+                        "name": "on_conflict",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности: повтор запроса с тем же ключом и телом вернёт сохранённый ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результаты по элементам в порядке запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BatchCreateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации; поля элементов называются items[\u003cиндекс\u003e].\u003cполе\u003e",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписки уже существуют (on_conflict=error); элементы перечислены в errors",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Данные элемента нарушают ограничения (например, отрицательная цена)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions:bulkDelete": {
+            "post": {
+                "description": "Обработчик POST /subscriptions:bulkDelete. Удаляет все подписки, подходящие под фильтры GET /subscriptions (нужен хотя бы один фильтр).\nПо умолчанию выполняется пробный запуск (dry_run=true): возвращается число подходящих подписок и до 10 примеров, ничего не удаляется.\nДля удаления передайте dry_run=false и expected_count из пробного запуска; если число подходящих подписок изменилось, ответ — 412.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Удалить подписки по фильтру",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "substring",
+                            "exact"
+                        ],
+                        "type": "string",
+                        "description": "Режим поиска по названию сервиса: substring (по умолчанию, без учёта регистра) или exact",
+                        "name": "service_name_match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата начала не раньше (MM-YYYY)",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата начала не позже (MM-YYYY)",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "199.99",
+                        "description": "Цена не меньше (сумма не более чем с двумя знаками после точки)",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "999.00",
+                        "description": "Цена не больше (сумма не более чем с двумя знаками после точки)",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Подписка активна в указанном месяце (MM-YYYY)",
+                        "name": "active_on",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата окончания раньше указанного месяца (MM-YYYY)",
+                        "name": "ends_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата окончания позже указанного месяца (MM-YYYY)",
+                        "name": "ends_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "true — только бессрочные подписки, false — только с датой окончания",
+                        "name": "open_ended",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "active",
+                            "upcoming",
+                            "expired",
+                            "paused",
+                            "cancelled"
+                        ],
+                        "type": "string",
+                        "description": "Состояние подписки в текущем месяце",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Пробный запуск без изменений",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Число подписок из пробного запуска (обязательно при dry_run=false)",
+                        "name": "expected_count",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат пробного запуска или число удалённых подписок",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BulkResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров или пустой фильтр",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Число подходящих подписок не совпадает с expected_count",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions:bulkUpdate": {
+            "post": {
+                "description": "Обработчик POST /subscriptions:bulkUpdate. Меняет цену, валюту, период оплаты и/или дату окончания всех подписок, подходящих под фильтры GET /subscriptions (нужен хотя бы один фильтр).\nТело запроса — JSON Merge Patch с полями price и end_date (null делает подписки бессрочными); каждая подписка получает новую версию.\nПробный запуск и подтверждение через expected_count — как в POST /subscriptions:bulkDelete.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Изменить подписки по фильтру",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "substring",
+                            "exact"
+                        ],
+                        "type": "string",
+                        "description": "Режим поиска по названию сервиса: substring (по умолчанию, без учёта регистра) или exact",
+                        "name": "service_name_match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата начала не раньше (MM-YYYY)",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата начала не позже (MM-YYYY)",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "199.99",
+                        "description": "Цена не меньше (сумма не более чем с двумя знаками после точки)",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "999.00",
+                        "description": "Цена не больше (сумма не более чем с двумя знаками после точки)",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Подписка активна в указанном месяце (MM-YYYY)",
+                        "name": "active_on",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата окончания раньше указанного месяца (MM-YYYY)",
+                        "name": "ends_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата окончания позже указанного месяца (MM-YYYY)",
+                        "name": "ends_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "true — только бессрочные подписки, false — только с датой окончания",
+                        "name": "open_ended",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "active",
+                            "upcoming",
+                            "expired",
+                            "paused",
+                            "cancelled"
+                        ],
+                        "type": "string",
+                        "description": "Состояние подписки в текущем месяце",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Пробный запуск без изменений",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Число подписок из пробного запуска (обязательно при dry_run=false)",
+                        "name": "expected_count",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Новые значения полей",
+                        "name": "changes",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.BulkUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат пробного запуска или число изменённых подписок",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BulkResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации параметров или тела, пустой фильтр",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Число подходящих подписок не совпадает с expected_count",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{user_id}/upcoming_charges": {
+            "get": {
+                "description": "Обработчик GET /users/:user_id/upcoming_charges. Разворачивает каждую действующую подписку пользователя\nпо её периоду оплаты и дню списаний (billing_day) и возвращает список списаний на days дней вперёд,\nначиная с from (по умолчанию сегодня, UTC), в порядке дат с нарастающим итогом.\nСуммы указаны в валюте подписки; нарастающий итог и totals ведутся отдельно по каждой валюте.\nМесяцы приостановок и месяцы после окончания подписки пропускаются.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Календарь ожидаемых списаний пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Горизонт в днях (1–366, по умолчанию 30)",
+                        "name": "days",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Первый день календаря (YYYY-MM-DD, по умолчанию сегодня)",
+                        "name": "from",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ожидаемые списания",
+                        "schema": {
+                            "$ref": "#/definitions/handler.UpcomingChargesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка запроса",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handler.BatchCreateRequest": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "description": "Подписки в том же формате, что и в POST /subscriptions",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.SubscriptionRequest"
+                    }
+                }
+            }
+        },
+        "handler.BatchCreateResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "description": "Число созданных подписок",
+                    "type": "integer",
+                    "example": 98
+                },
+                "results": {
+                    "description": "Результаты по элементам в порядке запроса",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.BatchResult"
+                    }
+                },
+                "skipped": {
+                    "description": "Число пропущенных подписок (on_conflict=skip)",
+                    "type": "integer",
+                    "example": 2
+                },
+                "updated": {
+                    "description": "Число обновлённых подписок (on_conflict=update)",
+                    "type": "integer",
+                    "example": 0
+                }
+            }
+        },
+        "handler.BulkResponse": {
+            "type": "object",
+            "properties": {
+                "affected": {
+                    "description": "Число подписок, подходящих под фильтр (пробный запуск) или затронутых операцией",
+                    "type": "integer",
+                    "example": 12
+                },
+                "dry_run": {
+                    "description": "true — пробный запуск, изменения не выполнены",
+                    "type": "boolean",
+                    "example": true
+                },
+                "sample": {
+                    "description": "Примеры подходящих подписок (только для пробного запуска, не больше 10)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Subscription"
+                    }
+                }
+            }
+        },
+        "handler.BulkUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "billing_period": {
+                    "description": "Новый период оплаты: week, month, quarter, year или Nm",
+                    "type": "string",
+                    "example": "year"
+                },
+                "currency": {
+                    "description": "Новая валюта цены (ISO 4217)",
+                    "type": "string",
+                    "example": "USD"
+                },
+                "end_date": {
+                    "description": "Новая дата окончания (MM-YYYY) или null, чтобы сделать подписки бессрочными",
+                    "type": "string",
+                    "example": "09-2025"
+                },
+                "price": {
+                    "description": "Новая цена подписок",
+                    "type": "string",
+                    "example": "599.00"
+                }
+            }
+        },
+        "handler.CancelRequest": {
+            "type": "object",
+            "properties": {
+                "effective_month": {
+                    "description": "Последний оплачиваемый месяц (MM-YYYY); по умолчанию — текущий месяц",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "09-2025"
+                }
+            }
+        },
+        "handler.FieldError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Нарушенное правило: required, uuid, type, format",
+                    "type": "string",
+                    "example": "format"
+                },
+                "field": {
+                    "description": "Имя поля тела запроса или параметра",
+                    "type": "string",
+                    "example": "start_date"
+                },
+                "message": {
+                    "description": "Описание ошибки для человека",
+                    "type": "string",
+                    "example": "ожидается MM-YYYY"
+                }
+            }
+        },
+        "handler.ListResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "description": "Подписки текущей страницы",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Subscription"
+                    }
+                },
+                "next_cursor": {
+                    "description": "Курсор следующей страницы; null, если страница последняя",
+                    "type": "string",
+                    "example": "eyJzIjoiIiwidSI6Ii4uLiJ9"
+                },
+                "total": {
+                    "description": "Общее количество подписок, подходящих под фильтр",
+                    "type": "integer",
+                    "example": 1234
+                }
+            }
+        },
+        "handler.PauseRequest": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "description": "Первый месяц приостановки (MM-YYYY); по умолчанию — текущий месяц",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "08-2025"
+                },
+                "to": {
+                    "description": "Последний месяц приостановки (MM-YYYY); без него подписка приостановлена до возобновления",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "09-2025"
+                }
+            }
+        },
+        "handler.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Машиночитаемый код ошибки",
+                    "type": "string",
+                    "example": "invalid_start_date"
+                },
+                "detail": {
+                    "description": "Описание конкретного случая для человека",
+                    "type": "string",
+                    "example": "неверное значение start_date: ожидается MM-YYYY"
+                },
+                "errors": {
+                    "description": "Ошибки отдельных полей (только для ошибок валидации)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.FieldError"
+                    }
+                },
+                "instance": {
+                    "description": "Идентификатор запроса (совпадает с заголовком X-Request-ID)",
+                    "type": "string",
+                    "example": "0b6f1c1e-8a0e-4a47-9f3c-5b7f3d2c9e10"
+                },
+                "status": {
+                    "description": "HTTP-статус ответа",
+                    "type": "integer",
+                    "example": 400
+                },
+                "title": {
+                    "description": "Краткое описание типа ошибки",
+                    "type": "string",
+                    "example": "Неверное значение start_date"
+                },
+                "type": {
+                    "description": "URI типа ошибки",
+                    "type": "string",
+                    "example": "/problems/invalid_start_date"
+                }
+            }
+        },
+        "handler.RateCorrectionRequest": {
+            "type": "object",
+            "properties": {
+                "quote": {
+                    "description": "Валюта, в которой выражен курс (по умолчанию RUB)",
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "rate": {
+                    "description": "Стоимость одной единицы валюты в quote — строка или число",
+                    "type": "string",
+                    "example": "90.1234"
+                }
+            }
+        },
+        "handler.RateInput": {
+            "type": "object",
+            "required": [
+                "currency",
+                "date",
+                "rate"
+            ],
+            "properties": {
+                "currency": {
+                    "description": "Валюта, стоимость единицы которой задаёт курс (ISO 4217)",
+                    "type": "string",
+                    "example": "USD"
+                },
+                "date": {
+                    "description": "Дата курса (YYYY-MM-DD)",
+                    "type": "string",
+                    "format": "date",
+                    "example": "2025-03-01"
+                },
+                "quote": {
+                    "description": "Валюта, в которой выражен курс (по умолчанию RUB)",
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "rate": {
+                    "description": "Стоимость одной единицы currency в quote — строка или число",
+                    "type": "string",
+                    "example": "89.9865"
+                }
+            }
+        },
+        "handler.RatesListResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "description": "Курсы валют",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ExchangeRate"
+                    }
+                }
+            }
+        },
+        "handler.RatesUploadRequest": {
+            "type": "object",
+            "properties": {
+                "rates": {
+                    "description": "Курсы валют",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.RateInput"
+                    }
+                }
+            }
+        },
+        "handler.RatesUploadResponse": {
+            "type": "object",
+            "properties": {
+                "received": {
+                    "description": "Число курсов в запросе (повторы пары и даты учитываются один раз при сохранении)",
+                    "type": "integer",
+                    "example": 43
+                },
+                "recorded_at": {
+                    "description": "Момент записи новых версий; отсутствует, если ничего не сохранено",
+                    "type": "string",
+                    "example": "2025-03-01T12:00:00Z"
+                },
+                "saved": {
+                    "description": "Число сохранённых новых версий курсов",
+                    "type": "integer",
+                    "example": 2
+                },
+                "unchanged": {
+                    "description": "Число курсов, не сохранённых из-за совпадения с последней версией",
+                    "type": "integer",
+                    "example": 41
+                }
+            }
+        },
+        "handler.ResumeRequest": {
+            "type": "object",
+            "properties": {
+                "month": {
+                    "description": "Первый снова оплачиваемый месяц (MM-YYYY); по умолчанию — текущий месяц",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "10-2025"
+                }
+            }
+        },
+        "handler.SubscriptionPatchRequest": {
+            "type": "object",
+            "properties": {
+                "billing_day": {
+                    "description": "Новый день месяца списаний (1–31): дата начала переносится на этот день того же месяца",
+                    "type": "integer",
+                    "example": 15
+                },
+                "billing_period": {
+                    "description": "Новый период оплаты: week, month, quarter, year или Nm",
+                    "type": "string",
+                    "example": "year"
+                },
+                "currency": {
+                    "description": "Новая валюта цены (ISO 4217)",
+                    "type": "string",
+                    "example": "USD"
+                },
+                "end_date": {
+                    "description": "Новая дата окончания (MM-YYYY); null — подписка становится бессрочной",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "12-2025"
+                },
+                "price": {
+                    "description": "Новая цена за период оплаты (строка с двумя знаками после точки или число)",
+                    "type": "string",
+                    "example": "1099.00"
+                },
+                "service_name": {
+                    "description": "Новое название сервиса",
+                    "type": "string",
+                    "example": "Netflix"
+                },
+                "start_date": {
+                    "description": "Новая дата начала (MM-YYYY или YYYY-MM-DD)",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "08-2025"
+                }
+            }
+        },
+        "handler.SubscriptionRequest": {
+            "type": "object",
+            "required": [
+                "price",
+                "service_name",
+                "start_date",
+                "user_id"
+            ],
+            "properties": {
+                "billing_day": {
+                    "description": "День месяца списаний (1–31); по умолчанию день даты начала",
+                    "type": "integer",
+                    "example": 15
+                },
+                "billing_period": {
+                    "description": "Период оплаты: week, month, quarter, year или Nm; по умолчанию month",
+                    "type": "string",
+                    "example": "month"
+                },
+                "currency": {
+                    "description": "Валюта цены (ISO 4217); по умолчанию RUB",
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "end_date": {
+                    "description": "Дата окончания (MM-YYYY); отсутствует — бессрочная подписка",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "12-2025"
+                },
+                "price": {
+                    "description": "Цена за период оплаты: строка не более чем с двумя знаками после точки или число; может быть 0",
+                    "type": "string",
+                    "example": "999.00"
+                },
+                "service_name": {
+                    "description": "Название сервиса",
+                    "type": "string",
+                    "example": "Netflix"
+                },
+                "start_date": {
+                    "description": "Дата начала (MM-YYYY или YYYY-MM-DD)",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "07-2025"
+                },
+                "user_id": {
+                    "description": "UUID пользователя",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "4a79c82c-b09f-4cde-bf80-6edfd680793e"
+                }
+            }
+        },
+        "handler.SubscriptionUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "description": "Новая дата окончания (MM-YYYY); отсутствует — не изменяется, null — подписка становится бессрочной",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "12-2025"
+                },
+                "price": {
+                    "description": "Новая цена подписки (обязательное поле, допускается 0): строка с двумя знаками после точки или число",
+                    "type": "string",
+                    "example": "1099.00"
+                }
+            }
+        },
+        "handler.TimelineResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "description": "Валюта сумм разбивки",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Currency"
+                        }
+                    ],
+                    "example": "RUB"
+                },
+                "months": {
+                    "description": "Расходы по месяцам периода, по возрастанию",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.MonthlySpend"
+                    }
+                },
+                "rates_as_of": {
+                    "description": "Момент, на который взяты курсы (только при заданном rates_as_of)",
+                    "type": "string",
+                    "example": "2025-04-01T00:00:00Z"
+                },
+                "rates_used": {
+                    "description": "Курсы, по которым пересчитаны суммы: для каждого месяца — дата и версия курса (только при пересчёте)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.AppliedRate"
+                    }
+                },
+                "subtotals": {
+                    "description": "Суммы за весь период в исходных валютах подписок без пересчёта",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.CurrencyAmount"
+                    }
+                },
+                "total_price": {
+                    "description": "Общая стоимость подписок за весь период",
+                    "type": "string",
+                    "example": "2996.00"
+                }
+            }
+        },
+        "handler.TotalPriceResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "description": "Валюта total_price и сумм детализации",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Currency"
+                        }
+                    ],
+                    "example": "RUB"
+                },
+                "groups": {
+                    "description": "Суммы по группам (только при заданном group_by)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SpendGroup"
+                    }
+                },
+                "rates_as_of": {
+                    "description": "Момент, на который взяты курсы (только при заданном rates_as_of)",
+                    "type": "string",
+                    "example": "2025-04-01T00:00:00Z"
+                },
+                "rates_used": {
+                    "description": "Курсы, по которым пересчитаны суммы: для каждого месяца — дата и версия курса (только при пересчёте)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.AppliedRate"
+                    }
+                },
+                "subscriptions": {
+                    "description": "Детализация: какие подписки и за сколько месяцев вошли в сумму",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SubscriptionCost"
+                    }
+                },
+                "subtotals": {
+                    "description": "Суммы в исходных валютах подписок без пересчёта",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.CurrencyAmount"
+                    }
+                },
+                "total_price": {
+                    "description": "Общая стоимость подписок за период",
+                    "type": "string",
+                    "example": "5994.00"
+                }
+            }
+        },
+        "handler.UpcomingChargesResponse": {
+            "type": "object",
+            "properties": {
+                "charges": {
+                    "description": "Списания в порядке дат",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Charge"
+                    }
+                },
+                "from": {
+                    "description": "Первый день календаря (YYYY-MM-DD)",
+                    "type": "string",
+                    "format": "date",
+                    "example": "2025-08-01"
+                },
+                "to": {
+                    "description": "Последний день календаря (YYYY-MM-DD), включительно",
+                    "type": "string",
+                    "format": "date",
+                    "example": "2025-08-30"
+                },
+                "totals": {
+                    "description": "Суммы всех списаний календаря по валютам",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.CurrencyAmount"
+                    }
+                },
+                "user_id": {
+                    "description": "UUID пользователя",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "4a79c82c-b09f-4cde-bf80-6edfd680793e"
+                }
+            }
+        },
+        "importer.Report": {
+            "type": "object",
+            "properties": {
+                "accepted": {
+                    "description": "Число принятых строк (созданные и обновлённые подписки)",
+                    "type": "integer",
+                    "example": 998
+                },
+                "created": {
+                    "description": "Число созданных подписок",
+                    "type": "integer",
+                    "example": 990
+                },
+                "errors": {
+                    "description": "Отклонённые строки (не больше 1000 первых)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/importer.RowError"
+                    }
+                },
+                "rejected": {
+                    "description": "Число отклонённых строк",
+                    "type": "integer",
+                    "example": 2
+                },
+                "skipped": {
+                    "description": "Число пропущенных строк с уже существующими подписками (on_conflict=skip)",
+                    "type": "integer",
+                    "example": 0
+                },
+                "updated": {
+                    "description": "Число обновлённых подписок (on_conflict=update)",
+                    "type": "integer",
+                    "example": 8
+                }
+            }
+        },
+        "importer.RowError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "Колонка с неверным значением; пустая, если строка отклонена целиком",
+                    "type": "string",
+                    "example": "start_date"
+                },
+                "line": {
+                    "description": "Номер строки файла (с 1, считая заголовок)",
+                    "type": "integer",
+                    "example": 12
+                },
+                "reason": {
+                    "description": "Причина отклонения",
+                    "type": "string",
+                    "example": "ожидается MM-YYYY"
+                }
+            }
+        },
+        "model.AppliedRate": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "description": "Валюта, стоимость единицы которой задаёт курс",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Currency"
+                        }
+                    ],
+                    "example": "USD"
+                },
+                "date": {
+                    "description": "Дата курса (YYYY-MM-DD); курс действует до даты следующего",
+                    "type": "string",
+                    "format": "date",
+                    "example": "2025-03-01"
+                },
+                "month": {
+                    "description": "Месяц, суммы которого пересчитаны по этому курсу (курс берётся на первое число)",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "03-2025"
+                },
+                "quote": {
+                    "description": "Валюта, в которой выражен курс",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Currency"
+                        }
+                    ],
+                    "example": "RUB"
+                },
+                "rate": {
+                    "description": "Стоимость одной единицы currency в quote",
+                    "type": "string",
+                    "example": "89.9865"
+                },
+                "recorded_at": {
+                    "description": "Момент записи этой версии курса в хранилище",
+                    "type": "string",
+                    "example": "2025-03-01T12:00:00Z"
+                },
+                "source": {
+                    "description": "Источник курса: cbr, ecb, csv или manual",
+                    "type": "string",
+                    "example": "cbr"
+                }
+            }
+        },
+        "model.BatchResult": {
+            "type": "object",
+            "properties": {
+                "index": {
+                    "description": "Индекс элемента в запросе",
+                    "type": "integer",
+                    "example": 0
+                },
+                "status": {
+                    "description": "Результат: created, updated или skipped",
+                    "enum": [
+                        "created",
+                        "updated",
+                        "skipped"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.BatchStatus"
+                        }
+                    ],
+                    "example": "created"
+                },
+                "subscription": {
+                    "description": "Созданная или обновлённая подписка (для skipped не возвращается)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    ]
+                }
+            }
+        },
+        "model.BatchStatus": {
+            "type": "string",
+            "enum": [
+                "created",
+                "updated",
+                "skipped"
+            ],
+            "x-enum-comments": {
+                "BatchCreated": "подписка создана",
+                "BatchSkipped": "подписка уже существует и оставлена без изменений (ConflictSkip)",
+                "BatchUpdated": "существующая подписка обновлена (ConflictUpdate)"
+            },
+            "x-enum-descriptions": [
+                "подписка создана",
+                "существующая подписка обновлена (ConflictUpdate)",
+                "подписка уже существует и оставлена без изменений (ConflictSkip)"
+            ],
+            "x-enum-varnames": [
+                "BatchCreated",
+                "BatchUpdated",
+                "BatchSkipped"
+            ]
+        },
+        "model.BillingPeriod": {
+            "type": "string",
+            "enum": [
+                "week",
+                "month",
+                "quarter",
+                "year"
+            ],
+            "x-enum-comments": {
+                "BillingMonth": "раз в месяц",
+                "BillingQuarter": "раз в три месяца",
+                "BillingWeek": "раз в неделю, начиная с даты начала подписки",
+                "BillingYear": "раз в год"
+            },
+            "x-enum-descriptions": [
+                "раз в неделю, начиная с даты начала подписки",
+                "раз в месяц",
+                "раз в три месяца",
+                "раз в год"
+            ],
+            "x-enum-varnames": [
+                "BillingWeek",
+                "BillingMonth",
+                "BillingQuarter",
+                "BillingYear"
+            ]
+        },
+        "model.Charge": {
+            "description": "Одно ожидаемое списание: дата, сумма в валюте подписки и нарастающий итог в этой валюте с начала календаря.",
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Сумма списания",
+                    "type": "string",
+                    "example": "999.00"
+                },
+                "billing_period": {
+                    "description": "Период оплаты подписки",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.BillingPeriod"
+                        }
+                    ],
+                    "example": "month"
+                },
+                "currency": {
+                    "description": "Валюта суммы списания (валюта подписки)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Currency"
+                        }
+                    ],
+                    "example": "RUB"
+                },
+                "date": {
+                    "description": "Дата списания (YYYY-MM-DD)",
+                    "type": "string",
+                    "format": "date",
+                    "example": "2025-08-15"
+                },
+                "running_total": {
+                    "description": "Сумма этого и всех предыдущих списаний календаря в той же валюте",
+                    "type": "string",
+                    "example": "1498.00"
+                },
+                "service_name": {
+                    "description": "Название сервиса",
+                    "type": "string",
+                    "example": "Netflix"
+                },
+                "subscription_id": {
+                    "description": "Идентификатор подписки",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "0b6c3f1e-8f0a-4a57-9d2c-1d2f5e7a9b10"
+                }
+            }
+        },
+        "model.Currency": {
+            "type": "string",
+            "enum": [
+                "RUB"
+            ],
+            "x-enum-varnames": [
+                "BaseCurrency"
+            ]
+        },
+        "model.CurrencyAmount": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Сумма в этой валюте",
+                    "type": "string",
+                    "example": "9.99"
+                },
+                "currency": {
+                    "description": "Код валюты ISO 4217",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Currency"
+                        }
+                    ],
+                    "example": "USD"
+                }
+            }
+        },
+        "model.ExchangeRate": {
+            "description": "Курс валюты на дату. Исправление курса сохраняется новой версией с другим recorded_at.",
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "description": "Валюта, стоимость единицы которой задаёт курс",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Currency"
+                        }
+                    ],
+                    "example": "USD"
+                },
+                "date": {
+                    "description": "Дата курса (YYYY-MM-DD); курс действует до даты следующего",
+                    "type": "string",
+                    "format": "date",
+                    "example": "2025-03-01"
+                },
+                "quote": {
+                    "description": "Валюта, в которой выражен курс",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Currency"
+                        }
+                    ],
+                    "example": "RUB"
+                },
+                "rate": {
+                    "description": "Стоимость одной единицы currency в quote",
+                    "type": "string",
+                    "example": "89.9865"
+                },
+                "recorded_at": {
+                    "description": "Момент записи этой версии курса в хранилище",
+                    "type": "string",
+                    "example": "2025-03-01T12:00:00Z"
+                },
+                "source": {
+                    "description": "Источник курса: cbr, ecb, csv или manual",
+                    "type": "string",
+                    "example": "cbr"
+                }
+            }
+        },
+        "model.MonthlySpend": {
+            "description": "Сумма стоимости всех подписок, активных в указанном месяце.",
+            "type": "object",
+            "properties": {
+                "month": {
+                    "description": "Месяц (месяц и год)",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "07-2025"
+                },
+                "subscriptions": {
+                    "description": "Количество подписок, активных в этом месяце",
+                    "type": "integer",
+                    "example": 2
+                },
+                "subtotals": {
+                    "description": "Суммы по валютам подписок без пересчёта",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.CurrencyAmount"
+                    }
+                },
+                "total": {
+                    "description": "Суммарная стоимость активных в этом месяце подписок в валюте результата: списания или нормализованные ежемесячные платежи",
+                    "type": "string",
+                    "example": "1498.00"
+                }
+            }
+        },
+        "model.Pause": {
+            "description": "Приостановка подписки; без to подписка приостановлена до возобновления.",
+            "type": "object",
+            "properties": {
+                "from": {
+                    "description": "Первый месяц приостановки",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "08-2025"
+                },
+                "to": {
+                    "description": "Последний месяц приостановки; отсутствует, пока подписку не возобновили",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "09-2025"
+                }
+            }
+        },
+        "model.SpendGroup": {
+            "description": "Сумма стоимости подписок в группе (по сервису, пользователю и/или месяцу).",
+            "type": "object",
+            "properties": {
+                "month": {
+                    "description": "Месяц (при группировке по month)",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "07-2025"
+                },
+                "service_name": {
+                    "description": "Название сервиса (при группировке по service_name)",
+                    "type": "string",
+                    "example": "Netflix"
+                },
+                "subscriptions": {
+                    "description": "Количество подписок, вошедших в группу",
+                    "type": "integer",
+                    "example": 1
+                },
+                "subtotals": {
+                    "description": "Суммы по валютам подписок без пересчёта",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.CurrencyAmount"
+                    }
+                },
+                "total": {
+                    "description": "Суммарная стоимость подписок группы за период в валюте результата",
+                    "type": "string",
+                    "example": "5994.00"
+                },
+                "user_id": {
+                    "description": "UUID пользователя (при группировке по user_id)",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "4a79c82c-b09f-4cde-bf80-6edfd680793e"
+                }
+            }
+        },
+        "model.Status": {
+            "type": "string",
+            "enum": [
+                "active",
+                "upcoming",
+                "expired",
+                "paused",
+                "cancelled"
+            ],
+            "x-enum-comments": {
+                "StatusActive": "подписка действует",
+                "StatusCancelled": "подписка отменена и её последний оплачиваемый месяц прошёл",
+                "StatusExpired": "подписка закончилась по end_date",
+                "StatusPaused": "подписка приостановлена",
+                "StatusUpcoming": "подписка ещё не началась"
+            },
+            "x-enum-descriptions": [
+                "подписка действует",
+                "подписка ещё не началась",
+                "подписка закончилась по end_date",
+                "подписка приостановлена",
+                "подписка отменена и её последний оплачиваемый месяц прошёл"
+            ],
+            "x-enum-varnames": [
+                "StatusActive",
+                "StatusUpcoming",
+                "StatusExpired",
+                "StatusPaused",
+                "StatusCancelled"
+            ]
+        },
+        "model.Subscription": {
+            "description": "Подписка пользователя на онлайн-сервис. Используется для учёта затрат.",
             "type": "object",
             "properties": {
+                "billing_day": {
+                    "description": "День месяца, к которому привязаны списания (день даты начала, 1–31)",
+                    "type": "integer",
+                    "example": 15
+                },
+                "billing_period": {
+                    "description": "Период оплаты: week, month (по умолчанию), quarter, year или Nm — раз в N месяцев",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.BillingPeriod"
+                        }
+                    ],
+                    "example": "month"
+                },
+                "cancelled_at": {
+                    "description": "Момент отмены подписки; end_date отменённой подписки — её последний оплачиваемый месяц (только для чтения)",
+                    "type": "string",
+                    "example": "2025-08-15T10:00:00Z"
+                },
+                "currency": {
+                    "description": "Валюта цены (код ISO 4217), по умолчанию RUB",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Currency"
+                        }
+                    ],
+                    "example": "RUB"
+                },
                 "end_date": {
                     "description": "Опциональная дата окончания подписки (месяц и год)",
                     "type": "string",
                     "format": "MM-YYYY",
                     "example": "12-2025"
                 },
+                "id": {
+                    "description": "Идентификатор подписки (назначается при создании)",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "0b6c3f1e-8f0a-4a57-9d2c-1d2f5e7a9b10"
+                },
+                "pauses": {
+                    "description": "Приостановки подписки; месяцы приостановки не учитываются в расходах (только для чтения)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Pause"
+                    }
+                },
                 "price": {
-                    "description": "Цена подписки в рублях",
-                    "type": "integer",
-                    "example": 999
+                    "description": "Цена подписки за один период оплаты в валюте currency (строка с двумя знаками после точки)",
+                    "type": "string",
+                    "example": "999.00"
                 },
                 "service_name": {
                     "description": "Название сервиса, например \"Netflix\"",
                     "type": "string",
                     "example": "Netflix"
                 },
+                "start_date": {
+                    "description": "Дата начала подписки (месяц и год); день начала хранится и возвращается в billing_day",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "07-2025"
+                },
+                "status": {
+                    "description": "Состояние подписки в текущем месяце (вычисляется, только для чтения)",
+                    "enum": [
+                        "active",
+                        "upcoming",
+                        "expired",
+                        "paused",
+                        "cancelled"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Status"
+                        }
+                    ],
+                    "example": "active"
+                },
+                "user_id": {
+                    "description": "UUID пользователя",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "4a79c82c-b09f-4cde-bf80-6edfd680793e"
+                },
+                "version": {
+                    "description": "Версия подписки; меняется при каждом изменении и возвращается в заголовке ETag",
+                    "type": "integer",
+                    "example": 42
+                }
+            }
+        },
+        "model.SubscriptionCost": {
+            "description": "Детализация расчёта: сколько месяцев подписка была активна в периоде и во сколько это обошлось.",
+            "type": "object",
+            "properties": {
+                "billing_period": {
+                    "description": "Период оплаты подписки",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.BillingPeriod"
+                        }
+                    ],
+                    "example": "month"
+                },
+                "cost": {
+                    "description": "Стоимость в валюте результата: помесячные суммы, пересчитанные по курсу на первое число каждого месяца",
+                    "type": "string",
+                    "example": "5994.00"
+                },
+                "currency": {
+                    "description": "Валюта подписки (код ISO 4217)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Currency"
+                        }
+                    ],
+                    "example": "RUB"
+                },
+                "end_date": {
+                    "description": "Дата окончания подписки, если задана",
+                    "type": "string",
+                    "format": "MM-YYYY",
+                    "example": "12-2025"
+                },
+                "monthly_price": {
+                    "description": "Цена, пересчитанная на один месяц (в валюте подписки)",
+                    "type": "string",
+                    "example": "999.00"
+                },
+                "months": {
+                    "description": "Количество месяцев периода, в которых подписка была активна",
+                    "type": "integer",
+                    "example": 6
+                },
+                "original_cost": {
+                    "description": "Стоимость в валюте подписки: сумма списаний за активные месяцы (view=charges) или monthly_price × months (view=run_rate)",
+                    "type": "string",
+                    "example": "5994.00"
+                },
+                "price": {
+                    "description": "Цена подписки за период оплаты (в валюте подписки)",
+                    "type": "string",
+                    "example": "999.00"
+                },
+                "service_name": {
+                    "description": "Название сервиса",
+                    "type": "string",
+                    "example": "Netflix"
+                },
                 "start_date": {
                     "description": "Дата начала подписки (месяц и год)",
                     "type": "string",
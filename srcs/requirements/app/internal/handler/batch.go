@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+)
+
+// DefaultMaxBatchSize — максимальное число подписок в одном пакетном запросе по умолчанию
+const DefaultMaxBatchSize = 1000
+
+// actionParam — имя параметра пути с действием над коллекцией в маршруте /subscriptions:action.
+// gin не поддерживает двоеточие внутри статического сегмента, поэтому все действия
+// (/subscriptions:batch, /subscriptions:bulkDelete, ...) регистрируются одним маршрутом, а значение параметра
+// включает двоеточие: ":batch".
+const actionParam = "action"
+
+// WithMaxBatchSize — ограничить число подписок в одном пакетном запросе
+func WithMaxBatchSize(n int) Option {
+	return func(h *SubscriptionHandler) {
+		h.maxBatchSize = n
+	}
+}
+
+// SubscriptionsAction — обработчик POST /subscriptions:<действие>; выбирает обработчик по имени действия.
+// Для неизвестного действия отвечает 404.
+func (h *SubscriptionHandler) SubscriptionsAction(c *gin.Context) {
+	action := c.Param(actionParam)
+	switch action {
+	case ":batch":
+		h.BatchCreateSubscriptions(c)
+	case ":bulkDelete":
+		h.BulkDeleteSubscriptions(c)
+	case ":bulkUpdate":
+		h.BulkUpdateSubscriptions(c)
+	default:
+		log.Printf("Неизвестное действие над подписками: %q", action)
+		respondError(c, http.StatusNotFound, codeUnknownAction, strings.TrimPrefix(action, ":"))
+	}
+}
+
+// BatchCreateSubscriptions godoc
+// @Summary Создать пакет подписок
+// @Description Обработчик POST /subscriptions:batch. Создает до MAX_BATCH_SIZE подписок в одной транзакции.
+// @Description Каждый элемент проверяется по тем же правилам, что и в POST /subscriptions; при ошибке валидации любого элемента ничего не сохраняется.
+// @Description Параметр on_conflict задает поведение, если подписка с тем же user_id, service_name и start_date уже существует:
+// @Description error — отменить весь пакет (409), skip — пропустить элемент, update — обновить цену, период оплаты и дату окончания.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param request body BatchCreateRequest true "Подписки"
+// @Param on_conflict query string false "Поведение при конфликте ключа" Enums(error, skip, update) default(error)
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор запроса с тем же ключом и телом вернёт сохранённый ответ"
+// @Success 200 {object} BatchCreateResponse "Результаты по элементам в порядке запроса"
+// @Failure 400 {object} Problem "Ошибка валидации; поля элементов называются items[<индекс>].<поле>"
+// @Failure 409 {object} Problem "Подписки уже существуют (on_conflict=error); элементы перечислены в errors"
+// @Failure 422 {object} Problem "Данные элемента нарушают ограничения (например, отрицательная цена)"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /subscriptions:batch [post]
+func (h *SubscriptionHandler) BatchCreateSubscriptions(c *gin.Context) {
+	mode, err := model.ParseConflictMode(c.Query("on_conflict"))
+	if err != nil {
+		log.Printf("Неверный параметр on_conflict: %v", err)
+		respondInvalidField(c, "on_conflict", msgExpectOneOf, "error, skip, update")
+		return
+	}
+
+	var input struct {
+		Items []json.RawMessage `json:"items" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("Ошибка парсинга тела запроса: %v", err)
+		respondBindError(c, err)
+		return
+	}
+	if len(input.Items) == 0 || len(input.Items) > h.maxBatchSize {
+		log.Printf("Неверный размер пакета: %d", len(input.Items))
+		respondInvalidField(c, "items", msgExpectBatchSize, h.maxBatchSize)
+		return
+	}
+
+	subs, fields := bindBatchItems(c, input.Items)
+	if len(fields) > 0 {
+		log.Printf("Пакет не прошёл валидацию: %d ошибок", len(fields))
+		respondProblem(c, Problem{
+			Status: http.StatusBadRequest,
+			Code:   codeValidationFailed,
+			Detail: msg(c, codeValidationFailed),
+			Errors: fields,
+		})
+		return
+	}
+
+	results, err := h.repo.CreateSubscriptions(c.Request.Context(), subs, mode)
+	if err != nil {
+		log.Printf("Ошибка при пакетном создании подписок: %v", err)
+		var batchErr *repository.BatchError
+		if errors.As(err, &batchErr) {
+			respondBatchError(c, batchErr)
+			return
+		}
+		respondStoreError(c, err, msgBatchFailed)
+		return
+	}
+
+	resp := BatchCreateResponse{Results: results}
+	for _, r := range results {
+		switch r.Status {
+		case model.BatchCreated:
+			resp.Created++
+		case model.BatchUpdated:
+			resp.Updated++
+		case model.BatchSkipped:
+			resp.Skipped++
+		}
+	}
+	log.Printf("Пакет подписок обработан: создано %d, обновлено %d, пропущено %d", resp.Created, resp.Updated, resp.Skipped)
+	c.JSON(http.StatusOK, resp)
+}
+
+// bindBatchItems — разбирает и проверяет элементы пакета по правилам POST /subscriptions.
+// Возвращает подписки или ошибки всех неверных элементов; поля называются items[<индекс>].<поле>.
+func bindBatchItems(c *gin.Context, items []json.RawMessage) ([]model.Subscription, []FieldError) {
+	subs := make([]model.Subscription, 0, len(items))
+	var fields []FieldError
+	for i, raw := range items {
+		prefix := fmt.Sprintf("items[%d].", i)
+
+		var item subscriptionInput
+		err := json.Unmarshal(raw, &item)
+		if err == nil {
+			err = binding.Validator.ValidateStruct(&item)
+		}
+		if err != nil {
+			if itemFields := bindFieldErrors(c, err, prefix); itemFields != nil {
+				fields = append(fields, itemFields...)
+			} else {
+				fields = append(fields, FieldError{Field: strings.TrimSuffix(prefix, "."), Code: "type", Message: msg(c, msgType, "object")})
+			}
+			continue
+		}
+
+		sub, field, key := item.toSubscription()
+		if sub == nil {
+			fields = append(fields, FieldError{Field: prefix + field, Code: "format", Message: msg(c, key)})
+			continue
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, fields
+}
+
+// respondBatchError — отвечает на ошибку пакета, отменившую транзакцию: 409 для занятых ключей,
+// 422 для нарушений ограничений, остальные — 500. Элементы с ошибкой перечисляются в errors.
+func respondBatchError(c *gin.Context, batchErr *repository.BatchError) {
+	var p Problem
+	var message string
+	switch {
+	case errors.Is(batchErr.Err, repository.ErrAlreadyExists):
+		p = Problem{Status: http.StatusConflict, Code: codeAlreadyExists, Detail: msg(c, msgBatchConflict)}
+		message = msg(c, codeAlreadyExists)
+	case errors.Is(batchErr.Err, repository.ErrInvalid):
+		// Ограничение, относящееся к полю, указывается у поля каждого элемента
+		for _, i := range batchErr.Indexes {
+			detail, fields := constraintReason(c, batchErr.Err, fmt.Sprintf("items[%d].", i))
+			if fields == nil {
+				fields = []FieldError{{Field: fmt.Sprintf("items[%d]", i), Code: codeInvalid, Message: detail}}
+			}
+			p.Detail, p.Errors = detail, append(p.Errors, fields...)
+		}
+		p.Status, p.Code = http.StatusUnprocessableEntity, codeInvalid
+		respondProblem(c, p)
+		return
+	default:
+		respondProblem(c, Problem{Status: http.StatusInternalServerError, Code: codeInternal, Detail: msg(c, msgBatchFailed)})
+		return
+	}
+	for _, i := range batchErr.Indexes {
+		p.Errors = append(p.Errors, FieldError{Field: fmt.Sprintf("items[%d]", i), Code: p.Code, Message: message})
+	}
+	respondProblem(c, p)
+}
+
+// BatchCreateRequest — тело запроса пакетного создания подписок
+type BatchCreateRequest struct {
+	// Подписки в том же формате, что и в POST /subscriptions
+	Items []SubscriptionRequest `json:"items"`
+}
+
+// BatchCreateResponse — результаты пакетного создания подписок
+type BatchCreateResponse struct {
+	// Результаты по элементам в порядке запроса
+	Results []model.BatchResult `json:"results"`
+
+	// Число созданных подписок
+	Created int `json:"created" example:"98"`
+
+	// Число обновлённых подписок (on_conflict=update)
+	Updated int `json:"updated" example:"0"`
+
+	// Число пропущенных подписок (on_conflict=skip)
+	Skipped int `json:"skipped" example:"2"`
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"subscription_service/internal/model"
+)
+
+// bulkSampleSize — число подписок-примеров в ответе пробного запуска массовой операции
+const bulkSampleSize = 10
+
+// BulkDeleteSubscriptions godoc
+// @Summary Удалить подписки по фильтру
+// @Description Обработчик POST /subscriptions:bulkDelete. Удаляет все подписки, подходящие под фильтры GET /subscriptions (нужен хотя бы один фильтр).
+// @Description По умолчанию выполняется пробный запуск (dry_run=true): возвращается число подходящих подписок и до 10 примеров, ничего не удаляется.
+// @Description Для удаления передайте dry_run=false и expected_count из пробного запуска; если число подходящих подписок изменилось, ответ — 412.
+// @Tags subscriptions
+// @Produce json
+// @Param user_id query string false "UUID пользователя"
+// @Param service_name query string false "Название сервиса"
+// @Param service_name_match query string false "Режим поиска по названию сервиса: substring (по умолчанию, без учёта регистра) или exact" Enums(substring, exact)
+// @Param start_date query string false "Дата начала не раньше (MM-YYYY)"
+// @Param end_date query string false "Дата начала не позже (MM-YYYY)"
+// @Param min_price query string false "Цена не меньше (сумма не более чем с двумя знаками после точки)" example(199.99)
+// @Param max_price query string false "Цена не больше (сумма не более чем с двумя знаками после точки)" example(999.00)
+// @Param active_on query string false "Подписка активна в указанном месяце (MM-YYYY)"
+// @Param ends_before query string false "Дата окончания раньше указанного месяца (MM-YYYY)"
+// @Param ends_after query string false "Дата окончания позже указанного месяца (MM-YYYY)"
+// @Param open_ended query bool false "true — только бессрочные подписки, false — только с датой окончания"
+// @Param status query string false "Состояние подписки в текущем месяце" Enums(active, upcoming, expired, paused, cancelled)
+// @Param dry_run query bool false "Пробный запуск без изменений" default(true)
+// @Param expected_count query int false "Число подписок из пробного запуска (обязательно при dry_run=false)"
+// @Success 200 {object} BulkResponse "Результат пробного запуска или число удалённых подписок"
+// @Failure 400 {object} Problem "Ошибка валидации параметров или пустой фильтр"
+// @Failure 412 {object} Problem "Число подходящих подписок не совпадает с expected_count"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /subscriptions:bulkDelete [post]
+func (h *SubscriptionHandler) BulkDeleteSubscriptions(c *gin.Context) {
+	filter, expected, ok := parseBulkParams(c)
+	if !ok {
+		return
+	}
+	if expected == nil {
+		h.bulkPreview(c, filter)
+		return
+	}
+
+	n, err := h.repo.DeleteSubscriptionsByFilter(c.Request.Context(), filter, *expected)
+	if err != nil {
+		log.Printf("Ошибка массового удаления подписок: %v", err)
+		respondStoreError(c, err, msgBulkFailed)
+		return
+	}
+	log.Printf("Массово удалено подписок: %d", n)
+	c.JSON(http.StatusOK, BulkResponse{Affected: n})
+}
+
+// BulkUpdateSubscriptions godoc
+// @Summary Изменить подписки по фильтру
+// @Description Обработчик POST /subscriptions:bulkUpdate. Меняет цену, валюту, период оплаты и/или дату окончания всех подписок, подходящих под фильтры GET /subscriptions (нужен хотя бы один фильтр).
+// @Description Тело запроса — JSON Merge Patch с полями price и end_date (null делает подписки бессрочными); каждая подписка получает новую версию.
+// @Description Пробный запуск и подтверждение через expected_count — как в POST /subscriptions:bulkDelete.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param user_id query string false "UUID пользователя"
+// @Param service_name query string false "Название сервиса"
+// @Param service_name_match query string false "Режим поиска по названию сервиса: substring (по умолчанию, без учёта регистра) или exact" Enums(substring, exact)
+// @Param start_date query string false "Дата начала не раньше (MM-YYYY)"
+// @Param end_date query string false "Дата начала не позже (MM-YYYY)"
+// @Param min_price query string false "Цена не меньше (сумма не более чем с двумя знаками после точки)" example(199.99)
+// @Param max_price query string false "Цена не больше (сумма не более чем с двумя знаками после точки)" example(999.00)
+// @Param active_on query string false "Подписка активна в указанном месяце (MM-YYYY)"
+// @Param ends_before query string false "Дата окончания раньше указанного месяца (MM-YYYY)"
+// @Param ends_after query string false "Дата окончания позже указанного месяца (MM-YYYY)"
+// @Param open_ended query bool false "true — только бессрочные подписки, false — только с датой окончания"
+// @Param status query string false "Состояние подписки в текущем месяце" Enums(active, upcoming, expired, paused, cancelled)
+// @Param dry_run query bool false "Пробный запуск без изменений" default(true)
+// @Param expected_count query int false "Число подписок из пробного запуска (обязательно при dry_run=false)"
+// @Param changes body BulkUpdateRequest true "Новые значения полей"
+// @Success 200 {object} BulkResponse "Результат пробного запуска или число изменённых подписок"
+// @Failure 400 {object} Problem "Ошибка валидации параметров или тела, пустой фильтр"
+// @Failure 412 {object} Problem "Число подходящих подписок не совпадает с expected_count"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /subscriptions:bulkUpdate [post]
+func (h *SubscriptionHandler) BulkUpdateSubscriptions(c *gin.Context) {
+	filter, expected, ok := parseBulkParams(c)
+	if !ok {
+		return
+	}
+
+	patch, ok := bindPatch(c)
+	if !ok {
+		return
+	}
+	keyFields := []struct {
+		name string
+		set  bool
+	}{
+		{"service_name", patch.ServiceName != nil},
+		{"start_date", patch.StartDate != nil},
+		{"billing_day", patch.BillingDay != nil},
+	}
+	for _, f := range keyFields {
+		if !f.set {
+			continue
+		}
+		message := msg(c, msgReadOnly)
+		log.Printf("Массовое обновление поля %s не поддерживается", f.name)
+		respondProblem(c, Problem{
+			Status: http.StatusBadRequest,
+			Code:   codeValidationFailed,
+			Detail: msg(c, msgInvalidField, f.name, message),
+			Errors: []FieldError{{Field: f.name, Code: "read_only", Message: message}},
+		})
+		return
+	}
+	if patch == (model.SubscriptionPatch{}) {
+		log.Printf("Пустое тело массового обновления")
+		respondProblem(c, Problem{Status: http.StatusBadRequest, Code: codeValidationFailed, Detail: msg(c, msgBulkPatchEmpty)})
+		return
+	}
+
+	if expected == nil {
+		h.bulkPreview(c, filter)
+		return
+	}
+
+	n, err := h.repo.UpdateSubscriptionsByFilter(c.Request.Context(), filter, patch, *expected)
+	if err != nil {
+		log.Printf("Ошибка массового обновления подписок: %v", err)
+		respondStoreError(c, err, msgBulkFailed)
+		return
+	}
+	log.Printf("Массово обновлено подписок: %d", n)
+	c.JSON(http.StatusOK, BulkResponse{Affected: n})
+}
+
+// parseBulkParams — парсит фильтр и параметры подтверждения массовой операции.
+// Возвращает expected_count или nil для пробного запуска (dry_run=true, по умолчанию).
+// Пустой фильтр отклоняется, чтобы операция не затронула все подписки случайно.
+// При ошибке сам отвечает клиенту 400 и возвращает false.
+func parseBulkParams(c *gin.Context) (model.SubscriptionFilter, *int, bool) {
+	filter, ok := parseFilter(c)
+	if !ok {
+		return filter, nil, false
+	}
+	if filter.IsEmpty() {
+		log.Printf("Массовая операция без фильтра")
+		respondError(c, http.StatusBadRequest, codeFilterRequired)
+		return filter, nil, false
+	}
+
+	dryRun := true
+	if v := c.Query("dry_run"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Printf("Неверный dry_run в query: %q", v)
+			respondInvalidField(c, "dry_run", msgExpectBool)
+			return filter, nil, false
+		}
+		dryRun = parsed
+	}
+	if dryRun {
+		return filter, nil, true
+	}
+
+	expected, err := strconv.Atoi(c.Query("expected_count"))
+	if err != nil || expected < 0 {
+		log.Printf("Неверный expected_count в query: %q", c.Query("expected_count"))
+		respondInvalidField(c, "expected_count", msgExpectExpectedCount)
+		return filter, nil, false
+	}
+	return filter, &expected, true
+}
+
+// bulkPreview — отвечает результатом пробного запуска: число подходящих подписок и примеры
+func (h *SubscriptionHandler) bulkPreview(c *gin.Context, filter model.SubscriptionFilter) {
+	sample, _, total, err := h.repo.ListSubscriptions(c.Request.Context(), filter, model.Page{Limit: bulkSampleSize})
+	if err != nil {
+		log.Printf("Ошибка пробного запуска массовой операции: %v", err)
+		respondStoreError(c, err, msgBulkFailed)
+		return
+	}
+	log.Printf("Пробный запуск массовой операции: подходит подписок %d", total)
+	c.JSON(http.StatusOK, BulkResponse{DryRun: true, Affected: total, Sample: sample})
+}
+
+// BulkUpdateRequest — тело запроса массового обновления подписок; передаются только изменяемые поля
+type BulkUpdateRequest struct {
+	// Новая цена подписок
+	Price *string `json:"price,omitempty" example:"599.00"`
+
+	// Новая валюта цены (ISO 4217)
+	Currency *string `json:"currency,omitempty" example:"USD"`
+
+	// Новый период оплаты: week, month, quarter, year или Nm
+	BillingPeriod *string `json:"billing_period,omitempty" example:"year"`
+
+	// Новая дата окончания (MM-YYYY) или null, чтобы сделать подписки бессрочными
+	EndDate *string `json:"end_date,omitempty" example:"09-2025"`
+}
+
+// BulkResponse — результат массовой операции над подписками
+type BulkResponse struct {
+	// true — пробный запуск, изменения не выполнены
+	DryRun bool `json:"dry_run" example:"true"`
+
+	// Число подписок, подходящих под фильтр (пробный запуск) или затронутых операцией
+	Affected int `json:"affected" example:"12"`
+
+	// Примеры подходящих подписок (только для пробного запуска, не больше 10)
+	Sample []model.Subscription `json:"sample,omitempty"`
+}
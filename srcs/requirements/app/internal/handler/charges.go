@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"subscription_service/internal/model"
+)
+
+// Горизонт календаря списаний в днях: по умолчанию и максимальный
+const (
+	defaultChargeDays = 30
+	maxChargeDays     = 366
+)
+
+// UpcomingCharges godoc
+// @Summary Календарь ожидаемых списаний пользователя
+// @Description Обработчик GET /users/:user_id/upcoming_charges. Разворачивает каждую действующую подписку пользователя
+// @Description по её периоду оплаты и дню списаний (billing_day) и возвращает список списаний на days дней вперёд,
+// @Description начиная с from (по умолчанию сегодня, UTC), в порядке дат с нарастающим итогом.
+// @Description Суммы указаны в валюте подписки; нарастающий итог и totals ведутся отдельно по каждой валюте.
+// @Description Месяцы приостановок и месяцы после окончания подписки пропускаются.
+// @Tags users
+// @Produce json
+// @Param user_id path string true "UUID пользователя"
+// @Param days query int false "Горизонт в днях (1–366, по умолчанию 30)"
+// @Param from query string false "Первый день календаря (YYYY-MM-DD, по умолчанию сегодня)"
+// @Success 200 {object} UpcomingChargesResponse "Ожидаемые списания"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /users/{user_id}/upcoming_charges [get]
+func (h *SubscriptionHandler) UpcomingCharges(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		log.Printf("Неверный user_id в URL: %v", err)
+		respondInvalidField(c, "user_id", msgExpectUUID)
+		return
+	}
+
+	days := defaultChargeDays
+	if v, ok := c.GetQuery("days"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxChargeDays {
+			log.Printf("Неверный days для календаря списаний: %q", v)
+			respondInvalidField(c, "days", msgExpectLimit, maxChargeDays)
+			return
+		}
+		days = n
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if v, ok := c.GetQuery("from"); ok {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			log.Printf("Неверный from для календаря списаний: %v", err)
+			respondInvalidField(c, "from", msgExpectFullDate)
+			return
+		}
+	}
+	to := from.AddDate(0, 0, days-1)
+
+	charges, totals, err := h.repo.UpcomingCharges(c.Request.Context(), userID, from, to)
+	if err != nil {
+		log.Printf("Ошибка построения календаря списаний: %v", err)
+		respondStoreError(c, err, msgChargesFailed)
+		return
+	}
+
+	log.Printf("Построен календарь списаний user_id=%s: списаний %d", userID, len(charges))
+	c.JSON(http.StatusOK, UpcomingChargesResponse{
+		UserID:  userID,
+		From:    from.Format("2006-01-02"),
+		To:      to.Format("2006-01-02"),
+		Totals:  totals.List(),
+		Charges: charges,
+	})
+}
+
+// UpcomingChargesResponse — календарь ожидаемых списаний пользователя
+type UpcomingChargesResponse struct {
+	// UUID пользователя
+	UserID uuid.UUID `json:"user_id" format:"uuid" example:"4a79c82c-b09f-4cde-bf80-6edfd680793e"`
+
+	// Первый день календаря (YYYY-MM-DD)
+	From string `json:"from" format:"date" example:"2025-08-01"`
+
+	// Последний день календаря (YYYY-MM-DD), включительно
+	To string `json:"to" format:"date" example:"2025-08-30"`
+
+	// Суммы всех списаний календаря по валютам
+	Totals []model.CurrencyAmount `json:"totals"`
+
+	// Списания в порядке дат
+	Charges []model.Charge `json:"charges"`
+}
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+)
+
+// problemContentType — тип содержимого ответов с ошибкой (RFC 7807)
+const problemContentType = "application/problem+json"
+
+// problemTypeBase — префикс URI типа ошибки; полный тип — problemTypeBase + код ошибки
+const problemTypeBase = "/problems/"
+
+// Коды ошибок в теле ответа — стабильные значения, по которым клиенты могут различать ошибки.
+// Ошибки отдельных параметров имеют код invalid_<параметр>, например invalid_start_date.
+const (
+	codeNotFound              = "not_found"
+	codeAlreadyExists         = "already_exists"
+	codeInvalid               = "invalid_subscription"
+	codeInternal              = "internal_error"
+	codeInvalidBody           = "invalid_body"
+	codeValidationFailed      = "validation_failed"
+	codeInvalidPriceRange     = "invalid_price_range"
+	codeInvalidPeriod         = "invalid_period"
+	codeCursorWithOffset      = "cursor_with_offset"
+	codeInvalidIfMatch        = "invalid_if_match"
+	codePreconditionFailed    = "precondition_failed"
+	codePreconditionRequired  = "precondition_required"
+	codeIdempotencyKeyReused  = "idempotency_key_reused"
+	codeIdempotencyInProgress = "idempotency_in_progress"
+	codeUnknownAction         = "unknown_action"
+	codeFilterRequired        = "filter_required"
+	codeCountMismatch         = "count_mismatch"
+	codeInvalidCSVHeader      = "invalid_csv_header"
+	codeInvalidTransition     = "invalid_transition"
+	codeRateUnavailable       = "rate_unavailable"
+	codeInvalidRatesFile      = "invalid_rates_file"
+	codeExportTooLarge        = "export_too_large"
+)
+
+// Ключи сообщений каталога i18n, кроме кодов ошибок (описание ошибки с кодом code хранится под ключом code)
+const (
+	msgInvalidField         = "invalid_field"
+	msgBodyNotObject        = "body_not_object"
+	msgPeriodReversed       = "period_reversed"
+	msgPeriodTooLong        = "period_too_long"
+	msgRequired             = "required"
+	msgRule                 = "rule"
+	msgType                 = "type"
+	msgReadOnly             = "read_only"
+	msgExpectUUID           = "expect_uuid"
+	msgExpectMonth          = "expect_month"
+	msgExpectMonthOrNull    = "expect_month_or_null"
+	msgExpectNonEmptyString = "expect_non_empty_string"
+	msgExpectBillingPeriod  = "expect_billing_period"
+	msgExpectCurrency       = "expect_currency"
+	msgExpectDate           = "expect_date"
+	msgExpectFullDate       = "expect_full_date"
+	msgExpectBillingDay     = "expect_billing_day"
+	msgBillingDayMismatch   = "billing_day_mismatch"
+	msgExpectNonNegativeInt = "expect_non_negative_int"
+	msgExpectMoney          = "expect_money"
+	msgExpectRate           = "expect_rate"
+	msgExpectQuote          = "expect_quote"
+	msgExpectTimestamp      = "expect_timestamp"
+	msgExpectLimit          = "expect_limit"
+	msgExpectBool           = "expect_bool"
+	msgExpectOneOf          = "expect_one_of"
+	msgExpectSort           = "expect_sort"
+	msgExpectETag           = "expect_etag"
+	msgExpectIdempotencyKey = "expect_idempotency_key"
+	msgExpectBatchSize      = "expect_batch_size"
+	msgBatchConflict        = "batch_conflict"
+	msgExpectExpectedCount  = "expect_expected_count"
+	msgBulkPatchEmpty       = "bulk_patch_empty"
+	msgCursorMalformed      = "cursor_malformed"
+	msgCursorSortMismatch   = "cursor_sort_mismatch"
+	msgCreateFailed         = "create_failed"
+	msgGetFailed            = "get_failed"
+	msgUpdateFailed         = "update_failed"
+	msgDeleteFailed         = "delete_failed"
+	msgListFailed           = "list_failed"
+	msgTotalFailed          = "total_failed"
+	msgTimelineFailed       = "timeline_failed"
+	msgBatchFailed          = "batch_failed"
+	msgBulkFailed           = "bulk_failed"
+	msgImportFailed         = "import_failed"
+	msgExportFailed         = "export_failed"
+	msgExportTotal          = "export_total"
+	msgTransitionFailed     = "transition_failed"
+	msgChargesFailed        = "charges_failed"
+	msgRatesSaveFailed      = "rates_save_failed"
+	msgRatesListFailed      = "rates_list_failed"
+	msgSubscriptionUpdated  = "subscription_updated"
+	msgSubscriptionDeleted  = "subscription_deleted"
+)
+
+// Problem — тело ответа с ошибкой в формате application/problem+json (RFC 7807)
+type Problem struct {
+	// URI типа ошибки
+	Type string `json:"type" example:"/problems/invalid_start_date"`
+
+	// Краткое описание типа ошибки
+	Title string `json:"title" example:"Неверное значение start_date"`
+
+	// HTTP-статус ответа
+	Status int `json:"status" example:"400"`
+
+	// Описание конкретного случая для человека
+	Detail string `json:"detail" example:"неверное значение start_date: ожидается MM-YYYY"`
+
+	// Идентификатор запроса (совпадает с заголовком X-Request-ID)
+	Instance string `json:"instance" example:"0b6f1c1e-8a0e-4a47-9f3c-5b7f3d2c9e10"`
+
+	// Машиночитаемый код ошибки
+	Code string `json:"code" example:"invalid_start_date"`
+
+	// Ошибки отдельных полей (только для ошибок валидации)
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError — ошибка валидации одного поля запроса
+type FieldError struct {
+	// Имя поля тела запроса или параметра
+	Field string `json:"field" example:"start_date"`
+
+	// Нарушенное правило: required, uuid, type, format
+	Code string `json:"code" example:"format"`
+
+	// Описание ошибки для человека
+	Message string `json:"message" example:"ожидается MM-YYYY"`
+}
+
+// respondProblem — дополняет ошибку типом, заголовком и идентификатором запроса и отправляет клиенту
+func respondProblem(c *gin.Context, p Problem) {
+	p.Type = problemTypeBase + p.Code
+	p.Title = problemTitle(c, p.Code)
+	p.Instance = requestID(c)
+	c.Header("Content-Type", problemContentType)
+	c.JSON(p.Status, p)
+}
+
+// problemTitle — заголовок ошибки по её коду на языке запроса
+func problemTitle(c *gin.Context, code string) string {
+	if catalog(c).Has("title." + code) {
+		return msg(c, "title."+code)
+	}
+	if field, ok := strings.CutPrefix(code, "invalid_"); ok {
+		return msg(c, "title.invalid_field", field)
+	}
+	return msg(c, "title.unknown")
+}
+
+// respondError — отвечает клиенту ошибкой в едином формате; описание берётся из каталога по коду ошибки
+func respondError(c *gin.Context, status int, code string, args ...any) {
+	respondProblem(c, Problem{Status: status, Code: code, Detail: msg(c, code, args...)})
+}
+
+// respondInvalidField — отвечает 400 с кодом invalid_<field> на неверное значение одного поля или параметра;
+// key — ключ каталога с ожидаемым форматом значения
+func respondInvalidField(c *gin.Context, field, key string, args ...any) {
+	message := msg(c, key, args...)
+	respondProblem(c, Problem{
+		Status: http.StatusBadRequest,
+		Code:   "invalid_" + field,
+		Detail: msg(c, msgInvalidField, field, message),
+		Errors: []FieldError{{Field: field, Code: "format", Message: message}},
+	})
+}
+
+// respondBindError — отвечает 400 на ошибку привязки тела или query параметров:
+// нарушения правил binding перечисляются по полям, неразбираемое тело — invalid_body.
+func respondBindError(c *gin.Context, err error) {
+	if fields := bindFieldErrors(c, err, ""); fields != nil {
+		respondProblem(c, Problem{
+			Status: http.StatusBadRequest,
+			Code:   codeValidationFailed,
+			Detail: msg(c, codeValidationFailed),
+			Errors: fields,
+		})
+		return
+	}
+	respondError(c, http.StatusBadRequest, codeInvalidBody, err.Error())
+}
+
+// bindFieldErrors — ошибки отдельных полей из ошибки привязки: нарушения правил binding
+// и значения неверного типа. Имена полей дополняются префиксом prefix.
+// Для остальных ошибок (например, неразбираемого JSON) возвращает nil.
+func bindFieldErrors(c *gin.Context, err error, prefix string) []FieldError {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		fields := make([]FieldError, 0, len(verrs))
+		for _, fe := range verrs {
+			fields = append(fields, FieldError{Field: prefix + fe.Field(), Code: fe.Tag(), Message: validationMessage(c, fe)})
+		}
+		return fields
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []FieldError{{Field: prefix + typeErr.Field, Code: "type", Message: msg(c, msgType, typeErr.Type.String())}}
+	}
+	return nil
+}
+
+// validationMessage — описание нарушенного правила binding для человека
+func validationMessage(c *gin.Context, fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return msg(c, msgRequired)
+	case "uuid":
+		return msg(c, msgExpectUUID)
+	default:
+		return msg(c, msgRule, fe.Tag())
+	}
+}
+
+// respondStoreError — переводит ошибку хранилища в HTTP-ответ:
+// ErrNotFound — 404, ErrAlreadyExists — 409, ErrVersionMismatch и ErrCountMismatch — 412,
+// ErrInvalid и model.ErrNoRate — 422, model.ErrInvalidTransition — 409,
+// остальные — 500 с сообщением по ключу key.
+// Причины нарушения ограничений, переходов состояния и отсутствия курса переводятся по ключам каталога
+// из repository.ConstraintError и model.ReasonError.
+func respondStoreError(c *gin.Context, err error, key string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		respondError(c, http.StatusNotFound, codeNotFound)
+	case errors.Is(err, repository.ErrAlreadyExists):
+		respondError(c, http.StatusConflict, codeAlreadyExists)
+	case errors.Is(err, repository.ErrVersionMismatch):
+		respondError(c, http.StatusPreconditionFailed, codePreconditionFailed)
+	case errors.Is(err, repository.ErrCountMismatch):
+		respondError(c, http.StatusPreconditionFailed, codeCountMismatch)
+	case errors.Is(err, repository.ErrInvalid):
+		detail, fields := constraintReason(c, err, "")
+		respondProblem(c, Problem{Status: http.StatusUnprocessableEntity, Code: codeInvalid, Detail: detail, Errors: fields})
+	case errors.Is(err, model.ErrInvalidTransition):
+		respondError(c, http.StatusConflict, codeInvalidTransition, reasonMessage(c, err))
+	case errors.Is(err, model.ErrNoRate):
+		respondError(c, http.StatusUnprocessableEntity, codeRateUnavailable, reasonMessage(c, err))
+	default:
+		respondProblem(c, Problem{Status: http.StatusInternalServerError, Code: codeInternal, Detail: msg(c, key)})
+	}
+}
+
+// constraintReason — описание нарушения ограничений хранилища на языке запроса и ошибка поля,
+// к которому оно относится (имя поля дополняется префиксом prefix). Для ошибок без repository.ConstraintError
+// в описание попадает их текст.
+func constraintReason(c *gin.Context, err error, prefix string) (string, []FieldError) {
+	var constraintErr *repository.ConstraintError
+	if !errors.As(err, &constraintErr) {
+		return msg(c, codeInvalid, strings.TrimPrefix(err.Error(), repository.ErrInvalid.Error()+": ")), nil
+	}
+	message := msg(c, constraintErr.Key, constraintErr.Args...)
+	if constraintErr.Field == "" {
+		return msg(c, codeInvalid, message), nil
+	}
+	field := prefix + constraintErr.Field
+	return msg(c, msgInvalidField, field, message), []FieldError{{Field: field, Code: "constraint", Message: message}}
+}
+
+// reasonMessage — причина ошибки на языке запроса: из ключа каталога model.ReasonError,
+// а для остальных ошибок — текст ошибки без перевода
+func reasonMessage(c *gin.Context, err error) string {
+	var reasonErr *model.ReasonError
+	if errors.As(err, &reasonErr) {
+		return msg(c, reasonErr.Key, reasonErr.Args...)
+	}
+	return err.Error()
+}
+
+// В ошибках валидации поля называются так же, как в JSON или query, а не как в Go-структуре
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+				return name
+			}
+		}
+		return f.Name
+	})
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"subscription_service/internal/model"
+)
+
+// etag — значение заголовка ETag для версии подписки
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// splitETags — разбирает список сущностей из If-Match / If-None-Match; признак слабой сущности W/ отбрасывается
+func splitETags(header string) []string {
+	var tags []string
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.TrimPrefix(strings.TrimSpace(part), "W/")
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ifMatch — разбирает заголовок If-Match в ожидаемую версию подписки.
+// Без заголовка или со значением "*" версия не проверяется (nil), если только обработчик
+// не требует If-Match — тогда отвечает 428. При неверном значении отвечает 400.
+// Возвращает false, если ответ клиенту уже отправлен.
+func (h *SubscriptionHandler) ifMatch(c *gin.Context) (*int64, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		if h.requireIfMatch {
+			log.Printf("Изменение подписки без If-Match: %s %s", c.Request.Method, c.Request.URL.Path)
+			respondError(c, http.StatusPreconditionRequired, codePreconditionRequired)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	tags := splitETags(header)
+	if len(tags) == 1 && tags[0] == "*" {
+		return nil, true
+	}
+	if len(tags) == 1 {
+		if v, err := strconv.Unquote(tags[0]); err == nil {
+			if version, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return &version, true
+			}
+		}
+	}
+	log.Printf("Неверный заголовок If-Match: %q", header)
+	message := msg(c, msgExpectETag)
+	respondProblem(c, Problem{
+		Status: http.StatusBadRequest,
+		Code:   codeInvalidIfMatch,
+		Detail: msg(c, msgInvalidField, "If-Match", message),
+		Errors: []FieldError{{Field: "If-Match", Code: "format", Message: message}},
+	})
+	return nil, false
+}
+
+// notModified — проверяет заголовок If-None-Match запроса GET: если одна из сущностей совпадает
+// с tag (слабое сравнение) или передано "*", отвечает 304 Not Modified и возвращает true.
+func notModified(c *gin.Context, tag string) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, t := range splitETags(header) {
+		if t == "*" || t == tag {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// respondSubscription — отвечает подпиской с её версией в заголовке ETag
+func respondSubscription(c *gin.Context, status int, sub *model.Subscription) {
+	c.Header("ETag", etag(sub.Version))
+	c.JSON(status, sub)
+}
+
+// respondCacheableJSON — отвечает JSON со слабым ETag, вычисленным по телу ответа,
+// или 304 Not Modified, если клиент прислал тот же ETag в If-None-Match.
+func respondCacheableJSON(c *gin.Context, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("Ошибка сериализации ответа: %v", err)
+		respondError(c, http.StatusInternalServerError, codeInternal)
+		return
+	}
+	sum := sha256.Sum256(data)
+	tag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", tag)
+	if notModified(c, tag) {
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
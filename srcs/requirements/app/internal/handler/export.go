@@ -0,0 +1,434 @@
+package handler
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"subscription_service/internal/model"
+)
+
+// Форматы ответа GET /subscriptions, которые клиент выбирает заголовком Accept
+const (
+	mimeJSON   = "application/json"
+	mimeCSV    = "text/csv"
+	mimeNDJSON = "application/x-ndjson"
+	mimeXLSX   = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// listFormats — поддерживаемые форматы списка подписок; первый используется без заголовка Accept
+var listFormats = []string{mimeJSON, mimeCSV, mimeNDJSON, mimeXLSX}
+
+// exportColumns — колонки выгрузки CSV и XLSX
+var exportColumns = []string{"id", "service_name", "price", "user_id", "start_date", "end_date", "status", "billing_period", "billing_day", "currency"}
+
+// Индексы и буквы колонок exportColumns, по которым строятся строки итогов
+const (
+	exportPriceIndex      = 2
+	exportPeriodIndex     = 7
+	exportBillingDayIndex = 8
+	exportCurrencyIndex   = 9
+
+	exportPriceColumn    = "C"
+	exportPeriodColumn   = "H"
+	exportCurrencyColumn = "J"
+)
+
+// exportTotal — сумма цен подписок одной валюты и одного периода оплаты.
+// Цены в разных валютах и за разные периоды не складываются, поэтому итогов столько, сколько таких пар.
+type exportTotal struct {
+	currency model.Currency
+	period   string
+	sum      model.Money
+}
+
+// exportTotals — суммы цен подписок по валютам и периодам оплаты
+type exportTotals map[exportTotal]model.Money
+
+// add учитывает цену подписки в итоге её валюты и периода оплаты
+func (t exportTotals) add(sub model.Subscription) {
+	t[exportTotal{currency: model.Currency(sub.Currency.String()), period: sub.BillingPeriod.String()}] += sub.Price
+}
+
+// list возвращает итоги в порядке валют, затем периодов оплаты
+func (t exportTotals) list() []exportTotal {
+	list := make([]exportTotal, 0, len(t))
+	for key, sum := range t {
+		key.sum = sum
+		list = append(list, key)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].currency != list[j].currency {
+			return list[i].currency < list[j].currency
+		}
+		return list[i].period < list[j].period
+	})
+	return list
+}
+
+// exporter — запись выгрузки подписок в одном из форматов
+type exporter interface {
+	// write записывает одну подписку
+	write(sub model.Subscription) error
+	// finish дописывает строки итогов (по одной на валюту и период оплаты) и завершает ответ
+	finish(totals []exportTotal) error
+	// close освобождает ресурсы; вызывается и после finish, и при ошибке
+	close()
+}
+
+// exportSubscriptions — отвечает всеми подписками, подходящими под фильтр, в формате format.
+// Подписки читаются из хранилища одним обходом (IterateSubscriptions) и сразу пишутся в ответ,
+// поэтому выгрузка не держит в памяти весь список. Если ошибка случилась после начала ответа,
+// выгрузка обрывается без строки итогов. Выгрузка XLSX, которая не поместится в лист, отклоняется
+// с 422 до начала ответа.
+func (h *SubscriptionHandler) exportSubscriptions(c *gin.Context, filter model.SubscriptionFilter, sort model.SortField, format string) {
+	if format == mimeXLSX {
+		_, _, total, err := h.repo.ListSubscriptions(c.Request.Context(), filter, model.Page{Limit: 1, Sort: sort})
+		if err != nil {
+			log.Printf("Ошибка подсчёта подписок для выгрузки: %v", err)
+			respondStoreError(c, err, msgExportFailed)
+			return
+		}
+		// Первая строка листа — заголовок
+		if total > xlsxMaxRows-1 {
+			log.Printf("Выгрузка XLSX слишком велика: %d подписок", total)
+			respondError(c, http.StatusUnprocessableEntity, codeExportTooLarge, xlsxMaxRows-1, total)
+			return
+		}
+	}
+
+	exp, err := newExporter(c, format)
+	if err != nil {
+		log.Printf("Ошибка подготовки выгрузки %s: %v", format, err)
+		respondProblem(c, Problem{Status: http.StatusInternalServerError, Code: codeInternal, Detail: msg(c, msgExportFailed)})
+		return
+	}
+	defer exp.close()
+
+	count, totals := 0, exportTotals{}
+	err = h.repo.IterateSubscriptions(c.Request.Context(), filter, model.Page{Sort: sort}, func(sub model.Subscription) error {
+		if err := exp.write(sub); err != nil {
+			return err
+		}
+		count++
+		totals.add(sub)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Ошибка выгрузки подписок: %v", err)
+		abortExport(c, err)
+		return
+	}
+
+	if err := exp.finish(totals.list()); err != nil {
+		log.Printf("Ошибка завершения выгрузки: %v", err)
+		abortExport(c, err)
+		return
+	}
+	log.Printf("Выгружено подписок в формате %s: %d", format, count)
+}
+
+// abortExport — отвечает ошибкой, если ответ ещё не начат; иначе ответ остаётся оборванным
+func abortExport(c *gin.Context, err error) {
+	if c.Writer.Written() {
+		c.Abort()
+		return
+	}
+	c.Writer.Header().Del("Content-Disposition")
+	respondStoreError(c, err, msgExportFailed)
+}
+
+// newExporter — выгрузка в формате format; заголовки ответа выставляются сразу,
+// а статус и тело отправляются при первой записи
+func newExporter(c *gin.Context, format string) (exporter, error) {
+	c.Header("Content-Type", format+exportCharset(format))
+	switch format {
+	case mimeCSV:
+		c.Header("Content-Disposition", `attachment; filename="subscriptions.csv"`)
+		return newCSVExporter(c.Writer, msg(c, msgExportTotal)), nil
+	case mimeNDJSON:
+		return &ndjsonExporter{enc: json.NewEncoder(c.Writer)}, nil
+	case mimeXLSX:
+		c.Header("Content-Disposition", `attachment; filename="subscriptions.xlsx"`)
+		return newXLSXExporter(c.Writer, msg(c, msgExportTotal)), nil
+	}
+	return nil, fmt.Errorf("неподдерживаемый формат выгрузки: %s", format)
+}
+
+// exportCharset — параметр charset для текстовых форматов выгрузки
+func exportCharset(format string) string {
+	if format == mimeXLSX {
+		return ""
+	}
+	return "; charset=utf-8"
+}
+
+// exportRecord — значения колонок exportColumns для подписки в текстовом виде
+func exportRecord(sub model.Subscription) []string {
+	end := ""
+	if sub.EndDate != nil {
+		end = formatMonthYear(*sub.EndDate)
+	}
+	return []string{sub.ID.String(), sub.ServiceName, sub.Price.String(), sub.UserID.String(), formatMonthYear(sub.StartDate), end, string(sub.Status), sub.BillingPeriod.String(), strconv.Itoa(sub.BillingDay), sub.Currency.String()}
+}
+
+// formatMonthYear — месяц в формате MM-YYYY, как в JSON
+func formatMonthYear(m model.MonthYear) string {
+	t := m.ToTime()
+	return fmt.Sprintf("%02d-%d", t.Month(), t.Year())
+}
+
+// csvExporter — выгрузка в CSV с заголовком и строками итогов
+type csvExporter struct {
+	w          *csv.Writer
+	totalLabel string
+	started    bool
+}
+
+func newCSVExporter(w io.Writer, totalLabel string) *csvExporter {
+	return &csvExporter{w: csv.NewWriter(w), totalLabel: totalLabel}
+}
+
+// start пишет BOM (по нему табличные редакторы распознают UTF-8) и строку заголовка
+func (e *csvExporter) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	bom := []string{"\ufeff" + exportColumns[0]}
+	return e.w.Write(append(bom, exportColumns[1:]...))
+}
+
+func (e *csvExporter) write(sub model.Subscription) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	return e.w.Write(exportRecord(sub))
+}
+
+func (e *csvExporter) finish(totals []exportTotal) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	for _, t := range totals {
+		record := make([]string, len(exportColumns))
+		record[0], record[exportPriceIndex] = e.totalLabel, t.sum.String()
+		record[exportPeriodIndex], record[exportCurrencyIndex] = t.period, t.currency.String()
+		if err := e.w.Write(record); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) close() {}
+
+// ndjsonExporter — выгрузка в NDJSON: по одной подписке в формате JSON на строку, без итогов
+type ndjsonExporter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExporter) write(sub model.Subscription) error {
+	return e.enc.Encode(sub)
+}
+
+func (e *ndjsonExporter) finish(totals []exportTotal) error {
+	return nil
+}
+
+func (e *ndjsonExporter) close() {}
+
+// xlsxExporter — выгрузка в XLSX с заголовком и строками итогов
+// (формула суммы колонки price по валюте и периоду оплаты).
+// Книга пишется прямо в ответ: служебные части ZIP-архива — при первой записи, а лист — по строке,
+// поэтому выгрузка не держит строки ни в памяти, ни во временном файле. Суммы записываются
+// точной десятичной записью (Money.String) с числовым форматом xlsxMoneyFormat.
+type xlsxExporter struct {
+	zip        *zip.Writer
+	sheet      io.Writer
+	totalLabel string
+	row        int
+}
+
+// xlsxSheet — имя листа выгрузки
+const xlsxSheet = "Sheet1"
+
+// xlsxMaxRows — число строк листа XLSX; выгрузка, которая в него не помещается, отклоняется
+const xlsxMaxRows = 1048576
+
+// xlsxMoneyFormat — числовой формат колонки price: разделитель разрядов и два знака после точки
+const xlsxMoneyFormat = "#,##0.00"
+
+// Стили ячеек из xlsxStyles (индексы cellXfs)
+const (
+	xlsxStyleBold      = 1
+	xlsxStyleMoney     = 2
+	xlsxStyleBoldMoney = 3
+)
+
+// errXLSXRowLimit — строки выгрузки не поместились в лист XLSX
+var errXLSXRowLimit = fmt.Errorf("выгрузка не помещается в лист XLSX (%d строк)", xlsxMaxRows)
+
+// xlsxParts — служебные части книги с одним листом xlsxSheet, которые не зависят от данных
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + xlsxSheet + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+	{"xl/styles.xml", `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<numFmts count="1"><numFmt numFmtId="164" formatCode="` + xlsxMoneyFormat + `"/></numFmts>` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="4"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+		`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`<xf numFmtId="164" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1" applyNumberFormat="1"/></cellXfs>` +
+		`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+		`</styleSheet>`},
+}
+
+// xlsxCell — ячейка строки листа: текст, число или формула с вычисленным значением
+type xlsxCell struct {
+	text    string
+	number  string // десятичная запись числа; пустая — ячейка текстовая
+	formula string
+	style   int
+}
+
+func newXLSXExporter(out io.Writer, totalLabel string) *xlsxExporter {
+	return &xlsxExporter{zip: zip.NewWriter(out), totalLabel: totalLabel}
+}
+
+// start пишет служебные части книги, открывает лист и пишет строку заголовка
+func (e *xlsxExporter) start() error {
+	if e.sheet != nil {
+		return nil
+	}
+	for _, part := range xlsxParts {
+		w, err := e.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, xml.Header+part.body); err != nil {
+			return err
+		}
+	}
+	sheet, err := e.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = sheet
+	head := xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<cols><col min="1" max="` + strconv.Itoa(len(exportColumns)) + `" width="20" customWidth="1"/></cols><sheetData>`
+	if _, err := io.WriteString(e.sheet, head); err != nil {
+		return err
+	}
+	header := make([]xlsxCell, len(exportColumns))
+	for i, name := range exportColumns {
+		header[i] = xlsxCell{text: name, style: xlsxStyleBold}
+	}
+	return e.writeRow(header)
+}
+
+// writeRow пишет следующую строку листа; пустые ячейки пропускаются
+func (e *xlsxExporter) writeRow(cells []xlsxCell) error {
+	if e.row == xlsxMaxRows {
+		return errXLSXRowLimit
+	}
+	e.row++
+	row := strconv.Itoa(e.row)
+	var b strings.Builder
+	b.WriteString(`<row r="` + row + `">`)
+	for i, cell := range cells {
+		if cell.text == "" && cell.number == "" {
+			continue
+		}
+		b.WriteString(`<c r="` + string(rune('A'+i)) + row + `"`)
+		if cell.style != 0 {
+			b.WriteString(` s="` + strconv.Itoa(cell.style) + `"`)
+		}
+		if cell.number == "" {
+			b.WriteString(` t="inlineStr"><is><t xml:space="preserve">`)
+			_ = xml.EscapeText(&b, []byte(cell.text))
+			b.WriteString(`</t></is></c>`)
+			continue
+		}
+		b.WriteString(`>`)
+		if cell.formula != "" {
+			b.WriteString(`<f>`)
+			_ = xml.EscapeText(&b, []byte(cell.formula))
+			b.WriteString(`</f>`)
+		}
+		b.WriteString(`<v>` + cell.number + `</v></c>`)
+	}
+	b.WriteString(`</row>`)
+	_, err := io.WriteString(e.sheet, b.String())
+	return err
+}
+
+func (e *xlsxExporter) write(sub model.Subscription) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	record := exportRecord(sub)
+	cells := make([]xlsxCell, len(record))
+	for i, value := range record {
+		cells[i] = xlsxCell{text: value}
+	}
+	cells[exportPriceIndex] = xlsxCell{number: sub.Price.String(), style: xlsxStyleMoney}
+	cells[exportBillingDayIndex] = xlsxCell{number: strconv.Itoa(sub.BillingDay)}
+	return e.writeRow(cells)
+}
+
+func (e *xlsxExporter) finish(totals []exportTotal) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	if e.row+len(totals) > xlsxMaxRows {
+		return errXLSXRowLimit
+	}
+	last := e.row
+	for _, t := range totals {
+		cells := make([]xlsxCell, len(exportColumns))
+		cells[0] = xlsxCell{text: e.totalLabel, style: xlsxStyleBold}
+		cells[exportPriceIndex] = xlsxCell{number: t.sum.String(), style: xlsxStyleBoldMoney, formula: fmt.Sprintf(
+			`SUMIFS(%[1]s2:%[1]s%[4]d,%[2]s2:%[2]s%[4]d,"%[5]s",%[3]s2:%[3]s%[4]d,"%[6]s")`,
+			exportPriceColumn, exportPeriodColumn, exportCurrencyColumn, last, t.period, t.currency)}
+		cells[exportPeriodIndex] = xlsxCell{text: t.period, style: xlsxStyleBold}
+		cells[exportCurrencyIndex] = xlsxCell{text: t.currency.String(), style: xlsxStyleBold}
+		if err := e.writeRow(cells); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(e.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return e.zip.Close()
+}
+
+func (e *xlsxExporter) close() {}
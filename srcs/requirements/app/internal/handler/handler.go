@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,64 +15,230 @@ import (
 	"log"
 )
 
-// SubscriptionHandler — структура с зависимостью репозитория подписок
+// SubscriptionHandler — структура с зависимостью хранилища подписок
 type SubscriptionHandler struct {
-	repo *repository.SubRepository
+	repo           repository.SubscriptionStore
+	requireIfMatch bool // изменения без заголовка If-Match отклоняются с 428
+
+	idempotency      repository.IdempotencyStore // хранилище ключей Idempotency-Key; nil — заголовок игнорируется
+	idempotencyTTL   time.Duration               // срок хранения ответа на запрос с ключом
+	idempotencyLease time.Duration               // срок резерва ключа за выполняющимся запросом
+
+	maxBatchSize int // максимальное число подписок в пакетном запросе
+
+	rates repository.RateStore // история курсов валют для пересчёта сумм; nil — пересчёт между разными валютами невозможен
+}
+
+// Option — необязательная настройка SubscriptionHandler
+type Option func(*SubscriptionHandler)
+
+// WithRequireIfMatch — требовать заголовок If-Match у запросов PUT, PATCH и DELETE
+func WithRequireIfMatch() Option {
+	return func(h *SubscriptionHandler) {
+		h.requireIfMatch = true
+	}
+}
+
+// WithRates — хранилище курсов валют: управление курсами (/rates) и пересчёт сумм в валюту запроса (параметр currency)
+func WithRates(store repository.RateStore) Option {
+	return func(h *SubscriptionHandler) {
+		h.rates = store
+	}
 }
 
 // NewSubscriptionHandler — конструктор для SubscriptionHandler
-func NewSubscriptionHandler(repo *repository.SubRepository) *SubscriptionHandler {
-	return &SubscriptionHandler{repo: repo}
+func NewSubscriptionHandler(repo repository.SubscriptionStore, opts ...Option) *SubscriptionHandler {
+	h := &SubscriptionHandler{repo: repo, maxBatchSize: DefaultMaxBatchSize}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
+// userIDParam — имя параметра пути с user_id в составных маршрутах /subscriptions/:id/:service_name/:start_date.
+// gin требует одинаковых имён параметров на одной позиции пути, поэтому user_id составного ключа
+// и идентификатор подписки в /subscriptions/:id называются одинаково.
+const userIDParam = "id"
+
 // parseMonthYear — парсит строку формата "MM-YYYY"
 func parseMonthYear(s string) (model.MonthYear, error) {
-	t, err := time.Parse("01-2006", s)
-	return model.MonthYear(t), err
+	return model.ParseMonthYear(s)
+}
+
+// parseDate — парсит дату начала в формате "MM-YYYY" или "YYYY-MM-DD"
+func parseDate(s string) (model.MonthYear, error) {
+	return model.ParseDate(s)
+}
+
+// parsePrice — разбирает цену из JSON: строку "199.99" или число 199.99 (не больше двух знаков после точки);
+// null не допускается
+func parsePrice(raw json.RawMessage) (model.Money, bool) {
+	var price *model.Money
+	if err := json.Unmarshal(raw, &price); err != nil || price == nil {
+		return 0, false
+	}
+	return *price, true
+}
+
+// isFullDate — задана ли дата полностью (YYYY-MM-DD), а не только месяцем
+func isFullDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// parseKey — парсит составной ключ подписки из пути /subscriptions/:user_id/:service_name/:start_date.
+// При ошибке сам отвечает клиенту 400 и возвращает false.
+func parseKey(c *gin.Context) (uuid.UUID, string, model.MonthYear, bool) {
+	userID, err := uuid.Parse(c.Param(userIDParam))
+	if err != nil {
+		log.Printf("Неверный user_id в URL: %v", err)
+		respondInvalidField(c, "user_id", msgExpectUUID)
+		return userID, "", model.MonthYear{}, false
+	}
+
+	startDate, err := parseDate(c.Param("start_date"))
+	if err != nil {
+		log.Printf("Неверный формат start_date в URL: %v", err)
+		respondInvalidField(c, "start_date", msgExpectDate)
+		return userID, "", startDate, false
+	}
+	return userID, c.Param("service_name"), startDate, true
+}
+
+// parseGroupBy — парсит значения group_by; каждое значение может содержать несколько полей через запятую
+func parseGroupBy(values []string) ([]model.GroupField, error) {
+	var fields []model.GroupField
+	seen := map[model.GroupField]bool{}
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			f, err := model.ParseGroupField(part)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[f] {
+				seen[f] = true
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields, nil
 }
 
 // CreateSubscription godoc
 // @Summary Создать подписку
-// @Description Создает новую запись о подпискена основе JSON-запроса. обработчик POST /subscriptions
+// @Description Создает новую запись о подписке на основе JSON-запроса. обработчик POST /subscriptions. Возвращает созданную подписку с назначенным id
 // @Tags subscriptions
 // @Accept json
 // @Produce json
-// @Param subscription body model.Subscription true "Данные подписки"
-// @Success 201 {string} string "Подписка успешно создана"
-// @Failure 400 {string} string "Ошибка запроса"
-// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Param subscription body SubscriptionRequest true "Данные подписки"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор запроса с тем же ключом и телом вернёт сохранённый ответ"
+// @Success 201 {object} model.Subscription "Созданная подписка; адрес в заголовке Location"
+// @Header 201 {string} Location "/subscriptions/{id}"
+// @Header 201 {string} ETag "Версия подписки"
+// @Header 201 {string} Idempotent-Replayed "true, если ответ повторён по Idempotency-Key"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 409 {object} Problem "Подписка с таким ключом уже существует или запрос с тем же Idempotency-Key ещё выполняется"
+// @Failure 422 {object} Problem "Данные нарушают ограничения (например, отрицательная цена) или Idempotency-Key использован с другим телом"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
 // @Router /subscriptions [post]
 func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
-	// Входящая структура для десериализации JSON тела запроса
-	var input struct {
-		ServiceName string  `json:"service_name" binding:"required"`      // Название сервиса (обязательное)
-		Price       int     `json:"price" binding:"required"`             // Цена подписки (обязательное)
-		UserID      string  `json:"user_id" binding:"required,uuid"`      // UUID пользователя (обязательное)
-		StartDate   string  `json:"start_date" binding:"required"`        // Дата начала (формат MM-YYYY)
-		EndDate     *string `json:"end_date"`                             // Опциональная дата окончания (формат MM-YYYY)
+	sub, ok := bindSubscription(c)
+	if !ok {
+		return
 	}
 
-	// Привязка JSON к структуре
-	if err := c.ShouldBindJSON(&input); err != nil {
-		log.Printf("Ошибка парсинга тела запроса: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// Вызываем репозиторий для создания подписки в БД
+	err := h.repo.CreateSubscription(c.Request.Context(), sub)
+	if err != nil {
+		log.Printf("Ошибка при создании подписки: %v", err)
+		respondStoreError(c, err, msgCreateFailed)
 		return
 	}
 
+	log.Printf("Подписка создана: id=%s user_id=%s service=%s", sub.ID, sub.UserID, sub.ServiceName)
+	c.Header("Location", "/subscriptions/"+sub.ID.String())
+	respondSubscription(c, http.StatusCreated, sub)
+}
+
+// SubscriptionRequest — тело запроса создания и замены подписки в том виде, в котором его принимает
+// subscriptionInput (в отличие от model.Subscription, цена передаётся строкой или числом, даты — строками)
+type SubscriptionRequest struct {
+	// Название сервиса
+	ServiceName string `json:"service_name" binding:"required" example:"Netflix"`
+
+	// Цена за период оплаты: строка не более чем с двумя знаками после точки или число; может быть 0
+	Price string `json:"price" binding:"required" example:"999.00"`
+
+	// Валюта цены (ISO 4217); по умолчанию RUB
+	Currency string `json:"currency,omitempty" example:"RUB"`
+
+	// Период оплаты: week, month, quarter, year или Nm; по умолчанию month
+	BillingPeriod string `json:"billing_period,omitempty" example:"month"`
+
+	// UUID пользователя
+	UserID string `json:"user_id" binding:"required" format:"uuid" example:"4a79c82c-b09f-4cde-bf80-6edfd680793e"`
+
+	// Дата начала (MM-YYYY или YYYY-MM-DD)
+	StartDate string `json:"start_date" binding:"required" format:"MM-YYYY" example:"07-2025"`
+
+	// День месяца списаний (1–31); по умолчанию день даты начала
+	BillingDay *int `json:"billing_day,omitempty" example:"15"`
+
+	// Дата окончания (MM-YYYY); отсутствует — бессрочная подписка
+	EndDate *string `json:"end_date,omitempty" format:"MM-YYYY" example:"12-2025"`
+}
+
+// subscriptionInput — JSON тело запроса с полными данными подписки
+type subscriptionInput struct {
+	ServiceName   string  `json:"service_name" binding:"required"` // Название сервиса (обязательное)
+	Price         json.RawMessage `json:"price" binding:"required"` // Цена за период оплаты: "199.99" или 199.99 (обязательное, может быть 0)
+	Currency      string  `json:"currency"`                        // Валюта цены (ISO 4217; по умолчанию RUB)
+	BillingPeriod string  `json:"billing_period"`                  // Период оплаты (week, month, quarter, year, Nm; по умолчанию month)
+	UserID        string  `json:"user_id" binding:"required,uuid"` // UUID пользователя (обязательное)
+	StartDate     string  `json:"start_date" binding:"required"`   // Дата начала (формат MM-YYYY или YYYY-MM-DD)
+	BillingDay    *int    `json:"billing_day"`                     // День месяца списаний (1–31); по умолчанию день даты начала
+	EndDate       *string `json:"end_date"`                        // Опциональная дата окончания (формат MM-YYYY)
+}
+
+// toSubscription — разбирает значения полей в подписку. При ошибке возвращает имя неверного поля
+// и ключ каталога с ожидаемым форматом значения.
+func (input subscriptionInput) toSubscription() (*model.Subscription, string, string) {
 	// Парсим user_id из строки в UUID
 	userUUID, err := uuid.Parse(input.UserID)
 	if err != nil {
 		log.Printf("Неверный формат user_id: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат user_id"})
-		return
+		return nil, "user_id", msgExpectUUID
+	}
+
+	// Цена с точностью до сотых
+	price, ok := parsePrice(input.Price)
+	if !ok {
+		log.Printf("Неверный формат price: %s", input.Price)
+		return nil, "price", msgExpectMoney
 	}
 
 	// Парсим дату начала подписки
-	startDate, err := parseMonthYear(input.StartDate)
+	startDate, err := parseDate(input.StartDate)
 	if err != nil {
 		log.Printf("Неверный формат start_date: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат start_date, ожидается MM-YYYY"})
-		return
+		return nil, "start_date", msgExpectDate
+	}
+
+	// День списаний переносит дату начала на этот день месяца; полная дата начала уже задаёт день
+	if input.BillingDay != nil {
+		if *input.BillingDay < 1 || *input.BillingDay > 31 {
+			log.Printf("Неверный billing_day: %d", *input.BillingDay)
+			return nil, "billing_day", msgExpectBillingDay
+		}
+		if isFullDate(input.StartDate) && startDate.Day() != *input.BillingDay {
+			log.Printf("billing_day %d не совпадает с днём start_date %s", *input.BillingDay, input.StartDate)
+			return nil, "billing_day", msgBillingDayMismatch
+		}
+		startDate = startDate.WithDay(*input.BillingDay)
 	}
 
 	// Если дата окончания задана, парсим её
@@ -78,31 +247,55 @@ func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
 		ed, err := parseMonthYear(*input.EndDate)
 		if err != nil {
 			log.Printf("Неверный формат end_date: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат end_date, ожидается MM-YYYY"})
-			return
+			return nil, "end_date", msgExpectMonth
 		}
 		endDate = &ed
 	}
 
-	// Формируем структуру подписки
-	sub := &model.Subscription{
-		ServiceName: input.ServiceName,
-		Price:       input.Price,
-		UserID:      userUUID,
-		StartDate:   startDate,
-		EndDate:     endDate,
+	// Период оплаты: по умолчанию месяц
+	period, err := model.ParseBillingPeriod(input.BillingPeriod)
+	if err != nil {
+		log.Printf("Неверный формат billing_period: %v", err)
+		return nil, "billing_period", msgExpectBillingPeriod
 	}
 
-	// Вызываем репозиторий для создания подписки в БД
-	err = h.repo.CreateSubscription(c.Request.Context(), sub)
+	// Валюта цены: по умолчанию рубли
+	currency, err := model.ParseCurrency(input.Currency)
 	if err != nil {
-		log.Printf("Ошибка при создании подписки: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось создать подписку"})
-		return
+		log.Printf("Неверный формат currency: %v", err)
+		return nil, "currency", msgExpectCurrency
+	}
+
+	// Формируем структуру подписки
+	return &model.Subscription{
+		ServiceName:   input.ServiceName,
+		Price:         price,
+		Currency:      currency,
+		BillingPeriod: period,
+		UserID:        userUUID,
+		StartDate:     startDate,
+		EndDate:       endDate,
+	}, "", ""
+}
+
+// bindSubscription — разбирает JSON тело запроса с полными данными подписки
+// (используется при создании и при полной замене подписки).
+// При ошибке сам отвечает клиенту 400 и возвращает false.
+func bindSubscription(c *gin.Context) (*model.Subscription, bool) {
+	// Привязка JSON к структуре
+	var input subscriptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("Ошибка парсинга тела запроса: %v", err)
+		respondBindError(c, err)
+		return nil, false
 	}
 
-	log.Printf("Подписка создана: user_id=%s service=%s start_date=%s", userUUID, input.ServiceName, input.StartDate)
-	c.JSON(http.StatusCreated, gin.H{"message": "подписка успешно создана"})
+	sub, field, key := input.toSubscription()
+	if sub == nil {
+		respondInvalidField(c, field, key)
+		return nil, false
+	}
+	return sub, true
 }
 
 // GetSubscription godoc
@@ -112,111 +305,188 @@ func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
 // @Produce json
 // @Param user_id path string true "UUID пользователя"
 // @Param service_name path string true "Название сервиса"
-// @Param start_date path string true "Дата начала подписки (MM-YYYY)"
+// @Param start_date path string true "Месяц начала подписки (MM-YYYY или YYYY-MM-DD; день не учитывается)"
+// @Param If-None-Match header string false "ETag известной клиенту версии подписки"
 // @Success 200 {object} model.Subscription
-// @Failure 404 {string} string "Подписка не найдена"
-// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Header 200 {string} ETag "Версия подписки"
+// @Success 304 "Подписка не изменилась"
+// @Failure 400 {object} Problem "Неверный user_id или start_date"
+// @Failure 404 {object} Problem "Подписка не найдена"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
 // @Router /subscriptions/{user_id}/{service_name}/{start_date} [get]
 func (h *SubscriptionHandler) GetSubscription(c *gin.Context) {
-	userIDStr := c.Param("user_id")
+	userIDStr := c.Param(userIDParam)
 	serviceName := c.Param("service_name")
 	startDateStr := c.Param("start_date")
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
 		log.Printf("Неверный user_id в URL: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный user_id"})
+		respondInvalidField(c, "user_id", msgExpectUUID)
 		return
 	}
 
-	startDate, err := parseMonthYear(startDateStr)
+	startDate, err := parseDate(startDateStr)
 	if err != nil {
 		log.Printf("Неверный формат start_date в URL: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат start_date"})
+		respondInvalidField(c, "start_date", msgExpectDate)
 		return
 	}
 
 	sub, err := h.repo.GetSubscription(c.Request.Context(), userID, serviceName, startDate)
 	if err != nil {
 		log.Printf("Ошибка получения подписки: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось получить подписку"})
-		return
-	}
-	if sub == nil {
-		log.Printf("Подписка не найдена: user_id=%s service=%s start_date=%s", userID, serviceName, startDateStr)
-		c.JSON(http.StatusNotFound, gin.H{"error": "подписка не найдена"})
+		respondStoreError(c, err, msgGetFailed)
 		return
 	}
 
 	log.Printf("Подписка найдена: user_id=%s service=%s start_date=%s", userID, serviceName, startDateStr)
-	c.JSON(http.StatusOK, sub)
+	if notModified(c, etag(sub.Version)) {
+		return
+	}
+	respondSubscription(c, http.StatusOK, sub)
 }
 
 // UpdateSubscription godoc
 // @Summary Обновить подписку
-// @Description Обработчик PUT /subscriptions/:user_id/:service_name/:start_date Обновляет цену и дату окончания подписки по ключу user_id + service_name + start_date
+// @Description Обработчик PUT /subscriptions/:user_id/:service_name/:start_date Обновляет цену и дату окончания подписки по ключу user_id + service_name + start_date.
+// @Description Цена обязательна (допускается 0). Отсутствующий end_date не изменяется, null делает подписку бессрочной.
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Param user_id path string true "UUID пользователя"
 // @Param service_name path string true "Название сервиса"
-// @Param start_date path string true "Дата начала подписки (MM-YYYY)"
-// @Param subscription body model.Subscription true "Обновленные данные подписки"
+// @Param start_date path string true "Месяц начала подписки (MM-YYYY или YYYY-MM-DD; день не учитывается)"
+// @Param subscription body SubscriptionUpdateRequest true "Обновленные данные подписки"
+// @Param If-Match header string false "ETag изменяемой версии подписки"
 // @Success 200 {string} string "Подписка успешно обновлена"
-// @Failure 400 {string} string "Ошибка запроса"
-// @Failure 404 {string} string "Подписка не найдена"
-// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 404 {object} Problem "Подписка не найдена"
+// @Failure 412 {object} Problem "Подписка изменилась после получения ETag"
+// @Failure 428 {object} Problem "Не передан обязательный заголовок If-Match"
+// @Failure 422 {object} Problem "Данные нарушают ограничения (например, отрицательная цена)"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
 // @Router /subscriptions/{user_id}/{service_name}/{start_date} [put]
 func (h *SubscriptionHandler) UpdateSubscription(c *gin.Context) {
-	userIDStr := c.Param("user_id")
-	serviceName := c.Param("service_name")
-	startDateStr := c.Param("start_date") // MM-YYYY
+	userID, serviceName, startDate, ok := parseKey(c)
+	if !ok {
+		return
+	}
 
 	var input struct {
-		Price   int     `json:"price" binding:"required"` // Новая цена подписки
-		EndDate *string `json:"end_date"`                 // Новая дата окончания
+		Price   json.RawMessage `json:"price" binding:"required"` // Новая цена подписки: "199.99" или 199.99
+		EndDate json.RawMessage `json:"end_date"`                 // Новая дата окончания; null — бессрочная
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		log.Printf("Ошибка парсинга тела запроса на обновление: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		log.Printf("Неверный user_id для обновления: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный user_id"})
+	ifVersion, ok := h.ifMatch(c)
+	if !ok {
 		return
 	}
 
-	startDate, err := parseMonthYear(startDateStr)
-	if err != nil {
-		log.Printf("Неверный формат start_date для обновления: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат start_date"})
+	price, ok := parsePrice(input.Price)
+	if !ok {
+		log.Printf("Неверный формат price для обновления: %s", input.Price)
+		respondInvalidField(c, "price", msgExpectMoney)
 		return
 	}
 
-	var endDate *model.MonthYear
+	patch := model.SubscriptionPatch{Price: &price}
 	if input.EndDate != nil {
-		ed, err := parseMonthYear(*input.EndDate)
-		if err != nil {
+		var ed *string
+		if err := json.Unmarshal(input.EndDate, &ed); err != nil {
 			log.Printf("Неверный формат end_date для обновления: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат end_date"})
+			respondInvalidField(c, "end_date", msgExpectMonthOrNull)
 			return
 		}
-		endDate = &ed
+		if ed == nil {
+			patch.ClearEndDate = true
+		} else {
+			endDate, err := parseMonthYear(*ed)
+			if err != nil {
+				log.Printf("Неверный формат end_date для обновления: %v", err)
+				respondInvalidField(c, "end_date", msgExpectMonthOrNull)
+				return
+			}
+			patch.EndDate = &endDate
+		}
 	}
 
-	err = h.repo.UpdateSubscription(c.Request.Context(), userID, serviceName, startDate, input.Price, endDate)
+	updated, err := h.repo.PatchSubscriptionByKey(c.Request.Context(), userID, serviceName, startDate, patch, ifVersion)
 	if err != nil {
 		log.Printf("Ошибка обновления подписки: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось обновить подписку"})
+		respondStoreError(c, err, msgUpdateFailed)
+		return
+	}
+	c.Header("ETag", etag(updated.Version))
+
+	log.Printf("Подписка обновлена: user_id=%s service=%s start_date=%s", userID, serviceName, c.Param("start_date"))
+	c.JSON(http.StatusOK, gin.H{"message": msg(c, msgSubscriptionUpdated)})
+}
+
+// SubscriptionUpdateRequest — тело запроса обновления подписки по составному ключу
+type SubscriptionUpdateRequest struct {
+	// Новая цена подписки (обязательное поле, допускается 0): строка с двумя знаками после точки или число
+	Price string `json:"price" example:"1099.00"`
+
+	// Новая дата окончания (MM-YYYY); отсутствует — не изменяется, null — подписка становится бессрочной
+	EndDate *string `json:"end_date,omitempty" format:"MM-YYYY" example:"12-2025"`
+}
+
+// PatchSubscription godoc
+// @Summary Частично обновить подписку по составному ключу
+// @Description Обработчик PATCH /subscriptions/:user_id/:service_name/:start_date (JSON Merge Patch). Изменяются только переданные поля:
+// @Description service_name, price, currency, billing_period, start_date, end_date. Значение null у end_date делает подписку бессрочной.
+// @Description Смена service_name или start_date переносит подписку на новый составной ключ в одной транзакции.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param user_id path string true "UUID пользователя"
+// @Param service_name path string true "Название сервиса"
+// @Param start_date path string true "Месяц начала подписки (MM-YYYY или YYYY-MM-DD; день не учитывается)"
+// @Param patch body SubscriptionPatchRequest true "Изменяемые поля"
+// @Param If-Match header string false "ETag изменяемой версии подписки"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 404 {object} Problem "Подписка не найдена"
+// @Failure 409 {object} Problem "Подписка с новым составным ключом уже существует"
+// @Failure 412 {object} Problem "Подписка изменилась после получения ETag"
+// @Failure 428 {object} Problem "Не передан обязательный заголовок If-Match"
+// @Failure 422 {object} Problem "Данные нарушают ограничения (например, отрицательная цена)"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /subscriptions/{user_id}/{service_name}/{start_date} [patch]
+func (h *SubscriptionHandler) PatchSubscription(c *gin.Context) {
+	userID, serviceName, startDate, ok := parseKey(c)
+	if !ok {
+		return
+	}
+
+	patch, ok := bindPatch(c)
+	if !ok {
+		return
+	}
+
+	ifVersion, ok := h.ifMatch(c)
+	if !ok {
 		return
 	}
 
-	log.Printf("Подписка обновлена: user_id=%s service=%s start_date=%s", userID, serviceName, startDateStr)
-	c.JSON(http.StatusOK, gin.H{"message": "подписка успешно обновлена"})
+	updated, err := h.repo.PatchSubscriptionByKey(c.Request.Context(), userID, serviceName, startDate, patch, ifVersion)
+	if err != nil {
+		log.Printf("Ошибка частичного обновления подписки: %v", err)
+		respondStoreError(c, err, msgUpdateFailed)
+		return
+	}
+
+	log.Printf("Подписка частично обновлена: id=%s", updated.ID)
+	respondSubscription(c, http.StatusOK, updated)
 }
 
 // DeleteSubscription godoc
@@ -226,128 +496,363 @@ func (h *SubscriptionHandler) UpdateSubscription(c *gin.Context) {
 // @Produce json
 // @Param user_id path string true "UUID пользователя"
 // @Param service_name path string true "Название сервиса"
-// @Param start_date path string true "Дата начала подписки (MM-YYYY)"
+// @Param start_date path string true "Месяц начала подписки (MM-YYYY или YYYY-MM-DD; день не учитывается)"
+// @Param If-Match header string false "ETag удаляемой версии подписки"
 // @Success 200 {string} string "Подписка удалена"
-// @Failure 404 {string} string "Подписка не найдена"
-// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Failure 400 {object} Problem "Неверный user_id или start_date"
+// @Failure 404 {object} Problem "Подписка не найдена"
+// @Failure 412 {object} Problem "Подписка изменилась после получения ETag"
+// @Failure 428 {object} Problem "Не передан обязательный заголовок If-Match"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
 // @Router /subscriptions/{user_id}/{service_name}/{start_date} [delete]
 func (h *SubscriptionHandler) DeleteSubscription(c *gin.Context) {
-	userIDStr := c.Param("user_id")
+	userIDStr := c.Param(userIDParam)
 	serviceName := c.Param("service_name")
 	startDateStr := c.Param("start_date")
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
 		log.Printf("Неверный user_id при удалении: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный user_id"})
+		respondInvalidField(c, "user_id", msgExpectUUID)
 		return
 	}
 
-	startDate, err := parseMonthYear(startDateStr)
+	startDate, err := parseDate(startDateStr)
 	if err != nil {
 		log.Printf("Неверный формат start_date при удалении: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный start_date"})
+		respondInvalidField(c, "start_date", msgExpectDate)
 		return
 	}
 
-	err = h.repo.DeleteSubscription(c.Request.Context(), userID, serviceName, startDate)
+	ifVersion, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
+	err = h.repo.DeleteSubscription(c.Request.Context(), userID, serviceName, startDate, ifVersion)
 	if err != nil {
 		log.Printf("Ошибка удаления подписки: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось удалить подписку"})
+		respondStoreError(c, err, msgDeleteFailed)
 		return
 	}
 
 	log.Printf("Подписка удалена: user_id=%s service=%s start_date=%s", userID, serviceName, startDateStr)
-	c.JSON(http.StatusOK, gin.H{"message": "подписка успешно удалена"})
+	c.JSON(http.StatusOK, gin.H{"message": msg(c, msgSubscriptionDeleted)})
 }
 
+// Параметры пагинации списка подписок
+const (
+	defaultListLimit = 100  // размер страницы по умолчанию
+	maxListLimit     = 1000 // максимальный размер страницы
+)
+
 // ListSubscriptions godoc
 // @Summary Получить список подписок
-// @Description Обработчик GET /subscriptions с параметрами фильтрации. Возвращает список подписок с возможной фильтрацией по user_id, service_name, start_date и end_date
+// @Description Обработчик GET /subscriptions с параметрами фильтрации. Возвращает страницу подписок с возможной фильтрацией по user_id, service_name, датам, цене и активности
+// @Description и общее количество подходящих записей. Для перехода на следующую страницу передайте next_cursor в параметре cursor.
+// @Description С заголовком Accept: text/csv, application/x-ndjson или application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Description возвращается выгрузка всех подходящих подписок в этом формате (limit, offset и cursor не учитываются, sort — учитывается).
+// @Description CSV и XLSX начинаются строкой заголовка и заканчиваются строками итогов с суммой цен по каждой паре валюты и периода оплаты; NDJSON содержит по одной подписке на строку.
 // @Tags subscriptions
 // @Produce json
-// @Success 200 {array} model.Subscription
-// @Failure 400 {string} string "Ошибка валидации входных параметров (например, неверный UUID или формат даты)"
-// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param user_id query string false "UUID пользователя"
+// @Param service_name query string false "Название сервиса"
+// @Param service_name_match query string false "Режим поиска по названию сервиса: substring (по умолчанию, без учёта регистра) или exact" Enums(substring, exact)
+// @Param start_date query string false "Дата начала не раньше (MM-YYYY)"
+// @Param end_date query string false "Дата начала не позже (MM-YYYY)"
+// @Param min_price query string false "Цена не меньше (сумма не более чем с двумя знаками после точки)" example(199.99)
+// @Param max_price query string false "Цена не больше (сумма не более чем с двумя знаками после точки)" example(999.00)
+// @Param active_on query string false "Подписка активна в указанном месяце (MM-YYYY)"
+// @Param ends_before query string false "Дата окончания раньше указанного месяца (MM-YYYY)"
+// @Param ends_after query string false "Дата окончания позже указанного месяца (MM-YYYY)"
+// @Param open_ended query bool false "true — только бессрочные подписки, false — только с датой окончания"
+// @Param status query string false "Состояние подписки в текущем месяце" Enums(active, upcoming, expired, paused, cancelled)
+// @Param limit query int false "Размер страницы (по умолчанию 100, максимум 1000)"
+// @Param offset query int false "Смещение (нельзя использовать вместе с cursor)"
+// @Param cursor query string false "Курсор следующей страницы из next_cursor"
+// @Param sort query string false "Сортировка: price, start_date, service_name; префикс '-' — по убыванию" Enums(price, -price, start_date, -start_date, service_name, -service_name)
+// @Param If-None-Match header string false "ETag ранее полученной страницы"
+// @Success 200 {object} ListResponse
+// @Header 200 {string} ETag "Слабый ETag содержимого страницы"
+// @Success 304 "Страница не изменилась"
+// @Failure 400 {object} Problem "Ошибка валидации входных параметров (например, неверный UUID или формат даты)"
+// @Failure 422 {object} Problem "Выгрузка XLSX не помещается в лист"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
 // @Router /subscriptions [get]
 func (h *SubscriptionHandler) ListSubscriptions(c *gin.Context) {
-	var userID *uuid.UUID
-	var serviceName *string
-	var startDate, endDate *model.MonthYear
+	filter, ok := parseFilter(c)
+	if !ok {
+		return
+	}
+
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	if format := c.NegotiateFormat(listFormats...); format != "" && format != mimeJSON {
+		h.exportSubscriptions(c, filter, page.Sort, format)
+		return
+	}
+
+	subs, next, total, err := h.repo.ListSubscriptions(c.Request.Context(), filter, page)
+	if err != nil {
+		log.Printf("Ошибка получения списка подписок: %v", err)
+		respondStoreError(c, err, msgListFailed)
+		return
+	}
+
+	resp := ListResponse{Items: subs, Total: total}
+	if next != nil {
+		encoded := next.Encode()
+		resp.NextCursor = &encoded
+	}
+	log.Printf("Получен список подписок, кол-во: %d из %d", len(subs), total)
+	respondCacheableJSON(c, resp)
+}
+
+// parseFilter — парсит query параметры фильтрации списка подписок.
+// При ошибке сам отвечает клиенту 400 и возвращает false.
+func parseFilter(c *gin.Context) (model.SubscriptionFilter, bool) {
+	var filter model.SubscriptionFilter
 
-	// Получаем и парсим query параметры
 	if u := c.Query("user_id"); u != "" {
 		uid, err := uuid.Parse(u)
-		if err == nil {
-			userID = &uid
-		} else {
+		if err != nil {
 			log.Printf("Неверный user_id в query: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "неверный user_id"})
-			return
+			respondInvalidField(c, "user_id", msgExpectUUID)
+			return filter, false
 		}
+		filter.UserID = &uid
 	}
 
 	if s := c.Query("service_name"); s != "" {
-		serviceName = &s
+		filter.ServiceName = &s
+	}
+
+	switch m := c.DefaultQuery("service_name_match", "substring"); m {
+	case "substring":
+	case "exact":
+		filter.ServiceNameExact = true
+	default:
+		log.Printf("Неверный service_name_match в query: %q", m)
+		respondInvalidField(c, "service_name_match", msgExpectOneOf, "substring, exact")
+		return filter, false
 	}
 
-	if sd := c.Query("start_date"); sd != "" {
-		sdParsed, err := parseMonthYear(sd)
+	// Параметры-месяцы в формате MM-YYYY
+	months := []struct {
+		name string
+		dst  **model.MonthYear
+	}{
+		{"start_date", &filter.StartFrom},
+		{"end_date", &filter.StartTo},
+		{"active_on", &filter.ActiveOn},
+		{"ends_before", &filter.EndsBefore},
+		{"ends_after", &filter.EndsAfter},
+	}
+	for _, m := range months {
+		v := c.Query(m.name)
+		if v == "" {
+			continue
+		}
+		parsed, err := parseMonthYear(v)
 		if err != nil {
-			log.Printf("Неверный start_date в query: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "неверный start_date"})
-			return
+			log.Printf("Неверный %s в query: %v", m.name, err)
+			respondInvalidField(c, m.name, msgExpectMonth)
+			return filter, false
 		}
-		startDate = &sdParsed
+		*m.dst = &parsed
 	}
 
-	if ed := c.Query("end_date"); ed != "" {
-		edParsed, err := parseMonthYear(ed)
+	// Границы цены
+	prices := []struct {
+		name string
+		dst  **model.Money
+	}{
+		{"min_price", &filter.MinPrice},
+		{"max_price", &filter.MaxPrice},
+	}
+	for _, p := range prices {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		price, err := model.ParseMoney(v)
+		if err != nil || price < 0 {
+			log.Printf("Неверный %s в query: %q", p.name, v)
+			respondInvalidField(c, p.name, msgExpectMoney)
+			return filter, false
+		}
+		*p.dst = &price
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		log.Printf("min_price больше max_price: %s > %s", *filter.MinPrice, *filter.MaxPrice)
+		respondError(c, http.StatusBadRequest, codeInvalidPriceRange)
+		return filter, false
+	}
+
+	if oe := c.Query("open_ended"); oe != "" {
+		openEnded, err := strconv.ParseBool(oe)
 		if err != nil {
-			log.Printf("Неверный end_date в query: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "неверный end_date"})
-			return
+			log.Printf("Неверный open_ended в query: %q", oe)
+			respondInvalidField(c, "open_ended", msgExpectBool)
+			return filter, false
 		}
-		endDate = &edParsed
+		filter.OpenEnded = &openEnded
 	}
 
-	subs, err := h.repo.ListSubscriptions(c.Request.Context(), userID, serviceName, startDate, endDate)
+	if st := c.Query("status"); st != "" {
+		status, err := model.ParseStatus(st)
+		if err != nil {
+			log.Printf("Неверный status в query: %v", err)
+			respondInvalidField(c, "status", msgExpectOneOf, "active, upcoming, expired, paused, cancelled")
+			return filter, false
+		}
+		filter.Status = &status
+	}
+
+	return filter, true
+}
+
+// parsePage — парсит параметры пагинации и сортировки (limit, offset, cursor, sort).
+// При ошибке сам отвечает клиенту 400 и возвращает false.
+func parsePage(c *gin.Context) (model.Page, bool) {
+	page := model.Page{Limit: defaultListLimit}
+
+	if l := c.Query("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			log.Printf("Неверный limit в query: %q", l)
+			respondInvalidField(c, "limit", msgExpectLimit, maxListLimit)
+			return page, false
+		}
+		page.Limit = limit
+	}
+
+	if o := c.Query("offset"); o != "" {
+		offset, err := strconv.Atoi(o)
+		if err != nil || offset < 0 {
+			log.Printf("Неверный offset в query: %q", o)
+			respondInvalidField(c, "offset", msgExpectNonNegativeInt)
+			return page, false
+		}
+		page.Offset = offset
+	}
+
+	sort, err := model.ParseSort(c.Query("sort"))
 	if err != nil {
-		log.Printf("Ошибка получения списка подписок: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось получить список подписок"})
-		return
+		log.Printf("Неверный sort в query: %v", err)
+		respondInvalidField(c, "sort", msgExpectSort)
+		return page, false
+	}
+	page.Sort = sort
+
+	if cur := c.Query("cursor"); cur != "" {
+		if page.Offset > 0 {
+			log.Printf("Одновременно заданы cursor и offset")
+			respondError(c, http.StatusBadRequest, codeCursorWithOffset)
+			return page, false
+		}
+		cursor, err := model.DecodeCursor(cur)
+		if err != nil {
+			log.Printf("Неверный cursor в query: %v", err)
+			respondInvalidField(c, "cursor", msgCursorMalformed)
+			return page, false
+		}
+		if cursor.Sort != page.Sort.String() {
+			log.Printf("Курсор выдан для сортировки %q, запрошена %q", cursor.Sort, page.Sort.String())
+			respondInvalidField(c, "cursor", msgCursorSortMismatch)
+			return page, false
+		}
+		page.Cursor = &cursor
 	}
 
-	log.Printf("Получен список подписок, кол-во: %d", len(subs))
-	c.JSON(http.StatusOK, subs)
+	return page, true
+}
+
+// ListResponse — страница списка подписок
+type ListResponse struct {
+	// Подписки текущей страницы
+	Items []model.Subscription `json:"items"`
+
+	// Курсор следующей страницы; null, если страница последняя
+	NextCursor *string `json:"next_cursor" example:"eyJzIjoiIiwidSI6Ii4uLiJ9"`
+
+	// Общее количество подписок, подходящих под фильтр
+	Total int `json:"total" example:"1234"`
 }
 
 // CalculateTotalPrice godoc
 // @Summary Посчитать суммарную стоимость подписок
 // @Description Обработчик GET /subscriptions/total_price.Считает стоимость подписок за период с фильтрацией по id пользователя и названию сервиса.
-// @Description /subscriptions/total_price?from_date={start_date}&to_date={end_date}&user_id={user_id}&service_name={service_name}
+// @Description Каждая подписка учитывается за месяцы, в которых она активна внутри периода (с учётом end_date и приостановок):
+// @Description при view=charges (по умолчанию) — списаниями своего периода оплаты (годовая подписка — один раз в год),
+// @Description при view=run_rate — ценой, пересчитанной на месяц (monthly_price), за каждый активный месяц.
+// @Description Суммы пересчитываются в валюту currency (по умолчанию RUB) по курсу на первое число каждого месяца;
+// @Description subtotals — суммы в исходных валютах подписок без пересчёта, rates_used — курсы, по которым выполнен пересчёт.
+// @Description С rates_as_of используются курсы, записанные не позже этого момента, и отчёт повторяет посчитанный тогда.
+// @Description /subscriptions/total_price?from_date={from_date}&to_date={to_date}&user_id={user_id}&service_name={service_name}
+// @Description При заданном group_by дополнительно возвращаются суммы по группам: по месяцу (если он в группировке), затем по убыванию суммы;
+// @Description группы считаются в том же проходе, что и общая сумма. Период — не более 120 месяцев.
 // @Tags subscriptions
 // @Produce json
 // @Param user_id query string false "UUID пользователя"
 // @Param service_name query string false "Название сервиса"
-// @Param start_date query string true "Начало периода (MM-YYYY)"
-// @Param end_date query string true "Конец периода (MM-YYYY)"
-// @Success 200 {object} TotalPriceResponse "Общая сумма"
-// @Failure 400 {string} string "Ошибка запроса"
-// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Param from_date query string true "Начало периода (MM-YYYY)"
+// @Param to_date query string true "Конец периода (MM-YYYY)"
+// @Param group_by query []string false "Группировка: service_name, user_id, month (можно комбинировать через запятую)" collectionFormat(csv)
+// @Param view query string false "Распределение стоимости: charges — списания (по умолчанию), run_rate — ежемесячный эквивалент" Enums(charges, run_rate)
+// @Param currency query string false "Валюта результата (ISO 4217, по умолчанию RUB)"
+// @Param rates_as_of query string false "Пересчитать по курсам, известным на этот момент (RFC 3339); по умолчанию — текущие"
+// @Success 200 {object} TotalPriceResponse "Общая сумма и детализация по подпискам"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 422 {object} Problem "Нет курса валюты для пересчёта"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
 // @Router /subscriptions/total_price [get]
 func (h *SubscriptionHandler) CalculateTotalPrice(c *gin.Context) {
 	var input struct {
-		UserID      *string `form:"user_id"`      // Опциональный user_id для фильтрации
-		ServiceName *string `form:"service_name"` // Опциональное имя сервиса для фильтрации
-		FromDate    string  `form:"from_date" binding:"required"` // Начальная дата периода (MM-YYYY)
-		ToDate      string  `form:"to_date" binding:"required"`   // Конечная дата периода (MM-YYYY)
+		UserID      *string  `form:"user_id"`                      // Опциональный user_id для фильтрации
+		ServiceName *string  `form:"service_name"`                 // Опциональное имя сервиса для фильтрации
+		FromDate    string   `form:"from_date" binding:"required"` // Начальная дата периода (MM-YYYY)
+		ToDate      string   `form:"to_date" binding:"required"`   // Конечная дата периода (MM-YYYY)
+		GroupBy     []string `form:"group_by"`                     // Опциональные поля группировки
+		View        string   `form:"view"`                         // Способ распределения стоимости: charges или run_rate
+		Currency    string   `form:"currency"`                     // Валюта результата (по умолчанию RUB)
 	}
 
 	// Парсим query параметры из запроса
 	if err := c.ShouldBindQuery(&input); err != nil {
 		log.Printf("Ошибка парсинга query параметров для подсчета стоимости: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
+		return
+	}
+
+	groupBy, err := parseGroupBy(input.GroupBy)
+	if err != nil {
+		log.Printf("Неверный group_by для подсчета стоимости: %v", err)
+		respondInvalidField(c, "group_by", msgExpectOneOf, "service_name, user_id, month")
+		return
+	}
+
+	view, err := model.ParseCostView(input.View)
+	if err != nil {
+		log.Printf("Неверный view для подсчета стоимости: %v", err)
+		respondInvalidField(c, "view", msgExpectOneOf, "charges, run_rate")
+		return
+	}
+
+	currency, err := model.ParseCurrency(input.Currency)
+	if err != nil {
+		log.Printf("Неверная валюта для подсчета стоимости: %v", err)
+		respondInvalidField(c, "currency", msgExpectCurrency)
+		return
+	}
+	ratesAsOf, ok := parseAsOf(c, "rates_as_of")
+	if !ok {
 		return
 	}
 
@@ -356,7 +861,7 @@ func (h *SubscriptionHandler) CalculateTotalPrice(c *gin.Context) {
 		uid, err := uuid.Parse(*input.UserID)
 		if err != nil {
 			log.Printf("Неверный user_id для подсчета стоимости: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "неверный user_id"})
+			respondInvalidField(c, "user_id", msgExpectUUID)
 			return
 		}
 		userID = &uid
@@ -365,30 +870,220 @@ func (h *SubscriptionHandler) CalculateTotalPrice(c *gin.Context) {
 	fromDate, err := parseMonthYear(input.FromDate)
 	if err != nil {
 		log.Printf("Неверный from_date для подсчета стоимости: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный from_date"})
+		respondInvalidField(c, "from_date", msgExpectMonth)
 		return
 	}
 
 	toDate, err := parseMonthYear(input.ToDate)
 	if err != nil {
 		log.Printf("Неверный to_date для подсчета стоимости: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный to_date"})
+		respondInvalidField(c, "to_date", msgExpectMonth)
 		return
 	}
 
-	// Вызываем репозиторий для подсчета суммы
-	total, err := h.repo.CalculateTotalPrice(c.Request.Context(), userID, input.ServiceName, fromDate, toDate)
+	if toDate.ToTime().Before(fromDate.ToTime()) {
+		log.Printf("to_date раньше from_date: %s < %s", input.ToDate, input.FromDate)
+		respondProblem(c, Problem{Status: http.StatusBadRequest, Code: codeInvalidPeriod, Detail: msg(c, msgPeriodReversed)})
+		return
+	}
+	if model.MonthsBetween(fromDate, toDate) > maxTimelineMonths {
+		log.Printf("Слишком длинный период для подсчета стоимости: %s - %s", input.FromDate, input.ToDate)
+		respondProblem(c, Problem{Status: http.StatusBadRequest, Code: codeInvalidPeriod, Detail: msg(c, msgPeriodTooLong, maxTimelineMonths)})
+		return
+	}
+
+	// Курсы для пересчёта: текущие или известные на момент rates_as_of
+	rateLog := h.costRates(c.Request.Context(), ratesAsOf, fromDate, toDate)
+	opts := model.CostOptions{View: view, Currency: currency}
+	if rateLog != nil {
+		opts.Rates = rateLog
+	}
+
+	// Вызываем репозиторий для подсчета суммы; группы считаются в том же проходе
+	total, costs, groups, err := h.repo.CalculateTotalPrice(c.Request.Context(), userID, input.ServiceName, fromDate, toDate, groupBy, opts)
 	if err != nil {
 		log.Printf("Ошибка подсчета общей стоимости подписок: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось подсчитать общую стоимость"})
+		respondStoreError(c, err, msgTotalFailed)
 		return
 	}
-	var totalP TotalPriceResponse
-	totalP.TotalPrice = total
-	log.Printf("Подсчитана общая стоимость подписок: %d", totalP.TotalPrice)
-	c.JSON(http.StatusOK, gin.H{"total_price": totalP.TotalPrice})
+	subtotals := model.Subtotals{}
+	for _, cost := range costs {
+		subtotals[cost.Currency] += cost.OriginalCost
+	}
+	totalP := TotalPriceResponse{
+		TotalPrice:    total,
+		Currency:      currency,
+		Subtotals:     subtotals.List(),
+		Subscriptions: costs,
+		Groups:        groups,
+	}
+	totalP.RatesAsOf, totalP.RatesUsed = ratesAsOf, rateLog.Applied()
+	log.Printf("Подсчитана общая стоимость подписок: %s", totalP.TotalPrice)
+	c.JSON(http.StatusOK, totalP)
 }
 
+// TotalPriceResponse — ответ на запрос общей стоимости подписок за период
 type TotalPriceResponse struct {
-    TotalPrice int `json:"total_price"`
+	// Общая стоимость подписок за период
+	TotalPrice model.Money `json:"total_price" swaggertype:"string" example:"5994.00"`
+
+	// Валюта total_price и сумм детализации
+	Currency model.Currency `json:"currency" example:"RUB"`
+
+	// Суммы в исходных валютах подписок без пересчёта
+	Subtotals []model.CurrencyAmount `json:"subtotals"`
+
+	// Детализация: какие подписки и за сколько месяцев вошли в сумму
+	Subscriptions []model.SubscriptionCost `json:"subscriptions"`
+
+	// Суммы по группам (только при заданном group_by)
+	Groups []model.SpendGroup `json:"groups,omitempty"`
+
+	// Момент, на который взяты курсы (только при заданном rates_as_of)
+	RatesAsOf *time.Time `json:"rates_as_of,omitempty" example:"2025-04-01T00:00:00Z"`
+
+	// Курсы, по которым пересчитаны суммы: для каждого месяца — дата и версия курса (только при пересчёте)
+	RatesUsed []model.AppliedRate `json:"rates_used,omitempty"`
+}
+
+// maxTimelineMonths — максимальная длина периода для подсчёта стоимости и помесячной разбивки расходов
+const maxTimelineMonths = 120
+
+// SpendTimeline godoc
+// @Summary Помесячная разбивка расходов на подписки
+// @Description Обработчик GET /subscriptions/spend/timeline. Возвращает по одному элементу на каждый календарный месяц периода
+// @Description с суммарной стоимостью подписок, активных в этом месяце. Фильтрация по user_id и service_name как в GET /subscriptions.
+// @Description При view=charges (по умолчанию) подписка попадает в месяцы своих списаний (годовая — раз в год),
+// @Description при view=run_rate — в каждый активный месяц с ценой, пересчитанной на месяц (monthly run-rate).
+// @Description Суммы пересчитываются в валюту currency (по умолчанию RUB) по курсу на первое число каждого месяца
+// @Description (rates_used — использованные курсы; с rates_as_of — курсы, записанные не позже этого момента).
+// @Tags subscriptions
+// @Produce json
+// @Param user_id query string false "UUID пользователя"
+// @Param service_name query string false "Название сервиса"
+// @Param from_date query string true "Начало периода (MM-YYYY)"
+// @Param to_date query string true "Конец периода (MM-YYYY)"
+// @Param view query string false "Распределение стоимости: charges — списания (по умолчанию), run_rate — ежемесячный эквивалент" Enums(charges, run_rate)
+// @Param currency query string false "Валюта результата (ISO 4217, по умолчанию RUB)"
+// @Param rates_as_of query string false "Пересчитать по курсам, известным на этот момент (RFC 3339); по умолчанию — текущие"
+// @Success 200 {object} TimelineResponse "Помесячная разбивка расходов"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 422 {object} Problem "Нет курса валюты для пересчёта"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /subscriptions/spend/timeline [get]
+func (h *SubscriptionHandler) SpendTimeline(c *gin.Context) {
+	var input struct {
+		UserID      *string `form:"user_id"`                      // Опциональный user_id для фильтрации
+		ServiceName *string `form:"service_name"`                 // Опциональное имя сервиса для фильтрации
+		FromDate    string  `form:"from_date" binding:"required"` // Начальная дата периода (MM-YYYY)
+		ToDate      string  `form:"to_date" binding:"required"`   // Конечная дата периода (MM-YYYY)
+		View        string  `form:"view"`                         // Способ распределения стоимости: charges или run_rate
+		Currency    string  `form:"currency"`                     // Валюта результата (по умолчанию RUB)
+	}
+
+	if err := c.ShouldBindQuery(&input); err != nil {
+		log.Printf("Ошибка парсинга query параметров для разбивки расходов: %v", err)
+		respondBindError(c, err)
+		return
+	}
+
+	view, err := model.ParseCostView(input.View)
+	if err != nil {
+		log.Printf("Неверный view для разбивки расходов: %v", err)
+		respondInvalidField(c, "view", msgExpectOneOf, "charges, run_rate")
+		return
+	}
+
+	currency, err := model.ParseCurrency(input.Currency)
+	if err != nil {
+		log.Printf("Неверная валюта для разбивки расходов: %v", err)
+		respondInvalidField(c, "currency", msgExpectCurrency)
+		return
+	}
+	ratesAsOf, ok := parseAsOf(c, "rates_as_of")
+	if !ok {
+		return
+	}
+
+	var userID *uuid.UUID
+	if input.UserID != nil {
+		uid, err := uuid.Parse(*input.UserID)
+		if err != nil {
+			log.Printf("Неверный user_id для разбивки расходов: %v", err)
+			respondInvalidField(c, "user_id", msgExpectUUID)
+			return
+		}
+		userID = &uid
+	}
+
+	fromDate, err := parseMonthYear(input.FromDate)
+	if err != nil {
+		log.Printf("Неверный from_date для разбивки расходов: %v", err)
+		respondInvalidField(c, "from_date", msgExpectMonth)
+		return
+	}
+
+	toDate, err := parseMonthYear(input.ToDate)
+	if err != nil {
+		log.Printf("Неверный to_date для разбивки расходов: %v", err)
+		respondInvalidField(c, "to_date", msgExpectMonth)
+		return
+	}
+
+	if toDate.ToTime().Before(fromDate.ToTime()) {
+		log.Printf("to_date раньше from_date: %s < %s", input.ToDate, input.FromDate)
+		respondProblem(c, Problem{Status: http.StatusBadRequest, Code: codeInvalidPeriod, Detail: msg(c, msgPeriodReversed)})
+		return
+	}
+	if model.MonthsBetween(fromDate, toDate) > maxTimelineMonths {
+		log.Printf("Слишком длинный период для разбивки расходов: %s - %s", input.FromDate, input.ToDate)
+		respondProblem(c, Problem{Status: http.StatusBadRequest, Code: codeInvalidPeriod, Detail: msg(c, msgPeriodTooLong, maxTimelineMonths)})
+		return
+	}
+
+	rateLog := h.costRates(c.Request.Context(), ratesAsOf, fromDate, toDate)
+	opts := model.CostOptions{View: view, Currency: currency}
+	if rateLog != nil {
+		opts.Rates = rateLog
+	}
+
+	timeline, err := h.repo.SpendTimeline(c.Request.Context(), userID, input.ServiceName, fromDate, toDate, opts)
+	if err != nil {
+		log.Printf("Ошибка построения разбивки расходов: %v", err)
+		respondStoreError(c, err, msgTimelineFailed)
+		return
+	}
+
+	resp := TimelineResponse{Currency: currency, Months: timeline, RatesAsOf: ratesAsOf, RatesUsed: rateLog.Applied()}
+	subtotals := model.Subtotals{}
+	for _, m := range timeline {
+		resp.TotalPrice += m.Total
+		for _, s := range m.Subtotals {
+			subtotals[s.Currency] += s.Amount
+		}
+	}
+	resp.Subtotals = subtotals.List()
+	log.Printf("Построена разбивка расходов: месяцев %d, итого %s", len(timeline), resp.TotalPrice)
+	c.JSON(http.StatusOK, resp)
+}
+
+// TimelineResponse — ответ на запрос помесячной разбивки расходов
+type TimelineResponse struct {
+	// Общая стоимость подписок за весь период
+	TotalPrice model.Money `json:"total_price" swaggertype:"string" example:"2996.00"`
+
+	// Валюта сумм разбивки
+	Currency model.Currency `json:"currency" example:"RUB"`
+
+	// Суммы за весь период в исходных валютах подписок без пересчёта
+	Subtotals []model.CurrencyAmount `json:"subtotals"`
+
+	// Расходы по месяцам периода, по возрастанию
+	Months []model.MonthlySpend `json:"months"`
+
+	// Момент, на который взяты курсы (только при заданном rates_as_of)
+	RatesAsOf *time.Time `json:"rates_as_of,omitempty" example:"2025-04-01T00:00:00Z"`
+
+	// Курсы, по которым пересчитаны суммы: для каждого месяца — дата и версия курса (только при пересчёте)
+	RatesUsed []model.AppliedRate `json:"rates_used,omitempty"`
 }
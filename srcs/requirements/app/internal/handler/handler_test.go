@@ -0,0 +1,1178 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+
+	"subscription_service/internal/i18n"
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+)
+
+// newTestRouter — роутер с обработчиками подписок поверх хранилища в памяти;
+// ключи идемпотентности хранятся в том же хранилище
+func newTestRouter(opts ...Option) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	repo := repository.NewMemoryRepository()
+	h := NewSubscriptionHandler(repo, append([]Option{WithIdempotency(repo, DefaultIdempotencyTTL), WithRates(repo)}, opts...)...)
+
+	router := gin.New()
+	router.Use(RequestID(), Localize(i18n.NewCatalog(i18n.RU)))
+	router.POST("/subscriptions", h.Idempotent(), h.CreateSubscription)
+	router.POST("/subscriptions:action", h.Idempotent(), h.SubscriptionsAction)
+	router.POST("/subscriptions/import", h.ImportSubscriptions)
+	router.GET("/subscriptions/:id", h.GetSubscriptionByID)
+	router.PUT("/subscriptions/:id", h.ReplaceSubscriptionByID)
+	router.PATCH("/subscriptions/:id", h.PatchSubscriptionByID)
+	router.DELETE("/subscriptions/:id", h.DeleteSubscriptionByID)
+	router.POST("/subscriptions/:id/cancel", h.CancelSubscription)
+	router.POST("/subscriptions/:id/pause", h.PauseSubscription)
+	router.POST("/subscriptions/:id/resume", h.ResumeSubscription)
+	router.GET("/subscriptions/:id/:service_name/:start_date", h.GetSubscription)
+	router.PUT("/subscriptions/:id/:service_name/:start_date", h.UpdateSubscription)
+	router.PATCH("/subscriptions/:id/:service_name/:start_date", h.PatchSubscription)
+	router.DELETE("/subscriptions/:id/:service_name/:start_date", h.DeleteSubscription)
+	router.GET("/subscriptions", h.ListSubscriptions)
+	router.GET("/subscriptions/total_price", h.CalculateTotalPrice)
+	router.GET("/subscriptions/spend/timeline", h.SpendTimeline)
+	router.GET("/users/:user_id/upcoming_charges", h.UpcomingCharges)
+	router.POST("/rates", h.UploadRates)
+	router.GET("/rates", h.ListRates)
+	router.PUT("/rates/:currency/:date", h.CorrectRate)
+	return router
+}
+
+// do выполняет запрос к роутеру и возвращает ответ; headers — пары имя, значение дополнительных заголовков
+func do(router *gin.Engine, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+const testUserID = "4a79c82c-b09f-4cde-bf80-6edfd680793e"
+
+func TestSubscriptionLifecycle(t *testing.T) {
+	router := newTestRouter()
+
+	w := do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Netflix","price":500,"user_id":"`+testUserID+`","start_date":"01-2025"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /subscriptions: код %d, тело %s", w.Code, w.Body)
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || created.ID == "" {
+		t.Fatalf("В ответе нет id: %s", w.Body)
+	}
+	if loc := w.Header().Get("Location"); loc != "/subscriptions/"+created.ID {
+		t.Errorf("Location = %q", loc)
+	}
+
+	w = do(router, http.MethodPatch, "/subscriptions/"+created.ID, `{"end_date":"06-2025"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"end_date":"06-2025"`) {
+		t.Errorf("PATCH: код %d, тело %s", w.Code, w.Body)
+	}
+
+	w = do(router, http.MethodGet, "/subscriptions/"+testUserID+"/Netflix/01-2025", "")
+	if w.Code != http.StatusOK {
+		t.Errorf("GET по составному ключу: код %d, тело %s", w.Code, w.Body)
+	}
+
+	w = do(router, http.MethodGet, "/subscriptions/total_price?from_date=01-2025&to_date=12-2025", "")
+	var total TotalPriceResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &total); err != nil || total.TotalPrice != 300000 {
+		t.Errorf("total_price: код %d, тело %s", w.Code, w.Body)
+	}
+
+	w = do(router, http.MethodDelete, "/subscriptions/"+created.ID, "")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("DELETE: код %d", w.Code)
+	}
+	w = do(router, http.MethodGet, "/subscriptions/"+created.ID, "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET после удаления: код %d", w.Code)
+	}
+}
+
+func TestListSubscriptionsValidation(t *testing.T) {
+	router := newTestRouter()
+
+	tests := []struct {
+		query string
+		code  string
+	}{
+		{"user_id=not-a-uuid", "invalid_user_id"},
+		{"limit=0", "invalid_limit"},
+		{"sort=user_id", "invalid_sort"},
+		{"active_on=2025-01", "invalid_active_on"},
+		{"start_date=13-2025", "invalid_start_date"},
+		{"end_date=2025", "invalid_end_date"},
+		{"ends_before=00-2025", "invalid_ends_before"},
+		{"ends_after=10/2025", "invalid_ends_after"},
+		{"min_price=-1", "invalid_min_price"},
+		{"min_price=1.999", "invalid_min_price"},
+		{"max_price=abc", "invalid_max_price"},
+		{"min_price=10&max_price=5", codeInvalidPriceRange},
+		{"open_ended=maybe", "invalid_open_ended"},
+		{"service_name=Okko&service_name_match=regex", "invalid_service_name_match"},
+		{"status=frozen", "invalid_status"},
+		{"cursor=not-a-cursor", "invalid_cursor"},
+	}
+	for _, tt := range tests {
+		w := do(router, http.MethodGet, "/subscriptions?"+tt.query, "")
+		var p Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || w.Code != http.StatusBadRequest || p.Code != tt.code {
+			t.Errorf("GET /subscriptions?%s: код %d (%q), ожидался 400 (%q)", tt.query, w.Code, p.Code, tt.code)
+		}
+	}
+
+	// Граничные значения, которые проходят проверку
+	for _, query := range []string{"min_price=0", "min_price=5&max_price=5", "max_price=199.99", "open_ended=false", "service_name_match=exact"} {
+		if w := do(router, http.MethodGet, "/subscriptions?"+query, ""); w.Code != http.StatusOK {
+			t.Errorf("GET /subscriptions?%s: код %d, ожидался 200", query, w.Code)
+		}
+	}
+}
+
+func TestStoreErrorsMapping(t *testing.T) {
+	router := newTestRouter()
+	body := `{"service_name":"Okko","price":300,"user_id":"` + testUserID + `","start_date":"03-2025"}`
+
+	if w := do(router, http.MethodPost, "/subscriptions", body); w.Code != http.StatusCreated {
+		t.Fatalf("POST /subscriptions: код %d, тело %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name, method, path, body string
+		status                   int
+		code                     string
+	}{
+		{"повторное создание", http.MethodPost, "/subscriptions", body, http.StatusConflict, codeAlreadyExists},
+		{"обновление отсутствующей", http.MethodPut, "/subscriptions/" + testUserID + "/Okko/04-2025", `{"price":1}`, http.StatusNotFound, codeNotFound},
+		{"удаление отсутствующей", http.MethodDelete, "/subscriptions/" + testUserID + "/Okko/04-2025", "", http.StatusNotFound, codeNotFound},
+		{"отсутствующая по id", http.MethodGet, "/subscriptions/" + testUserID, "", http.StatusNotFound, codeNotFound},
+	}
+	for _, tt := range tests {
+		w := do(router, tt.method, tt.path, tt.body)
+		var resp Problem
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != tt.status || resp.Code != tt.code {
+			t.Errorf("%s: код %d (%q), ожидался %d (%q)", tt.name, w.Code, resp.Code, tt.status, tt.code)
+		}
+	}
+}
+
+func TestProblemResponses(t *testing.T) {
+	router := newTestRouter()
+
+	tests := []struct {
+		name, method, path, body string
+		code                     string
+		fields                   []string
+	}{
+		{"неверная дата начала", http.MethodPost, "/subscriptions",
+			`{"service_name":"Okko","price":300,"user_id":"` + testUserID + `","start_date":"2025-03"}`,
+			"invalid_start_date", []string{"start_date"}},
+		{"нет обязательных полей", http.MethodPost, "/subscriptions", `{"price":300}`,
+			codeValidationFailed, []string{"service_name", "user_id", "start_date"}},
+		{"неверный тип поля", http.MethodPost, "/subscriptions", `{"service_name":5}`,
+			codeValidationFailed, []string{"service_name"}},
+		{"неразбираемое тело", http.MethodPost, "/subscriptions", `{`, codeInvalidBody, nil},
+		{"неверный limit", http.MethodGet, "/subscriptions?limit=0", "", "invalid_limit", []string{"limit"}},
+		{"нет from_date", http.MethodGet, "/subscriptions/total_price?to_date=12-2025", "",
+			codeValidationFailed, []string{"from_date"}},
+		{"слишком длинный период", http.MethodGet, "/subscriptions/total_price?from_date=01-2000&to_date=12-2025", "",
+			codeInvalidPeriod, nil},
+		{"неизменяемое поле", http.MethodPatch, "/subscriptions/" + testUserID, `{"user_id":"` + testUserID + `"}`,
+			codeValidationFailed, []string{"user_id"}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Request-ID", "req-"+tt.code)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: код %d, ожидался 400", tt.name, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, problemContentType) {
+			t.Errorf("%s: Content-Type = %q", tt.name, ct)
+		}
+		var p Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatalf("%s: тело не problem+json: %s", tt.name, w.Body)
+		}
+		if p.Code != tt.code || p.Type != problemTypeBase+tt.code || p.Status != http.StatusBadRequest ||
+			p.Title == "" || p.Detail == "" || p.Instance != "req-"+tt.code {
+			t.Errorf("%s: получено %+v", tt.name, p)
+		}
+		got := map[string]bool{}
+		for _, fe := range p.Errors {
+			got[fe.Field] = true
+		}
+		if len(got) != len(tt.fields) {
+			t.Errorf("%s: ошибки полей %+v, ожидались %v", tt.name, p.Errors, tt.fields)
+		}
+		for _, f := range tt.fields {
+			if !got[f] {
+				t.Errorf("%s: нет ошибки поля %s в %+v", tt.name, f, p.Errors)
+			}
+		}
+	}
+}
+
+func TestLocalizedMessages(t *testing.T) {
+	router := newTestRouter()
+
+	tests := []struct {
+		acceptLanguage, lang, detail string
+	}{
+		{"", "ru", "подписка не найдена"},
+		{"en-US,en;q=0.9", "en", "subscription not found"},
+		{"de, en;q=0.5, ru;q=0.3", "en", "subscription not found"},
+		{"fr", "ru", "подписка не найдена"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions/"+testUserID, nil)
+		req.Header.Set("Accept-Language", tt.acceptLanguage)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var p Problem
+		_ = json.Unmarshal(w.Body.Bytes(), &p)
+		if p.Detail != tt.detail || w.Header().Get("Content-Language") != tt.lang {
+			t.Errorf("Accept-Language %q: язык %q, detail %q; ожидались %q, %q",
+				tt.acceptLanguage, w.Header().Get("Content-Language"), p.Detail, tt.lang, tt.detail)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions?limit=5000", nil)
+	req.Header.Set("Accept-Language", "en")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var p Problem
+	_ = json.Unmarshal(w.Body.Bytes(), &p)
+	if p.Title != "Invalid value of limit" || p.Detail != "invalid value of limit: number from 1 to 1000 expected" {
+		t.Errorf("Ошибка валидации на английском: %+v", p)
+	}
+}
+
+func TestCompositeKeyUpdates(t *testing.T) {
+	router := newTestRouter()
+	key := "/subscriptions/" + testUserID + "/Netflix/01-2025"
+
+	w := do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Netflix","price":0,"user_id":"`+testUserID+`","start_date":"01-2025","end_date":"06-2025"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST с нулевой ценой: код %d, тело %s", w.Code, w.Body)
+	}
+
+	// PUT без end_date не сбрасывает дату окончания
+	if w := do(router, http.MethodPut, key, `{"price":0}`); w.Code != http.StatusOK {
+		t.Fatalf("PUT с нулевой ценой: код %d, тело %s", w.Code, w.Body)
+	}
+	w = do(router, http.MethodGet, key, "")
+	if !strings.Contains(w.Body.String(), `"end_date":"06-2025"`) || !strings.Contains(w.Body.String(), `"price":"0.00"`) {
+		t.Errorf("После PUT без end_date: %s", w.Body)
+	}
+	if w := do(router, http.MethodPut, key, `{"end_date":"07-2025"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT без price: код %d, ожидался 400", w.Code)
+	}
+
+	// PATCH переносит подписку на новый составной ключ и явно очищает end_date
+	w = do(router, http.MethodPatch, key, `{"service_name":"Okko","start_date":"02-2025","end_date":null}`)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "end_date") {
+		t.Fatalf("PATCH по составному ключу: код %d, тело %s", w.Code, w.Body)
+	}
+	if w := do(router, http.MethodGet, key, ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET по старому ключу: код %d, ожидался 404", w.Code)
+	}
+	moved := "/subscriptions/" + testUserID + "/Okko/02-2025"
+	if w := do(router, http.MethodGet, moved, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"price":"0.00"`) {
+		t.Errorf("GET по новому ключу: код %d, тело %s", w.Code, w.Body)
+	}
+
+	// Перенос на занятый ключ — 409
+	do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Netflix","price":100,"user_id":"`+testUserID+`","start_date":"01-2025"}`)
+	if w := do(router, http.MethodPatch, moved, `{"service_name":"Netflix","start_date":"01-2025"}`); w.Code != http.StatusConflict {
+		t.Errorf("PATCH на занятый ключ: код %d, ожидался 409", w.Code)
+	}
+}
+
+func TestSubscriptionStatusTransitions(t *testing.T) {
+	router := newTestRouter()
+
+	w := do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Netflix","price":100,"user_id":"`+testUserID+`","start_date":"01-2020"}`)
+	var created struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	path := "/subscriptions/" + created.ID
+	if !strings.Contains(w.Body.String(), `"status":"active"`) {
+		t.Errorf("POST: нет состояния active: %s", w.Body)
+	}
+
+	// Приостановка с 01-2021 до возобновления: весь 2021 год не оплачивается
+	w = do(router, http.MethodPost, path+"/pause", `{"from":"01-2021"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"paused"`) {
+		t.Fatalf("pause: код %d, тело %s", w.Code, w.Body)
+	}
+	w = do(router, http.MethodGet, "/subscriptions?status=paused", "")
+	if !strings.Contains(w.Body.String(), `"total":1`) {
+		t.Errorf("список со status=paused: %s", w.Body)
+	}
+	w = do(router, http.MethodGet, "/subscriptions/total_price?from_date=01-2020&to_date=12-2021", "")
+	var total TotalPriceResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &total); err != nil || total.TotalPrice != 120000 {
+		t.Errorf("total_price с приостановкой: код %d, тело %s", w.Code, w.Body)
+	}
+
+	// Возобновление без тела — с текущего месяца
+	w = do(router, http.MethodPost, path+"/resume", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"active"`) {
+		t.Fatalf("resume: код %d, тело %s", w.Code, w.Body)
+	}
+	w = do(router, http.MethodPost, path+"/resume", "")
+	var p Problem
+	_ = json.Unmarshal(w.Body.Bytes(), &p)
+	if w.Code != http.StatusConflict || p.Code != codeInvalidTransition {
+		t.Errorf("повторное возобновление: код %d (%q), ожидался 409", w.Code, p.Code)
+	}
+
+	// Отмена в прошлом месяце: подписка отменена, повторная отмена отклоняется
+	w = do(router, http.MethodPost, path+"/cancel", `{"effective_month":"12-2020"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"cancelled"`) || !strings.Contains(w.Body.String(), `"end_date":"12-2020"`) {
+		t.Fatalf("cancel: код %d, тело %s", w.Code, w.Body)
+	}
+	if w := do(router, http.MethodPost, path+"/cancel", ""); w.Code != http.StatusConflict ||
+		!strings.Contains(w.Body.String(), `"detail":"недопустимое изменение состояния подписки: подписка уже отменена"`) {
+		t.Errorf("повторная отмена: код %d, тело %s; ожидался 409", w.Code, w.Body)
+	}
+	// Причина перехода переводится на язык запроса
+	if w := do(router, http.MethodPost, path+"/cancel", "", "Accept-Language", "en"); w.Code != http.StatusConflict ||
+		!strings.Contains(w.Body.String(), `"detail":"invalid subscription state transition: subscription is already cancelled"`) {
+		t.Errorf("повторная отмена на английском: код %d, тело %s", w.Code, w.Body)
+	}
+	if w := do(router, http.MethodGet, "/subscriptions?status=cancelled", ""); !strings.Contains(w.Body.String(), `"total":1`) {
+		t.Errorf("список со status=cancelled: %s", w.Body)
+	}
+
+	if w := do(router, http.MethodGet, "/subscriptions?status=frozen", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("неизвестный status: код %d, ожидался 400", w.Code)
+	}
+	if w := do(router, http.MethodPost, path+"/pause", `{"from":"2021-01"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("неверный from: код %d, ожидался 400", w.Code)
+	}
+}
+
+func TestBillingPeriods(t *testing.T) {
+	router := newTestRouter()
+
+	w := do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Yandex Plus","price":5990,"billing_period":"year","user_id":"`+testUserID+`","start_date":"03-2025"}`)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"billing_period":"year"`) {
+		t.Fatalf("POST: код %d, тело %s", w.Code, w.Body)
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+
+	// Списания: вся цена в марте; нормализованный вид: 499.17 в каждом месяце
+	var charges, runRate TimelineResponse
+	w = do(router, http.MethodGet, "/subscriptions/spend/timeline?from_date=01-2025&to_date=12-2025", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &charges); err != nil || charges.TotalPrice != 599000 || charges.Months[2].Total != 599000 || charges.Months[3].Total != 0 {
+		t.Errorf("timeline view=charges: код %d, тело %s", w.Code, w.Body)
+	}
+	w = do(router, http.MethodGet, "/subscriptions/spend/timeline?from_date=01-2025&to_date=12-2025&view=run_rate", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &runRate); err != nil || runRate.TotalPrice != 49917*10 || runRate.Months[3].Total != 49917 {
+		t.Errorf("timeline view=run_rate: код %d, тело %s", w.Code, w.Body)
+	}
+
+	w = do(router, http.MethodPatch, "/subscriptions/"+created.ID, `{"billing_period":"6m"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"billing_period":"6m"`) {
+		t.Errorf("PATCH billing_period: код %d, тело %s", w.Code, w.Body)
+	}
+	if w := do(router, http.MethodPatch, "/subscriptions/"+created.ID, `{"billing_period":""}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PATCH пустой billing_period: код %d, ожидался 400", w.Code)
+	}
+
+	if w := do(router, http.MethodGet, "/subscriptions/total_price?from_date=01-2025&to_date=12-2025&view=monthly", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("неизвестный view: код %d, ожидался 400", w.Code)
+	}
+	w = do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Spotify","price":100,"billing_period":"daily","user_id":"`+testUserID+`","start_date":"03-2025"}`)
+	var p Problem
+	_ = json.Unmarshal(w.Body.Bytes(), &p)
+	if w.Code != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Field != "billing_period" {
+		t.Errorf("неверный billing_period: код %d, тело %s", w.Code, w.Body)
+	}
+}
+
+func TestMoneyAmounts(t *testing.T) {
+	router := newTestRouter()
+
+	// Цена принимается строкой или числом и возвращается строкой с двумя знаками после точки
+	w := do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Yandex Plus","price":"0.10","user_id":"`+testUserID+`","start_date":"01-2025"}`)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"price":"0.10"`) {
+		t.Fatalf("POST с ценой-строкой: код %d, тело %s", w.Code, w.Body)
+	}
+	w = do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Spotify","price":0.2,"user_id":"`+testUserID+`","start_date":"01-2025"}`)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"price":"0.20"`) {
+		t.Fatalf("POST с ценой-числом: код %d, тело %s", w.Code, w.Body)
+	}
+
+	// Суммы складываются точно: 3 × (0.10 + 0.20) = 0.90
+	w = do(router, http.MethodGet, "/subscriptions/total_price?from_date=01-2025&to_date=03-2025", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"total_price":"0.90"`) {
+		t.Errorf("total_price: код %d, тело %s", w.Code, w.Body)
+	}
+	if w := do(router, http.MethodGet, "/subscriptions?min_price=0.15", ""); !strings.Contains(w.Body.String(), `"total":1`) {
+		t.Errorf("min_price с копейками: %s", w.Body)
+	}
+
+	for _, price := range []string{`"1.999"`, `"1e3"`, `null`, `"много"`} {
+		w := do(router, http.MethodPost, "/subscriptions",
+			`{"service_name":"Okko","price":`+price+`,"user_id":"`+testUserID+`","start_date":"01-2025"}`)
+		var p Problem
+		_ = json.Unmarshal(w.Body.Bytes(), &p)
+		if w.Code != http.StatusBadRequest || p.Code != "invalid_price" {
+			t.Errorf("price %s: код %d, тело %s", price, w.Code, w.Body)
+		}
+	}
+	if w := do(router, http.MethodPatch, "/subscriptions/"+testUserID+"/Spotify/01-2025", `{"price":"-1.00"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PATCH с отрицательной ценой: код %d, ожидался 400", w.Code)
+	}
+	if w := do(router, http.MethodGet, "/subscriptions?max_price=1.001", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("max_price с тремя знаками: код %d, ожидался 400", w.Code)
+	}
+}
+
+func TestBillingDay(t *testing.T) {
+	router := newTestRouter()
+
+	// Полная дата начала: в JSON start_date остаётся MM-YYYY, день возвращается в billing_day
+	w := do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Netflix","price":100,"user_id":"`+testUserID+`","start_date":"2025-03-15"}`)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"start_date":"03-2025"`) || !strings.Contains(w.Body.String(), `"billing_day":15`) {
+		t.Fatalf("POST с полной датой: код %d, тело %s", w.Code, w.Body)
+	}
+	if w := do(router, http.MethodGet, "/subscriptions/"+testUserID+"/Netflix/2025-03-15", ""); w.Code != http.StatusOK {
+		t.Errorf("GET по составному ключу с полной датой: код %d", w.Code)
+	}
+	// Составной ключ из ответа (MM-YYYY) находит подписку, начатую в середине месяца
+	if w := do(router, http.MethodGet, "/subscriptions/"+testUserID+"/Netflix/03-2025", ""); w.Code != http.StatusOK {
+		t.Errorf("GET по составному ключу с месяцем: код %d", w.Code)
+	}
+	if w := do(router, http.MethodPatch, "/subscriptions/"+testUserID+"/Netflix/03-2025", `{"price":"150"}`, "Content-Type", "application/merge-patch+json"); w.Code != http.StatusOK {
+		t.Errorf("PATCH по составному ключу с месяцем: код %d, тело %s", w.Code, w.Body)
+	}
+	// Подписка на тот же сервис с другим днём того же месяца — дубликат
+	if w := do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Netflix","price":100,"user_id":"`+testUserID+`","start_date":"2025-03-20"}`); w.Code != http.StatusConflict {
+		t.Errorf("POST в том же месяце: код %d, ожидался 409", w.Code)
+	}
+
+	// billing_day с датой MM-YYYY переносит начало на этот день
+	w = do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Okko","price":100,"billing_day":10,"user_id":"`+testUserID+`","start_date":"04-2025"}`)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"billing_day":10`) {
+		t.Fatalf("POST с billing_day: код %d, тело %s", w.Code, w.Body)
+	}
+	if w := do(router, http.MethodGet, "/subscriptions?end_date=04-2025&active_on=04-2025&service_name=okko", ""); !strings.Contains(w.Body.String(), `"total":1`) {
+		t.Errorf("фильтр по месяцу начала: %s", w.Body)
+	}
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"день вне диапазона", `"start_date":"04-2025","billing_day":32`, "billing_day"},
+		{"день не совпадает с датой", `"start_date":"2025-04-05","billing_day":10`, "billing_day"},
+		{"несуществующая дата", `"start_date":"2025-02-30"`, "start_date"},
+	}
+	for _, tt := range tests {
+		w := do(router, http.MethodPost, "/subscriptions", `{"service_name":"Ivi","price":1,"user_id":"`+testUserID+`",`+tt.body+`}`)
+		var p Problem
+		_ = json.Unmarshal(w.Body.Bytes(), &p)
+		if w.Code != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Field != tt.field {
+			t.Errorf("%s: код %d, тело %s", tt.name, w.Code, w.Body)
+		}
+	}
+}
+
+func TestUpcomingCharges(t *testing.T) {
+	router := newTestRouter()
+	for _, body := range []string{
+		`{"service_name":"Netflix","price":999,"user_id":"` + testUserID + `","start_date":"2025-05-05"}`,
+		`{"service_name":"Kinopoisk","price":2990,"billing_period":"year","user_id":"` + testUserID + `","start_date":"2024-08-20"}`,
+		`{"service_name":"Okko","price":300,"user_id":"00000000-0000-0000-0000-000000000001","start_date":"01-2025"}`,
+		`{"service_name":"GitHub","price":"4.00","currency":"USD","user_id":"` + testUserID + `","start_date":"2025-08-10"}`,
+	} {
+		if w := do(router, http.MethodPost, "/subscriptions", body); w.Code != http.StatusCreated {
+			t.Fatalf("POST: код %d, тело %s", w.Code, w.Body)
+		}
+	}
+
+	w := do(router, http.MethodGet, "/users/"+testUserID+"/upcoming_charges?from=2025-08-01&days=45", "")
+	var resp UpcomingChargesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("upcoming_charges: код %d, тело %s", w.Code, w.Body)
+	}
+	// Суммы в рублях и долларах не складываются
+	if resp.To != "2025-09-14" || len(resp.Charges) != 5 || len(resp.Totals) != 2 ||
+		resp.Totals[0] != (model.CurrencyAmount{Currency: "RUB", Amount: 99900*2 + 299000}) || resp.Totals[1] != (model.CurrencyAmount{Currency: "USD", Amount: 800}) {
+		t.Fatalf("upcoming_charges: %+v", resp)
+	}
+	if c := resp.Charges[2]; c.Date != "2025-08-20" || c.ServiceName != "Kinopoisk" || c.Currency != "RUB" || c.RunningTotal != 99900+299000 {
+		t.Errorf("третье списание: %+v", c)
+	}
+	if c := resp.Charges[1]; c.ServiceName != "GitHub" || c.Currency != "USD" || c.RunningTotal != 400 {
+		t.Errorf("списание в USD: %+v", c)
+	}
+
+	for _, query := range []string{"days=0", "days=400", "from=01-08-2025"} {
+		if w := do(router, http.MethodGet, "/users/"+testUserID+"/upcoming_charges?"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: код %d, ожидался 400", query, w.Code)
+		}
+	}
+	if w := do(router, http.MethodGet, "/users/nobody/upcoming_charges", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("неверный user_id: код %d, ожидался 400", w.Code)
+	}
+}
+
+func TestCurrency(t *testing.T) {
+	router := newTestRouter()
+	w := do(router, http.MethodPost, "/rates",
+		`{"rates":[{"currency":"USD","date":"2025-01-01","rate":100},{"currency":"USD","date":"2025-02-01","rate":"90"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /rates: код %d, тело %s", w.Code, w.Body)
+	}
+
+	w = do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"GitHub","price":10,"currency":"usd","user_id":"`+testUserID+`","start_date":"01-2025"}`)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"currency":"USD"`) {
+		t.Fatalf("POST в USD: код %d, тело %s", w.Code, w.Body)
+	}
+	w = do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Okko","price":450,"user_id":"`+testUserID+`","start_date":"01-2025"}`)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"currency":"RUB"`) {
+		t.Fatalf("POST без валюты: код %d, тело %s", w.Code, w.Body)
+	}
+
+	// По умолчанию суммы в рублях по курсу на первое число каждого месяца, subtotals — без пересчёта
+	var total TotalPriceResponse
+	w = do(router, http.MethodGet, "/subscriptions/total_price?from_date=01-2025&to_date=02-2025", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &total); err != nil || total.Currency != "RUB" || total.TotalPrice != 100000+90000+45000*2 {
+		t.Fatalf("total_price в RUB: код %d, тело %s", w.Code, w.Body)
+	}
+	if len(total.Subtotals) != 2 || total.Subtotals[0] != (model.CurrencyAmount{Currency: "RUB", Amount: 90000}) || total.Subtotals[1] != (model.CurrencyAmount{Currency: "USD", Amount: 2000}) {
+		t.Errorf("subtotals: %+v", total.Subtotals)
+	}
+
+	var timeline TimelineResponse
+	w = do(router, http.MethodGet, "/subscriptions/spend/timeline?from_date=01-2025&to_date=02-2025&currency=USD", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &timeline); err != nil || timeline.Currency != "USD" || timeline.Months[0].Total != 1000+450 || timeline.Months[1].Total != 1000+500 {
+		t.Errorf("timeline в USD: код %d, тело %s", w.Code, w.Body)
+	}
+
+	// Курса евро нет — пересчёт невозможен
+	w = do(router, http.MethodGet, "/subscriptions/total_price?from_date=01-2025&to_date=02-2025&currency=EUR", "")
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"code":"rate_unavailable"`) {
+		t.Errorf("нет курса: код %d, тело %s", w.Code, w.Body)
+	}
+	w = do(router, http.MethodGet, "/subscriptions/total_price?from_date=01-2025&to_date=02-2025&currency=EUR", "", "Accept-Language", "en")
+	if !strings.Contains(w.Body.String(), `"detail":"failed to convert amounts to the requested currency: no EUR exchange rate for 2025-01-01"`) {
+		t.Errorf("нет курса на английском: %s", w.Body)
+	}
+	if w := do(router, http.MethodGet, "/subscriptions/total_price?from_date=01-2025&to_date=02-2025&currency=dollar", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("неверная валюта: код %d, ожидался 400", w.Code)
+	}
+
+	w = do(router, http.MethodPatch, "/subscriptions/"+testUserID+"/Okko/01-2025", `{"currency":"EUR"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"currency":"EUR"`) {
+		t.Errorf("PATCH currency: код %d, тело %s", w.Code, w.Body)
+	}
+	if w := do(router, http.MethodPatch, "/subscriptions/"+testUserID+"/Okko/01-2025", `{"currency":null}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PATCH currency null: код %d, ожидался 400", w.Code)
+	}
+}
+
+func TestRates(t *testing.T) {
+	router := newTestRouter()
+	do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"GitHub","price":"10","currency":"USD","user_id":"`+testUserID+`","start_date":"01-2025"}`)
+
+	// Курсы из CSV-файла в теле запроса
+	var upload RatesUploadResponse
+	w := do(router, http.MethodPost, "/rates", "date,currency,rate\n2025-01-01,USD,100\n", "Content-Type", "text/csv")
+	if err := json.Unmarshal(w.Body.Bytes(), &upload); err != nil || w.Code != http.StatusOK || upload.Saved != 1 || upload.RecordedAt == nil {
+		t.Fatalf("POST /rates (CSV): код %d, тело %s", w.Code, w.Body)
+	}
+	reportedAt := url.QueryEscape(upload.RecordedAt.Format(time.RFC3339Nano))
+
+	var total TotalPriceResponse
+	w = do(router, http.MethodGet, "/subscriptions/total_price?from_date=01-2025&to_date=01-2025", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &total); err != nil || total.TotalPrice != 100000 || len(total.RatesUsed) != 1 {
+		t.Fatalf("total_price: код %d, тело %s", w.Code, w.Body)
+	}
+	if used := total.RatesUsed[0]; used.Currency != "USD" || used.Date != "2025-01-01" || !used.Rate.Equal(decimal.NewFromInt(100)) || used.Source != "csv" {
+		t.Errorf("rates_used: %+v", used)
+	}
+
+	// Исправление курса — новая версия; отчёт на момент первой загрузки считается по прежнему курсу
+	w = do(router, http.MethodPut, "/rates/usd/2025-01-01", `{"rate":"105"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"rate":"105"`) || !strings.Contains(w.Body.String(), `"source":"manual"`) {
+		t.Fatalf("PUT /rates: код %d, тело %s", w.Code, w.Body)
+	}
+	w = do(router, http.MethodGet, "/subscriptions/total_price?from_date=01-2025&to_date=01-2025", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &total); err != nil || total.TotalPrice != 105000 {
+		t.Errorf("total_price после исправления: код %d, тело %s", w.Code, w.Body)
+	}
+	w = do(router, http.MethodGet, "/subscriptions/total_price?from_date=01-2025&to_date=01-2025&rates_as_of="+reportedAt, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &total); err != nil || total.TotalPrice != 100000 || total.RatesAsOf == nil || !total.RatesUsed[0].Rate.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("total_price с rates_as_of: код %d, тело %s", w.Code, w.Body)
+	}
+	var timeline TimelineResponse
+	w = do(router, http.MethodGet, "/subscriptions/spend/timeline?from_date=01-2025&to_date=01-2025&rates_as_of="+reportedAt, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &timeline); err != nil || timeline.TotalPrice != 100000 || len(timeline.RatesUsed) != 1 {
+		t.Errorf("timeline с rates_as_of: код %d, тело %s", w.Code, w.Body)
+	}
+
+	// Повтор того же значения не создаёт версию
+	if w := do(router, http.MethodPut, "/rates/USD/2025-01-01", `{"rate":105.0}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"rate":"105"`) {
+		t.Errorf("PUT того же курса: код %d, тело %s", w.Code, w.Body)
+	}
+	var list RatesListResponse
+	w = do(router, http.MethodGet, "/rates?currency=usd&history=true", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Items) != 2 || list.Items[0].Source != "csv" || list.Items[1].Source != "manual" {
+		t.Errorf("история курса: код %d, тело %s", w.Code, w.Body)
+	}
+	w = do(router, http.MethodGet, "/rates?as_of="+reportedAt, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Items) != 1 || !list.Items[0].Rate.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("курсы на момент первой загрузки: код %d, тело %s", w.Code, w.Body)
+	}
+
+	// CSV ЕЦБ: курсы евро сохраняются как есть, к рублю пересчитываются через курс евро
+	w = do(router, http.MethodPost, "/rates?format=ecb", "Date,USD,\n2025-01-01,1.05,\n", "Content-Type", "text/csv")
+	if err := json.Unmarshal(w.Body.Bytes(), &upload); err != nil || w.Code != http.StatusOK || upload.Saved != 1 {
+		t.Errorf("POST /rates (ЕЦБ): код %d, тело %s", w.Code, w.Body)
+	}
+	w = do(router, http.MethodGet, "/rates?currency=EUR&quote=USD", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Items) != 1 || list.Items[0].Source != "ecb" {
+		t.Errorf("курсы ЕЦБ: код %d, тело %s", w.Code, w.Body)
+	}
+
+	w = do(router, http.MethodPost, "/rates", `{"rates":[{"currency":"USD","date":"2025-01-01","rate":"-1"},{"currency":"USD","quote":"usd","date":"2025-01-01","rate":"1"}]}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field":"rates[0].rate"`) || !strings.Contains(w.Body.String(), `"field":"rates[1].quote"`) {
+		t.Errorf("неверные курсы в JSON: код %d, тело %s", w.Code, w.Body)
+	}
+	w = do(router, http.MethodPost, "/rates", "2025-01-01,USD,abc\n", "Content-Type", "text/csv")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"code":"invalid_rates_file"`) {
+		t.Errorf("неверный файл: код %d, тело %s", w.Code, w.Body)
+	}
+	for _, path := range []string{
+		"/subscriptions/total_price?from_date=01-2025&to_date=01-2025&rates_as_of=2025-04-01",
+		"/rates?from=01-2025",
+		"/rates?currency=dollar",
+		"/rates?history=maybe",
+	} {
+		if w := do(router, http.MethodGet, path, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: код %d, ожидался 400", path, w.Code)
+		}
+	}
+	if w := do(router, http.MethodPut, "/rates/USD/01-2025", `{"rate":"1"}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"code":"invalid_date"`) {
+		t.Errorf("PUT с неверной датой: код %d, тело %s", w.Code, w.Body)
+	}
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	router := newTestRouter()
+
+	w := do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Netflix","price":500,"user_id":"`+testUserID+`","start_date":"01-2025"}`)
+	var created struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	path := "/subscriptions/" + created.ID
+	tag := w.Header().Get("ETag")
+	if tag == "" {
+		t.Fatalf("POST: нет ETag")
+	}
+
+	if w := do(router, http.MethodGet, path, "", "If-None-Match", tag); w.Code != http.StatusNotModified {
+		t.Errorf("GET с актуальным If-None-Match: код %d, ожидался 304", w.Code)
+	}
+	if w := do(router, http.MethodGet, path, "", "If-None-Match", `"0"`); w.Code != http.StatusOK || w.Header().Get("ETag") != tag {
+		t.Errorf("GET с устаревшим If-None-Match: код %d, ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+
+	// Первый оператор меняет цену, второй пытается изменить по той же версии
+	w = do(router, http.MethodPatch, path, `{"price":600}`, "If-Match", tag)
+	newTag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || newTag == "" || newTag == tag {
+		t.Fatalf("PATCH с актуальным If-Match: код %d, ETag %q", w.Code, newTag)
+	}
+	w = do(router, http.MethodPatch, path, `{"price":700}`, "If-Match", tag)
+	var p Problem
+	_ = json.Unmarshal(w.Body.Bytes(), &p)
+	if w.Code != http.StatusPreconditionFailed || p.Code != codePreconditionFailed {
+		t.Errorf("PATCH с устаревшим If-Match: код %d (%q), ожидался 412", w.Code, p.Code)
+	}
+	composite := "/subscriptions/" + testUserID + "/Netflix/01-2025"
+	if w := do(router, http.MethodPut, composite, `{"price":700}`, "If-Match", tag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT по составному ключу с устаревшим If-Match: код %d, ожидался 412", w.Code)
+	}
+	if w := do(router, http.MethodDelete, path, "", "If-Match", "not-an-etag"); w.Code != http.StatusBadRequest {
+		t.Errorf("DELETE с неверным If-Match: код %d, ожидался 400", w.Code)
+	}
+	if w := do(router, http.MethodDelete, path, "", "If-Match", tag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("DELETE с устаревшим If-Match: код %d, ожидался 412", w.Code)
+	}
+
+	// ETag списка меняется вместе с содержимым
+	w = do(router, http.MethodGet, "/subscriptions", "")
+	listTag := w.Header().Get("ETag")
+	if w := do(router, http.MethodGet, "/subscriptions", "", "If-None-Match", listTag); w.Code != http.StatusNotModified {
+		t.Errorf("GET /subscriptions с актуальным If-None-Match: код %d, ожидался 304", w.Code)
+	}
+	if w := do(router, http.MethodDelete, path, "", "If-Match", newTag); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE с актуальным If-Match: код %d", w.Code)
+	}
+	if w := do(router, http.MethodGet, "/subscriptions", "", "If-None-Match", listTag); w.Code != http.StatusOK {
+		t.Errorf("GET /subscriptions после удаления: код %d, ожидался 200", w.Code)
+	}
+}
+
+func TestRequireIfMatch(t *testing.T) {
+	router := newTestRouter(WithRequireIfMatch())
+
+	w := do(router, http.MethodPost, "/subscriptions",
+		`{"service_name":"Okko","price":300,"user_id":"`+testUserID+`","start_date":"03-2025"}`)
+	path := "/subscriptions/" + testUserID + "/Okko/03-2025"
+	if w := do(router, http.MethodDelete, path, ""); w.Code != http.StatusPreconditionRequired {
+		t.Errorf("DELETE без If-Match: код %d, ожидался 428", w.Code)
+	}
+	if w := do(router, http.MethodDelete, path, "", "If-Match", w.Header().Get("ETag")); w.Code != http.StatusOK {
+		t.Errorf("DELETE с If-Match: код %d", w.Code)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	router := newTestRouter()
+	body := `{"service_name":"Kion","price":250,"user_id":"` + testUserID + `","start_date":"04-2025"}`
+
+	first := do(router, http.MethodPost, "/subscriptions", body, "Idempotency-Key", "retry-1")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("POST с ключом: код %d, тело %s", first.Code, first.Body.String())
+	}
+
+	// Тот же запрос с другим порядком полей получает сохранённый ответ, а не 409
+	reordered := `{"user_id":"` + testUserID + `","start_date":"04-2025","price":250,"service_name":"Kion"}`
+	replay := do(router, http.MethodPost, "/subscriptions", reordered, "Idempotency-Key", "retry-1")
+	if replay.Code != http.StatusCreated || replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("повтор с ключом: код %d, Idempotent-Replayed %q", replay.Code, replay.Header().Get("Idempotent-Replayed"))
+	}
+	if replay.Body.String() != first.Body.String() {
+		t.Errorf("повтор вернул другое тело: %s, ожидалось %s", replay.Body.String(), first.Body.String())
+	}
+	for _, name := range []string{"Location", "ETag", "Content-Type"} {
+		if replay.Header().Get(name) != first.Header().Get(name) {
+			t.Errorf("повтор: заголовок %s = %q, ожидалось %q", name, replay.Header().Get(name), first.Header().Get(name))
+		}
+	}
+
+	// Тот же ключ с другим телом
+	other := strings.Replace(body, `"price":250`, `"price":300`, 1)
+	w := do(router, http.MethodPost, "/subscriptions", other, "Idempotency-Key", "retry-1")
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("ключ с другим телом: код %d, ожидался 422", w.Code)
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || p.Code != "idempotency_key_reused" {
+		t.Errorf("ключ с другим телом: код ошибки %q (%v)", p.Code, err)
+	}
+
+	// Без ключа повтор по-прежнему конфликтует с созданной подпиской
+	if w := do(router, http.MethodPost, "/subscriptions", body); w.Code != http.StatusConflict {
+		t.Errorf("повтор без ключа: код %d, ожидался 409", w.Code)
+	}
+
+	if w := do(router, http.MethodPost, "/subscriptions", body, "Idempotency-Key", strings.Repeat("k", 256)); w.Code != http.StatusBadRequest {
+		t.Errorf("слишком длинный ключ: код %d, ожидался 400", w.Code)
+	}
+}
+
+// Ключ, зарезервированный упавшим запросом, освобождается по истечении резерва, а не через IDEMPOTENCY_TTL
+func TestIdempotencyLease(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := repository.NewMemoryRepository()
+	h := NewSubscriptionHandler(repo, WithIdempotency(repo, DefaultIdempotencyTTL))
+	router := gin.New()
+	router.Use(Localize(i18n.NewCatalog(i18n.RU)))
+	router.POST("/subscriptions", h.Idempotent(), h.CreateSubscription)
+
+	body := `{"service_name":"Kion","price":250,"user_id":"` + testUserID + `","start_date":"04-2025"}`
+	hash := requestHash(http.MethodPost, "/subscriptions", []byte(body))
+	now := time.Now()
+	for key, lease := range map[string]time.Time{"running": now.Add(time.Minute), "crashed": now.Add(-time.Second)} {
+		rec := model.IdempotencyRecord{Key: key, RequestHash: hash, ExpiresAt: now.Add(DefaultIdempotencyTTL), LeaseUntil: lease}
+		if _, err := repo.ReserveIdempotencyKey(context.Background(), rec); err != nil {
+			t.Fatalf("резервирование %s: %v", key, err)
+		}
+	}
+
+	if w := do(router, http.MethodPost, "/subscriptions", body, "Idempotency-Key", "running"); w.Code != http.StatusConflict ||
+		!strings.Contains(w.Body.String(), `"code":"idempotency_in_progress"`) {
+		t.Errorf("повтор во время резерва: код %d, тело %s; ожидался 409", w.Code, w.Body)
+	}
+	if w := do(router, http.MethodPost, "/subscriptions", body, "Idempotency-Key", "crashed"); w.Code != http.StatusCreated {
+		t.Fatalf("повтор после истечения резерва: код %d, тело %s; ожидался 201", w.Code, w.Body)
+	}
+	if w := do(router, http.MethodPost, "/subscriptions", body, "Idempotency-Key", "crashed"); w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("ответ на повтор после истечения резерва не сохранён: код %d", w.Code)
+	}
+}
+
+// Резерв истекает, пока первый запрос ещё выполняется: повтор перехватывает ключ, и сохранённым
+// остаётся его ответ, а не ответ первого запроса, завершившегося позже
+func TestIdempotencyLeaseExpiredDuringRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := repository.NewMemoryRepository()
+	h := NewSubscriptionHandler(repo, WithIdempotency(repo, DefaultIdempotencyTTL))
+	h.idempotencyLease = -time.Second // резерв истекает сразу после резервирования
+	router := gin.New()
+	router.Use(Localize(i18n.NewCatalog(i18n.RU)))
+
+	body := `{"service_name":"Kion","price":250,"user_id":"` + testUserID + `","start_date":"04-2025"}`
+	var retry *httptest.ResponseRecorder
+	retried := false
+	router.POST("/subscriptions", h.Idempotent(), func(c *gin.Context) {
+		// Первый запрос «завис»: повтор с тем же ключом приходит и завершается раньше него
+		if !retried {
+			retried = true
+			retry = do(router, http.MethodPost, "/subscriptions", body, "Idempotency-Key", "slow")
+		}
+		h.CreateSubscription(c)
+	})
+
+	first := do(router, http.MethodPost, "/subscriptions", body, "Idempotency-Key", "slow")
+	if retry.Code != http.StatusCreated || first.Code != http.StatusConflict {
+		t.Fatalf("повтор: код %d, ожидался 201; первый запрос: код %d, ожидался 409", retry.Code, first.Code)
+	}
+	replay := do(router, http.MethodPost, "/subscriptions", body, "Idempotency-Key", "slow")
+	if replay.Code != http.StatusCreated || replay.Header().Get("Idempotent-Replayed") != "true" || replay.Body.String() != retry.Body.String() {
+		t.Errorf("сохранённый ответ: код %d, тело %s; ожидался ответ повтора %s", replay.Code, replay.Body, retry.Body)
+	}
+}
+
+// Строки сверх размера листа XLSX не отбрасываются молча: выгрузка завершается ошибкой
+func TestXLSXRowLimit(t *testing.T) {
+	e := newXLSXExporter(io.Discard, "Итого")
+	if err := e.start(); err != nil {
+		t.Fatal(err)
+	}
+	e.row = xlsxMaxRows - 1
+	if err := e.write(model.Subscription{ServiceName: "Okko"}); err != nil {
+		t.Fatalf("последняя строка листа: %v", err)
+	}
+	if err := e.write(model.Subscription{ServiceName: "Ivi"}); !errors.Is(err, errXLSXRowLimit) {
+		t.Errorf("строка сверх листа: %v, ожидалась errXLSXRowLimit", err)
+	}
+	e.row = xlsxMaxRows - 1
+	if err := e.finish([]exportTotal{{currency: "RUB", period: "month"}, {currency: "USD", period: "month"}}); !errors.Is(err, errXLSXRowLimit) {
+		t.Errorf("итоги сверх листа: %v, ожидалась errXLSXRowLimit", err)
+	}
+}
+
+func TestBatchCreate(t *testing.T) {
+	router := newTestRouter(WithMaxBatchSize(3))
+	item := func(service string, price int) string {
+		return `{"service_name":"` + service + `","price":` + strconv.Itoa(price) + `,"user_id":"` + testUserID + `","start_date":"05-2025"}`
+	}
+	batch := func(items ...string) string {
+		return `{"items":[` + strings.Join(items, ",") + `]}`
+	}
+
+	w := do(router, http.MethodPost, "/subscriptions:batch", batch(item("Netflix", 500), item("Okko", 300)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("пакетное создание: код %d, тело %s", w.Code, w.Body.String())
+	}
+	var resp BatchCreateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Created != 2 || len(resp.Results) != 2 || resp.Results[1].Subscription == nil || resp.Results[1].Subscription.ServiceName != "Okko" {
+		t.Fatalf("пакетное создание: %+v", resp)
+	}
+
+	// По умолчанию конфликт отменяет весь пакет и перечисляет занятые элементы
+	w = do(router, http.MethodPost, "/subscriptions:batch", batch(item("Kion", 100), item("Okko", 400)))
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || w.Code != http.StatusConflict ||
+		len(p.Errors) != 1 || p.Errors[0].Field != "items[1]" {
+		t.Fatalf("конфликт: код %d, тело %s", w.Code, w.Body.String())
+	}
+	if w := do(router, http.MethodGet, "/subscriptions/"+testUserID+"/Kion/05-2025", ""); w.Code != http.StatusNotFound {
+		t.Errorf("после отменённого пакета подписка Kion существует: код %d", w.Code)
+	}
+
+	w = do(router, http.MethodPost, "/subscriptions:batch?on_conflict=skip", batch(item("Kion", 100), item("Okko", 400)))
+	resp = BatchCreateResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Created != 1 || resp.Skipped != 1 {
+		t.Fatalf("on_conflict=skip: код %d, тело %s", w.Code, w.Body.String())
+	}
+
+	w = do(router, http.MethodPost, "/subscriptions:batch?on_conflict=update", batch(item("Okko", 400)))
+	resp = BatchCreateResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Updated != 1 || resp.Results[0].Subscription.Price != 40000 {
+		t.Fatalf("on_conflict=update: код %d, тело %s", w.Code, w.Body.String())
+	}
+
+	// Ошибки валидации перечисляются по элементам; ничего не сохраняется
+	w = do(router, http.MethodPost, "/subscriptions:batch", batch(item("Ivi", 100), `{"service_name":"Wink","price":"9.999","user_id":"`+testUserID+`","start_date":"05-2025"}`, `{"service_name":"Start","price":1,"user_id":"`+testUserID+`","start_date":"2025-05"}`))
+	p = Problem{}
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || w.Code != http.StatusBadRequest || p.Code != "validation_failed" {
+		t.Fatalf("ошибки валидации: код %d, тело %s", w.Code, w.Body.String())
+	}
+	fields := map[string]bool{}
+	for _, fe := range p.Errors {
+		fields[fe.Field] = true
+	}
+	if !fields["items[1].price"] || !fields["items[2].start_date"] || len(fields) != 2 {
+		t.Errorf("ошибки валидации: %+v", p.Errors)
+	}
+
+	// Нарушение ограничения хранилища указывается у поля элемента на языке запроса
+	w = do(router, http.MethodPost, "/subscriptions:batch", batch(item("Ivi", 100), item("Wink", -1)), "Accept-Language", "en")
+	p = Problem{}
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || w.Code != http.StatusUnprocessableEntity ||
+		len(p.Errors) != 1 || p.Errors[0].Field != "items[1].price" || p.Errors[0].Code != "constraint" ||
+		p.Detail != "invalid value of items[1].price: non-negative amount with at most two decimal places expected, e.g. 199.99" {
+		t.Errorf("нарушение ограничения: код %d, тело %s", w.Code, w.Body.String())
+	}
+
+	cases := []struct {
+		path, body string
+		code       int
+	}{
+		{"/subscriptions:batch?on_conflict=merge", batch(item("Ivi", 100)), http.StatusBadRequest},
+		{"/subscriptions:batch", batch(), http.StatusBadRequest},
+		{"/subscriptions:batch", batch(item("A", 1), item("B", 1), item("C", 1), item("D", 1)), http.StatusBadRequest},
+		{"/subscriptions:batch", batch(item("Ivi", -1)), http.StatusUnprocessableEntity},
+		{"/subscriptions:purge", batch(item("Ivi", 100)), http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		if w := do(router, http.MethodPost, tc.path, tc.body); w.Code != tc.code {
+			t.Errorf("POST %s: код %d, ожидался %d (%s)", tc.path, w.Code, tc.code, w.Body.String())
+		}
+	}
+}
+
+func TestBulkOperations(t *testing.T) {
+	router := newTestRouter()
+	otherUser := "0b6c3f1e-8f0a-4a57-9d2c-1d2f5e7a9b10"
+	for _, body := range []string{
+		`{"service_name":"Okko","price":300,"user_id":"` + testUserID + `","start_date":"01-2025"}`,
+		`{"service_name":"Okko","price":300,"user_id":"` + testUserID + `","start_date":"03-2025"}`,
+		`{"service_name":"Netflix","price":500,"user_id":"` + testUserID + `","start_date":"01-2025"}`,
+		`{"service_name":"Okko","price":300,"user_id":"` + otherUser + `","start_date":"01-2025"}`,
+	} {
+		if w := do(router, http.MethodPost, "/subscriptions", body); w.Code != http.StatusCreated {
+			t.Fatalf("создание: код %d, тело %s", w.Code, w.Body.String())
+		}
+	}
+	filter := "user_id=" + testUserID + "&service_name=Okko"
+
+	// Пробный запуск по умолчанию ничего не меняет
+	w := do(router, http.MethodPost, "/subscriptions:bulkUpdate?"+filter, `{"end_date":"09-2025"}`)
+	var resp BulkResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || !resp.DryRun || resp.Affected != 2 || len(resp.Sample) != 2 {
+		t.Fatalf("пробный запуск: код %d, тело %s", w.Code, w.Body.String())
+	}
+	if resp.Sample[0].EndDate != nil {
+		t.Errorf("пробный запуск изменил подписку: %+v", resp.Sample[0])
+	}
+
+	// Подтверждение с устаревшим числом отклоняется
+	if w := do(router, http.MethodPost, "/subscriptions:bulkUpdate?dry_run=false&expected_count=3&"+filter, `{"end_date":"09-2025"}`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("неверный expected_count: код %d, ожидался 412", w.Code)
+	}
+
+	w = do(router, http.MethodPost, "/subscriptions:bulkUpdate?dry_run=false&expected_count=2&"+filter, `{"end_date":"09-2025"}`)
+	resp = BulkResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.DryRun || resp.Affected != 2 {
+		t.Fatalf("массовое обновление: код %d, тело %s", w.Code, w.Body.String())
+	}
+	w = do(router, http.MethodGet, "/subscriptions?ends_before=10-2025", "")
+	var list ListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || list.Total != 2 {
+		t.Errorf("после массового обновления с end_date: %s", w.Body.String())
+	}
+
+	w = do(router, http.MethodPost, "/subscriptions:bulkDelete?dry_run=false&expected_count=2&"+filter, "")
+	resp = BulkResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Affected != 2 {
+		t.Fatalf("массовое удаление: код %d, тело %s", w.Code, w.Body.String())
+	}
+	w = do(router, http.MethodGet, "/subscriptions", "")
+	list = ListResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || list.Total != 2 {
+		t.Errorf("после массового удаления осталось: %s", w.Body.String())
+	}
+
+	cases := []struct {
+		path, body string
+		code       string
+	}{
+		{"/subscriptions:bulkDelete", "", "filter_required"},
+		{"/subscriptions:bulkDelete?service_name_match=exact", "", "filter_required"},
+		{"/subscriptions:bulkDelete?" + filter + "&dry_run=false", "", "invalid_expected_count"},
+		{"/subscriptions:bulkDelete?" + filter + "&dry_run=maybe", "", "invalid_dry_run"},
+		{"/subscriptions:bulkUpdate?" + filter, `{}`, "validation_failed"},
+		{"/subscriptions:bulkUpdate?" + filter, `{"service_name":"Kion"}`, "validation_failed"},
+		{"/subscriptions:bulkUpdate?" + filter, `{"price":-1}`, "validation_failed"},
+	}
+	for _, tc := range cases {
+		w := do(router, http.MethodPost, tc.path, tc.body)
+		var p Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || w.Code != http.StatusBadRequest || p.Code != tc.code {
+			t.Errorf("POST %s %s: код %d, тело %s, ожидался %s", tc.path, tc.body, w.Code, w.Body.String(), tc.code)
+		}
+	}
+}
+
+func TestImportSubscriptions(t *testing.T) {
+	router := newTestRouter()
+	csv := "service_name,price,user_id,start_date,end_date\n" +
+		"Netflix,500," + testUserID + ",01-2025,\n" +
+		"Okko,300," + testUserID + ",13-2025,\n"
+
+	w := do(router, http.MethodPost, "/subscriptions/import", csv, "Content-Type", "text/csv", "Accept-Language", "en")
+	if w.Code != http.StatusOK {
+		t.Fatalf("импорт: код %d, тело %s", w.Code, w.Body.String())
+	}
+	var report struct {
+		Accepted int `json:"accepted"`
+		Rejected int `json:"rejected"`
+		Errors   []struct {
+			Line   int    `json:"line"`
+			Field  string `json:"field"`
+			Reason string `json:"reason"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil || report.Accepted != 1 || report.Rejected != 1 {
+		t.Fatalf("отчёт: %s", w.Body.String())
+	}
+	if e := report.Errors[0]; e.Line != 3 || e.Field != "start_date" || e.Reason != "MM-YYYY or YYYY-MM-DD expected" {
+		t.Errorf("отклонённая строка: %+v", e)
+	}
+
+	w = do(router, http.MethodPost, "/subscriptions/import", "service_name,cost\n", "Content-Type", "text/csv")
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || w.Code != http.StatusBadRequest || p.Code != "invalid_csv_header" {
+		t.Errorf("неверный заголовок: код %d, тело %s", w.Code, w.Body.String())
+	}
+}
+
+func TestExportSubscriptions(t *testing.T) {
+	router := newTestRouter()
+	// Подписок больше максимального размера страницы списка: выгрузка идёт без пагинации
+	var b strings.Builder
+	n := maxListLimit + 1
+	for i := 0; i < n; i++ {
+		b.WriteString("S" + strconv.Itoa(i) + ",2," + testUserID + ",01-2025,\n")
+	}
+	if w := do(router, http.MethodPost, "/subscriptions/import", b.String(), "Content-Type", "text/csv"); w.Code != http.StatusOK {
+		t.Fatalf("импорт: код %d, тело %s", w.Code, w.Body.String())
+	}
+	// Годовая подписка и подписка в долларах попадают в отдельные итоги
+	for _, body := range []string{
+		`{"service_name":"Y","price":"120","billing_period":"year","user_id":"` + testUserID + `","start_date":"01-2025"}`,
+		`{"service_name":"U","price":"5","currency":"USD","user_id":"` + testUserID + `","start_date":"01-2025"}`,
+	} {
+		if w := do(router, http.MethodPost, "/subscriptions", body); w.Code != http.StatusCreated {
+			t.Fatalf("POST: код %d, тело %s", w.Code, w.Body)
+		}
+	}
+
+	w := do(router, http.MethodGet, "/subscriptions", "", "Accept", "text/csv")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("CSV: код %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1+n+2+3 || records[0][0] != "\ufeffid" || records[0][2] != "price" {
+		t.Fatalf("CSV: %d строк, заголовок %q", len(records), records[0])
+	}
+	wantTotals := [][3]string{{strconv.Itoa(2*n) + ".00", "month", "RUB"}, {"120.00", "year", "RUB"}, {"5.00", "month", "USD"}}
+	for i, want := range wantTotals {
+		total := records[len(records)-3+i]
+		if total[0] != "Итого" || total[2] != want[0] || total[7] != want[1] || total[9] != want[2] {
+			t.Errorf("строка итогов CSV #%d: %q, ожидалось %q", i, total, want)
+		}
+	}
+
+	w = do(router, http.MethodGet, "/subscriptions?service_name=S7&service_name_match=exact", "", "Accept", "application/x-ndjson")
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if w.Code != http.StatusOK || len(lines) != 1 || !strings.Contains(lines[0], `"service_name":"S7"`) {
+		t.Errorf("NDJSON: код %d, тело %s", w.Code, w.Body.String())
+	}
+
+	w = do(router, http.MethodGet, "/subscriptions?service_name=S1&service_name_match=exact", "",
+		"Accept", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "Accept-Language", "en")
+	book, err := excelize.OpenReader(w.Body)
+	if err != nil {
+		t.Fatalf("XLSX: код %d, %v", w.Code, err)
+	}
+	defer book.Close()
+	rows, err := book.GetRows(xlsxSheet)
+	if err != nil || len(rows) != 3 || rows[1][1] != "S1" || rows[2][0] != "Total" || rows[2][2] != "2.00" {
+		t.Errorf("XLSX: %q, %v", rows, err)
+	}
+	if formula, _ := book.GetCellFormula(xlsxSheet, "C3"); formula != `SUMIFS(C2:C2,H2:H2,"month",J2:J2,"RUB")` {
+		t.Errorf("формула итога XLSX: %q", formula)
+	}
+	// Цена — число в точной десятичной записи с денежным форматом
+	if value, _ := book.GetCellValue(xlsxSheet, "C2", excelize.Options{RawCellValue: true}); value != "2.00" {
+		t.Errorf("цена в XLSX: %q", value)
+	}
+	if cellType, _ := book.GetCellType(xlsxSheet, "C2"); cellType != excelize.CellTypeUnset && cellType != excelize.CellTypeNumber {
+		t.Errorf("тип ячейки цены в XLSX: %v", cellType)
+	}
+
+	// Без выбора формата — прежний JSON со страницей
+	w = do(router, http.MethodGet, "/subscriptions?limit=1", "", "Accept", "*/*")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"next_cursor"`) {
+		t.Errorf("JSON: код %d, тело %s", w.Code, w.Body.String())
+	}
+}
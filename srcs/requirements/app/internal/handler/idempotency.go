@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+)
+
+// Заголовки идемпотентных запросов
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+// maxIdempotencyKeyLength — ограничение длины ключа идемпотентности
+const maxIdempotencyKeyLength = 255
+
+// DefaultIdempotencyTTL — время, в течение которого повтор запроса с тем же ключом получает сохранённый ответ
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyLease — время, на которое ключ резервируется за выполняющимся запросом. Если за это время
+// ответ не сохранён (процесс упал), повтор с тем же ключом выполняется заново, а не получает 409.
+// Запрос, чей резерв перешёл к повтору, свой ответ уже не сохраняет.
+const idempotencyLease = time.Minute
+
+// replayedHeaders — заголовки ответа, которые сохраняются вместе с телом и повторяются при replay
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// WithIdempotency — поддерживать заголовок Idempotency-Key: ответы на запросы с ключом
+// сохраняются в store и повторяются для идентичных запросов в течение ttl
+func WithIdempotency(store repository.IdempotencyStore, ttl time.Duration) Option {
+	return func(h *SubscriptionHandler) {
+		h.idempotency = store
+		h.idempotencyTTL = ttl
+		h.idempotencyLease = idempotencyLease
+	}
+}
+
+// Idempotent — middleware для запросов с заголовком Idempotency-Key.
+// Первый запрос с ключом выполняется, и его ответ сохраняется; повтор с тем же ключом и тем же телом
+// получает сохранённый ответ с заголовком Idempotent-Replayed: true, повтор с другим телом — 422,
+// повтор до завершения первого запроса — 409, пока не истёк резерв idempotencyLease.
+// Ответы 5xx не сохраняются, такой запрос можно повторить.
+// Запросы без заголовка и обработчик без WithIdempotency пропускаются без изменений.
+func (h *SubscriptionHandler) Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if h.idempotency == nil || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			log.Printf("Слишком длинный ключ идемпотентности: %d символов", len(key))
+			c.Abort()
+			respondInvalidField(c, "idempotency_key", msgExpectIdempotencyKey, maxIdempotencyKeyLength)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			log.Printf("Ошибка чтения тела запроса: %v", err)
+			c.Abort()
+			respondError(c, http.StatusBadRequest, codeInvalidBody, err.Error())
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		hash := requestHash(c.Request.Method, c.Request.URL.Path, body)
+		now := time.Now()
+		token := uuid.NewString()
+		existing, err := h.idempotency.ReserveIdempotencyKey(ctx, model.IdempotencyRecord{
+			Key:         key,
+			RequestHash: hash,
+			ExpiresAt:   now.Add(h.idempotencyTTL),
+			LeaseUntil:  now.Add(h.idempotencyLease),
+			LeaseToken:  token,
+		})
+		if err != nil {
+			log.Printf("Ошибка при резервировании ключа идемпотентности %q: %v", key, err)
+			c.Abort()
+			respondStoreError(c, err, msgCreateFailed)
+			return
+		}
+
+		if existing != nil {
+			c.Abort()
+			switch {
+			case existing.RequestHash != hash:
+				log.Printf("Ключ идемпотентности %q повторно использован с другим запросом", key)
+				respondError(c, http.StatusUnprocessableEntity, codeIdempotencyKeyReused)
+			case !existing.Completed():
+				log.Printf("Запрос с ключом идемпотентности %q ещё выполняется", key)
+				respondError(c, http.StatusConflict, codeIdempotencyInProgress)
+			default:
+				log.Printf("Повтор сохранённого ответа для ключа идемпотентности %q", key)
+				for name, value := range existing.Headers {
+					c.Header(name, value)
+				}
+				c.Header(idempotencyReplayedHeader, "true")
+				c.Status(existing.Status)
+				_, _ = c.Writer.Write(existing.Body)
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Ответ уже отправлен клиенту, поэтому сохраняем его даже при отмене контекста запроса
+		ctx = context.WithoutCancel(ctx)
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			if err := h.idempotency.ReleaseIdempotencyKey(ctx, key, token); err != nil {
+				log.Printf("Ошибка при снятии резерва ключа идемпотентности %q: %v", key, err)
+			}
+			return
+		}
+		headers := make(map[string]string, len(replayedHeaders))
+		for _, name := range replayedHeaders {
+			if value := c.Writer.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		// Если резерв истёк и перешёл к повтору, сохранённым остаётся ответ повтора
+		if err := h.idempotency.CompleteIdempotencyKey(ctx, key, token, status, headers, recorder.body.Bytes()); err != nil {
+			log.Printf("Ошибка при сохранении ответа для ключа идемпотентности %q: %v", key, err)
+		}
+	}
+}
+
+// requestHash — хеш метода, пути и тела запроса. JSON-тело приводится к каноническому виду,
+// поэтому порядок полей и пробелы не влияют на результат.
+func requestHash(method, path string, body []byte) string {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err == nil {
+		if canonical, err := json.Marshal(v); err == nil {
+			body = canonical
+		}
+	}
+	sum := sha256.New()
+	sum.Write([]byte(method + " " + path + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// responseRecorder — копирует тело ответа, чтобы сохранить его для повторов запроса
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
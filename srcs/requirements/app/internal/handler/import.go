@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"subscription_service/internal/importer"
+	"subscription_service/internal/model"
+)
+
+// ImportSubscriptions godoc
+// @Summary Импортировать подписки из CSV
+// @Description Обработчик POST /subscriptions/import. Принимает CSV с колонками service_name, price, user_id, start_date, end_date
+// @Description (даты в формате MM-YYYY, end_date может быть пустой) в теле запроса (text/csv) или в поле file формы multipart/form-data.
+// @Description Первая строка с колонкой service_name считается заголовком, и колонки в ней могут идти в любом порядке.
+// @Description Строки проверяются по правилам POST /subscriptions и записываются пакетами по мере чтения; неверные строки не прерывают импорт
+// @Description и перечисляются в отчёте с номером строки и причиной. При on_conflict=error существующая подписка отклоняет только свою строку.
+// @Tags subscriptions
+// @Accept text/csv
+// @Accept multipart/form-data
+// @Produce json
+// @Param on_conflict query string false "Поведение при существующей подписке" Enums(error, skip, update) default(error)
+// @Param file formData file false "CSV-файл (для multipart/form-data)"
+// @Success 200 {object} importer.Report "Отчёт об импорте"
+// @Failure 400 {object} Problem "Неверный параметр или заголовок CSV"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера; часть строк могла быть записана"
+// @Router /subscriptions/import [post]
+func (h *SubscriptionHandler) ImportSubscriptions(c *gin.Context) {
+	mode, err := model.ParseConflictMode(c.Query("on_conflict"))
+	if err != nil {
+		log.Printf("Неверный параметр on_conflict: %v", err)
+		respondInvalidField(c, "on_conflict", msgExpectOneOf, "error, skip, update")
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			log.Printf("Не удалось получить файл из формы: %v", err)
+			respondInvalidField(c, "file", msgRequired)
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			log.Printf("Не удалось открыть загруженный файл: %v", err)
+			respondProblem(c, Problem{Status: http.StatusInternalServerError, Code: codeInternal, Detail: msg(c, msgImportFailed)})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	report, err := importer.Import(c.Request.Context(), h.repo, body, importer.Options{
+		Mode:    mode,
+		Message: func(key string, args ...any) string { return msg(c, key, args...) },
+	})
+	if err != nil {
+		var headerErr *importer.HeaderError
+		if errors.As(err, &headerErr) {
+			respondError(c, http.StatusBadRequest, codeInvalidCSVHeader, msg(c, headerErr.Key, headerErr.Column))
+			return
+		}
+		log.Printf("Ошибка импорта подписок: %v (принято строк до ошибки: %d)", err, report.Accepted)
+		respondProblem(c, Problem{Status: http.StatusInternalServerError, Code: codeInternal, Detail: msg(c, msgImportFailed)})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
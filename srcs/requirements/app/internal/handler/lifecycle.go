@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"subscription_service/internal/model"
+)
+
+// CancelRequest — тело запроса отмены подписки
+type CancelRequest struct {
+	// Последний оплачиваемый месяц (MM-YYYY); по умолчанию — текущий месяц
+	EffectiveMonth string `json:"effective_month" format:"MM-YYYY" example:"09-2025"`
+}
+
+// PauseRequest — тело запроса приостановки подписки
+type PauseRequest struct {
+	// Первый месяц приостановки (MM-YYYY); по умолчанию — текущий месяц
+	From string `json:"from" format:"MM-YYYY" example:"08-2025"`
+
+	// Последний месяц приостановки (MM-YYYY); без него подписка приостановлена до возобновления
+	To string `json:"to" format:"MM-YYYY" example:"09-2025"`
+}
+
+// ResumeRequest — тело запроса возобновления подписки
+type ResumeRequest struct {
+	// Первый снова оплачиваемый месяц (MM-YYYY); по умолчанию — текущий месяц
+	Month string `json:"month" format:"MM-YYYY" example:"10-2025"`
+}
+
+// CancelSubscription godoc
+// @Summary Отменить подписку
+// @Description Обработчик POST /subscriptions/:id/cancel. Отменяет подписку: effective_month становится её последним
+// @Description оплачиваемым месяцем (end_date). Приостановки после него отбрасываются. После effective_month подписка
+// @Description получает состояние cancelled. Тело запроса необязательно.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Идентификатор подписки (UUID)"
+// @Param request body CancelRequest false "Месяц отмены"
+// @Param If-Match header string false "ETag изменяемой версии подписки"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 404 {object} Problem "Подписка не найдена"
+// @Failure 409 {object} Problem "Подписка уже отменена или месяц отмены вне подписки"
+// @Failure 412 {object} Problem "Подписка изменилась после получения ETag"
+// @Failure 428 {object} Problem "Не передан обязательный заголовок If-Match"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /subscriptions/{id}/cancel [post]
+func (h *SubscriptionHandler) CancelSubscription(c *gin.Context) {
+	var req CancelRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+	effective, ok := optionalMonth(c, "effective_month", req.EffectiveMonth)
+	if !ok {
+		return
+	}
+
+	cancelledAt := time.Now().UTC()
+	h.transition(c, "отмена", func(sub *model.Subscription) error {
+		return sub.Cancel(effective, cancelledAt)
+	})
+}
+
+// PauseSubscription godoc
+// @Summary Приостановить подписку
+// @Description Обработчик POST /subscriptions/:id/pause. Приостанавливает подписку с месяца from по месяц to включительно;
+// @Description без to — до возобновления (POST /subscriptions/:id/resume). Месяцы приостановки не учитываются
+// @Description в стоимости и разбивке расходов. Тело запроса необязательно.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Идентификатор подписки (UUID)"
+// @Param request body PauseRequest false "Период приостановки"
+// @Param If-Match header string false "ETag изменяемой версии подписки"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 404 {object} Problem "Подписка не найдена"
+// @Failure 409 {object} Problem "Подписка отменена, период вне подписки или пересекается с другой приостановкой"
+// @Failure 412 {object} Problem "Подписка изменилась после получения ETag"
+// @Failure 428 {object} Problem "Не передан обязательный заголовок If-Match"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /subscriptions/{id}/pause [post]
+func (h *SubscriptionHandler) PauseSubscription(c *gin.Context) {
+	var req PauseRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+	from, ok := optionalMonth(c, "from", req.From)
+	if !ok {
+		return
+	}
+	var to *model.MonthYear
+	if req.To != "" {
+		parsed, err := parseMonthYear(req.To)
+		if err != nil {
+			log.Printf("Неверный to в запросе приостановки: %v", err)
+			respondInvalidField(c, "to", msgExpectMonth)
+			return
+		}
+		to = &parsed
+	}
+
+	h.transition(c, "приостановка", func(sub *model.Subscription) error {
+		return sub.Pause(from, to)
+	})
+}
+
+// ResumeSubscription godoc
+// @Summary Возобновить подписку
+// @Description Обработчик POST /subscriptions/:id/resume. Завершает приостановку, в которую попадает month, в предыдущем месяце.
+// @Description Отменённая подписка снова становится бессрочной; месяцы между её последним оплаченным месяцем и month
+// @Description записываются как приостановка. Тело запроса необязательно.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Идентификатор подписки (UUID)"
+// @Param request body ResumeRequest false "Месяц возобновления"
+// @Param If-Match header string false "ETag изменяемой версии подписки"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 404 {object} Problem "Подписка не найдена"
+// @Failure 409 {object} Problem "Подписка не приостановлена и не отменена"
+// @Failure 412 {object} Problem "Подписка изменилась после получения ETag"
+// @Failure 428 {object} Problem "Не передан обязательный заголовок If-Match"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /subscriptions/{id}/resume [post]
+func (h *SubscriptionHandler) ResumeSubscription(c *gin.Context) {
+	var req ResumeRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+	month, ok := optionalMonth(c, "month", req.Month)
+	if !ok {
+		return
+	}
+
+	h.transition(c, "возобновление", func(sub *model.Subscription) error {
+		return sub.Resume(month)
+	})
+}
+
+// transition — общая часть переходов состояния: разбирает id и If-Match,
+// применяет переход в хранилище и отвечает обновлённой подпиской
+func (h *SubscriptionHandler) transition(c *gin.Context, name string, apply func(*model.Subscription) error) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	ifVersion, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.repo.TransitionSubscription(c.Request.Context(), id, ifVersion, apply)
+	if err != nil {
+		log.Printf("Ошибка перехода состояния подписки (%s): %v", name, err)
+		respondStoreError(c, err, msgTransitionFailed)
+		return
+	}
+
+	log.Printf("Состояние подписки изменено (%s): id=%s, состояние %s", name, id, updated.Status)
+	respondSubscription(c, http.StatusOK, updated)
+}
+
+// bindOptionalJSON — разбирает тело запроса в dst, если оно передано.
+// При ошибке сам отвечает клиенту 400 и возвращает false.
+func bindOptionalJSON(c *gin.Context, dst any) bool {
+	if c.Request.ContentLength == 0 {
+		return true
+	}
+	if err := c.ShouldBindJSON(dst); err != nil {
+		log.Printf("Ошибка парсинга тела запроса: %v", err)
+		respondBindError(c, err)
+		return false
+	}
+	return true
+}
+
+// optionalMonth — парсит месяц MM-YYYY из поля field; пустое значение означает текущий месяц.
+// При ошибке сам отвечает клиенту 400 и возвращает false.
+func optionalMonth(c *gin.Context, field, value string) (model.MonthYear, bool) {
+	if value == "" {
+		return model.CurrentMonth(), true
+	}
+	m, err := parseMonthYear(value)
+	if err != nil {
+		log.Printf("Неверный %s в теле запроса: %v", field, err)
+		respondInvalidField(c, field, msgExpectMonth)
+		return m, false
+	}
+	return m, true
+}
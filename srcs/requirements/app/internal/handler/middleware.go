@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"subscription_service/internal/i18n"
+)
+
+// Заголовок и ключ контекста с идентификатором запроса
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+)
+
+// Ключи контекста с каталогом сообщений и выбранным языком
+const (
+	catalogKey = "i18n_catalog"
+	langKey    = "lang"
+)
+
+// defaultCatalog — каталог сообщений, если middleware Localize не подключено
+var defaultCatalog = i18n.NewCatalog(i18n.RU)
+
+// maxRequestIDLength — ограничение длины идентификатора запроса, переданного клиентом
+const maxRequestIDLength = 128
+
+// RequestID — middleware, назначающее каждому запросу идентификатор.
+// Берёт его из заголовка X-Request-ID, если клиент его передал, иначе генерирует UUID,
+// и возвращает в одноимённом заголовке ответа. Идентификатор попадает в поле instance ошибок.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.NewString()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// requestID — идентификатор текущего запроса; если middleware RequestID не подключено, назначает его здесь
+func requestID(c *gin.Context) string {
+	if id := c.GetString(requestIDKey); id != "" {
+		return id
+	}
+	id := uuid.NewString()
+	c.Set(requestIDKey, id)
+	c.Header(requestIDHeader, id)
+	return id
+}
+
+// Localize — middleware, выбирающее язык сообщений API по заголовку Accept-Language.
+// Выбранный язык возвращается в заголовке Content-Language.
+func Localize(catalog *i18n.Catalog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := catalog.Negotiate(c.GetHeader("Accept-Language"))
+		c.Set(catalogKey, catalog)
+		c.Set(langKey, lang)
+		c.Header("Content-Language", string(lang))
+		c.Next()
+	}
+}
+
+// catalog — каталог сообщений текущего запроса
+func catalog(c *gin.Context) *i18n.Catalog {
+	if v, ok := c.Get(catalogKey); ok {
+		return v.(*i18n.Catalog)
+	}
+	return defaultCatalog
+}
+
+// msg — сообщение каталога по ключу key на языке текущего запроса
+func msg(c *gin.Context, key string, args ...any) string {
+	cat := catalog(c)
+	lang, ok := c.Get(langKey)
+	if !ok {
+		return cat.Message(cat.Default(), key, args...)
+	}
+	return cat.Message(lang.(i18n.Lang), key, args...)
+}
@@ -0,0 +1,422 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/shopspring/decimal"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/rates"
+)
+
+// rateSourceManual — источник курсов, переданных в JSON или исправленных вручную
+const rateSourceManual = "manual"
+
+// UploadRates godoc
+// @Summary Загрузить курсы валют
+// @Description Обработчик POST /rates. Принимает курсы в JSON ({"rates": [...]}) или файлом: ежедневный XML ЦБ РФ,
+// @Description CSV ЕЦБ (eurofxref.csv, eurofxref-hist.csv) или CSV с колонками date, currency, rate — в теле запроса
+// @Description или в поле file формы multipart/form-data. Формат файла определяется по содержимому, если не задан format.
+// @Description Курсы сохраняются новыми версиями; курс, совпадающий с последней версией на ту же дату, не сохраняется.
+// @Description recorded_at в ответе можно передать в rates_as_of отчётов, чтобы позже получить те же суммы.
+// @Tags rates
+// @Accept json
+// @Accept text/csv
+// @Accept xml
+// @Accept multipart/form-data
+// @Produce json
+// @Param request body RatesUploadRequest false "Курсы (для application/json)"
+// @Param format query string false "Формат файла курсов" Enums(cbr, ecb, csv)
+// @Param file formData file false "Файл курсов (для multipart/form-data)"
+// @Success 200 {object} RatesUploadResponse "Итоги загрузки"
+// @Failure 400 {object} Problem "Ошибка запроса или неверный файл курсов"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /rates [post]
+func (h *SubscriptionHandler) UploadRates(c *gin.Context) {
+	var list []model.ExchangeRate
+	if c.ContentType() == binding.MIMEJSON {
+		var ok bool
+		if list, ok = h.bindRates(c); !ok {
+			return
+		}
+	} else {
+		format, err := rates.ParseFormat(c.Query("format"))
+		if err != nil {
+			log.Printf("Неверный параметр format: %v", err)
+			respondInvalidField(c, "format", msgExpectOneOf, "cbr, ecb, csv")
+			return
+		}
+
+		var body io.Reader = c.Request.Body
+		if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+			header, err := c.FormFile("file")
+			if err != nil {
+				log.Printf("Не удалось получить файл из формы: %v", err)
+				respondInvalidField(c, "file", msgRequired)
+				return
+			}
+			file, err := header.Open()
+			if err != nil {
+				log.Printf("Не удалось открыть загруженный файл: %v", err)
+				respondProblem(c, Problem{Status: http.StatusInternalServerError, Code: codeInternal, Detail: msg(c, msgRatesSaveFailed)})
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+
+		if list, err = rates.Parse(body, format); err != nil {
+			log.Printf("Неверный файл курсов: %v", err)
+			respondError(c, http.StatusBadRequest, codeInvalidRatesFile, err.Error())
+			return
+		}
+		for _, r := range list {
+			if err := r.Validate(); err != nil {
+				log.Printf("Неверный курс в файле: %v", err)
+				respondError(c, http.StatusBadRequest, codeInvalidRatesFile, err.Error())
+				return
+			}
+		}
+	}
+
+	saved, err := h.rates.SaveRates(c.Request.Context(), list)
+	if err != nil {
+		log.Printf("Ошибка сохранения курсов валют: %v", err)
+		respondStoreError(c, err, msgRatesSaveFailed)
+		return
+	}
+
+	resp := RatesUploadResponse{Received: len(list), Saved: len(saved), Unchanged: len(list) - len(saved)}
+	if len(saved) > 0 {
+		resp.RecordedAt = &saved[0].RecordedAt
+	}
+	log.Printf("Загружены курсы валют: получено %d, сохранено %d", resp.Received, resp.Saved)
+	c.JSON(http.StatusOK, resp)
+}
+
+// bindRates — разбирает и проверяет курсы из JSON-тела POST /rates.
+// При ошибке сам отвечает клиенту 400 и возвращает false; поля называются rates[<индекс>].<поле>.
+func (h *SubscriptionHandler) bindRates(c *gin.Context) ([]model.ExchangeRate, bool) {
+	var input struct {
+		Rates []json.RawMessage `json:"rates" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("Ошибка парсинга тела запроса: %v", err)
+		respondBindError(c, err)
+		return nil, false
+	}
+	if len(input.Rates) == 0 || len(input.Rates) > h.maxBatchSize {
+		log.Printf("Неверное число курсов: %d", len(input.Rates))
+		respondInvalidField(c, "rates", msgExpectBatchSize, h.maxBatchSize)
+		return nil, false
+	}
+
+	list := make([]model.ExchangeRate, 0, len(input.Rates))
+	var fields []FieldError
+	for i, raw := range input.Rates {
+		prefix := fmt.Sprintf("rates[%d].", i)
+
+		var item RateInput
+		err := json.Unmarshal(raw, &item)
+		if err == nil {
+			err = binding.Validator.ValidateStruct(&item)
+		}
+		if err != nil {
+			if itemFields := bindFieldErrors(c, err, prefix); itemFields != nil {
+				fields = append(fields, itemFields...)
+			} else {
+				fields = append(fields, FieldError{Field: strings.TrimSuffix(prefix, "."), Code: "type", Message: msg(c, msgType, "object")})
+			}
+			continue
+		}
+
+		rate, field, key := item.toRate()
+		if rate == nil {
+			fields = append(fields, FieldError{Field: prefix + field, Code: "format", Message: msg(c, key)})
+			continue
+		}
+		list = append(list, *rate)
+	}
+	if len(fields) > 0 {
+		log.Printf("Курсы не прошли валидацию: %d ошибок", len(fields))
+		respondProblem(c, Problem{
+			Status: http.StatusBadRequest,
+			Code:   codeValidationFailed,
+			Detail: msg(c, codeValidationFailed),
+			Errors: fields,
+		})
+		return nil, false
+	}
+	return list, true
+}
+
+// RateInput — курс валюты в теле POST /rates
+type RateInput struct {
+	// Валюта, стоимость единицы которой задаёт курс (ISO 4217)
+	Currency string `json:"currency" binding:"required" example:"USD"`
+
+	// Валюта, в которой выражен курс (по умолчанию RUB)
+	Quote string `json:"quote" example:"RUB"`
+
+	// Дата курса (YYYY-MM-DD)
+	Date string `json:"date" binding:"required" format:"date" example:"2025-03-01"`
+
+	// Стоимость одной единицы currency в quote — строка или число
+	Rate json.RawMessage `json:"rate" binding:"required" swaggertype:"string" example:"89.9865"`
+}
+
+// toRate — проверяет поля курса; при ошибке возвращает nil, имя поля и ключ сообщения
+func (in RateInput) toRate() (*model.ExchangeRate, string, string) {
+	currency, err := model.ParseCurrency(in.Currency)
+	if err != nil {
+		return nil, "currency", msgExpectCurrency
+	}
+	quote, err := model.ParseCurrency(in.Quote)
+	if err != nil || quote == currency {
+		return nil, "quote", msgExpectQuote
+	}
+	if !isFullDate(in.Date) {
+		return nil, "date", msgExpectFullDate
+	}
+	value, ok := parseRate(in.Rate)
+	if !ok {
+		return nil, "rate", msgExpectRate
+	}
+	return &model.ExchangeRate{Currency: currency, Quote: quote, Date: in.Date, Rate: value, Source: rateSourceManual}, "", ""
+}
+
+// parseRate — разбирает положительный курс из строки или числа JSON без преобразования в float
+func parseRate(raw json.RawMessage) (decimal.Decimal, bool) {
+	s := string(raw)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := decimal.NewFromString(s)
+	if err != nil || !v.IsPositive() {
+		return decimal.Decimal{}, false
+	}
+	return v, true
+}
+
+// RatesUploadRequest — тело POST /rates в формате JSON
+type RatesUploadRequest struct {
+	// Курсы валют
+	Rates []RateInput `json:"rates"`
+}
+
+// RatesUploadResponse — итоги загрузки курсов
+type RatesUploadResponse struct {
+	// Число курсов в запросе (повторы пары и даты учитываются один раз при сохранении)
+	Received int `json:"received" example:"43"`
+
+	// Число сохранённых новых версий курсов
+	Saved int `json:"saved" example:"2"`
+
+	// Число курсов, не сохранённых из-за совпадения с последней версией
+	Unchanged int `json:"unchanged" example:"41"`
+
+	// Момент записи новых версий; отсутствует, если ничего не сохранено
+	RecordedAt *time.Time `json:"recorded_at,omitempty" example:"2025-03-01T12:00:00Z"`
+}
+
+// ListRates godoc
+// @Summary Получить курсы валют
+// @Description Обработчик GET /rates. Возвращает курсы по фильтру в порядке валюты, валюты курса и даты.
+// @Description По умолчанию — последняя версия курса на каждую дату; с as_of — версии, записанные не позже этого момента
+// @Description (курсы, по которым считались отчёты в тот момент); с history=true — все версии.
+// @Tags rates
+// @Produce json
+// @Param currency query string false "Валюта курса (ISO 4217)"
+// @Param quote query string false "Валюта, в которой выражен курс (ISO 4217)"
+// @Param from query string false "Дата курса не раньше (YYYY-MM-DD)"
+// @Param to query string false "Дата курса не позже (YYYY-MM-DD)"
+// @Param as_of query string false "Момент, на который известны курсы (RFC 3339)"
+// @Param history query bool false "Вернуть все версии курсов"
+// @Success 200 {object} RatesListResponse "Курсы валют"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /rates [get]
+func (h *SubscriptionHandler) ListRates(c *gin.Context) {
+	var filter model.RateFilter
+	for _, param := range []struct {
+		name string
+		dst  **model.Currency
+	}{{"currency", &filter.Currency}, {"quote", &filter.Quote}} {
+		v, ok := c.GetQuery(param.name)
+		if !ok {
+			continue
+		}
+		currency, err := model.ParseCurrency(v)
+		if err != nil || v == "" {
+			log.Printf("Неверный %s для списка курсов: %q", param.name, v)
+			respondInvalidField(c, param.name, msgExpectCurrency)
+			return
+		}
+		*param.dst = &currency
+	}
+	for _, param := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		v, ok := c.GetQuery(param.name)
+		if !ok {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			log.Printf("Неверный %s для списка курсов: %v", param.name, err)
+			respondInvalidField(c, param.name, msgExpectFullDate)
+			return
+		}
+		*param.dst = &date
+	}
+	asOf, ok := parseAsOf(c, "as_of")
+	if !ok {
+		return
+	}
+	filter.AsOf = asOf
+	if v, ok := c.GetQuery("history"); ok {
+		history, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Printf("Неверный history для списка курсов: %q", v)
+			respondInvalidField(c, "history", msgExpectBool)
+			return
+		}
+		filter.History = history
+	}
+
+	list, err := h.rates.ListRates(c.Request.Context(), filter)
+	if err != nil {
+		log.Printf("Ошибка получения курсов валют: %v", err)
+		respondStoreError(c, err, msgRatesListFailed)
+		return
+	}
+	if list == nil {
+		list = []model.ExchangeRate{}
+	}
+	log.Printf("Получено курсов валют: %d", len(list))
+	c.JSON(http.StatusOK, RatesListResponse{Items: list})
+}
+
+// RatesListResponse — список курсов валют
+type RatesListResponse struct {
+	// Курсы валют
+	Items []model.ExchangeRate `json:"items"`
+}
+
+// CorrectRate godoc
+// @Summary Исправить курс валюты на дату
+// @Description Обработчик PUT /rates/:currency/:date. Сохраняет курс валюты на дату новой версией (источник manual);
+// @Description прежние версии остаются в истории и используются отчётами с rates_as_of до момента исправления.
+// @Description Если курс совпадает с последней версией, новая версия не создаётся и возвращается текущая.
+// @Tags rates
+// @Accept json
+// @Produce json
+// @Param currency path string true "Валюта курса (ISO 4217)"
+// @Param date path string true "Дата курса (YYYY-MM-DD)"
+// @Param request body RateCorrectionRequest true "Новое значение курса"
+// @Success 200 {object} model.ExchangeRate "Текущая версия курса"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /rates/{currency}/{date} [put]
+func (h *SubscriptionHandler) CorrectRate(c *gin.Context) {
+	var input struct {
+		Rate  json.RawMessage `json:"rate" binding:"required"`
+		Quote string          `json:"quote"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("Ошибка парсинга тела запроса: %v", err)
+		respondBindError(c, err)
+		return
+	}
+
+	rate, field, key := RateInput{Currency: c.Param("currency"), Quote: input.Quote, Date: c.Param("date"), Rate: input.Rate}.toRate()
+	if rate == nil {
+		log.Printf("Неверный %s исправляемого курса", field)
+		respondInvalidField(c, field, key)
+		return
+	}
+
+	ctx := c.Request.Context()
+	saved, err := h.rates.SaveRates(ctx, []model.ExchangeRate{*rate})
+	if err != nil {
+		log.Printf("Ошибка сохранения курса валюты: %v", err)
+		respondStoreError(c, err, msgRatesSaveFailed)
+		return
+	}
+	if len(saved) == 1 {
+		log.Printf("Исправлен курс %s/%s на %s: %s", rate.Currency, rate.Quote, rate.Date, rate.Rate)
+		c.JSON(http.StatusOK, saved[0])
+		return
+	}
+
+	// Курс не изменился — возвращаем текущую версию
+	date, _ := time.Parse("2006-01-02", rate.Date)
+	current, err := h.rates.ListRates(ctx, model.RateFilter{Currency: &rate.Currency, Quote: &rate.Quote, From: &date, To: &date})
+	if err != nil || len(current) != 1 {
+		log.Printf("Ошибка получения текущего курса валюты: %v", err)
+		respondStoreError(c, err, msgRatesListFailed)
+		return
+	}
+	c.JSON(http.StatusOK, current[0])
+}
+
+// RateCorrectionRequest — тело PUT /rates/:currency/:date
+type RateCorrectionRequest struct {
+	// Стоимость одной единицы валюты в quote — строка или число
+	Rate string `json:"rate" example:"90.1234"`
+
+	// Валюта, в которой выражен курс (по умолчанию RUB)
+	Quote string `json:"quote" example:"RUB"`
+}
+
+// parseAsOf — разбирает необязательный параметр момента времени (RFC 3339) с именем name.
+// При ошибке сам отвечает клиенту 400 и возвращает false.
+func parseAsOf(c *gin.Context, name string) (*time.Time, bool) {
+	v, ok := c.GetQuery(name)
+	if !ok {
+		return nil, true
+	}
+	asOf, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		log.Printf("Неверный %s: %v", name, err)
+		respondInvalidField(c, name, msgExpectTimestamp)
+		return nil, false
+	}
+	return &asOf, true
+}
+
+// costRates — курсы для пересчёта сумм отчёта за месяцы from..to: последние версии, записанные не позже asOf
+// (nil — текущие), с журналом использованных курсов. Курсы валюты читаются из хранилища при первом пересчёте
+// из неё или в неё и только за период отчёта (с курсом, действующим на его начало).
+// Без хранилища курсов возвращает nil — пересчёт между валютами невозможен.
+func (h *SubscriptionHandler) costRates(ctx context.Context, asOf *time.Time, from, to model.MonthYear) *model.RateLog {
+	if h.rates == nil {
+		return nil
+	}
+	since, until := from.StartOfMonth().ToTime(), to.StartOfMonth().ToTime()
+	return model.NewRateLog(rates.NewLazy(func(currency model.Currency) ([]model.ExchangeRate, error) {
+		var list []model.ExchangeRate
+		for _, filter := range []model.RateFilter{
+			{Currency: &currency, Since: &since, To: &until, AsOf: asOf},
+			{Quote: &currency, Since: &since, To: &until, AsOf: asOf},
+		} {
+			part, err := h.rates.ListRates(ctx, filter)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, part...)
+		}
+		return list, nil
+	}))
+}
@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"subscription_service/internal/model"
+)
+
+// parseID — парсит идентификатор подписки из пути /subscriptions/:id.
+// При ошибке сам отвечает клиенту 400 и возвращает false.
+func parseID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		log.Printf("Неверный id подписки в URL: %v", err)
+		respondInvalidField(c, "id", msgExpectUUID)
+		return id, false
+	}
+	return id, true
+}
+
+// GetSubscriptionByID godoc
+// @Summary Получить подписку по id
+// @Description Обработчик GET /subscriptions/:id. Получает подписку по её идентификатору
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "Идентификатор подписки (UUID)"
+// @Param If-None-Match header string false "ETag известной клиенту версии подписки"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Версия подписки"
+// @Success 304 "Подписка не изменилась"
+// @Failure 400 {object} Problem "Неверный id"
+// @Failure 404 {object} Problem "Подписка не найдена"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /subscriptions/{id} [get]
+func (h *SubscriptionHandler) GetSubscriptionByID(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	sub, err := h.repo.GetSubscriptionByID(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("Ошибка получения подписки: %v", err)
+		respondStoreError(c, err, msgGetFailed)
+		return
+	}
+
+	if notModified(c, etag(sub.Version)) {
+		return
+	}
+	respondSubscription(c, http.StatusOK, sub)
+}
+
+// ReplaceSubscriptionByID godoc
+// @Summary Заменить подписку по id
+// @Description Обработчик PUT /subscriptions/:id. Полностью заменяет данные подписки, включая сервис, пользователя и дату начала.
+// @Description Отсутствующий end_date означает бессрочную подписку.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Идентификатор подписки (UUID)"
+// @Param subscription body SubscriptionRequest true "Новые данные подписки"
+// @Param If-Match header string false "ETag изменяемой версии подписки"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 404 {object} Problem "Подписка не найдена"
+// @Failure 409 {object} Problem "Подписка с таким составным ключом уже существует"
+// @Failure 412 {object} Problem "Подписка изменилась после получения ETag"
+// @Failure 428 {object} Problem "Не передан обязательный заголовок If-Match"
+// @Failure 422 {object} Problem "Данные нарушают ограничения (например, отрицательная цена)"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /subscriptions/{id} [put]
+func (h *SubscriptionHandler) ReplaceSubscriptionByID(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	sub, ok := bindSubscription(c)
+	if !ok {
+		return
+	}
+	sub.ID = id
+
+	ifVersion, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.repo.ReplaceSubscription(c.Request.Context(), sub, ifVersion)
+	if err != nil {
+		log.Printf("Ошибка замены подписки: %v", err)
+		respondStoreError(c, err, msgUpdateFailed)
+		return
+	}
+
+	log.Printf("Подписка заменена: id=%s", id)
+	respondSubscription(c, http.StatusOK, updated)
+}
+
+// PatchSubscriptionByID godoc
+// @Summary Частично обновить подписку по id
+// @Description Обработчик PATCH /subscriptions/:id (JSON Merge Patch). Изменяются только переданные поля:
+// @Description service_name, price, currency, billing_period, start_date, billing_day, end_date. Значение null у end_date делает подписку бессрочной.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Идентификатор подписки (UUID)"
+// @Param patch body SubscriptionPatchRequest true "Изменяемые поля"
+// @Param If-Match header string false "ETag изменяемой версии подписки"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} Problem "Ошибка запроса"
+// @Failure 404 {object} Problem "Подписка не найдена"
+// @Failure 409 {object} Problem "Подписка с таким составным ключом уже существует"
+// @Failure 412 {object} Problem "Подписка изменилась после получения ETag"
+// @Failure 428 {object} Problem "Не передан обязательный заголовок If-Match"
+// @Failure 422 {object} Problem "Данные нарушают ограничения (например, отрицательная цена)"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /subscriptions/{id} [patch]
+func (h *SubscriptionHandler) PatchSubscriptionByID(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	patch, ok := bindPatch(c)
+	if !ok {
+		return
+	}
+
+	ifVersion, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.repo.PatchSubscription(c.Request.Context(), id, patch, ifVersion)
+	if err != nil {
+		log.Printf("Ошибка частичного обновления подписки: %v", err)
+		respondStoreError(c, err, msgUpdateFailed)
+		return
+	}
+
+	log.Printf("Подписка частично обновлена: id=%s", id)
+	respondSubscription(c, http.StatusOK, updated)
+}
+
+// DeleteSubscriptionByID godoc
+// @Summary Удалить подписку по id
+// @Description Обработчик DELETE /subscriptions/:id. Удаляет подписку по её идентификатору
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "Идентификатор подписки (UUID)"
+// @Param If-Match header string false "ETag удаляемой версии подписки"
+// @Success 204 "Подписка удалена"
+// @Failure 400 {object} Problem "Неверный id"
+// @Failure 404 {object} Problem "Подписка не найдена"
+// @Failure 412 {object} Problem "Подписка изменилась после получения ETag"
+// @Failure 428 {object} Problem "Не передан обязательный заголовок If-Match"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /subscriptions/{id} [delete]
+func (h *SubscriptionHandler) DeleteSubscriptionByID(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	ifVersion, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
+	_, err := h.repo.DeleteSubscriptionByID(c.Request.Context(), id, ifVersion)
+	if err != nil {
+		log.Printf("Ошибка удаления подписки: %v", err)
+		respondStoreError(c, err, msgDeleteFailed)
+		return
+	}
+
+	log.Printf("Подписка удалена: id=%s", id)
+	c.Status(http.StatusNoContent)
+}
+
+// SubscriptionPatchRequest — тело запроса частичного обновления подписки (JSON Merge Patch).
+// Отсутствующие поля не изменяются.
+type SubscriptionPatchRequest struct {
+	// Новое название сервиса
+	ServiceName *string `json:"service_name,omitempty" example:"Netflix"`
+
+	// Новая цена за период оплаты (строка с двумя знаками после точки или число)
+	Price *string `json:"price,omitempty" example:"1099.00"`
+
+	// Новая валюта цены (ISO 4217)
+	Currency *string `json:"currency,omitempty" example:"USD"`
+
+	// Новый период оплаты: week, month, quarter, year или Nm
+	BillingPeriod *string `json:"billing_period,omitempty" example:"year"`
+
+	// Новая дата начала (MM-YYYY или YYYY-MM-DD)
+	StartDate *string `json:"start_date,omitempty" format:"MM-YYYY" example:"08-2025"`
+
+	// Новый день месяца списаний (1–31): дата начала переносится на этот день того же месяца
+	BillingDay *int `json:"billing_day,omitempty" example:"15"`
+
+	// Новая дата окончания (MM-YYYY); null — подписка становится бессрочной
+	EndDate *string `json:"end_date,omitempty" format:"MM-YYYY" example:"12-2025"`
+}
+
+// bindPatch — разбирает тело JSON Merge Patch в model.SubscriptionPatch.
+// Отличает отсутствующее поле от явного null (null допустим только для end_date).
+// При ошибке сам отвечает клиенту 400 и возвращает false.
+func bindPatch(c *gin.Context) (model.SubscriptionPatch, bool) {
+	var patch model.SubscriptionPatch
+
+	var fields map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&fields); err != nil {
+		log.Printf("Ошибка парсинга тела запроса на частичное обновление: %v", err)
+		respondProblem(c, Problem{Status: http.StatusBadRequest, Code: codeInvalidBody, Detail: msg(c, msgBodyNotObject)})
+		return patch, false
+	}
+
+	fail := func(field, key string) (model.SubscriptionPatch, bool) {
+		message := msg(c, key)
+		log.Printf("Неверное поле %s в частичном обновлении: %s", field, message)
+		rule := "format"
+		if key == msgReadOnly {
+			rule = "read_only"
+		}
+		respondProblem(c, Problem{
+			Status: http.StatusBadRequest,
+			Code:   codeValidationFailed,
+			Detail: msg(c, msgInvalidField, field, message),
+			Errors: []FieldError{{Field: field, Code: rule, Message: message}},
+		})
+		return patch, false
+	}
+
+	fullStart := false
+	for name, raw := range fields {
+		isNull := bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+		switch name {
+		case "service_name":
+			var v string
+			if isNull || json.Unmarshal(raw, &v) != nil || v == "" {
+				return fail(name, msgExpectNonEmptyString)
+			}
+			patch.ServiceName = &v
+		case "price":
+			v, ok := parsePrice(raw)
+			if !ok || v < 0 {
+				return fail(name, msgExpectMoney)
+			}
+			patch.Price = &v
+		case "currency":
+			var v string
+			if isNull || json.Unmarshal(raw, &v) != nil || v == "" {
+				return fail(name, msgExpectCurrency)
+			}
+			currency, err := model.ParseCurrency(v)
+			if err != nil {
+				return fail(name, msgExpectCurrency)
+			}
+			patch.Currency = &currency
+		case "billing_period":
+			var v string
+			if isNull || json.Unmarshal(raw, &v) != nil {
+				return fail(name, msgExpectBillingPeriod)
+			}
+			period, err := model.ParseBillingPeriod(v)
+			if err != nil || v == "" {
+				return fail(name, msgExpectBillingPeriod)
+			}
+			patch.BillingPeriod = &period
+		case "start_date":
+			var v string
+			if isNull || json.Unmarshal(raw, &v) != nil {
+				return fail(name, msgExpectDate)
+			}
+			sd, err := parseDate(v)
+			if err != nil {
+				return fail(name, msgExpectDate)
+			}
+			patch.StartDate = &sd
+			fullStart = isFullDate(v)
+		case "billing_day":
+			var v int
+			if isNull || json.Unmarshal(raw, &v) != nil || v < 1 || v > 31 {
+				return fail(name, msgExpectBillingDay)
+			}
+			patch.BillingDay = &v
+		case "end_date":
+			if isNull {
+				patch.ClearEndDate = true
+				continue
+			}
+			var v string
+			if json.Unmarshal(raw, &v) != nil {
+				return fail(name, msgExpectMonthOrNull)
+			}
+			ed, err := parseMonthYear(v)
+			if err != nil {
+				return fail(name, msgExpectMonthOrNull)
+			}
+			patch.EndDate = &ed
+		default:
+			return fail(name, msgReadOnly)
+		}
+	}
+	if fullStart && patch.BillingDay != nil && patch.StartDate.Day() != *patch.BillingDay {
+		return fail("billing_day", msgBillingDayMismatch)
+	}
+	return patch, true
+}
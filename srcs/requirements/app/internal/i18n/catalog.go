@@ -0,0 +1,105 @@
+// Package i18n — каталог сообщений API на русском и английском языках
+// и выбор языка по заголовку Accept-Language.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lang — язык сообщений
+type Lang string
+
+// Поддерживаемые языки
+const (
+	RU Lang = "ru"
+	EN Lang = "en"
+)
+
+// ParseLang — разбирает код языка ("ru", "en", "en-US" и т.п.); возвращает ошибку для неподдерживаемых языков
+func ParseLang(s string) (Lang, error) {
+	primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "-")
+	switch Lang(primary) {
+	case RU, EN:
+		return Lang(primary), nil
+	}
+	return "", fmt.Errorf("неподдерживаемый язык %q, допустимые значения: ru, en", s)
+}
+
+// Catalog — каталог сообщений с языком по умолчанию
+type Catalog struct {
+	def Lang
+}
+
+// NewCatalog — конструктор каталога; def — язык, если клиент не указал поддерживаемый
+func NewCatalog(def Lang) *Catalog {
+	return &Catalog{def: def}
+}
+
+// Default — язык каталога по умолчанию
+func (c *Catalog) Default() Lang {
+	return c.def
+}
+
+// Negotiate — выбирает язык по значению заголовка Accept-Language с учётом весов q.
+// Если ни один из перечисленных языков не поддерживается, возвращает язык по умолчанию.
+func (c *Catalog) Negotiate(acceptLanguage string) Lang {
+	type candidate struct {
+		lang Lang
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		if strings.TrimSpace(tag) == "*" {
+			candidates = append(candidates, candidate{c.def, q})
+			continue
+		}
+		if lang, err := ParseLang(tag); err == nil {
+			candidates = append(candidates, candidate{lang, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return c.def
+	}
+	// При равных весах побеждает язык, указанный в заголовке раньше
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// Has — есть ли в каталоге сообщение с ключом key
+func (c *Catalog) Has(key string) bool {
+	_, ok := messages[key]
+	return ok
+}
+
+// Message — сообщение по ключу на языке lang; args подставляются в шаблон через fmt.Sprintf.
+// Если перевода нет, используется язык по умолчанию, затем русский; для неизвестного ключа возвращается сам ключ.
+func (c *Catalog) Message(lang Lang, key string, args ...any) string {
+	translations, ok := messages[key]
+	if !ok {
+		return key
+	}
+	for _, l := range []Lang{lang, c.def, RU} {
+		if tmpl, ok := translations[l]; ok {
+			if len(args) == 0 {
+				return tmpl
+			}
+			return fmt.Sprintf(tmpl, args...)
+		}
+	}
+	return key
+}
@@ -0,0 +1,58 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		def    Lang
+		header string
+		want   Lang
+	}{
+		{RU, "", RU},
+		{EN, "", EN},
+		{RU, "en", EN},
+		{RU, "en-GB,en;q=0.8", EN},
+		{EN, "ru-RU", RU},
+		{RU, "de, en;q=0.7, ru;q=0.5", EN},
+		{RU, "en;q=0.3, ru;q=0.9", RU},
+		{RU, "en;q=0", RU},
+		{EN, "fr, *;q=0.1", EN},
+		{RU, "en;q=abc", RU},
+	}
+	for _, tt := range tests {
+		if got := NewCatalog(tt.def).Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) с языком по умолчанию %s = %s, ожидалось %s", tt.header, tt.def, got, tt.want)
+		}
+	}
+}
+
+func TestMessagesTranslated(t *testing.T) {
+	verbs := regexp.MustCompile(`%[a-z]`)
+	for key, translations := range messages {
+		ru, okRU := translations[RU]
+		en, okEN := translations[EN]
+		if !okRU || !okEN {
+			t.Errorf("Сообщение %q переведено не на все языки", key)
+			continue
+		}
+		if a, b := verbs.FindAllString(ru, -1), verbs.FindAllString(en, -1); len(a) != len(b) {
+			t.Errorf("Сообщение %q: разное число аргументов в переводах (%v и %v)", key, a, b)
+		}
+	}
+}
+
+func TestMessageFallback(t *testing.T) {
+	c := NewCatalog(EN)
+	if got := c.Message(RU, "period_too_long", 120); got != "период не может превышать 120 месяцев" {
+		t.Errorf("Message(ru) = %q", got)
+	}
+	if got := c.Message(Lang("de"), "not_found"); got != "subscription not found" {
+		t.Errorf("Message(de) = %q, ожидался перевод на язык по умолчанию", got)
+	}
+	if got := c.Message(RU, "unknown_key"); got != "unknown_key" {
+		t.Errorf("Message для неизвестного ключа = %q", got)
+	}
+}
@@ -0,0 +1,496 @@
+package i18n
+
+// messages — переводы сообщений API. Ключ — код ошибки из ответа (not_found, invalid_period, ...),
+// заголовок ошибки (title.<код>) или ключ отдельного сообщения. Шаблоны обоих языков
+// должны принимать одинаковые аргументы.
+var messages = map[string]map[Lang]string{
+	// Заголовки ошибок
+	"title.not_found": {
+		RU: "Подписка не найдена",
+		EN: "Subscription not found",
+	},
+	"title.already_exists": {
+		RU: "Подписка уже существует",
+		EN: "Subscription already exists",
+	},
+	"title.invalid_subscription": {
+		RU: "Данные подписки нарушают ограничения",
+		EN: "Subscription data violates constraints",
+	},
+	"title.internal_error": {
+		RU: "Внутренняя ошибка сервера",
+		EN: "Internal server error",
+	},
+	"title.invalid_body": {
+		RU: "Тело запроса не удалось разобрать",
+		EN: "Malformed request body",
+	},
+	"title.validation_failed": {
+		RU: "Ошибка валидации запроса",
+		EN: "Request validation failed",
+	},
+	"title.invalid_price_range": {
+		RU: "Неверный диапазон цен",
+		EN: "Invalid price range",
+	},
+	"title.invalid_period": {
+		RU: "Неверный период",
+		EN: "Invalid period",
+	},
+	"title.cursor_with_offset": {
+		RU: "Несовместимые параметры пагинации",
+		EN: "Conflicting pagination parameters",
+	},
+	"title.precondition_failed": {
+		RU: "Подписка была изменена",
+		EN: "Subscription has been modified",
+	},
+	"title.precondition_required": {
+		RU: "Требуется заголовок If-Match",
+		EN: "If-Match header required",
+	},
+	"title.invalid_if_match": {
+		RU: "Неверный заголовок If-Match",
+		EN: "Invalid If-Match header",
+	},
+	"title.idempotency_key_reused": {
+		RU: "Ключ идемпотентности уже использован",
+		EN: "Idempotency key already used",
+	},
+	"title.idempotency_in_progress": {
+		RU: "Запрос с этим ключом идемпотентности ещё выполняется",
+		EN: "Request with this idempotency key is in progress",
+	},
+	"title.unknown_action": {
+		RU: "Неизвестное действие",
+		EN: "Unknown action",
+	},
+	"title.filter_required": {
+		RU: "Не задан фильтр",
+		EN: "Filter required",
+	},
+	"title.count_mismatch": {
+		RU: "Число подписок изменилось",
+		EN: "Number of subscriptions changed",
+	},
+	"title.invalid_csv_header": {
+		RU: "Неверный заголовок CSV",
+		EN: "Invalid CSV header",
+	},
+	"title.export_too_large": {
+		RU: "Выгрузка слишком велика",
+		EN: "Export too large",
+	},
+	"title.invalid_transition": {
+		RU: "Недопустимое изменение состояния подписки",
+		EN: "Invalid subscription state transition",
+	},
+	"title.rate_unavailable": {
+		RU: "Нет курса валюты",
+		EN: "Exchange rate unavailable",
+	},
+	"title.invalid_rates_file": {
+		RU: "Неверный файл курсов",
+		EN: "Invalid exchange rates file",
+	},
+	"title.invalid_field": {
+		RU: "Неверное значение %s",
+		EN: "Invalid value of %s",
+	},
+	"title.unknown": {
+		RU: "Ошибка запроса",
+		EN: "Request error",
+	},
+
+	// Описания ошибок по коду
+	"not_found": {
+		RU: "подписка не найдена",
+		EN: "subscription not found",
+	},
+	"already_exists": {
+		RU: "подписка с такими user_id, service_name и start_date уже существует",
+		EN: "a subscription with the same user_id, service_name and start_date already exists",
+	},
+	"invalid_subscription": {
+		RU: "данные подписки нарушают ограничения хранилища: %s",
+		EN: "subscription data violates storage constraints: %s",
+	},
+	"invalid_body": {
+		RU: "не удалось разобрать тело запроса: %s",
+		EN: "failed to parse request body: %s",
+	},
+	"validation_failed": {
+		RU: "запрос не прошёл валидацию",
+		EN: "request validation failed",
+	},
+	"invalid_price_range": {
+		RU: "min_price не может быть больше max_price",
+		EN: "min_price must not be greater than max_price",
+	},
+	"cursor_with_offset": {
+		RU: "cursor и offset нельзя использовать одновременно",
+		EN: "cursor and offset cannot be used together",
+	},
+	"internal_error": {
+		RU: "внутренняя ошибка сервера",
+		EN: "internal server error",
+	},
+	"precondition_failed": {
+		RU: "подписка изменена другим запросом: её версия не совпадает с If-Match",
+		EN: "subscription was modified by another request: its version does not match If-Match",
+	},
+	"precondition_required": {
+		RU: "для изменения подписки передайте её ETag в заголовке If-Match",
+		EN: "pass the subscription ETag in the If-Match header to modify it",
+	},
+	"idempotency_key_reused": {
+		RU: "ключ Idempotency-Key уже использован для запроса с другим телом",
+		EN: "the Idempotency-Key has already been used for a request with a different body",
+	},
+	"idempotency_in_progress": {
+		RU: "запрос с тем же Idempotency-Key ещё выполняется, повторите позже",
+		EN: "a request with the same Idempotency-Key is still in progress, retry later",
+	},
+	"unknown_action": {
+		RU: "неизвестное действие над подписками: %s",
+		EN: "unknown subscriptions action: %s",
+	},
+	"filter_required": {
+		RU: "массовая операция требует хотя бы одного фильтра",
+		EN: "a bulk operation requires at least one filter",
+	},
+	"count_mismatch": {
+		RU: "число подписок, подходящих под фильтр, не совпадает с expected_count; повторите пробный запуск",
+		EN: "the number of subscriptions matching the filter differs from expected_count; repeat the dry run",
+	},
+	"invalid_csv_header": {
+		RU: "неверный заголовок CSV: %s",
+		EN: "invalid CSV header: %s",
+	},
+	"export_too_large": {
+		RU: "в лист XLSX помещается не больше %d подписок, под фильтр подходит %d: сузьте фильтр или выберите CSV",
+		EN: "an XLSX sheet holds at most %d subscriptions, the filter matches %d: narrow the filter or choose CSV",
+	},
+	"invalid_transition": {
+		RU: "недопустимое изменение состояния подписки: %s",
+		EN: "invalid subscription state transition: %s",
+	},
+	"rate_unavailable": {
+		RU: "не удалось пересчитать суммы в валюту запроса: %s",
+		EN: "failed to convert amounts to the requested currency: %s",
+	},
+	"constraint_violated": {
+		RU: "нарушено ограничение %s",
+		EN: "constraint %s violated",
+	},
+	"value_out_of_range": {
+		RU: "значение вне допустимого диапазона",
+		EN: "value out of range",
+	},
+	"date_out_of_range": {
+		RU: "дата вне допустимого диапазона",
+		EN: "date out of range",
+	},
+	"bulk_key_change": {
+		RU: "массовое обновление не меняет service_name, start_date и billing_day",
+		EN: "bulk update does not change service_name, start_date or billing_day",
+	},
+	"transition_already_cancelled": {
+		RU: "подписка уже отменена",
+		EN: "subscription is already cancelled",
+	},
+	"transition_cancel_before_start": {
+		RU: "месяц отмены раньше начала подписки",
+		EN: "cancellation month is before the subscription start",
+	},
+	"transition_cancel_after_end": {
+		RU: "подписка заканчивается раньше месяца отмены",
+		EN: "subscription ends before the cancellation month",
+	},
+	"transition_pause_cancelled": {
+		RU: "отменённую подписку нельзя приостановить",
+		EN: "a cancelled subscription cannot be paused",
+	},
+	"transition_pause_before_start": {
+		RU: "приостановка начинается раньше подписки",
+		EN: "pause starts before the subscription",
+	},
+	"transition_pause_reversed": {
+		RU: "приостановка заканчивается раньше, чем начинается",
+		EN: "pause ends before it starts",
+	},
+	"transition_pause_after_end": {
+		RU: "приостановка выходит за дату окончания подписки",
+		EN: "pause extends past the subscription end date",
+	},
+	"transition_pause_overlap": {
+		RU: "пересекается с приостановкой с %s",
+		EN: "overlaps the pause starting %s",
+	},
+	"transition_not_paused": {
+		RU: "подписка не приостановлена в %s",
+		EN: "subscription is not paused in %s",
+	},
+	"no_rate": {
+		RU: "нет курса %s на %s",
+		EN: "no %s exchange rate for %s",
+	},
+	"rates_not_loaded": {
+		RU: "курсы не загружены (%s → %s)",
+		EN: "exchange rates are not loaded (%s → %s)",
+	},
+	"invalid_rates_file": {
+		RU: "не удалось разобрать курсы валют: %s",
+		EN: "failed to parse exchange rates: %s",
+	},
+	"invalid_field": {
+		RU: "неверное значение %s: %s",
+		EN: "invalid value of %s: %s",
+	},
+
+	// Уточнения описаний
+	"body_not_object": {
+		RU: "тело запроса должно быть JSON-объектом",
+		EN: "request body must be a JSON object",
+	},
+	"period_reversed": {
+		RU: "to_date не может быть раньше from_date",
+		EN: "to_date must not be earlier than from_date",
+	},
+	"period_too_long": {
+		RU: "период не может превышать %d месяцев",
+		EN: "period must not exceed %d months",
+	},
+
+	// Ошибки отдельных полей
+	"required": {
+		RU: "обязательное поле",
+		EN: "field is required",
+	},
+	"rule": {
+		RU: "нарушено правило %s",
+		EN: "violates rule %s",
+	},
+	"type": {
+		RU: "ожидается значение типа %s",
+		EN: "value of type %s expected",
+	},
+	"read_only": {
+		RU: "поле нельзя изменить",
+		EN: "field cannot be changed",
+	},
+	"expect_uuid": {
+		RU: "ожидается UUID",
+		EN: "UUID expected",
+	},
+	"expect_month": {
+		RU: "ожидается MM-YYYY",
+		EN: "MM-YYYY expected",
+	},
+	"expect_date": {
+		RU: "ожидается MM-YYYY или YYYY-MM-DD",
+		EN: "MM-YYYY or YYYY-MM-DD expected",
+	},
+	"expect_full_date": {
+		RU: "ожидается YYYY-MM-DD",
+		EN: "YYYY-MM-DD expected",
+	},
+	"expect_billing_day": {
+		RU: "ожидается число от 1 до 31",
+		EN: "number from 1 to 31 expected",
+	},
+	"billing_day_mismatch": {
+		RU: "не совпадает с днём start_date",
+		EN: "does not match the day of start_date",
+	},
+	"expect_month_or_null": {
+		RU: "ожидается MM-YYYY или null",
+		EN: "MM-YYYY or null expected",
+	},
+	"expect_billing_period": {
+		RU: "ожидается week, month, quarter, year или Nm (N от 1 до 120)",
+		EN: "week, month, quarter, year or Nm (N from 1 to 120) expected",
+	},
+	"expect_currency": {
+		RU: "ожидается код валюты ISO 4217, например RUB",
+		EN: "ISO 4217 currency code expected, e.g. RUB",
+	},
+	"expect_non_empty_string": {
+		RU: "ожидается непустая строка",
+		EN: "non-empty string expected",
+	},
+	"expect_money": {
+		RU: "ожидается неотрицательная сумма не более чем с двумя знаками после точки, например 199.99",
+		EN: "non-negative amount with at most two decimal places expected, e.g. 199.99",
+	},
+	"expect_rate": {
+		RU: "ожидается положительное число, например 89.9865",
+		EN: "positive number expected, e.g. 89.9865",
+	},
+	"expect_quote": {
+		RU: "ожидается код валюты ISO 4217, отличный от валюты курса",
+		EN: "ISO 4217 currency code other than the rate currency expected",
+	},
+	"expect_timestamp": {
+		RU: "ожидается дата и время в формате RFC 3339, например 2025-04-01T00:00:00Z",
+		EN: "RFC 3339 timestamp expected, e.g. 2025-04-01T00:00:00Z",
+	},
+	"expect_non_negative_int": {
+		RU: "ожидается неотрицательное целое число",
+		EN: "non-negative integer expected",
+	},
+	"expect_limit": {
+		RU: "ожидается число от 1 до %d",
+		EN: "number from 1 to %d expected",
+	},
+	"expect_bool": {
+		RU: "ожидается true или false",
+		EN: "true or false expected",
+	},
+	"expect_one_of": {
+		RU: "допустимые значения: %s",
+		EN: "allowed values: %s",
+	},
+	"expect_sort": {
+		RU: "допустимые значения: price, start_date, service_name (с префиксом '-' для убывания)",
+		EN: "allowed values: price, start_date, service_name (prefix '-' for descending order)",
+	},
+	"expect_etag": {
+		RU: "ожидается ETag подписки в кавычках или *",
+		EN: "quoted subscription ETag or * expected",
+	},
+	"expect_idempotency_key": {
+		RU: "ожидается строка длиной не более %d символов",
+		EN: "string of at most %d characters expected",
+	},
+	"expect_batch_size": {
+		RU: "ожидается от 1 до %d подписок",
+		EN: "from 1 to %d subscriptions expected",
+	},
+	"batch_conflict": {
+		RU: "часть подписок пакета уже существует, пакет не сохранён; используйте on_conflict=skip или on_conflict=update",
+		EN: "some subscriptions of the batch already exist, nothing was saved; use on_conflict=skip or on_conflict=update",
+	},
+	"expect_expected_count": {
+		RU: "при dry_run=false ожидается неотрицательное число подписок из пробного запуска",
+		EN: "non-negative number of subscriptions from the dry run expected when dry_run=false",
+	},
+	"bulk_patch_empty": {
+		RU: "укажите price или end_date",
+		EN: "specify price or end_date",
+	},
+	"expect_month_or_empty": {
+		RU: "ожидается MM-YYYY или пустое значение",
+		EN: "MM-YYYY or empty value expected",
+	},
+	"csv_column_count": {
+		RU: "ожидается колонок: %d, получено: %d",
+		EN: "%d columns expected, got %d",
+	},
+	"csv_malformed": {
+		RU: "строка CSV повреждена: %v",
+		EN: "malformed CSV line: %v",
+	},
+	"csv_unknown_column": {
+		RU: "неизвестная колонка %q",
+		EN: "unknown column %q",
+	},
+	"csv_missing_column": {
+		RU: "нет обязательной колонки %s",
+		EN: "required column %s is missing",
+	},
+	"csv_duplicate_column": {
+		RU: "колонка %s указана несколько раз",
+		EN: "column %s is listed more than once",
+	},
+	"cursor_malformed": {
+		RU: "курсор повреждён",
+		EN: "malformed cursor",
+	},
+	"cursor_sort_mismatch": {
+		RU: "курсор выдан для другой сортировки",
+		EN: "cursor was issued for a different sort order",
+	},
+
+	// Внутренние ошибки по операциям
+	"create_failed": {
+		RU: "не удалось создать подписку",
+		EN: "failed to create subscription",
+	},
+	"get_failed": {
+		RU: "не удалось получить подписку",
+		EN: "failed to get subscription",
+	},
+	"update_failed": {
+		RU: "не удалось обновить подписку",
+		EN: "failed to update subscription",
+	},
+	"delete_failed": {
+		RU: "не удалось удалить подписку",
+		EN: "failed to delete subscription",
+	},
+	"list_failed": {
+		RU: "не удалось получить список подписок",
+		EN: "failed to list subscriptions",
+	},
+	"total_failed": {
+		RU: "не удалось подсчитать общую стоимость",
+		EN: "failed to calculate total price",
+	},
+	"timeline_failed": {
+		RU: "не удалось построить разбивку расходов",
+		EN: "failed to build spend timeline",
+	},
+	"batch_failed": {
+		RU: "не удалось создать пакет подписок",
+		EN: "failed to create subscriptions batch",
+	},
+	"bulk_failed": {
+		RU: "не удалось выполнить массовую операцию",
+		EN: "failed to perform bulk operation",
+	},
+	"import_failed": {
+		RU: "импорт прерван из-за ошибки; строки, записанные до неё, сохранены",
+		EN: "import aborted due to an error; rows written before it are kept",
+	},
+	"import_row_failed": {
+		RU: "не удалось записать строку",
+		EN: "failed to store the row",
+	},
+	"export_failed": {
+		RU: "не удалось выгрузить подписки",
+		EN: "failed to export subscriptions",
+	},
+	"transition_failed": {
+		RU: "не удалось изменить состояние подписки",
+		EN: "failed to change subscription state",
+	},
+	"charges_failed": {
+		RU: "не удалось построить календарь списаний",
+		EN: "failed to build upcoming charges",
+	},
+	"rates_save_failed": {
+		RU: "не удалось сохранить курсы валют",
+		EN: "failed to save exchange rates",
+	},
+	"rates_list_failed": {
+		RU: "не удалось получить курсы валют",
+		EN: "failed to get exchange rates",
+	},
+
+	// Подписи в выгрузках
+	"export_total": {
+		RU: "Итого",
+		EN: "Total",
+	},
+
+	// Сообщения об успехе
+	"subscription_updated": {
+		RU: "подписка успешно обновлена",
+		EN: "subscription updated",
+	},
+	"subscription_deleted": {
+		RU: "подписка успешно удалена",
+		EN: "subscription deleted",
+	},
+}
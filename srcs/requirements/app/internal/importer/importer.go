@@ -0,0 +1,377 @@
+// Package importer — импорт подписок из CSV: построчная проверка, запись в хранилище пакетами
+// и отчёт о принятых и отклонённых строках. Используется обработчиком POST /subscriptions/import
+// и командой import.
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"subscription_service/internal/i18n"
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+)
+
+// Columns — колонки CSV; в этом порядке читается файл без строки заголовка
+var Columns = []string{"service_name", "price", "user_id", "start_date", "end_date"}
+
+// optionalColumns — колонки, которые распознаются только в файле со строкой заголовка;
+// billing_period без значения означает month, currency без значения — RUB
+var optionalColumns = []string{"billing_period", "currency"}
+
+// requiredColumns — колонки, без которых заголовок CSV отклоняется; end_date необязательна
+var requiredColumns = []string{"service_name", "price", "user_id", "start_date"}
+
+// DefaultBatchSize — число строк, записываемых в хранилище одним пакетом
+const DefaultBatchSize = 500
+
+// maxReportedErrors — сколько отклонённых строк перечисляется в отчёте; остальные только считаются
+const maxReportedErrors = 1000
+
+// Ключи каталога i18n с причинами отклонения строк и ошибками заголовка
+const (
+	msgColumnCount      = "csv_column_count"
+	msgMalformed        = "csv_malformed"
+	msgUnknownColumn    = "csv_unknown_column"
+	msgMissingColumn    = "csv_missing_column"
+	msgDuplicateColumn  = "csv_duplicate_column"
+	msgExpectMonthEmpty = "expect_month_or_empty"
+	msgExpectPeriod     = "expect_billing_period"
+	msgExpectCurrency   = "expect_currency"
+	msgExpectMoney      = "expect_money"
+	msgAlreadyExists    = "already_exists"
+	msgInvalid          = "invalid_subscription"
+	msgRowFailed        = "import_row_failed"
+)
+
+// Options — параметры импорта
+type Options struct {
+	// Поведение, если подписка с тем же ключом уже существует: error — строка отклоняется,
+	// skip — пропускается, update — у подписки обновляются цена, валюта, период оплаты и дата окончания
+	Mode model.ConflictMode
+
+	// Число строк в одном пакете записи; 0 — DefaultBatchSize
+	BatchSize int
+
+	// Перевод причин отклонения по ключу каталога i18n; nil — каталог на русском языке
+	Message func(key string, args ...any) string
+}
+
+// RowError — отклонённая строка CSV
+type RowError struct {
+	// Номер строки файла (с 1, считая заголовок)
+	Line int `json:"line" example:"12"`
+
+	// Колонка с неверным значением; пустая, если строка отклонена целиком
+	Field string `json:"field,omitempty" example:"start_date"`
+
+	// Причина отклонения
+	Reason string `json:"reason" example:"ожидается MM-YYYY"`
+}
+
+// Report — отчёт об импорте
+type Report struct {
+	// Число принятых строк (созданные и обновлённые подписки)
+	Accepted int `json:"accepted" example:"998"`
+
+	// Число созданных подписок
+	Created int `json:"created" example:"990"`
+
+	// Число обновлённых подписок (on_conflict=update)
+	Updated int `json:"updated" example:"8"`
+
+	// Число пропущенных строк с уже существующими подписками (on_conflict=skip)
+	Skipped int `json:"skipped" example:"0"`
+
+	// Число отклонённых строк
+	Rejected int `json:"rejected" example:"2"`
+
+	// Отклонённые строки (не больше 1000 первых)
+	Errors []RowError `json:"errors"`
+}
+
+// HeaderError — заголовок CSV не подходит для импорта; ни одна строка не записана
+type HeaderError struct {
+	Key    string // ключ каталога i18n с описанием ошибки
+	Column string // колонка, к которой относится ошибка
+}
+
+func (e *HeaderError) Error() string {
+	return i18n.NewCatalog(i18n.RU).Message(i18n.RU, e.Key, e.Column)
+}
+
+// pendingRow — проверенная строка, ожидающая записи в хранилище
+type pendingRow struct {
+	line int
+	sub  model.Subscription
+}
+
+// importer — состояние одного импорта
+type importer struct {
+	store   repository.SubscriptionStore
+	opts    Options
+	report  *Report
+	pending []pendingRow
+}
+
+// Import читает CSV из r и записывает подписки в store пакетами по opts.BatchSize строк.
+// Первая строка считается заголовком, если в ней есть колонка service_name; иначе колонки
+// идут в порядке Columns. Строки с ошибками не прерывают импорт и попадают в отчёт с номером
+// строки и причиной. Пакеты фиксируются независимо, поэтому при ошибке чтения или хранилища
+// уже записанные строки остаются, а отчёт возвращается вместе с ошибкой.
+func Import(ctx context.Context, store repository.SubscriptionStore, r io.Reader, opts Options) (*Report, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Mode == "" {
+		opts.Mode = model.ConflictError
+	}
+	if opts.Message == nil {
+		catalog := i18n.NewCatalog(i18n.RU)
+		opts.Message = func(key string, args ...any) string { return catalog.Message(i18n.RU, key, args...) }
+	}
+	imp := &importer{store: store, opts: opts, report: &Report{Errors: []RowError{}}}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	columns := defaultColumns()
+	first := true
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				log.Printf("Ошибка чтения CSV: %v", err)
+				return imp.report, err
+			}
+			imp.reject(parseErr.StartLine, "", opts.Message(msgMalformed, parseErr.Err))
+			first = false
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+
+		if first {
+			first = false
+			if isHeader(record) {
+				if columns, err = parseHeader(record); err != nil {
+					log.Printf("Неверный заголовок CSV: %v", err)
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		sub, field, reason := imp.parseRow(record, columns)
+		if reason != "" {
+			imp.reject(line, field, reason)
+			continue
+		}
+		imp.pending = append(imp.pending, pendingRow{line: line, sub: sub})
+		if len(imp.pending) >= opts.BatchSize {
+			if err := imp.flush(ctx); err != nil {
+				return imp.report, err
+			}
+		}
+	}
+	if err := imp.flush(ctx); err != nil {
+		return imp.report, err
+	}
+
+	// Ошибки хранилища обнаруживаются при записи пакета, позже ошибок проверки следующих строк
+	sort.SliceStable(imp.report.Errors, func(i, j int) bool { return imp.report.Errors[i].Line < imp.report.Errors[j].Line })
+	log.Printf("Импорт CSV завершён: принято %d, пропущено %d, отклонено %d", imp.report.Accepted, imp.report.Skipped, imp.report.Rejected)
+	return imp.report, nil
+}
+
+// defaultColumns — позиции колонок для файла без заголовка
+func defaultColumns() map[string]int {
+	columns := make(map[string]int, len(Columns))
+	for i, name := range Columns {
+		columns[name] = i
+	}
+	return columns
+}
+
+// headerName — имя колонки заголовка без пробелов, регистра и BOM
+func headerName(s string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(s, "\ufeff")))
+}
+
+// isHeader — похожа ли строка на заголовок (есть колонка service_name)
+func isHeader(record []string) bool {
+	for _, v := range record {
+		if headerName(v) == "service_name" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHeader — позиции колонок по строке заголовка; колонки могут идти в любом порядке
+func parseHeader(record []string) (map[string]int, error) {
+	known := defaultColumns()
+	for _, name := range optionalColumns {
+		known[name] = len(known)
+	}
+	columns := map[string]int{}
+	for i, v := range record {
+		name := headerName(v)
+		if _, ok := known[name]; !ok {
+			return nil, &HeaderError{Key: msgUnknownColumn, Column: v}
+		}
+		if _, ok := columns[name]; ok {
+			return nil, &HeaderError{Key: msgDuplicateColumn, Column: name}
+		}
+		columns[name] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, &HeaderError{Key: msgMissingColumn, Column: name}
+		}
+	}
+	return columns, nil
+}
+
+// parseRow — проверяет строку CSV по правилам POST /subscriptions.
+// При ошибке возвращает колонку (пустую для строки целиком) и причину.
+func (imp *importer) parseRow(record []string, columns map[string]int) (model.Subscription, string, string) {
+	var sub model.Subscription
+	if len(record) != len(columns) {
+		return sub, "", imp.opts.Message(msgColumnCount, len(columns), len(record))
+	}
+	value := func(name string) string {
+		i, ok := columns[name]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	sub.ServiceName = value("service_name")
+	if sub.ServiceName == "" {
+		return sub, "service_name", imp.opts.Message("expect_non_empty_string")
+	}
+
+	price, err := model.ParseMoney(value("price"))
+	if err != nil || price < 0 {
+		return sub, "price", imp.opts.Message(msgExpectMoney)
+	}
+	sub.Price = price
+
+	if sub.Currency, err = model.ParseCurrency(value("currency")); err != nil {
+		return sub, "currency", imp.opts.Message(msgExpectCurrency)
+	}
+
+	if sub.BillingPeriod, err = model.ParseBillingPeriod(value("billing_period")); err != nil {
+		return sub, "billing_period", imp.opts.Message(msgExpectPeriod)
+	}
+
+	if sub.UserID, err = uuid.Parse(value("user_id")); err != nil {
+		return sub, "user_id", imp.opts.Message("expect_uuid")
+	}
+
+	if sub.StartDate, err = model.ParseDate(value("start_date")); err != nil {
+		return sub, "start_date", imp.opts.Message("expect_date")
+	}
+
+	if v := value("end_date"); v != "" {
+		end, err := model.ParseMonthYear(v)
+		if err != nil {
+			return sub, "end_date", imp.opts.Message(msgExpectMonthEmpty)
+		}
+		sub.EndDate = &end
+	}
+	return sub, "", ""
+}
+
+// reject — добавляет отклонённую строку в отчёт
+func (imp *importer) reject(line int, field, reason string) {
+	imp.report.Rejected++
+	if len(imp.report.Errors) < maxReportedErrors {
+		imp.report.Errors = append(imp.report.Errors, RowError{Line: line, Field: field, Reason: reason})
+	}
+}
+
+// flush — записывает накопленные строки одним пакетом. Строки, отклонённые хранилищем
+// (BatchError), попадают в отчёт, а остальные записываются повторным пакетом.
+// В режиме error существующие подписки не отменяют пакет, а отклоняют только свою строку.
+func (imp *importer) flush(ctx context.Context) error {
+	mode := imp.opts.Mode
+	if mode == model.ConflictError {
+		mode = model.ConflictSkip
+	}
+
+	rows := imp.pending
+	imp.pending = imp.pending[:0]
+	for len(rows) > 0 {
+		subs := make([]model.Subscription, len(rows))
+		for i, row := range rows {
+			subs[i] = row.sub
+		}
+
+		results, err := imp.store.CreateSubscriptions(ctx, subs, mode)
+		var batchErr *repository.BatchError
+		if errors.As(err, &batchErr) {
+			failed := map[int]bool{}
+			for _, i := range batchErr.Indexes {
+				failed[i] = true
+				field, reason := imp.storeReason(batchErr.Err)
+				imp.reject(rows[i].line, field, reason)
+			}
+			remaining := make([]pendingRow, 0, len(rows)-len(failed))
+			for i, row := range rows {
+				if !failed[i] {
+					remaining = append(remaining, row)
+				}
+			}
+			rows = remaining
+			continue
+		}
+		if err != nil {
+			log.Printf("Ошибка записи пакета импорта: %v", err)
+			return err
+		}
+
+		for i, res := range results {
+			switch {
+			case res.Status == model.BatchCreated:
+				imp.report.Created++
+			case res.Status == model.BatchUpdated:
+				imp.report.Updated++
+			case imp.opts.Mode == model.ConflictError:
+				imp.reject(rows[i].line, "", imp.opts.Message(msgAlreadyExists))
+			default:
+				imp.report.Skipped++
+			}
+		}
+		imp.report.Accepted = imp.report.Created + imp.report.Updated
+		return nil
+	}
+	return nil
+}
+
+// storeReason — поле и причина отклонения строки по ошибке хранилища. Текст прочих ошибок
+// в отчёт не попадает: он только записывается в лог.
+func (imp *importer) storeReason(err error) (string, string) {
+	var constraintErr *repository.ConstraintError
+	switch {
+	case errors.Is(err, repository.ErrAlreadyExists):
+		return "", imp.opts.Message(msgAlreadyExists)
+	case errors.As(err, &constraintErr):
+		return constraintErr.Field, imp.opts.Message(msgInvalid, imp.opts.Message(constraintErr.Key, constraintErr.Args...))
+	}
+	log.Printf("Строка импорта отклонена хранилищем: %v", err)
+	return "", imp.opts.Message(msgRowFailed)
+}
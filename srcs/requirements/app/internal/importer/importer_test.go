@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+)
+
+const testUserID = "4a79c82c-b09f-4cde-bf80-6edfd680793e"
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+
+	csv := strings.Join([]string{
+		"user_id,service_name,price,start_date,end_date",
+		testUserID + ",Netflix,500,01-2025,",
+		testUserID + ",Okko,abc,01-2025,",
+		testUserID + ",Kion,100,2025-01,",
+		testUserID + ",Ivi,100,01-2025,13-2025",
+		testUserID + ",Okko,300,02-2025,12-2025",
+		testUserID + ",Netflix,700,01-2025,",
+		testUserID + ",Wink,100",
+		`"broken,1`,
+	}, "\n")
+
+	// Пакеты по две строки: повтор Netflix попадает в другой пакет и отклоняется хранилищем
+	report, err := Import(ctx, repo, strings.NewReader(csv), Options{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("Импорт: %v", err)
+	}
+	if report.Accepted != 2 || report.Created != 2 || report.Rejected != 6 {
+		t.Fatalf("Отчёт: %+v", report)
+	}
+	want := []struct {
+		line  int
+		field string
+	}{{3, "price"}, {4, "start_date"}, {5, "end_date"}, {7, ""}, {8, ""}, {9, ""}}
+	for i, w := range want {
+		if report.Errors[i].Line != w.line || report.Errors[i].Field != w.field {
+			t.Errorf("Ошибка #%d: %+v, ожидалась строка %d, поле %q", i, report.Errors[i], w.line, w.field)
+		}
+	}
+
+	// Повторный импорт с update обновляет существующие подписки
+	report, err = Import(ctx, repo, strings.NewReader("Netflix,900,"+testUserID+",01-2025,06-2025\n"), Options{Mode: model.ConflictUpdate})
+	if err != nil || report.Updated != 1 {
+		t.Fatalf("Импорт с update: %+v, %v", report, err)
+	}
+
+	// Колонка billing_period распознаётся в файле с заголовком
+	report, err = Import(ctx, repo, strings.NewReader("service_name,price,user_id,start_date,billing_period\nKinopoisk,2990,"+testUserID+",01-2025,year\nStart,100,"+testUserID+",01-2025,daily\n"), Options{})
+	if err != nil || report.Created != 1 || report.Rejected != 1 || report.Errors[0].Field != "billing_period" {
+		t.Fatalf("Импорт с billing_period: %+v, %v", report, err)
+	}
+
+	// Цена с копейками сохраняется точно, больше двух знаков после точки — ошибка
+	report, err = Import(ctx, repo, strings.NewReader("Ivi,199.99,"+testUserID+",01-2025,\nWink,9.999,"+testUserID+",01-2025,\n"), Options{})
+	if err != nil || report.Created != 1 || report.Rejected != 1 || report.Errors[0].Field != "price" {
+		t.Fatalf("Импорт цен с копейками: %+v, %v", report, err)
+	}
+	if sub, _ := repo.GetSubscription(ctx, uuid.MustParse(testUserID), "Ivi", model.MonthYear(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC))); sub == nil || sub.Price != 19999 {
+		t.Errorf("Цена после импорта: %+v", sub)
+	}
+
+	var headerErr *HeaderError
+	if _, err := Import(ctx, repo, strings.NewReader("service_name,price,cost\n"), Options{}); !errors.As(err, &headerErr) || headerErr.Column != "cost" {
+		t.Errorf("Неизвестная колонка: %v", err)
+	}
+	if _, err := Import(ctx, repo, strings.NewReader("service_name,price,user_id\n"), Options{}); !errors.As(err, &headerErr) || headerErr.Column != "start_date" {
+		t.Errorf("Нет обязательной колонки: %v", err)
+	}
+}
+
+// failingStore — хранилище, пакетная запись в которое завершается ошибкой err
+type failingStore struct {
+	*repository.MemoryRepository
+	err error
+}
+
+func (s failingStore) CreateSubscriptions(ctx context.Context, subs []model.Subscription, mode model.ConflictMode) ([]model.BatchResult, error) {
+	return nil, s.err
+}
+
+func TestImportStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	csv := "Netflix,500," + testUserID + ",01-2025,\n"
+	store := func(err error) failingStore {
+		return failingStore{MemoryRepository: repository.NewMemoryRepository(), err: err}
+	}
+
+	// Сбой базы прерывает импорт, а не отклоняет строку
+	broken := errors.New("conn closed")
+	if _, err := Import(ctx, store(broken), strings.NewReader(csv), Options{}); !errors.Is(err, broken) {
+		t.Errorf("Сбой базы: %v, ожидалась исходная ошибка", err)
+	}
+
+	tests := []struct {
+		err           error
+		field, reason string
+	}{
+		{&repository.BatchError{Indexes: []int{0}, Err: &repository.ConstraintError{Field: "price", Key: msgExpectMoney}},
+			"price", "данные подписки нарушают ограничения хранилища: ожидается неотрицательная сумма не более чем с двумя знаками после точки, например 199.99"},
+		// Текст неизвестной ошибки хранилища в отчёт не попадает
+		{&repository.BatchError{Indexes: []int{0}, Err: errors.New(`ERROR: relation "subscriptions" does not exist`)},
+			"", "не удалось записать строку"},
+	}
+	for _, tt := range tests {
+		report, err := Import(ctx, store(tt.err), strings.NewReader(csv), Options{})
+		if err != nil || report.Rejected != 1 || report.Errors[0].Field != tt.field || report.Errors[0].Reason != tt.reason {
+			t.Errorf("%v: отчёт %+v, %v; ожидались поле %q и причина %q", tt.err, report, err, tt.field, tt.reason)
+		}
+	}
+}
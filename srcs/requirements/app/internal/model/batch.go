@@ -0,0 +1,47 @@
+package model
+
+import "fmt"
+
+// ConflictMode — поведение пакетного создания подписок, если подписка с таким же
+// составным ключом (user_id, service_name, start_date) уже существует
+type ConflictMode string
+
+// Режимы разрешения конфликтов пакетного создания
+const (
+	ConflictError  ConflictMode = "error"  // отменить весь пакет
+	ConflictSkip   ConflictMode = "skip"   // оставить существующую подписку без изменений
+	ConflictUpdate ConflictMode = "update" // обновить цену, период оплаты и дату окончания существующей подписки
+)
+
+// ParseConflictMode разбирает режим разрешения конфликтов; пустая строка означает ConflictError
+func ParseConflictMode(s string) (ConflictMode, error) {
+	switch m := ConflictMode(s); m {
+	case "":
+		return ConflictError, nil
+	case ConflictError, ConflictSkip, ConflictUpdate:
+		return m, nil
+	}
+	return "", fmt.Errorf("неизвестный режим разрешения конфликтов: %q", s)
+}
+
+// BatchStatus — результат обработки одного элемента пакета
+type BatchStatus string
+
+// Результаты обработки элемента пакета
+const (
+	BatchCreated BatchStatus = "created" // подписка создана
+	BatchUpdated BatchStatus = "updated" // существующая подписка обновлена (ConflictUpdate)
+	BatchSkipped BatchStatus = "skipped" // подписка уже существует и оставлена без изменений (ConflictSkip)
+)
+
+// BatchResult — результат обработки одного элемента пакетного создания подписок
+type BatchResult struct {
+	// Индекс элемента в запросе
+	Index int `json:"index" example:"0"`
+
+	// Результат: created, updated или skipped
+	Status BatchStatus `json:"status" enums:"created,updated,skipped" example:"created"`
+
+	// Созданная или обновлённая подписка (для skipped не возвращается)
+	Subscription *Subscription `json:"subscription,omitempty"`
+}
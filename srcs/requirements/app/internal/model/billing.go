@@ -0,0 +1,141 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BillingPeriod — период оплаты подписки: price списывается один раз за период.
+// Значения: week, month, quarter, year или Nm — раз в N месяцев (например, 6m).
+// Пустое значение означает month.
+type BillingPeriod string
+
+// Периоды оплаты
+const (
+	BillingWeek    BillingPeriod = "week"    // раз в неделю, начиная с даты начала подписки
+	BillingMonth   BillingPeriod = "month"   // раз в месяц
+	BillingQuarter BillingPeriod = "quarter" // раз в три месяца
+	BillingYear    BillingPeriod = "year"    // раз в год
+)
+
+// maxBillingMonths — максимальная длина периода оплаты в месяцах (Nm)
+const maxBillingMonths = 120
+
+// weeksPerYear — число недель в году для пересчёта еженедельной цены в месячную
+const weeksPerYear = 52
+
+// ParseBillingPeriod разбирает период оплаты; пустая строка означает month.
+// Nm, совпадающий с именованным периодом (1m, 3m, 12m), приводится к его имени.
+func ParseBillingPeriod(s string) (BillingPeriod, error) {
+	switch p := BillingPeriod(s); p {
+	case "":
+		return BillingMonth, nil
+	case BillingWeek, BillingMonth, BillingQuarter, BillingYear:
+		return p, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "m"))
+	if !strings.HasSuffix(s, "m") || err != nil || n < 1 || n > maxBillingMonths {
+		return "", fmt.Errorf("неизвестный период оплаты: %q", s)
+	}
+	switch n {
+	case 1:
+		return BillingMonth, nil
+	case 3:
+		return BillingQuarter, nil
+	case 12:
+		return BillingYear, nil
+	}
+	return BillingPeriod(strconv.Itoa(n) + "m"), nil
+}
+
+// String возвращает период оплаты с учётом значения по умолчанию: для пустого — month
+func (p BillingPeriod) String() string {
+	if p == "" {
+		return string(BillingMonth)
+	}
+	return string(p)
+}
+
+// Months возвращает длину периода оплаты в месяцах; для week — 0
+func (p BillingPeriod) Months() int {
+	switch p {
+	case "", BillingMonth:
+		return 1
+	case BillingQuarter:
+		return 3
+	case BillingYear:
+		return 12
+	case BillingWeek:
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSuffix(string(p), "m"))
+	return n
+}
+
+// CostView — способ распределения стоимости подписок по месяцам
+type CostView string
+
+// Способы распределения стоимости
+const (
+	ViewCharges CostView = "charges"  // списания: price попадает в месяцы, в которых подписка оплачивается
+	ViewRunRate CostView = "run_rate" // нормализованный ежемесячный платёж: каждый активный месяц стоит MonthlyPrice
+)
+
+// ParseCostView разбирает способ распределения стоимости; пустая строка означает ViewCharges
+func ParseCostView(s string) (CostView, error) {
+	switch v := CostView(s); v {
+	case "":
+		return ViewCharges, nil
+	case ViewCharges, ViewRunRate:
+		return v, nil
+	}
+	return "", fmt.Errorf("неизвестный способ распределения стоимости: %q", s)
+}
+
+// MonthlyPrice возвращает цену подписки, пересчитанную на один месяц (с округлением до сотых):
+// для годовой подписки за 5990.00 — 499.17, для еженедельной — price × 52 / 12
+func (s Subscription) MonthlyPrice() Money {
+	if n := Money(s.BillingPeriod.Months()); n > 0 {
+		return (s.Price + n/2) / n
+	}
+	return (s.Price*weeksPerYear + 6) / 12
+}
+
+// amountAt возвращает сумму, которая приходится на месяц с номером m (см. monthIndex) при способе view.
+// Вызывается только для месяцев, в которых подписка активна и не приостановлена.
+func (s Subscription) amountAt(m int, view CostView) Money {
+	if view == ViewRunRate {
+		return s.MonthlyPrice()
+	}
+	if n := s.BillingPeriod.Months(); n > 0 {
+		if (m-monthIndex(s.StartDate.ToTime()))%n == 0 {
+			return s.Price
+		}
+		return 0
+	}
+	return s.Price * Money(s.weeklyChargesAt(m))
+}
+
+// weeklyChargesAt возвращает число еженедельных списаний в месяце с номером m:
+// списания идут каждые 7 дней, начиная с даты начала подписки
+func (s Subscription) weeklyChargesAt(m int) int {
+	start := s.StartDate.ToTime()
+	month := monthStart(m)
+	days := func(t time.Time) int { return int(t.Sub(start).Hours()) / 24 }
+	// Номер первого списания не раньше даты t (округление вверх, не меньше нуля)
+	firstFrom := func(t time.Time) int {
+		d := days(t)
+		if d <= 0 {
+			return 0
+		}
+		return (d + 6) / 7
+	}
+	return firstFrom(month.AddDate(0, 1, 0)) - firstFrom(month)
+}
+
+// monthStart возвращает первое число месяца с номером m (см. monthIndex)
+func monthStart(m int) time.Time {
+	return time.Date(m/12, time.Month(m%12+1), 1, 0, 0, 0, 0, time.UTC)
+}
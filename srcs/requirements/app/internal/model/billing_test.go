@@ -0,0 +1,85 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBillingPeriod(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    BillingPeriod
+		wantErr bool
+	}{
+		{"", BillingMonth, false},
+		{"week", BillingWeek, false},
+		{"year", BillingYear, false},
+		{"6m", "6m", false},
+		{"3m", BillingQuarter, false},
+		{"12m", BillingYear, false},
+		{"0m", "", true},
+		{"121m", "", true},
+		{"m", "", true},
+		{"6", "", true},
+		{"daily", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBillingPeriod(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseBillingPeriod(%q) = %q, %v; ожидалось %q, ошибка %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestBillingPeriodCosts(t *testing.T) {
+	// Годовая подписка за 5990.00 с марта 2025: одно списание в год, 499.17 в месяц в нормализованном виде
+	annual := Subscription{Price: 599000, BillingPeriod: BillingYear, StartDate: my(2025, time.March)}
+	if got := annual.MonthlyPrice(); got != 49917 {
+		t.Errorf("MonthlyPrice() = %s, ожидалось 499.17", got)
+	}
+
+	charges := timelineOf(t, []Subscription{annual}, my(2025, time.January), my(2026, time.March), byCharges)
+	for _, m := range charges {
+		var want Money
+		if m.Month.ToTime().Month() == time.March {
+			want = 599000
+		}
+		if m.Total != want {
+			t.Errorf("charges %s: %s, ожидалось %s", m.Month.ToTime().Format("01-2006"), m.Total, want)
+		}
+	}
+	if charges[0].Subscriptions != 0 || charges[3].Subscriptions != 1 {
+		t.Errorf("число подписок: %+v", charges[:4])
+	}
+
+	runRate := timelineOf(t, []Subscription{annual}, my(2025, time.March), my(2025, time.December), byRunRate)
+	for _, m := range runRate {
+		if m.Total != 49917 {
+			t.Errorf("run_rate %s: %s, ожидалось 499.17", m.Month.ToTime().Format("01-2006"), m.Total)
+		}
+	}
+
+	if got := costOf(t, annual, my(2025, time.April), my(2026, time.March), byCharges).Cost; got != 599000 {
+		t.Errorf("CostInPeriod(charges) = %s, ожидалось 5990.00", got)
+	}
+	if got := costOf(t, annual, my(2025, time.April), my(2026, time.March), byRunRate).Cost; got != 49917*12 {
+		t.Errorf("CostInPeriod(run_rate) = %s, ожидалось %s", got, Money(49917*12))
+	}
+
+	// Ежеквартальная подписка, приостановленная в месяц списания: списание пропускается
+	quarterly := Subscription{Price: 300, BillingPeriod: BillingQuarter, StartDate: my(2025, time.January),
+		Pauses: []Pause{{From: my(2025, time.April), To: myPtr(2025, time.April)}}}
+	if got := costOf(t, quarterly, my(2025, time.January), my(2025, time.December), byCharges).Cost; got != 900 {
+		t.Errorf("ежеквартальная с приостановкой: %d, ожидалось 900", got)
+	}
+
+	// Еженедельная подписка с 1 января 2025: 5 списаний в январе (1, 8, 15, 22, 29), 4 в феврале
+	weekly := Subscription{Price: 100, BillingPeriod: BillingWeek, StartDate: my(2025, time.January)}
+	timeline := timelineOf(t, []Subscription{weekly}, my(2025, time.January), my(2025, time.February), byCharges)
+	if timeline[0].Total != 500 || timeline[1].Total != 400 {
+		t.Errorf("еженедельная подписка: %+v", timeline)
+	}
+	if got := weekly.MonthlyPrice(); got != 433 {
+		t.Errorf("MonthlyPrice() еженедельной = %s, ожидалось 4.33", got)
+	}
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Charge — ожидаемое списание по подписке
+// @Description Одно ожидаемое списание: дата, сумма в валюте подписки и нарастающий итог в этой валюте с начала календаря.
+type Charge struct {
+	// Дата списания (YYYY-MM-DD)
+	Date string `json:"date" format:"date" example:"2025-08-15"`
+
+	// Идентификатор подписки
+	SubscriptionID uuid.UUID `json:"subscription_id" format:"uuid" example:"0b6c3f1e-8f0a-4a57-9d2c-1d2f5e7a9b10"`
+
+	// Название сервиса
+	ServiceName string `json:"service_name" example:"Netflix"`
+
+	// Период оплаты подписки
+	BillingPeriod BillingPeriod `json:"billing_period" example:"month"`
+
+	// Сумма списания
+	Amount Money `json:"amount" swaggertype:"string" example:"999.00"`
+
+	// Валюта суммы списания (валюта подписки)
+	Currency Currency `json:"currency" example:"RUB"`
+
+	// Сумма этого и всех предыдущих списаний календаря в той же валюте
+	RunningTotal Money `json:"running_total" swaggertype:"string" example:"1498.00"`
+}
+
+// ChargeDates возвращает даты списаний подписки в интервале [from, to] (по дням, включительно).
+// Помесячные периоды списываются в день billing_day (в коротких месяцах — в последний день месяца)
+// каждые N месяцев от даты начала, еженедельные — каждые 7 дней от даты начала.
+// Списания в месяцы после окончания подписки и в месяцы приостановок не попадают.
+func (s Subscription) ChargeDates(from, to time.Time) []time.Time {
+	var dates []time.Time
+	start := s.StartDate.ToTime()
+	add := func(t time.Time) {
+		if !t.Before(from) && s.StatusAt(MonthYear(t).StartOfMonth()) == StatusActive {
+			dates = append(dates, t)
+		}
+	}
+
+	n := s.BillingPeriod.Months()
+	if n == 0 {
+		// Первое списание не раньше from
+		k := 0
+		if d := int(from.Sub(start).Hours()) / 24; d > 0 {
+			k = (d + 6) / 7
+		}
+		for t := start.AddDate(0, 0, 7*k); !t.After(to); t = t.AddDate(0, 0, 7) {
+			add(t)
+		}
+		return dates
+	}
+
+	first := monthIndex(start)
+	k := 0
+	if m := monthIndex(from); m > first {
+		k = (m - first) / n
+	}
+	for ; ; k++ {
+		t := MonthYear(monthStart(first + k*n)).WithDay(start.Day()).ToTime()
+		if t.After(to) {
+			break
+		}
+		add(t)
+	}
+	return dates
+}
+
+// UpcomingCharges возвращает ожидаемые списания подписок в интервале [from, to]
+// в порядке дат (при равных датах — по названию сервиса) с нарастающим итогом и суммы по валютам.
+// Суммы в разных валютах не складываются: нарастающий итог ведётся отдельно по каждой валюте.
+func UpcomingCharges(subs []Subscription, from, to time.Time) ([]Charge, Subtotals) {
+	charges := []Charge{}
+	for _, sub := range subs {
+		for _, t := range sub.ChargeDates(from, to) {
+			charges = append(charges, Charge{
+				Date:           t.Format(dateLayout),
+				SubscriptionID: sub.ID,
+				ServiceName:    sub.ServiceName,
+				BillingPeriod:  BillingPeriod(sub.BillingPeriod.String()),
+				Amount:         sub.Price,
+				Currency:       Currency(sub.Currency.String()),
+			})
+		}
+	}
+	// Даты в формате YYYY-MM-DD упорядочены так же, как строки
+	sort.Slice(charges, func(i, j int) bool {
+		a, b := charges[i], charges[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.ServiceName != b.ServiceName {
+			return a.ServiceName < b.ServiceName
+		}
+		return a.SubscriptionID.String() < b.SubscriptionID.String()
+	})
+
+	totals := Subtotals{}
+	for i := range charges {
+		totals[charges[i].Currency] += charges[i].Amount
+		charges[i].RunningTotal = totals[charges[i].Currency]
+	}
+	return charges, totals
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestChargeDates(t *testing.T) {
+	format := func(dates []time.Time) []string {
+		out := []string{}
+		for _, d := range dates {
+			out = append(out, d.Format(dateLayout))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		sub      Subscription
+		from, to time.Time
+		want     []string
+	}{
+		{
+			"ежемесячная с 31-го числа",
+			Subscription{StartDate: MonthYear(day(2025, time.January, 31))},
+			day(2025, time.January, 1), day(2025, time.April, 30),
+			[]string{"2025-01-31", "2025-02-28", "2025-03-31", "2025-04-30"},
+		},
+		{
+			"годовая, интервал внутри года",
+			Subscription{BillingPeriod: BillingYear, StartDate: MonthYear(day(2024, time.March, 10))},
+			day(2025, time.January, 1), day(2026, time.March, 9),
+			[]string{"2025-03-10"},
+		},
+		{
+			"еженедельная",
+			Subscription{BillingPeriod: BillingWeek, StartDate: MonthYear(day(2025, time.July, 2))},
+			day(2025, time.July, 10), day(2025, time.July, 31),
+			[]string{"2025-07-16", "2025-07-23", "2025-07-30"},
+		},
+		{
+			"приостановка и окончание",
+			Subscription{StartDate: MonthYear(day(2025, time.January, 5)), EndDate: myPtr(2025, time.April),
+				Pauses: []Pause{{From: my(2025, time.February), To: myPtr(2025, time.February)}}},
+			day(2025, time.January, 6), day(2025, time.June, 30),
+			[]string{"2025-03-05", "2025-04-05"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := format(tt.sub.ChargeDates(tt.from, tt.to))
+			if len(got) != len(tt.want) {
+				t.Fatalf("ChargeDates() = %v, ожидалось %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ChargeDates() = %v, ожидалось %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestUpcomingCharges(t *testing.T) {
+	subs := []Subscription{
+		{ServiceName: "Okko", Price: 300, StartDate: MonthYear(day(2025, time.June, 20))},
+		{ServiceName: "Netflix", Price: 999, StartDate: MonthYear(day(2025, time.May, 5))},
+		{ServiceName: "Kinopoisk", Price: 2990, BillingPeriod: BillingYear, StartDate: MonthYear(day(2024, time.July, 20))},
+		{ServiceName: "GitHub", Price: 400, Currency: "USD", StartDate: MonthYear(day(2025, time.June, 10))},
+	}
+	charges, totals := UpcomingCharges(subs, day(2025, time.July, 1), day(2025, time.July, 30))
+	want := []struct {
+		date, service string
+		currency      Currency
+		running       Money
+	}{
+		{"2025-07-05", "Netflix", "RUB", 999},
+		{"2025-07-10", "GitHub", "USD", 400},
+		{"2025-07-20", "Kinopoisk", "RUB", 3989},
+		{"2025-07-20", "Okko", "RUB", 4289},
+	}
+	// Итоги в разных валютах ведутся отдельно
+	if len(charges) != len(want) || len(totals) != 2 || totals["RUB"] != 4289 || totals["USD"] != 400 {
+		t.Fatalf("UpcomingCharges() = %+v, итоги %v", charges, totals)
+	}
+	for i, w := range want {
+		if c := charges[i]; c.Date != w.date || c.ServiceName != w.service || c.Currency != w.currency || c.RunningTotal != w.running {
+			t.Errorf("Списание #%d: %+v, ожидалось %s %s с итогом %s", i, c, w.date, w.service, w.running)
+		}
+	}
+}
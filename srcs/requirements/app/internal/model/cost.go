@@ -0,0 +1,207 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionCost — вклад одной подписки в общую стоимость за период.
+// @Description Детализация расчёта: сколько месяцев подписка была активна в периоде и во сколько это обошлось.
+type SubscriptionCost struct {
+	// Название сервиса
+	ServiceName string `json:"service_name" example:"Netflix"`
+
+	// UUID пользователя
+	UserID uuid.UUID `json:"user_id" format:"uuid" example:"4a79c82c-b09f-4cde-bf80-6edfd680793e"`
+
+	// Дата начала подписки (месяц и год)
+	StartDate MonthYear `json:"start_date" format:"MM-YYYY" example:"07-2025"`
+
+	// Дата окончания подписки, если задана
+	EndDate *MonthYear `json:"end_date,omitempty" format:"MM-YYYY" example:"12-2025"`
+
+	// Цена подписки за период оплаты (в валюте подписки)
+	Price Money `json:"price" swaggertype:"string" example:"999.00"`
+
+	// Валюта подписки (код ISO 4217)
+	Currency Currency `json:"currency" example:"RUB"`
+
+	// Период оплаты подписки
+	BillingPeriod BillingPeriod `json:"billing_period" example:"month"`
+
+	// Цена, пересчитанная на один месяц (в валюте подписки)
+	MonthlyPrice Money `json:"monthly_price" swaggertype:"string" example:"999.00"`
+
+	// Количество месяцев периода, в которых подписка была активна
+	Months int `json:"months" example:"6"`
+
+	// Стоимость в валюте подписки: сумма списаний за активные месяцы (view=charges) или monthly_price × months (view=run_rate)
+	OriginalCost Money `json:"original_cost" swaggertype:"string" example:"5994.00"`
+
+	// Стоимость в валюте результата: помесячные суммы, пересчитанные по курсу на первое число каждого месяца
+	Cost Money `json:"cost" swaggertype:"string" example:"5994.00"`
+}
+
+// monthIndex возвращает порядковый номер месяца (год*12 + месяц),
+// что позволяет считать разницу между датами в месяцах простым вычитанием.
+func monthIndex(t time.Time) int {
+	return t.Year()*12 + int(t.Month()) - 1
+}
+
+// MonthsBetween возвращает количество календарных месяцев в периоде [from, to] включительно.
+// Если from позже to, возвращает 0.
+func MonthsBetween(from, to MonthYear) int {
+	n := monthIndex(to.ToTime()) - monthIndex(from.ToTime()) + 1
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// AddMonths возвращает первое число месяца, отстоящего от c на n месяцев.
+func (c MonthYear) AddMonths(n int) MonthYear {
+	t := c.ToTime()
+	return MonthYear(time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC))
+}
+
+// ActiveMonths возвращает количество месяцев периода [from, to], в которых подписка активна.
+// Месяц начала и месяц окончания подписки считаются полностью оплаченными,
+// подписка без даты окончания считается бессрочной, месяцы приостановок не учитываются.
+func (s Subscription) ActiveMonths(from, to MonthYear) int {
+	start, end := s.activeRange(from, MonthsBetween(from, to))
+	if end < start {
+		return 0
+	}
+	first := monthIndex(from.ToTime())
+	return end - start + 1 - s.pausedMonths(first+start, first+end)
+}
+
+// pausedAt сообщает, что i-й месяц периода, начинающегося с from, попадает в приостановку подписки
+func (s Subscription) pausedAt(from MonthYear, i int) bool {
+	return s.paused(monthIndex(from.ToTime()) + i)
+}
+
+// activeRange возвращает номера первого и последнего месяца (отсчитывая от from),
+// в которых подписка активна внутри периода из n месяцев, начинающегося с from.
+// Если подписка не активна в периоде, start > end.
+func (s Subscription) activeRange(from MonthYear, n int) (start, end int) {
+	first := monthIndex(from.ToTime())
+	start = monthIndex(s.StartDate.ToTime()) - first
+	if start < 0 {
+		start = 0
+	}
+	end = n - 1
+	if s.EndDate != nil {
+		if e := monthIndex(s.EndDate.ToTime()) - first; e < end {
+			end = e
+		}
+	}
+	return start, end
+}
+
+// CostInPeriod рассчитывает стоимость подписки за период [from, to] с параметрами opts
+// с учётом только тех месяцев, в которых она была активна и не приостановлена.
+// Возвращает ошибку, обёрнутую вокруг ErrNoRate, если для пересчёта не хватает курса.
+func (s Subscription) CostInPeriod(from, to MonthYear, opts CostOptions) (SubscriptionCost, error) {
+	cost := SubscriptionCost{
+		ServiceName:   s.ServiceName,
+		UserID:        s.UserID,
+		StartDate:     s.StartDate,
+		EndDate:       s.EndDate,
+		Price:         s.Price,
+		Currency:      Currency(s.Currency.String()),
+		BillingPeriod: BillingPeriod(s.BillingPeriod.String()),
+		MonthlyPrice:  s.MonthlyPrice(),
+	}
+	first := monthIndex(from.ToTime())
+	start, end := s.activeRange(from, MonthsBetween(from, to))
+	for i := start; i <= end; i++ {
+		if s.paused(first + i) {
+			continue
+		}
+		original, converted, err := s.costAt(first+i, opts)
+		if err != nil {
+			return cost, err
+		}
+		cost.Months++
+		cost.OriginalCost += original
+		cost.Cost += converted
+	}
+	return cost, nil
+}
+
+// MonthlySpend — расходы на подписки за один календарный месяц.
+// @Description Сумма стоимости всех подписок, активных в указанном месяце.
+type MonthlySpend struct {
+	// Месяц (месяц и год)
+	Month MonthYear `json:"month" format:"MM-YYYY" example:"07-2025"`
+
+	// Суммарная стоимость активных в этом месяце подписок в валюте результата: списания или нормализованные ежемесячные платежи
+	Total Money `json:"total" swaggertype:"string" example:"1498.00"`
+
+	// Суммы по валютам подписок без пересчёта
+	Subtotals []CurrencyAmount `json:"subtotals"`
+
+	// Количество подписок, активных в этом месяце
+	Subscriptions int `json:"subscriptions" example:"2"`
+}
+
+// Timeline — помесячная разбивка расходов за период, в которую подписки добавляются по одной;
+// память не зависит от числа подписок
+type Timeline struct {
+	from      MonthYear
+	opts      CostOptions
+	months    []MonthlySpend
+	subtotals []Subtotals
+}
+
+// NewTimeline создаёт пустую разбивку с параметрами opts с элементом на каждый месяц периода [from, to]
+func NewTimeline(from, to MonthYear, opts CostOptions) *Timeline {
+	months := make([]MonthlySpend, MonthsBetween(from, to))
+	subtotals := make([]Subtotals, len(months))
+	for i := range months {
+		months[i].Month = from.AddMonths(i)
+		subtotals[i] = Subtotals{}
+	}
+	return &Timeline{from: from, opts: opts, months: months, subtotals: subtotals}
+}
+
+// Add учитывает стоимость подписки в месяцах периода, в которых она активна и не приостановлена.
+// Возвращает ошибку, обёрнутую вокруг ErrNoRate, если для пересчёта не хватает курса.
+func (t *Timeline) Add(sub Subscription) error {
+	start, end := sub.activeRange(t.from, len(t.months))
+	for i := start; i <= end; i++ {
+		if sub.pausedAt(t.from, i) {
+			continue
+		}
+		original, converted, err := sub.costAt(monthIndex(t.from.ToTime())+i, t.opts)
+		if err != nil {
+			return err
+		}
+		t.months[i].Total += converted
+		t.months[i].Subscriptions++
+		t.subtotals[i][Currency(sub.Currency.String())] += original
+	}
+	return nil
+}
+
+// Months возвращает разбивку: по одному элементу на каждый месяц, включая месяцы без активных подписок
+func (t *Timeline) Months() []MonthlySpend {
+	for i := range t.months {
+		t.months[i].Subtotals = t.subtotals[i].List()
+	}
+	return t.months
+}
+
+// BuildTimeline раскладывает стоимость подписок по месяцам периода [from, to] с параметрами opts.
+// Возвращает по одному элементу на каждый месяц, включая месяцы без активных подписок.
+func BuildTimeline(subs []Subscription, from, to MonthYear, opts CostOptions) ([]MonthlySpend, error) {
+	timeline := NewTimeline(from, to, opts)
+	for _, sub := range subs {
+		if err := timeline.Add(sub); err != nil {
+			return nil, err
+		}
+	}
+	return timeline.Months(), nil
+}
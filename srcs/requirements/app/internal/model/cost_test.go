@@ -0,0 +1,142 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func my(year int, month time.Month) MonthYear {
+	return MonthYear(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func myPtr(year int, month time.Month) *MonthYear {
+	m := my(year, month)
+	return &m
+}
+
+// Параметры расчёта без пересчёта валют
+var (
+	byCharges = CostOptions{View: ViewCharges}
+	byRunRate = CostOptions{View: ViewRunRate}
+)
+
+func timelineOf(t *testing.T, subs []Subscription, from, to MonthYear, opts CostOptions) []MonthlySpend {
+	t.Helper()
+	timeline, err := BuildTimeline(subs, from, to, opts)
+	if err != nil {
+		t.Fatalf("BuildTimeline: %v", err)
+	}
+	return timeline
+}
+
+func groupsOf(t *testing.T, subs []Subscription, from, to MonthYear, by []GroupField, opts CostOptions) []SpendGroup {
+	t.Helper()
+	groups, err := GroupSpend(subs, from, to, by, opts)
+	if err != nil {
+		t.Fatalf("GroupSpend: %v", err)
+	}
+	return groups
+}
+
+func costOf(t *testing.T, sub Subscription, from, to MonthYear, opts CostOptions) SubscriptionCost {
+	t.Helper()
+	cost, err := sub.CostInPeriod(from, to, opts)
+	if err != nil {
+		t.Fatalf("CostInPeriod: %v", err)
+	}
+	return cost
+}
+
+func TestActiveMonths(t *testing.T) {
+	from, to := my(2025, time.January), my(2025, time.December)
+
+	tests := []struct {
+		name  string
+		start MonthYear
+		end   *MonthYear
+		want  int
+	}{
+		{"бессрочная, начата до периода", my(2023, time.March), nil, 12},
+		{"бессрочная, начата внутри периода", my(2025, time.October), nil, 3},
+		{"целиком внутри периода", my(2025, time.March), myPtr(2025, time.May), 3},
+		{"закончилась внутри периода", my(2024, time.June), myPtr(2025, time.February), 2},
+		{"один месяц", my(2025, time.July), myPtr(2025, time.July), 1},
+		{"закончилась до периода", my(2023, time.January), myPtr(2024, time.December), 0},
+		{"начнётся после периода", my(2026, time.January), nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := Subscription{Price: 100, StartDate: tt.start, EndDate: tt.end}
+			if got := sub.ActiveMonths(from, to); got != tt.want {
+				t.Errorf("ActiveMonths() = %d, ожидалось %d", got, tt.want)
+			}
+			if got := costOf(t, sub, from, to, byCharges).Cost; got != Money(tt.want*100) {
+				t.Errorf("CostInPeriod().Cost = %s, ожидалось %d", got, tt.want*100)
+			}
+		})
+	}
+}
+
+func TestBuildTimeline(t *testing.T) {
+	subs := []Subscription{
+		{Price: 100, StartDate: my(2024, time.June)},                                      // бессрочная, начата до периода
+		{Price: 50, StartDate: my(2025, time.February), EndDate: myPtr(2025, time.March)}, // два месяца внутри периода
+		{Price: 7, StartDate: my(2025, time.April), EndDate: myPtr(2026, time.January)},   // выходит за конец периода
+	}
+
+	timeline := timelineOf(t, subs, my(2025, time.January), my(2025, time.April), byCharges)
+
+	want := []struct {
+		total Money
+		count int
+	}{
+		{100, 1},
+		{150, 2},
+		{150, 2},
+		{107, 2},
+	}
+	if len(timeline) != len(want) {
+		t.Fatalf("получено месяцев %d, ожидалось %d", len(timeline), len(want))
+	}
+	for i, w := range want {
+		m := timeline[i]
+		if !m.Month.ToTime().Equal(my(2025, time.January).AddMonths(i).ToTime()) {
+			t.Errorf("месяц %d: получен %v", i, m.Month.ToTime())
+		}
+		if m.Total != w.total || m.Subscriptions != w.count {
+			t.Errorf("месяц %d: получено total=%s subscriptions=%d, ожидалось %s/%d", i, m.Total, m.Subscriptions, w.total, w.count)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"07-2025", time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2025-07-15", time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC), false},
+		{"2025-02-30", time.Time{}, true},
+		{"2025-07", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.in)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && !got.ToTime().Equal(tt.want)) {
+			t.Errorf("ParseDate(%q) = %v, %v", tt.in, got.ToTime(), err)
+		}
+	}
+
+	// День сохраняется внутри, но в JSON выводятся только месяц и год
+	d, _ := ParseDate("2025-07-15")
+	if b, _ := d.MarshalJSON(); string(b) != `"07-2025"` || d.Day() != 15 {
+		t.Errorf("MarshalJSON() = %s, Day() = %d", b, d.Day())
+	}
+	if got := my(2025, time.February).WithDay(31).ToTime(); got.Day() != 28 {
+		t.Errorf("WithDay(31) в феврале: %v, ожидалось 28-е число", got)
+	}
+	if got := d.StartOfMonth().ToTime(); !got.Equal(my(2025, time.July).ToTime()) {
+		t.Errorf("StartOfMonth() = %v", got)
+	}
+}
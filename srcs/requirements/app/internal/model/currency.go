@@ -0,0 +1,110 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Currency — код валюты ISO 4217 (три латинские буквы в верхнем регистре)
+type Currency string
+
+// BaseCurrency — валюта по умолчанию и базовая валюта курсов (курсы ЦБ РФ задаются в рублях)
+const BaseCurrency Currency = "RUB"
+
+// ErrNoRate — нет курса валюты на нужную дату
+var ErrNoRate = errors.New("нет курса валюты")
+
+// ParseCurrency разбирает код валюты без учёта регистра; пустая строка означает BaseCurrency
+func ParseCurrency(s string) (Currency, error) {
+	if s == "" {
+		return BaseCurrency, nil
+	}
+	if len(s) != 3 {
+		return "", fmt.Errorf("неверный код валюты: %q", s)
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return "", fmt.Errorf("неверный код валюты: %q", s)
+		}
+	}
+	return Currency(strings.ToUpper(s)), nil
+}
+
+// String возвращает код валюты с учётом значения по умолчанию: для пустого — RUB
+func (c Currency) String() string {
+	if c == "" {
+		return string(BaseCurrency)
+	}
+	return string(c)
+}
+
+// CurrencyAmount — сумма в одной валюте
+type CurrencyAmount struct {
+	// Код валюты ISO 4217
+	Currency Currency `json:"currency" example:"USD"`
+
+	// Сумма в этой валюте
+	Amount Money `json:"amount" swaggertype:"string" example:"9.99"`
+}
+
+// Subtotals — суммы по валютам без пересчёта
+type Subtotals map[Currency]Money
+
+// List возвращает суммы по валютам в порядке кодов валют
+func (s Subtotals) List() []CurrencyAmount {
+	list := make([]CurrencyAmount, 0, len(s))
+	for c, amount := range s {
+		list = append(list, CurrencyAmount{Currency: c, Amount: amount})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Currency < list[j].Currency })
+	return list
+}
+
+// CostOptions — параметры расчёта стоимости подписок
+type CostOptions struct {
+	View     CostView // распределение стоимости по месяцам
+	Currency Currency // валюта результата; пустая — BaseCurrency
+	Rates    Rates    // курсы для пересчёта; nil — пересчёт между разными валютами невозможен
+}
+
+// convert пересчитывает сумму amount в валюте from в валюту результата по курсам на первое число
+// месяца с номером m (см. monthIndex); расчёт ведётся в десятичных числах, результат округляется до сотых
+func (o CostOptions) convert(amount Money, from Currency, m int) (Money, error) {
+	to := Currency(o.Currency.String())
+	from = Currency(from.String())
+	if from == to || amount == 0 {
+		return amount, nil
+	}
+	if o.Rates == nil {
+		return 0, &ReasonError{Err: ErrNoRate, Key: msgRatesNotLoaded, Args: []any{from.String(), to.String()}}
+	}
+	on := monthStart(m)
+	rate := func(c Currency) (decimal.Decimal, error) {
+		if c == BaseCurrency {
+			return decimal.NewFromInt(1), nil
+		}
+		rate, _, err := o.Rates.Rate(c, on)
+		return rate, err
+	}
+	fromRate, err := rate(from)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := rate(to)
+	if err != nil {
+		return 0, err
+	}
+	converted := decimal.NewFromInt(int64(amount)).Mul(fromRate).Div(toRate).Round(0)
+	return Money(converted.IntPart()), nil
+}
+
+// costAt возвращает сумму, которая приходится на месяц m при способе o.View, в валюте подписки и в валюте результата
+func (s Subscription) costAt(m int, o CostOptions) (original, converted Money, err error) {
+	original = s.amountAt(m, o.View)
+	converted, err = o.convert(original, s.Currency, m)
+	return original, converted, err
+}
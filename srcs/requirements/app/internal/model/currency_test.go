@@ -0,0 +1,134 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// monthlyRates — курсы валют к рублю, заданные по месяцам
+type monthlyRates map[Currency]map[time.Month]string
+
+func (r monthlyRates) Rate(currency Currency, on time.Time) (decimal.Decimal, []ExchangeRate, error) {
+	rate, ok := r[currency][on.Month()]
+	if !ok || on.Day() != 1 {
+		return decimal.Decimal{}, nil, fmt.Errorf("%w: %s на %s", ErrNoRate, currency, on.Format("2006-01-02"))
+	}
+	used := ExchangeRate{Currency: currency, Quote: BaseCurrency, Date: on.Format("2006-01-02"), Rate: decimal.RequireFromString(rate)}
+	return used.Rate, []ExchangeRate{used}, nil
+}
+
+func TestParseCurrency(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Currency
+		wantErr bool
+	}{
+		{"", BaseCurrency, false},
+		{"usd", "USD", false},
+		{"EUR", "EUR", false},
+		{"US", "", true},
+		{"US1", "", true},
+		{"ДОЛ", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCurrency(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCurrency(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestCurrencyConversion(t *testing.T) {
+	rates := monthlyRates{
+		"USD": {time.January: "100", time.February: "90.5"},
+		"EUR": {time.January: "110", time.February: "100"},
+	}
+	usd := Subscription{ServiceName: "GitHub", Price: 999, Currency: "USD", StartDate: my(2025, time.January)}
+	rub := Subscription{ServiceName: "Okko", Price: 50000, StartDate: my(2025, time.January)}
+
+	// Каждый месяц пересчитывается по своему курсу; исходная сумма остаётся в валюте подписки
+	cost := costOf(t, usd, my(2025, time.January), my(2025, time.February), CostOptions{Rates: rates})
+	if cost.Currency != "USD" || cost.OriginalCost != 1998 || cost.Cost != 99900+90410 {
+		t.Errorf("USD → RUB: %+v", cost)
+	}
+	// 9.99 × 100 / 110 = 9.0818…, 9.99 × 90.5 / 100 = 9.04095 — округление до сотых в каждом месяце
+	cost = costOf(t, usd, my(2025, time.January), my(2025, time.February), CostOptions{Currency: "EUR", Rates: rates})
+	if want := Money(908 + 904); cost.Cost != want {
+		t.Errorf("USD → EUR: %s, ожидалось %s", cost.Cost, want)
+	}
+
+	timeline := timelineOf(t, []Subscription{usd, rub}, my(2025, time.January), my(2025, time.February), CostOptions{Currency: "USD", Rates: rates})
+	if timeline[0].Total != 999+500 || timeline[1].Total != 999+552 {
+		t.Errorf("timeline в USD: %+v", timeline)
+	}
+	if s := timeline[1].Subtotals; len(s) != 2 || s[0] != (CurrencyAmount{"RUB", 50000}) || s[1] != (CurrencyAmount{"USD", 999}) {
+		t.Errorf("subtotals: %+v", s)
+	}
+
+	// Без курса на месяц или без таблицы курсов пересчёт невозможен; подписки в валюте результата считаются как есть
+	if _, err := usd.CostInPeriod(my(2025, time.January), my(2025, time.March), CostOptions{Rates: rates}); !errors.Is(err, ErrNoRate) {
+		t.Errorf("нет курса на март: %v", err)
+	}
+	if _, err := usd.CostInPeriod(my(2025, time.January), my(2025, time.January), byCharges); !errors.Is(err, ErrNoRate) {
+		t.Errorf("без курсов: %v", err)
+	}
+	if cost := costOf(t, rub, my(2025, time.January), my(2025, time.March), byCharges); cost.Cost != 150000 || cost.Currency != "RUB" {
+		t.Errorf("RUB без курсов: %+v", cost)
+	}
+}
+
+func TestRateLog(t *testing.T) {
+	log := NewRateLog(monthlyRates{
+		"USD": {time.January: "100", time.February: "90.5"},
+		"EUR": {time.January: "110", time.February: "100"},
+	})
+	usd := Subscription{ServiceName: "GitHub", Price: 999, Currency: "USD", StartDate: my(2025, time.January)}
+	rub := Subscription{ServiceName: "Okko", Price: 50000, StartDate: my(2025, time.January)}
+
+	// Каждый курс месяца попадает в журнал один раз, сколько бы подписок по нему ни пересчитывалось
+	timelineOf(t, []Subscription{usd, usd, rub}, my(2025, time.January), my(2025, time.February), CostOptions{Currency: "EUR", Rates: log})
+	applied := log.Applied()
+	want := []struct {
+		month    MonthYear
+		currency Currency
+		date     string
+	}{
+		{my(2025, time.January), "EUR", "2025-01-01"},
+		{my(2025, time.January), "USD", "2025-01-01"},
+		{my(2025, time.February), "EUR", "2025-02-01"},
+		{my(2025, time.February), "USD", "2025-02-01"},
+	}
+	if len(applied) != len(want) {
+		t.Fatalf("Applied() = %+v", applied)
+	}
+	for i, w := range want {
+		if a := applied[i]; a.Month != w.month || a.Currency != w.currency || a.Quote != BaseCurrency || a.Date != w.date {
+			t.Errorf("Applied()[%d] = %+v, ожидалось %+v", i, a, w)
+		}
+	}
+}
+
+func TestExchangeRateValidate(t *testing.T) {
+	valid := ExchangeRate{Currency: "USD", Quote: "RUB", Date: "2025-03-01", Rate: decimal.RequireFromString("89.9865")}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("верный курс: %v", err)
+	}
+	invalid := []func(r *ExchangeRate){
+		func(r *ExchangeRate) { r.Currency = "usd" },
+		func(r *ExchangeRate) { r.Quote = "" },
+		func(r *ExchangeRate) { r.Quote = "USD" },
+		func(r *ExchangeRate) { r.Date = "01-03-2025" },
+		func(r *ExchangeRate) { r.Rate = decimal.Zero },
+	}
+	for i, change := range invalid {
+		r := valid
+		change(&r)
+		if err := r.Validate(); err == nil {
+			t.Errorf("случай %d: %+v принят", i, r)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"subscription_service/internal/i18n"
+)
+
+// Ключи каталога i18n с причинами ошибок модели
+const (
+	msgAlreadyCancelled  = "transition_already_cancelled"
+	msgCancelBeforeStart = "transition_cancel_before_start"
+	msgCancelAfterEnd    = "transition_cancel_after_end"
+	msgPauseCancelled    = "transition_pause_cancelled"
+	msgPauseBeforeStart  = "transition_pause_before_start"
+	msgPauseReversed     = "transition_pause_reversed"
+	msgPauseAfterEnd     = "transition_pause_after_end"
+	msgPauseOverlap      = "transition_pause_overlap"
+	msgNotPaused         = "transition_not_paused"
+	msgNoRate            = "no_rate"
+	msgRatesNotLoaded    = "rates_not_loaded"
+)
+
+// ReasonError — ошибка Err с причиной, заданной ключом каталога i18n и аргументами сообщения,
+// чтобы обработчики могли перевести причину на язык запроса. Error возвращает причину на русском.
+type ReasonError struct {
+	Err  error  // исходная ошибка, например ErrInvalidTransition
+	Key  string // ключ каталога i18n с причиной
+	Args []any  // аргументы сообщения
+}
+
+func (e *ReasonError) Error() string {
+	return e.Err.Error() + ": " + i18n.NewCatalog(i18n.RU).Message(i18n.RU, e.Key, e.Args...)
+}
+
+func (e *ReasonError) Unwrap() error {
+	return e.Err
+}
+
+// transitionError — ErrInvalidTransition с причиной по ключу key
+func transitionError(key string, args ...any) error {
+	return &ReasonError{Err: ErrInvalidTransition, Key: key, Args: args}
+}
+
+// NoRateError — ErrNoRate: нет курса валюты currency на дату date (YYYY-MM-DD)
+func NoRateError(currency Currency, date string) error {
+	return &ReasonError{Err: ErrNoRate, Key: msgNoRate, Args: []any{currency.String(), date}}
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionFilter — условия отбора подписок для списка.
+// Пустые (nil) поля не ограничивают выборку.
+type SubscriptionFilter struct {
+	UserID           *uuid.UUID // Пользователь
+	ServiceName      *string    // Название сервиса: подстрока без учёта регистра или точное совпадение
+	ServiceNameExact bool       // Искать точное совпадение названия сервиса вместо подстроки
+	StartFrom        *MonthYear // Дата начала не раньше указанного месяца
+	StartTo          *MonthYear // Дата начала не позже указанного месяца
+	MinPrice         *Money     // Цена не меньше
+	MaxPrice         *Money     // Цена не больше
+	ActiveOn         *MonthYear // Подписка активна в указанном месяце
+	EndsBefore       *MonthYear // Дата окончания раньше указанного месяца
+	EndsAfter        *MonthYear // Дата окончания позже указанного месяца
+	OpenEnded        *bool      // true — только бессрочные подписки, false — только с датой окончания
+	ActiveSince      *MonthYear // Подписка не закончилась раньше указанного месяца (бессрочная или с end_date не раньше)
+	Status           *Status    // Состояние подписки в текущем месяце (см. Subscription.StatusAt)
+}
+
+// IsEmpty сообщает, что фильтр не ограничивает выборку (подходят все подписки)
+func (f SubscriptionFilter) IsEmpty() bool {
+	f.ServiceNameExact = false
+	return f == SubscriptionFilter{}
+}
+
+// SortField — поле сортировки списка подписок
+type SortField struct {
+	Column string // Колонка: price, start_date или service_name; пустая — порядок первичного ключа
+	Desc   bool   // Сортировка по убыванию
+}
+
+// sortColumns — допустимые колонки сортировки
+var sortColumns = map[string]bool{"price": true, "start_date": true, "service_name": true}
+
+// ParseSort разбирает параметр сортировки вида "price", "-start_date".
+// Пустая строка означает сортировку по первичному ключу (user_id, service_name, start_date).
+func ParseSort(s string) (SortField, error) {
+	if s == "" {
+		return SortField{}, nil
+	}
+	f := SortField{Column: strings.TrimPrefix(s, "-"), Desc: strings.HasPrefix(s, "-")}
+	if !sortColumns[f.Column] {
+		return SortField{}, fmt.Errorf("неизвестное поле сортировки: %q", s)
+	}
+	return f, nil
+}
+
+// String возвращает сортировку в том же виде, в котором она передаётся в запросе
+func (f SortField) String() string {
+	if f.Desc {
+		return "-" + f.Column
+	}
+	return f.Column
+}
+
+// Cursor — позиция в списке подписок для keyset-пагинации:
+// значения первичного ключа и цены последней выданной подписки,
+// а также сортировка, для которой курсор был выдан.
+type Cursor struct {
+	Sort        string    `json:"s"`
+	UserID      uuid.UUID `json:"u"`
+	ServiceName string    `json:"n"`
+	StartDate   time.Time `json:"d"`
+	Price       Money     `json:"p"`
+}
+
+// CursorAfter создаёт курсор, указывающий на позицию сразу после подписки sub
+func CursorAfter(sub Subscription, sort SortField) Cursor {
+	return Cursor{
+		Sort:        sort.String(),
+		UserID:      sub.UserID,
+		ServiceName: sub.ServiceName,
+		StartDate:   sub.StartDate.ToTime(),
+		Price:       sub.Price,
+	}
+}
+
+// Encode упаковывает курсор в непрозрачную строку для передачи клиенту
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor распаковывает курсор, полученный от клиента
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("некорректный курсор: %w", err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("некорректный курсор: %w", err)
+	}
+	return c, nil
+}
+
+// Page — параметры страницы списка подписок.
+// Cursor и Offset взаимоисключающие: при заданном курсоре используется keyset-пагинация.
+// Limit 0 при обходе подписок (IterateSubscriptions) означает обход без ограничения.
+type Page struct {
+	Limit  int
+	Offset int
+	Cursor *Cursor
+	Sort   SortField
+}
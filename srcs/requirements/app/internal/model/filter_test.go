@@ -0,0 +1,59 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SortField
+		wantErr bool
+	}{
+		{"", SortField{}, false},
+		{"price", SortField{Column: "price"}, false},
+		{"-start_date", SortField{Column: "start_date", Desc: true}, false},
+		{"service_name", SortField{Column: "service_name"}, false},
+		{"user_id", SortField{}, true},
+		{"-", SortField{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSort(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSort(%q): ошибка %v, ожидалась ошибка: %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSort(%q) = %+v, ожидалось %+v", tt.in, got, tt.want)
+		}
+		if !tt.wantErr && got.String() != tt.in {
+			t.Errorf("SortField.String() = %q, ожидалось %q", got.String(), tt.in)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	sub := Subscription{
+		ServiceName: "Yandex Plus",
+		Price:       299,
+		UserID:      uuid.New(),
+		StartDate:   MonthYear(time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	c := CursorAfter(sub, SortField{Column: "price", Desc: true})
+
+	got, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	if got.Sort != "-price" || got.UserID != sub.UserID || got.ServiceName != sub.ServiceName ||
+		got.Price != sub.Price || !got.StartDate.Equal(sub.StartDate.ToTime()) {
+		t.Errorf("курсор после распаковки %+v не совпадает с исходным %+v", got, c)
+	}
+
+	if _, err := DecodeCursor("не курсор"); err == nil {
+		t.Error("ожидалась ошибка для некорректного курсора")
+	}
+}
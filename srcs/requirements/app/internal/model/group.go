@@ -0,0 +1,185 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// GroupField — поле, по которому группируются расходы на подписки
+type GroupField string
+
+const (
+	GroupByService GroupField = "service_name" // группировка по названию сервиса
+	GroupByUser    GroupField = "user_id"      // группировка по пользователю
+	GroupByMonth   GroupField = "month"        // группировка по календарному месяцу
+)
+
+// ParseGroupField проверяет, что строка — допустимое поле группировки
+func ParseGroupField(s string) (GroupField, error) {
+	switch f := GroupField(s); f {
+	case GroupByService, GroupByUser, GroupByMonth:
+		return f, nil
+	}
+	return "", fmt.Errorf("неизвестное поле группировки: %q", s)
+}
+
+// SpendGroup — расходы на подписки в одной группе.
+// Заполнены только те ключевые поля, по которым выполнялась группировка.
+// @Description Сумма стоимости подписок в группе (по сервису, пользователю и/или месяцу).
+type SpendGroup struct {
+	// Название сервиса (при группировке по service_name)
+	ServiceName *string `json:"service_name,omitempty" example:"Netflix"`
+
+	// UUID пользователя (при группировке по user_id)
+	UserID *uuid.UUID `json:"user_id,omitempty" format:"uuid" example:"4a79c82c-b09f-4cde-bf80-6edfd680793e"`
+
+	// Месяц (при группировке по month)
+	Month *MonthYear `json:"month,omitempty" format:"MM-YYYY" example:"07-2025"`
+
+	// Суммарная стоимость подписок группы за период в валюте результата
+	Total Money `json:"total" swaggertype:"string" example:"5994.00"`
+
+	// Суммы по валютам подписок без пересчёта
+	Subtotals []CurrencyAmount `json:"subtotals"`
+
+	// Количество подписок, вошедших в группу
+	Subscriptions int `json:"subscriptions" example:"1"`
+}
+
+// groupKey — ключ группы в карте агрегации
+type groupKey struct {
+	serviceName string
+	userID      uuid.UUID
+	month       int
+}
+
+// SpendGrouper — агрегация расходов за период по полям группировки, в которую подписки добавляются по одной;
+// память зависит только от числа групп
+type SpendGrouper struct {
+	from                       MonthYear
+	months                     int
+	opts                       CostOptions
+	byService, byUser, byMonth bool
+	groups                     map[groupKey]*SpendGroup
+	subtotals                  map[groupKey]Subtotals
+	order                      []groupKey
+}
+
+// NewSpendGrouper создаёт пустую агрегацию расходов за период [from, to] по полям by с параметрами opts
+func NewSpendGrouper(from, to MonthYear, by []GroupField, opts CostOptions) *SpendGrouper {
+	g := &SpendGrouper{
+		from:      from,
+		months:    MonthsBetween(from, to),
+		opts:      opts,
+		groups:    map[groupKey]*SpendGroup{},
+		subtotals: map[groupKey]Subtotals{},
+	}
+	for _, f := range by {
+		switch f {
+		case GroupByService:
+			g.byService = true
+		case GroupByUser:
+			g.byUser = true
+		case GroupByMonth:
+			g.byMonth = true
+		}
+	}
+	return g
+}
+
+// Add учитывает подписку: она вносит свою стоимость (см. CostOptions) за каждый месяц, в котором активна
+// внутри периода и не приостановлена, и считается один раз в каждой группе, куда попала.
+// Возвращает ошибку, обёрнутую вокруг ErrNoRate, если для пересчёта не хватает курса.
+func (g *SpendGrouper) Add(sub Subscription) error {
+	start, end := sub.activeRange(g.from, g.months)
+	counted := false
+	for i := start; i <= end; i++ {
+		if sub.pausedAt(g.from, i) {
+			continue
+		}
+		original, converted, err := sub.costAt(monthIndex(g.from.ToTime())+i, g.opts)
+		if err != nil {
+			return err
+		}
+		var key groupKey
+		if g.byService {
+			key.serviceName = sub.ServiceName
+		}
+		if g.byUser {
+			key.userID = sub.UserID
+		}
+		if g.byMonth {
+			key.month = i
+		}
+
+		group, ok := g.groups[key]
+		if !ok {
+			group = &SpendGroup{}
+			if g.byService {
+				name := sub.ServiceName
+				group.ServiceName = &name
+			}
+			if g.byUser {
+				uid := sub.UserID
+				group.UserID = &uid
+			}
+			if g.byMonth {
+				m := g.from.AddMonths(i)
+				group.Month = &m
+			}
+			g.groups[key] = group
+			g.subtotals[key] = Subtotals{}
+			g.order = append(g.order, key)
+		}
+		group.Total += converted
+		g.subtotals[key][Currency(sub.Currency.String())] += original
+		// Без группировки по месяцу все месяцы подписки попадают в одну группу
+		if g.byMonth || !counted {
+			counted = true
+			group.Subscriptions++
+		}
+	}
+	return nil
+}
+
+// Groups возвращает группы, упорядоченные по месяцу (если он среди полей группировки), затем по убыванию суммы
+func (g *SpendGrouper) Groups() []SpendGroup {
+	order := append([]groupKey(nil), g.order...)
+	sort.SliceStable(order, func(i, j int) bool {
+		ki, kj := order[i], order[j]
+		if ki.month != kj.month {
+			return ki.month < kj.month
+		}
+		ti, tj := g.groups[ki].Total, g.groups[kj].Total
+		if ti != tj {
+			return ti > tj
+		}
+		if ki.serviceName != kj.serviceName {
+			return ki.serviceName < kj.serviceName
+		}
+		return ki.userID.String() < kj.userID.String()
+	})
+
+	result := make([]SpendGroup, 0, len(order))
+	for _, key := range order {
+		group := *g.groups[key]
+		group.Subtotals = g.subtotals[key].List()
+		result = append(result, group)
+	}
+	return result
+}
+
+// GroupSpend агрегирует стоимость подписок за период [from, to] по указанным полям с параметрами opts.
+// Каждая подписка вносит свою стоимость за каждый месяц, в котором она активна внутри периода.
+// Группы упорядочены по месяцу (если он среди полей группировки), затем по убыванию суммы.
+func GroupSpend(subs []Subscription, from, to MonthYear, by []GroupField, opts CostOptions) ([]SpendGroup, error) {
+	grouper := NewSpendGrouper(from, to, by, opts)
+	for _, sub := range subs {
+		if err := grouper.Add(sub); err != nil {
+			return nil, err
+		}
+	}
+	return grouper.Groups(), nil
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestGroupSpend(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	subs := []Subscription{
+		{ServiceName: "Netflix", Price: 100, UserID: alice, StartDate: my(2024, time.January)},
+		{ServiceName: "Okko", Price: 50, UserID: alice, StartDate: my(2025, time.February), EndDate: myPtr(2025, time.February)},
+		{ServiceName: "Netflix", Price: 100, UserID: bob, StartDate: my(2025, time.March)},
+	}
+	from, to := my(2025, time.January), my(2025, time.March)
+
+	t.Run("по сервису", func(t *testing.T) {
+		groups := groupsOf(t, subs, from, to, []GroupField{GroupByService}, byCharges)
+		if len(groups) != 2 {
+			t.Fatalf("получено групп %d, ожидалось 2", len(groups))
+		}
+		if *groups[0].ServiceName != "Netflix" || groups[0].Total != 400 || groups[0].Subscriptions != 2 {
+			t.Errorf("первая группа: %+v", groups[0])
+		}
+		if *groups[1].ServiceName != "Okko" || groups[1].Total != 50 || groups[1].Subscriptions != 1 {
+			t.Errorf("вторая группа: %+v", groups[1])
+		}
+		if groups[0].UserID != nil || groups[0].Month != nil {
+			t.Error("заполнены поля, по которым не было группировки")
+		}
+	})
+
+	t.Run("по пользователю и месяцу", func(t *testing.T) {
+		groups := groupsOf(t, subs, from, to, []GroupField{GroupByUser, GroupByMonth}, byCharges)
+		want := []struct {
+			month MonthYear
+			total Money
+		}{
+			{my(2025, time.January), 100},
+			{my(2025, time.February), 150},
+			{my(2025, time.March), 100},
+			{my(2025, time.March), 100},
+		}
+		if len(groups) != len(want) {
+			t.Fatalf("получено групп %d, ожидалось %d", len(groups), len(want))
+		}
+		users := map[uuid.UUID]int{}
+		for i, w := range want {
+			g := groups[i]
+			if g.Month == nil || !g.Month.ToTime().Equal(w.month.ToTime()) || g.Total != w.total {
+				t.Errorf("группа %d: %+v", i, g)
+			}
+			if g.ServiceName != nil {
+				t.Errorf("группа %d: заполнено service_name без группировки по сервису", i)
+			}
+			users[*g.UserID]++
+		}
+		if users[alice] != 3 || users[bob] != 1 {
+			t.Errorf("неверное распределение групп по пользователям: %v", users)
+		}
+	})
+}
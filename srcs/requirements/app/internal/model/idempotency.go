@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// IdempotencyRecord — сохранённый результат запроса с заголовком Idempotency-Key
+type IdempotencyRecord struct {
+	// Ключ идемпотентности, переданный клиентом
+	Key string
+
+	// Хеш запроса (метод, путь и тело), по которому выполнен первый запрос с этим ключом
+	RequestHash string
+
+	// HTTP-статус ответа; 0 — запрос ещё выполняется
+	Status int
+
+	// Заголовки ответа, которые нужно повторить (Content-Type, Location, ETag)
+	Headers map[string]string
+
+	// Тело ответа
+	Body []byte
+
+	// Момент, после которого ключ можно использовать заново
+	ExpiresAt time.Time
+
+	// Момент, до которого ключ зарезервирован за выполняющимся запросом; если ответ к этому времени
+	// не сохранён (например, процесс упал), ключ может занять повтор запроса
+	LeaseUntil time.Time
+
+	// Случайный токен резерва: сохранить ответ и снять резерв может только запрос, который им владеет
+	LeaseToken string
+}
+
+// Completed — получен ли уже ответ на запрос с этим ключом
+func (r IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
+
+// Reserved — занят ли ключ в момент now: действующим сохранённым ответом или резервом выполняющегося запроса
+func (r IdempotencyRecord) Reserved(now time.Time) bool {
+	if !r.ExpiresAt.After(now) {
+		return false
+	}
+	return r.Completed() || r.LeaseUntil.After(now)
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Money — денежная сумма в сотых долях единицы валюты (копейках, центах).
+// Суммы складываются и умножаются как целые числа, без округлений.
+// В JSON передаётся строкой с двумя знаками после точки ("199.99"); при разборе принимается и число.
+type Money int64
+
+// moneyScale — число сотых долей в единице валюты
+const moneyScale = 100
+
+// maxMoneyDigits — максимальное число цифр целой части суммы; больше не помещается в int64 в сотых долях
+const maxMoneyDigits = 15
+
+// ParseMoney разбирает сумму вида 199, 199.9 или 199.99 (не больше двух знаков после точки)
+func ParseMoney(s string) (Money, error) {
+	digits, negative := strings.CutPrefix(s, "-")
+	whole, frac, hasFrac := strings.Cut(digits, ".")
+	if whole == "" || len(whole) > maxMoneyDigits || !isDigits(whole) ||
+		hasFrac && (frac == "" || len(frac) > 2 || !isDigits(frac)) {
+		return 0, fmt.Errorf("неверная сумма: %q", s)
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	units, _ := strconv.ParseInt(whole, 10, 64)
+	cents, _ := strconv.ParseInt(frac, 10, 64)
+	m := Money(units*moneyScale + cents)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+// isDigits сообщает, что строка состоит только из цифр 0–9
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String возвращает сумму с двумя знаками после точки: "199.99", "-0.50"
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign, m = "-", -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, m/moneyScale, m%moneyScale)
+}
+
+// MarshalJSON сериализует сумму строкой "199.99"
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON разбирает сумму из строки "199.99" или числа 199.99 без преобразования в float
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return &json.UnmarshalTypeError{Value: string(data), Type: reflect.TypeOf(*m)}
+	}
+	*m = v
+	return nil
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{"199.99", 19999, false},
+		{"199.9", 19990, false},
+		{"5990", 599000, false},
+		{"0.01", 1, false},
+		{"-3.50", -350, false},
+		{"1.999", 0, true},
+		{"1.", 0, true},
+		{".5", 0, true},
+		{"1e3", 0, true},
+		{"12,50", 0, true},
+		{"1000000000000000", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, %v", tt.in, got, err)
+		}
+	}
+
+	for m, want := range map[Money]string{19999: "199.99", 5: "0.05", 0: "0.00", -350: "-3.50"} {
+		if got := m.String(); got != want {
+			t.Errorf("Money(%d).String() = %q, ожидалось %q", int64(m), got, want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var v struct {
+		Price Money `json:"price"`
+	}
+	// Строка и число разбираются одинаково и без округлений float
+	for _, in := range []string{`{"price":"0.29"}`, `{"price":0.29}`} {
+		if err := json.Unmarshal([]byte(in), &v); err != nil || v.Price != 29 {
+			t.Errorf("Unmarshal(%s) = %d, %v", in, v.Price, err)
+		}
+	}
+	if b, _ := json.Marshal(v); string(b) != `{"price":"0.29"}` {
+		t.Errorf("Marshal() = %s", b)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal([]byte(`{"price":"0.299"}`), &v); !errors.As(err, &typeErr) {
+		t.Errorf("Unmarshal с тремя знаками: %v", err)
+	}
+}
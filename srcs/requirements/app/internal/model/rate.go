@@ -0,0 +1,152 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRate — курс валюты на дату: одна единица Currency стоит Rate единиц Quote.
+// Курсы ЦБ РФ заданы к рублю (Quote = RUB), курсы ЕЦБ — как стоимость евро в другой валюте (Currency = EUR).
+// @Description Курс валюты на дату. Исправление курса сохраняется новой версией с другим recorded_at.
+type ExchangeRate struct {
+	// Валюта, стоимость единицы которой задаёт курс
+	Currency Currency `json:"currency" example:"USD"`
+
+	// Валюта, в которой выражен курс
+	Quote Currency `json:"quote" example:"RUB"`
+
+	// Дата курса (YYYY-MM-DD); курс действует до даты следующего
+	Date string `json:"date" format:"date" example:"2025-03-01"`
+
+	// Стоимость одной единицы currency в quote
+	Rate decimal.Decimal `json:"rate" swaggertype:"string" example:"89.9865"`
+
+	// Источник курса: cbr, ecb, csv или manual
+	Source string `json:"source,omitempty" example:"cbr"`
+
+	// Момент записи этой версии курса в хранилище
+	RecordedAt time.Time `json:"recorded_at,omitzero" example:"2025-03-01T12:00:00Z"`
+}
+
+// Validate проверяет курс: коды валют, их различие, дату YYYY-MM-DD и положительное значение
+func (r ExchangeRate) Validate() error {
+	for _, c := range []Currency{r.Currency, r.Quote} {
+		if c == "" {
+			return fmt.Errorf("не указан код валюты")
+		}
+		if parsed, err := ParseCurrency(string(c)); err != nil || parsed != c {
+			return fmt.Errorf("неверный код валюты: %q", c)
+		}
+	}
+	if r.Currency == r.Quote {
+		return fmt.Errorf("курс валюты %s к самой себе", r.Currency)
+	}
+	if _, err := time.Parse(dateLayout, r.Date); err != nil {
+		return fmt.Errorf("неверная дата курса %q: ожидается YYYY-MM-DD", r.Date)
+	}
+	if !r.Rate.IsPositive() {
+		return fmt.Errorf("курс %s/%s на %s должен быть положительным", r.Currency, r.Quote, r.Date)
+	}
+	return nil
+}
+
+// RateFilter — условия отбора курсов. Пустые (nil) поля не ограничивают выборку.
+type RateFilter struct {
+	Currency *Currency  // Валюта курса
+	Quote    *Currency  // Валюта, в которой выражен курс
+	From     *time.Time // Дата курса не раньше
+	Since    *time.Time // Курсы с этой даты и действующий на неё курс каждой пары (последний не позже неё)
+	To       *time.Time // Дата курса не позже
+	AsOf     *time.Time // Курсы, записанные не позже этого момента; nil — все записанные
+	History  bool       // Все версии курса на дату, а не только последняя
+}
+
+// Rates — источник курсов валют. Rate возвращает стоимость одной единицы валюты в BaseCurrency
+// по курсу, действовавшему на дату on, и курсы, из которых она получена (прямой курс к BaseCurrency
+// или два курса через промежуточную валюту), либо ошибку, обёрнутую вокруг ErrNoRate.
+// Курсы — десятичные числа без потери точности.
+type Rates interface {
+	Rate(currency Currency, on time.Time) (decimal.Decimal, []ExchangeRate, error)
+}
+
+// AppliedRate — курс, по которому пересчитаны суммы месяца
+type AppliedRate struct {
+	// Месяц, суммы которого пересчитаны по этому курсу (курс берётся на первое число)
+	Month MonthYear `json:"month" format:"MM-YYYY" example:"03-2025"`
+
+	ExchangeRate
+}
+
+// appliedKey — курс, применённый к месяцу
+type appliedKey struct {
+	month           int
+	currency, quote Currency
+	date            string
+}
+
+// RateLog — источник курсов, запоминающий курсы, по которым выполнялся пересчёт.
+// Позволяет показать в отчёте, курс на какую дату использован для каждого месяца.
+// Безопасен для одновременного использования.
+type RateLog struct {
+	rates Rates
+
+	mu   sync.Mutex
+	used map[appliedKey]AppliedRate
+}
+
+// NewRateLog — конструктор для RateLog поверх источника курсов rates
+func NewRateLog(rates Rates) *RateLog {
+	return &RateLog{rates: rates, used: map[appliedKey]AppliedRate{}}
+}
+
+// Rate возвращает курс из исходного источника и запоминает курсы, из которых он получен
+func (l *RateLog) Rate(currency Currency, on time.Time) (decimal.Decimal, []ExchangeRate, error) {
+	rate, used, err := l.rates.Rate(currency, on)
+	if err != nil {
+		return rate, used, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m := monthIndex(on)
+	for _, r := range used {
+		l.used[appliedKey{month: m, currency: r.Currency, quote: r.Quote, date: r.Date}] = AppliedRate{Month: MonthYear(monthStart(m)), ExchangeRate: r}
+	}
+	return rate, used, nil
+}
+
+// Applied возвращает использованные курсы в порядке месяцев, затем валют; для nil — nil
+func (l *RateLog) Applied() []AppliedRate {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys := make([]appliedKey, 0, len(l.used))
+	for k := range l.used {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.month != b.month {
+			return a.month < b.month
+		}
+		if a.currency != b.currency {
+			return a.currency < b.currency
+		}
+		if a.quote != b.quote {
+			return a.quote < b.quote
+		}
+		return a.date < b.date
+	})
+	applied := make([]AppliedRate, 0, len(keys))
+	for _, k := range keys {
+		applied = append(applied, l.used[k])
+	}
+	return applied
+}
@@ -0,0 +1,186 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Status — состояние подписки в заданном месяце. Вычисляется по датам подписки,
+// её приостановкам и отмене (см. Subscription.StatusAt), поэтому со временем меняется само.
+type Status string
+
+// Состояния подписки
+const (
+	StatusActive    Status = "active"    // подписка действует
+	StatusUpcoming  Status = "upcoming"  // подписка ещё не началась
+	StatusExpired   Status = "expired"   // подписка закончилась по end_date
+	StatusPaused    Status = "paused"    // подписка приостановлена
+	StatusCancelled Status = "cancelled" // подписка отменена и её последний оплачиваемый месяц прошёл
+)
+
+// ParseStatus разбирает состояние подписки
+func ParseStatus(s string) (Status, error) {
+	switch st := Status(s); st {
+	case StatusActive, StatusUpcoming, StatusExpired, StatusPaused, StatusCancelled:
+		return st, nil
+	}
+	return "", fmt.Errorf("неизвестное состояние подписки: %q", s)
+}
+
+// ErrInvalidTransition — переход недопустим в текущем состоянии подписки
+// (например, повторная отмена или возобновление неприостановленной подписки)
+var ErrInvalidTransition = errors.New("недопустимое изменение состояния подписки")
+
+// Pause — приостановка подписки: месяцы с From по To включительно не оплачиваются.
+// @Description Приостановка подписки; без to подписка приостановлена до возобновления.
+type Pause struct {
+	// Первый месяц приостановки
+	From MonthYear `json:"from" format:"MM-YYYY" example:"08-2025"`
+
+	// Последний месяц приостановки; отсутствует, пока подписку не возобновили
+	To *MonthYear `json:"to,omitempty" format:"MM-YYYY" example:"09-2025"`
+}
+
+// covers сообщает, что месяц с номером m (см. monthIndex) попадает в приостановку
+func (p Pause) covers(m int) bool {
+	return monthIndex(p.From.ToTime()) <= m && (p.To == nil || monthIndex(p.To.ToTime()) >= m)
+}
+
+// CurrentMonth возвращает текущий календарный месяц (UTC)
+func CurrentMonth() MonthYear {
+	now := time.Now().UTC()
+	return MonthYear(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC))
+}
+
+// StatusAt возвращает состояние подписки в месяце m
+func (s Subscription) StatusAt(m MonthYear) Status {
+	month := monthIndex(m.ToTime())
+	switch {
+	case monthIndex(s.StartDate.ToTime()) > month:
+		return StatusUpcoming
+	case s.EndDate != nil && monthIndex(s.EndDate.ToTime()) < month:
+		if s.CancelledAt != nil {
+			return StatusCancelled
+		}
+		return StatusExpired
+	case s.paused(month):
+		return StatusPaused
+	}
+	return StatusActive
+}
+
+// paused сообщает, что месяц с номером m (см. monthIndex) попадает в одну из приостановок подписки
+func (s Subscription) paused(m int) bool {
+	for _, p := range s.Pauses {
+		if p.covers(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// pausedMonths возвращает число месяцев с номерами [first, last], попадающих в приостановки.
+// Приостановки подписки не пересекаются (это проверяет Pause), поэтому их можно складывать.
+func (s Subscription) pausedMonths(first, last int) int {
+	n := 0
+	for _, p := range s.Pauses {
+		from := max(monthIndex(p.From.ToTime()), first)
+		to := last
+		if p.To != nil {
+			to = min(monthIndex(p.To.ToTime()), last)
+		}
+		if to >= from {
+			n += to - from + 1
+		}
+	}
+	return n
+}
+
+// Cancel отменяет подписку: effective становится её последним оплачиваемым месяцем (end_date),
+// at — моментом отмены. Приостановки после effective отбрасываются, незавершённая — завершается в effective.
+func (s *Subscription) Cancel(effective MonthYear, at time.Time) error {
+	month := monthIndex(effective.ToTime())
+	switch {
+	case s.CancelledAt != nil:
+		return transitionError(msgAlreadyCancelled)
+	case month < monthIndex(s.StartDate.ToTime()):
+		return transitionError(msgCancelBeforeStart)
+	case s.EndDate != nil && month > monthIndex(s.EndDate.ToTime()):
+		return transitionError(msgCancelAfterEnd)
+	}
+
+	pauses := s.Pauses[:0]
+	for _, p := range s.Pauses {
+		if monthIndex(p.From.ToTime()) > month {
+			continue
+		}
+		if p.To == nil || monthIndex(p.To.ToTime()) > month {
+			end := effective
+			p.To = &end
+		}
+		pauses = append(pauses, p)
+	}
+	s.Pauses = pauses
+	s.EndDate = &effective
+	s.CancelledAt = &at
+	return nil
+}
+
+// Pause приостанавливает подписку с месяца from по месяц to включительно (to == nil — до возобновления).
+// Приостановка не может выходить за начало и конец подписки и пересекаться с другими приостановками.
+func (s *Subscription) Pause(from MonthYear, to *MonthYear) error {
+	p := Pause{From: from, To: to}
+	first := monthIndex(from.ToTime())
+	switch {
+	case s.CancelledAt != nil:
+		return transitionError(msgPauseCancelled)
+	case first < monthIndex(s.StartDate.ToTime()):
+		return transitionError(msgPauseBeforeStart)
+	case to != nil && monthIndex(to.ToTime()) < first:
+		return transitionError(msgPauseReversed)
+	case s.EndDate != nil && (to == nil || monthIndex(to.ToTime()) > monthIndex(s.EndDate.ToTime())):
+		return transitionError(msgPauseAfterEnd)
+	}
+	for _, other := range s.Pauses {
+		if other.covers(first) || p.covers(monthIndex(other.From.ToTime())) {
+			return transitionError(msgPauseOverlap, other.From.ToTime().Format("01-2006"))
+		}
+	}
+
+	s.Pauses = append(s.Pauses, p)
+	sort.Slice(s.Pauses, func(i, j int) bool { return s.Pauses[i].From.ToTime().Before(s.Pauses[j].From.ToTime()) })
+	return nil
+}
+
+// Resume возобновляет подписку с месяца month. Приостановка, в которую попадает month, завершается
+// в предыдущем месяце. Отменённая подписка снова становится бессрочной; если month позже её
+// последнего месяца, пропущенные месяцы записываются как приостановка.
+func (s *Subscription) Resume(month MonthYear) error {
+	m := monthIndex(month.ToTime())
+
+	if s.CancelledAt != nil {
+		if last := s.EndDate.AddMonths(1); m > monthIndex(last.ToTime()) {
+			gap := month.AddMonths(-1)
+			s.Pauses = append(s.Pauses, Pause{From: last, To: &gap})
+		}
+		s.EndDate = nil
+		s.CancelledAt = nil
+		return nil
+	}
+
+	for i, p := range s.Pauses {
+		if !p.covers(m) {
+			continue
+		}
+		if monthIndex(p.From.ToTime()) == m {
+			s.Pauses = append(s.Pauses[:i], s.Pauses[i+1:]...)
+		} else {
+			end := month.AddMonths(-1)
+			s.Pauses[i].To = &end
+		}
+		return nil
+	}
+	return transitionError(msgNotPaused, month.ToTime().Format("01-2006"))
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStatusAt(t *testing.T) {
+	cancelledAt := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+	sub := Subscription{
+		StartDate: my(2025, time.January),
+		EndDate:   myPtr(2025, time.December),
+		Pauses:    []Pause{{From: my(2025, time.May), To: myPtr(2025, time.June)}},
+	}
+
+	tests := []struct {
+		name  string
+		sub   Subscription
+		month MonthYear
+		want  Status
+	}{
+		{"до начала", sub, my(2024, time.December), StatusUpcoming},
+		{"действует", sub, my(2025, time.March), StatusActive},
+		{"приостановлена", sub, my(2025, time.June), StatusPaused},
+		{"после приостановки", sub, my(2025, time.July), StatusActive},
+		{"закончилась", sub, my(2026, time.January), StatusExpired},
+		{"отменена", Subscription{StartDate: sub.StartDate, EndDate: sub.EndDate, CancelledAt: &cancelledAt}, my(2026, time.January), StatusCancelled},
+		{"отменена, последний месяц не прошёл", Subscription{StartDate: sub.StartDate, EndDate: sub.EndDate, CancelledAt: &cancelledAt}, my(2025, time.December), StatusActive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sub.StatusAt(tt.month); got != tt.want {
+				t.Errorf("StatusAt() = %s, ожидалось %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionTransitions(t *testing.T) {
+	sub := Subscription{Price: 100, StartDate: my(2025, time.January)}
+
+	// Незавершённая приостановка с апреля, затем возобновление в июле: не оплачиваются апрель–июнь
+	if err := sub.Pause(my(2025, time.April), nil); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if err := sub.Pause(my(2025, time.May), myPtr(2025, time.May)); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("пересекающаяся приостановка: ошибка %v, ожидалась ErrInvalidTransition", err)
+	}
+	if err := sub.Resume(my(2025, time.July)); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if err := sub.Resume(my(2025, time.August)); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("возобновление неприостановленной подписки: ошибка %v, ожидалась ErrInvalidTransition", err)
+	}
+	if got := sub.ActiveMonths(my(2025, time.January), my(2025, time.December)); got != 9 {
+		t.Errorf("ActiveMonths() = %d, ожидалось 9", got)
+	}
+
+	// Отмена с последним месяцем в сентябре, повторная отмена отклоняется
+	at := time.Date(2025, time.September, 5, 0, 0, 0, 0, time.UTC)
+	if err := sub.Cancel(my(2025, time.September), at); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if sub.EndDate == nil || !sub.EndDate.ToTime().Equal(my(2025, time.September).ToTime()) || sub.CancelledAt == nil {
+		t.Errorf("после отмены end_date=%v cancelled_at=%v", sub.EndDate, sub.CancelledAt)
+	}
+	if err := sub.Cancel(my(2025, time.September), at); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("повторная отмена: ошибка %v, ожидалась ErrInvalidTransition", err)
+	}
+	if err := sub.Pause(my(2025, time.August), nil); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("приостановка отменённой подписки: ошибка %v, ожидалась ErrInvalidTransition", err)
+	}
+
+	// Возобновление отменённой подписки в декабре: октябрь–ноябрь становятся приостановкой
+	if err := sub.Resume(my(2025, time.December)); err != nil {
+		t.Fatalf("Resume после отмены: %v", err)
+	}
+	if sub.EndDate != nil || sub.CancelledAt != nil || len(sub.Pauses) != 2 {
+		t.Fatalf("после возобновления end_date=%v cancelled_at=%v pauses=%v", sub.EndDate, sub.CancelledAt, sub.Pauses)
+	}
+	if got := sub.ActiveMonths(my(2025, time.January), my(2025, time.December)); got != 7 {
+		t.Errorf("ActiveMonths() = %d, ожидалось 7", got)
+	}
+
+	timeline := timelineOf(t, []Subscription{sub}, my(2025, time.October), my(2025, time.December), byCharges)
+	if timeline[0].Total != 0 || timeline[1].Total != 0 || timeline[2].Total != 100 {
+		t.Errorf("разбивка по месяцам: %+v", timeline)
+	}
+	groups := groupsOf(t, []Subscription{sub}, my(2025, time.March), my(2025, time.December), nil, byCharges)
+	if len(groups) != 1 || groups[0].Total != 500 || groups[0].Subscriptions != 1 {
+		t.Errorf("группировка: %+v", groups)
+	}
+}
@@ -26,41 +26,128 @@ func (c *MonthYear) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &s); err != nil {
 		return err // Ошибка при распаковке JSON строки
 	}
-	t, err := time.Parse("01-2006", s) // Парсим "ММ-ГГГГ"
+	m, err := ParseMonthYear(s) // Парсим "ММ-ГГГГ"
 	if err != nil {
 		return err // Ошибка парсинга даты
 	}
-	*c = MonthYear(t)
+	*c = m
 	return nil
 }
 
+// ParseMonthYear парсит строку формата "MM-YYYY" (например, "07-2025")
+func ParseMonthYear(s string) (MonthYear, error) {
+	t, err := time.Parse("01-2006", s)
+	return MonthYear(t), err
+}
+
 // ToTime конвертирует MonthYear в стандартный time.Time
 func (c MonthYear) ToTime() time.Time {
 	return time.Time(c)
 }
 
+// dateLayout — формат полной даты (YYYY-MM-DD), принимаемый наравне с MM-YYYY
+const dateLayout = "2006-01-02"
+
+// ParseDate парсит дату в формате "MM-YYYY" (первое число месяца) или полную дату "YYYY-MM-DD".
+// День полной даты сохраняется в MonthYear, но в JSON по-прежнему выводятся только месяц и год.
+func ParseDate(s string) (MonthYear, error) {
+	if len(s) == len(dateLayout) {
+		t, err := time.Parse(dateLayout, s)
+		return MonthYear(t), err
+	}
+	return ParseMonthYear(s)
+}
+
+// Day возвращает день месяца даты (1 для дат, заданных как MM-YYYY)
+func (c MonthYear) Day() int {
+	return time.Time(c).Day()
+}
+
+// StartOfMonth возвращает первое число месяца даты
+func (c MonthYear) StartOfMonth() MonthYear {
+	t := time.Time(c)
+	return MonthYear(time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC))
+}
+
+// WithDay возвращает дату того же месяца с днём day; в коротких месяцах — последний день месяца
+func (c MonthYear) WithDay(day int) MonthYear {
+	first := c.StartOfMonth().ToTime()
+	last := first.AddDate(0, 1, -1).Day()
+	return MonthYear(first.AddDate(0, 0, min(day, last)-1))
+}
+
 // Subscription — модель подписки пользователя на сервис
 // @Description Подписка пользователя на онлайн-сервис. Используется для учёта затрат.
 // @Example {
+//   "id": "0b6c3f1e-8f0a-4a57-9d2c-1d2f5e7a9b10",
 //   "service_name": "Netflix",
 //   "price": 999,
+//   "currency": "RUB",
+//   "billing_period": "month",
 //   "user_id": "4a79c82c-b09f-4cde-bf80-6edfd680793e",
 //   "start_date": "07-2025",
-//   "end_date": "12-2025"
+//   "billing_day": 15,
+//   "end_date": "12-2025",
+//   "version": 42,
+//   "status": "active"
 // }
 type Subscription struct {
+	// Идентификатор подписки (назначается при создании)
+	ID uuid.UUID `json:"id" format:"uuid" example:"0b6c3f1e-8f0a-4a57-9d2c-1d2f5e7a9b10"`
+
 	// Название сервиса, например "Netflix"
 	ServiceName string `json:"service_name" example:"Netflix"`
 
-	// Цена подписки в рублях
-	Price int `json:"price" example:"999"`
+	// Цена подписки за один период оплаты в валюте currency (строка с двумя знаками после точки)
+	Price Money `json:"price" swaggertype:"string" example:"999.00"`
+
+	// Валюта цены (код ISO 4217), по умолчанию RUB
+	Currency Currency `json:"currency" example:"RUB"`
+
+	// Период оплаты: week, month (по умолчанию), quarter, year или Nm — раз в N месяцев
+	BillingPeriod BillingPeriod `json:"billing_period" example:"month"`
 
 	// UUID пользователя
 	UserID uuid.UUID `json:"user_id" format:"uuid" example:"4a79c82c-b09f-4cde-bf80-6edfd680793e"`
 
-	// Дата начала подписки (месяц и год)
+	// Дата начала подписки (месяц и год); день начала хранится и возвращается в billing_day
 	StartDate MonthYear `json:"start_date" format:"MM-YYYY" example:"07-2025"`
 
+	// День месяца, к которому привязаны списания (день даты начала, 1–31)
+	BillingDay int `json:"billing_day" example:"15"`
+
 	// Опциональная дата окончания подписки (месяц и год)
 	EndDate *MonthYear `json:"end_date,omitempty" format:"MM-YYYY" example:"12-2025"`
-}
\ No newline at end of file
+
+	// Версия подписки; меняется при каждом изменении и возвращается в заголовке ETag
+	Version int64 `json:"version" example:"42"`
+
+	// Состояние подписки в текущем месяце (вычисляется, только для чтения)
+	Status Status `json:"status" enums:"active,upcoming,expired,paused,cancelled" example:"active"`
+
+	// Приостановки подписки; месяцы приостановки не учитываются в расходах (только для чтения)
+	Pauses []Pause `json:"pauses,omitempty"`
+
+	// Момент отмены подписки; end_date отменённой подписки — её последний оплачиваемый месяц (только для чтения)
+	CancelledAt *time.Time `json:"cancelled_at,omitempty" example:"2025-08-15T10:00:00Z"`
+}
+
+// FillComputed заполняет вычисляемые поля подписки: состояние на текущий месяц и день списаний
+func (s *Subscription) FillComputed() {
+	s.Status = s.StatusAt(CurrentMonth())
+	s.BillingDay = s.StartDate.Day()
+}
+
+// SubscriptionPatch — частичное обновление подписки.
+// Поля со значением nil не изменяются; ClearEndDate явно убирает дату окончания,
+// BillingDay переносит дату начала на другой день того же месяца.
+type SubscriptionPatch struct {
+	ServiceName   *string
+	Price         *Money
+	Currency      *Currency
+	BillingPeriod *BillingPeriod
+	StartDate     *MonthYear
+	BillingDay    *int
+	EndDate       *MonthYear
+	ClearEndDate  bool
+}
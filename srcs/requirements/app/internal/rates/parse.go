@@ -0,0 +1,250 @@
+package rates
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/net/html/charset"
+
+	"subscription_service/internal/model"
+)
+
+// Format — формат файла курсов; он же записывается источником (Source) разобранных курсов
+type Format string
+
+// Поддерживаемые форматы файлов курсов
+const (
+	FormatCBR Format = "cbr" // ежедневный XML ЦБ РФ
+	FormatECB Format = "ecb" // CSV ЕЦБ: курсы евро в валютах колонок
+	FormatCSV Format = "csv" // CSV с колонками date, currency, rate (курс к рублю)
+)
+
+// ParseFormat разбирает название формата; пустая строка означает определение формата по содержимому
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "", FormatCBR, FormatECB, FormatCSV:
+		return f, nil
+	default:
+		return "", fmt.Errorf("неизвестный формат курсов: %q", s)
+	}
+}
+
+// Parse разбирает курсы в формате format; при пустом format он определяется по началу данных:
+// XML — ежедневный XML ЦБ РФ, CSV с заголовком Date и колонками валют — ЕЦБ, иначе CSV date,currency,rate
+func Parse(r io.Reader, format Format) ([]model.ExchangeRate, error) {
+	if format == "" {
+		buffered := bufio.NewReader(r)
+		head, _ := buffered.Peek(512)
+		format, r = detect(head), buffered
+	}
+	switch format {
+	case FormatCBR:
+		return ParseCBR(r)
+	case FormatECB:
+		return ParseECB(r)
+	default:
+		return ParseCSV(r)
+	}
+}
+
+// detect определяет формат файла курсов по первым байтам
+func detect(head []byte) Format {
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\ufeff")), " \t\r\n")
+	if bytes.HasPrefix(head, []byte("<")) {
+		return FormatCBR
+	}
+	line, _, _ := bytes.Cut(head, []byte("\n"))
+	fields := strings.Split(string(line), ",")
+	if strings.TrimSpace(fields[0]) == "Date" && len(fields) > 1 && !strings.EqualFold(strings.TrimSpace(fields[1]), "currency") {
+		return FormatECB
+	}
+	return FormatCSV
+}
+
+// cbrDateLayout — формат даты в атрибуте Date ежедневного XML ЦБ РФ
+const cbrDateLayout = "02.01.2006"
+
+// cbrRates — ежедневный XML ЦБ РФ (http://www.cbr.ru/scripts/XML_daily.asp)
+type cbrRates struct {
+	Date    string `xml:"Date,attr"`
+	Valutes []struct {
+		CharCode string `xml:"CharCode"`
+		Nominal  string `xml:"Nominal"`
+		Value    string `xml:"Value"`
+	} `xml:"Valute"`
+}
+
+// ParseCBR разбирает ежедневный XML ЦБ РФ: курсы всех валют на дату из атрибута ValCurs Date.
+// Курс за Nominal единиц пересчитывается на одну единицу; дробная часть отделена запятой.
+func ParseCBR(r io.Reader) ([]model.ExchangeRate, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charset.NewReaderLabel
+
+	var doc cbrRates
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("неверный XML курсов: %w", err)
+	}
+	date, err := time.Parse(cbrDateLayout, doc.Date)
+	if err != nil {
+		return nil, fmt.Errorf("неверная дата курсов %q: ожидается DD.MM.YYYY", doc.Date)
+	}
+
+	rates := make([]model.ExchangeRate, 0, len(doc.Valutes))
+	for _, v := range doc.Valutes {
+		currency, err := model.ParseCurrency(strings.TrimSpace(v.CharCode))
+		if err != nil || v.CharCode == "" {
+			return nil, fmt.Errorf("неверный код валюты %q", v.CharCode)
+		}
+		nominal, err := strconv.Atoi(strings.TrimSpace(v.Nominal))
+		if err != nil || nominal <= 0 {
+			return nil, fmt.Errorf("неверный номинал %s: %q", currency, v.Nominal)
+		}
+		value, err := parseValue(strings.ReplaceAll(v.Value, ",", "."))
+		if err != nil {
+			return nil, fmt.Errorf("неверный курс %s: %w", currency, err)
+		}
+		rates = append(rates, model.ExchangeRate{
+			Currency: currency,
+			Quote:    model.BaseCurrency,
+			Date:     date.Format(dateLayout),
+			Rate:     value.Div(decimal.NewFromInt(int64(nominal))),
+			Source:   string(FormatCBR),
+		})
+	}
+	return rates, nil
+}
+
+// ParseCSV разбирает CSV с колонками date (YYYY-MM-DD), currency (ISO 4217) и rate —
+// стоимостью одной единицы валюты в рублях. Строка заголовка необязательна.
+func ParseCSV(r io.Reader) ([]model.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var rates []model.ExchangeRate
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("неверный CSV курсов: %w", err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimPrefix(record[0], "\ufeff"), "date") {
+			continue
+		}
+
+		date, err := time.Parse(dateLayout, strings.TrimPrefix(record[0], "\ufeff"))
+		if err != nil {
+			return nil, fmt.Errorf("строка %d: неверная дата %q: ожидается YYYY-MM-DD", line, record[0])
+		}
+		currency, err := model.ParseCurrency(record[1])
+		if err != nil || record[1] == "" {
+			return nil, fmt.Errorf("строка %d: неверный код валюты %q", line, record[1])
+		}
+		value, err := parseValue(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("строка %d: %w", line, err)
+		}
+		rates = append(rates, model.ExchangeRate{
+			Currency: currency,
+			Quote:    model.BaseCurrency,
+			Date:     date.Format(dateLayout),
+			Rate:     value,
+			Source:   string(FormatCSV),
+		})
+	}
+}
+
+// ecbDateLayouts — форматы дат в CSV ЕЦБ: исторический файл (eurofxref-hist.csv) и ежедневный (eurofxref.csv)
+var ecbDateLayouts = []string{"2006-01-02", "2 January 2006"}
+
+// ParseECB разбирает CSV ЕЦБ: заголовок Date и коды валют, далее по строке на дату с курсами евро
+// в каждой из валют. Курс сохраняется как есть — стоимость одного евро в валюте колонки;
+// пустые значения и N/A (курс не публиковался) пропускаются.
+func ParseECB(r io.Reader) ([]model.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("неверный CSV курсов ЕЦБ: %w", err)
+	}
+	if strings.TrimSpace(strings.TrimPrefix(header[0], "\ufeff")) != "Date" {
+		return nil, fmt.Errorf("неверный заголовок CSV курсов ЕЦБ: первая колонка %q, ожидается Date", header[0])
+	}
+	currencies := make([]model.Currency, len(header))
+	for i, code := range header[1:] {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue // ЕЦБ завершает строки запятой
+		}
+		currency, err := model.ParseCurrency(code)
+		if err != nil {
+			return nil, fmt.Errorf("неверный код валюты в заголовке: %q", code)
+		}
+		currencies[i+1] = currency
+	}
+
+	var rates []model.ExchangeRate
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("неверный CSV курсов ЕЦБ: %w", err)
+		}
+		date, err := parseECBDate(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("строка %d: %w", line, err)
+		}
+		for i, v := range record[1:] {
+			v = strings.TrimSpace(v)
+			if i+1 >= len(currencies) || currencies[i+1] == "" || v == "" || v == "N/A" {
+				continue
+			}
+			value, err := parseValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("строка %d, %s: %w", line, currencies[i+1], err)
+			}
+			rates = append(rates, model.ExchangeRate{
+				Currency: "EUR",
+				Quote:    currencies[i+1],
+				Date:     date,
+				Rate:     value,
+				Source:   string(FormatECB),
+			})
+		}
+	}
+}
+
+// parseECBDate разбирает дату строки CSV ЕЦБ в формат YYYY-MM-DD
+func parseECBDate(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range ecbDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(dateLayout), nil
+		}
+	}
+	return "", fmt.Errorf("неверная дата %q: ожидается YYYY-MM-DD или \"2 January 2006\"", s)
+}
+
+// parseValue разбирает положительный курс без потери точности
+func parseValue(s string) (decimal.Decimal, error) {
+	v, err := decimal.NewFromString(strings.TrimSpace(s))
+	if err != nil || !v.IsPositive() {
+		return decimal.Decimal{}, fmt.Errorf("ожидается положительное число, получено %q", s)
+	}
+	return v, nil
+}
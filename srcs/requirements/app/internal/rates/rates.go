@@ -0,0 +1,240 @@
+// Package rates — таблица курсов валют по датам и их разбор из файлов
+// (ежедневный XML ЦБ РФ, CSV ЕЦБ или CSV с колонками date, currency, rate).
+package rates
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"subscription_service/internal/model"
+)
+
+// dateLayout — формат дат курсов (совпадает с model.ExchangeRate.Date)
+const dateLayout = "2006-01-02"
+
+// pair — валютная пара курса: стоимость единицы currency в quote
+type pair struct {
+	currency, quote model.Currency
+}
+
+// Table — курсы валют по датам; реализует model.Rates.
+// На дату действует последний курс, установленный не позже неё (как курс ЦБ действует до следующего).
+// Если прямого курса к рублю нет, он получается через промежуточную валюту:
+// например, курс доллара из курсов ЕЦБ (евро в долларах) и курса евро к рублю.
+// Безопасна для одновременного использования.
+type Table struct {
+	mu     sync.RWMutex
+	points map[pair][]model.ExchangeRate // курсы каждой пары по возрастанию даты
+}
+
+// NewTable — конструктор для Table с курсами rates
+func NewTable(rates ...model.ExchangeRate) *Table {
+	t := &Table{points: make(map[pair][]model.ExchangeRate)}
+	t.Add(rates...)
+	return t
+}
+
+// Add добавляет курсы в таблицу; курс пары на уже известную дату заменяется
+func (t *Table) Add(rates ...model.ExchangeRate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, r := range rates {
+		p := pair{currency: r.Currency, quote: r.Quote}
+		points := t.points[p]
+		i := sort.Search(len(points), func(i int) bool { return points[i].Date >= r.Date })
+		if i < len(points) && points[i].Date == r.Date {
+			points[i] = r
+			continue
+		}
+		points = append(points, model.ExchangeRate{})
+		copy(points[i+1:], points[i:])
+		points[i] = r
+		t.points[p] = points
+	}
+}
+
+// Rate возвращает стоимость единицы валюты currency в рублях на дату on и курсы, из которых она получена
+func (t *Table) Rate(currency model.Currency, on time.Time) (decimal.Decimal, []model.ExchangeRate, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	date := on.Format(dateLayout)
+	if rate, used, ok := t.cross(currency, model.BaseCurrency, date); ok {
+		return rate, []model.ExchangeRate{used}, nil
+	}
+	// Кросс-курс через валюту, у которой есть и курс к рублю, и курс к currency
+	for _, via := range t.related(currency) {
+		first, firstUsed, ok := t.cross(currency, via, date)
+		if !ok {
+			continue
+		}
+		second, secondUsed, ok := t.cross(via, model.BaseCurrency, date)
+		if !ok {
+			continue
+		}
+		return first.Mul(second), []model.ExchangeRate{firstUsed, secondUsed}, nil
+	}
+	return decimal.Decimal{}, nil, model.NoRateError(currency, date)
+}
+
+// cross возвращает стоимость единицы from в to на дату date по прямому или обратному курсу пары
+func (t *Table) cross(from, to model.Currency, date string) (decimal.Decimal, model.ExchangeRate, bool) {
+	if r, ok := t.find(pair{currency: from, quote: to}, date); ok {
+		return r.Rate, r, true
+	}
+	if r, ok := t.find(pair{currency: to, quote: from}, date); ok {
+		return decimal.NewFromInt(1).Div(r.Rate), r, true
+	}
+	return decimal.Decimal{}, model.ExchangeRate{}, false
+}
+
+// find возвращает курс пары, действующий на дату date
+func (t *Table) find(p pair, date string) (model.ExchangeRate, bool) {
+	points := t.points[p]
+	i := sort.Search(len(points), func(i int) bool { return points[i].Date > date })
+	if i == 0 {
+		return model.ExchangeRate{}, false
+	}
+	return points[i-1], true
+}
+
+// related возвращает валюты, кроме рубля, с которыми у currency есть курсы, в порядке кодов
+func (t *Table) related(currency model.Currency) []model.Currency {
+	seen := map[model.Currency]bool{}
+	for p := range t.points {
+		switch {
+		case p.currency == currency && p.quote != model.BaseCurrency:
+			seen[p.quote] = true
+		case p.quote == currency && p.currency != model.BaseCurrency:
+			seen[p.currency] = true
+		}
+	}
+	related := make([]model.Currency, 0, len(seen))
+	for c := range seen {
+		related = append(related, c)
+	}
+	sort.Slice(related, func(i, j int) bool { return related[i] < related[j] })
+	return related
+}
+
+// Len возвращает число курсов в таблице
+func (t *Table) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	n := 0
+	for _, points := range t.points {
+		n += len(points)
+	}
+	return n
+}
+
+// Loader загружает курсы пар, в которых участвует валюта currency (как currency или как quote)
+type Loader func(currency model.Currency) ([]model.ExchangeRate, error)
+
+// Lazy — таблица курсов, которая загружает курсы валюты при первом запросе курса этой валюты,
+// а вместе с ними — курсы валют, с которыми у неё есть пары (для кросс-курса); реализует model.Rates.
+// Курсы валют, которые не пересчитываются, не загружаются вовсе. Безопасна для одновременного использования.
+type Lazy struct {
+	load  Loader
+	table *Table
+
+	mu       sync.Mutex
+	related  map[model.Currency][]model.Currency // загруженные валюты и валюты их пар
+	complete map[model.Currency]bool             // валюты, для которых загружены и курсы валют их пар
+}
+
+// NewLazy — конструктор для Lazy с загрузкой курсов через load
+func NewLazy(load Loader) *Lazy {
+	return &Lazy{
+		load:     load,
+		table:    NewTable(),
+		related:  map[model.Currency][]model.Currency{},
+		complete: map[model.Currency]bool{},
+	}
+}
+
+// Rate загружает курсы currency, если они ещё не загружены, и возвращает её курс к рублю на дату on (см. Table.Rate)
+func (l *Lazy) Rate(currency model.Currency, on time.Time) (decimal.Decimal, []model.ExchangeRate, error) {
+	if err := l.require(currency); err != nil {
+		return decimal.Decimal{}, nil, err
+	}
+	return l.table.Rate(currency, on)
+}
+
+// require загружает курсы currency и валют, с которыми у неё есть пары, кроме рубля
+func (l *Lazy) require(currency model.Currency) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.complete[currency] {
+		return nil
+	}
+	related, err := l.fetch(currency)
+	if err != nil {
+		return err
+	}
+	for _, via := range related {
+		if _, err := l.fetch(via); err != nil {
+			return err
+		}
+	}
+	l.complete[currency] = true
+	return nil
+}
+
+// fetch загружает курсы currency в таблицу, если они ещё не загружены, и возвращает валюты её пар, кроме рубля
+func (l *Lazy) fetch(currency model.Currency) ([]model.Currency, error) {
+	if related, ok := l.related[currency]; ok {
+		return related, nil
+	}
+	list, err := l.load(currency)
+	if err != nil {
+		return nil, err
+	}
+	l.table.Add(list...)
+
+	seen := map[model.Currency]bool{}
+	related := []model.Currency{}
+	for _, r := range list {
+		via := r.Quote
+		if via == currency {
+			via = r.Currency
+		}
+		if via != model.BaseCurrency && !seen[via] {
+			seen[via] = true
+			related = append(related, via)
+		}
+	}
+	l.related[currency] = related
+	return related, nil
+}
+
+// Load читает курсы из файлов paths; формат каждого файла определяется по содержимому (см. Parse)
+func Load(paths ...string) ([]model.ExchangeRate, error) {
+	var rates []model.ExchangeRate
+	for _, path := range paths {
+		parsed, err := loadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("файл курсов %s: %w", path, err)
+		}
+		rates = append(rates, parsed...)
+	}
+	return rates, nil
+}
+
+func loadFile(path string) ([]model.ExchangeRate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f, "")
+}
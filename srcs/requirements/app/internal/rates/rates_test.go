@@ -0,0 +1,248 @@
+package rates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/encoding/charmap"
+
+	"subscription_service/internal/model"
+)
+
+// cbrDaily — фрагмент ежедневного XML ЦБ РФ
+const cbrDaily = `<?xml version="1.0" encoding="windows-1251"?>
+<ValCurs Date="01.03.2025" name="Foreign Currency Market">
+<Valute ID="R01235"><NumCode>840</NumCode><CharCode>USD</CharCode><Nominal>1</Nominal><Name>Доллар США</Name><Value>89,9865</Value></Valute>
+<Valute ID="R01375"><NumCode>156</NumCode><CharCode>CNY</CharCode><Nominal>10</Nominal><Name>Китайский юань</Name><Value>123,4500</Value></Valute>
+</ValCurs>`
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// rub — курс валюты к рублю на дату
+func rub(currency model.Currency, date, value string) model.ExchangeRate {
+	return model.ExchangeRate{Currency: currency, Quote: model.BaseCurrency, Date: date, Rate: decimal.RequireFromString(value)}
+}
+
+func TestTableRate(t *testing.T) {
+	table := NewTable(rub("USD", "2025-03-01", "90"), rub("USD", "2025-01-01", "100"))
+	// Курс на дату заменяется, а не дублируется
+	table.Add(rub("USD", "2025-03-01", "89"))
+
+	tests := []struct {
+		on       time.Time
+		want     int64
+		wantDate string
+	}{
+		{date(2025, time.January, 1), 100, "2025-01-01"},
+		{date(2025, time.February, 15), 100, "2025-01-01"},
+		{date(2025, time.March, 1), 89, "2025-03-01"},
+		{date(2026, time.January, 1), 89, "2025-03-01"},
+	}
+	for _, tt := range tests {
+		got, used, err := table.Rate("USD", tt.on)
+		if err != nil || !got.Equal(decimal.NewFromInt(tt.want)) || len(used) != 1 || used[0].Date != tt.wantDate {
+			t.Errorf("Rate(USD, %s) = %v, %+v, %v; ожидалось %v на %s", tt.on.Format(dateLayout), got, used, err, tt.want, tt.wantDate)
+		}
+	}
+	if table.Len() != 2 {
+		t.Errorf("Len() = %d, ожидалось 2", table.Len())
+	}
+
+	if _, _, err := table.Rate("USD", date(2024, time.December, 31)); !errors.Is(err, model.ErrNoRate) {
+		t.Errorf("курс до первой даты: %v", err)
+	}
+	if _, _, err := table.Rate("EUR", date(2025, time.March, 1)); !errors.Is(err, model.ErrNoRate) {
+		t.Errorf("неизвестная валюта: %v", err)
+	}
+}
+
+func TestTableCrossRate(t *testing.T) {
+	// Курсы ЕЦБ (евро в долларах и кронах) и курс евро к рублю от ЦБ
+	table := NewTable(
+		rub("EUR", "2025-02-28", "100"),
+		model.ExchangeRate{Currency: "EUR", Quote: "USD", Date: "2025-03-03", Rate: decimal.RequireFromString("1.25")},
+		model.ExchangeRate{Currency: "EUR", Quote: "ISK", Date: "2025-03-03", Rate: decimal.RequireFromString("150")},
+	)
+
+	rate, used, err := table.Rate("USD", date(2025, time.March, 5))
+	if err != nil || !rate.Equal(decimal.NewFromInt(80)) {
+		t.Fatalf("USD через EUR: %v, %v", rate, err)
+	}
+	if len(used) != 2 || used[0].Quote != "USD" || used[0].Date != "2025-03-03" || used[1].Currency != "EUR" || used[1].Date != "2025-02-28" {
+		t.Errorf("использованные курсы: %+v", used)
+	}
+	// Курс евро к рублю есть, а курса ЕЦБ на эту дату ещё нет
+	if _, _, err := table.Rate("ISK", date(2025, time.March, 1)); !errors.Is(err, model.ErrNoRate) {
+		t.Errorf("ISK до первой даты ЕЦБ: %v", err)
+	}
+}
+
+func TestLazyRate(t *testing.T) {
+	all := []model.ExchangeRate{
+		rub("EUR", "2025-02-28", "100"),
+		model.ExchangeRate{Currency: "EUR", Quote: "USD", Date: "2025-03-03", Rate: decimal.RequireFromString("1.25")},
+		rub("CNY", "2025-03-01", "12"),
+	}
+	var calls []model.Currency
+	lazy := NewLazy(func(currency model.Currency) ([]model.ExchangeRate, error) {
+		calls = append(calls, currency)
+		var list []model.ExchangeRate
+		for _, r := range all {
+			if r.Currency == currency || r.Quote == currency {
+				list = append(list, r)
+			}
+		}
+		return list, nil
+	})
+
+	// Для кросс-курса загружаются курсы доллара и валюты его пары (евро), но не юаня
+	rate, used, err := lazy.Rate("USD", date(2025, time.March, 5))
+	if err != nil || !rate.Equal(decimal.NewFromInt(80)) || len(used) != 2 {
+		t.Fatalf("USD через EUR: %v, %+v, %v", rate, used, err)
+	}
+	if len(calls) != 2 || calls[0] != "USD" || calls[1] != "EUR" {
+		t.Errorf("загружены курсы %v, ожидались USD и EUR", calls)
+	}
+	// Повторные запросы не загружают курсы снова
+	if _, _, err := lazy.Rate("EUR", date(2025, time.March, 5)); err != nil || len(calls) != 2 {
+		t.Errorf("EUR: %v, загрузки %v", err, calls)
+	}
+	if _, _, err := lazy.Rate("GBP", date(2025, time.March, 5)); !errors.Is(err, model.ErrNoRate) {
+		t.Errorf("GBP без курсов: %v", err)
+	}
+
+	failed := NewLazy(func(model.Currency) ([]model.ExchangeRate, error) { return nil, os.ErrClosed })
+	if _, _, err := failed.Rate("USD", date(2025, time.March, 5)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("ошибка загрузки: %v", err)
+	}
+}
+
+func TestParseCBR(t *testing.T) {
+	encoded, err := charmap.Windows1251.NewEncoder().String(cbrDaily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates, err := ParseCBR(strings.NewReader(encoded))
+	if err != nil || len(rates) != 2 {
+		t.Fatalf("ParseCBR: %+v, %v", rates, err)
+	}
+	if r := rates[0]; r.Currency != "USD" || r.Quote != "RUB" || r.Date != "2025-03-01" || r.Source != "cbr" || !r.Rate.Equal(decimal.RequireFromString("89.9865")) {
+		t.Errorf("USD: %+v", r)
+	}
+	// Курс за 10 юаней пересчитывается на один
+	if r := rates[1]; r.Currency != "CNY" || !r.Rate.Equal(decimal.RequireFromString("12.345")) {
+		t.Errorf("CNY: %+v", r)
+	}
+
+	if _, err := ParseCBR(strings.NewReader(`<ValCurs Date="2025-03-01"></ValCurs>`)); err == nil {
+		t.Error("неверная дата: ожидалась ошибка")
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	rates, err := ParseCSV(strings.NewReader("date,currency,rate\n2025-01-01,usd,100.5\n2025-02-01, EUR, 110\n"))
+	if err != nil || len(rates) != 2 {
+		t.Fatalf("ParseCSV: %+v, %v", rates, err)
+	}
+	if r := rates[0]; r.Currency != "USD" || !r.Rate.Equal(decimal.RequireFromString("100.5")) || r.Date != "2025-01-01" {
+		t.Errorf("первая строка: %+v", r)
+	}
+
+	// Без заголовка файл тоже читается
+	if rates, err := ParseCSV(strings.NewReader("2025-01-01,USD,100\n")); err != nil || len(rates) != 1 {
+		t.Errorf("без заголовка: %+v, %v", rates, err)
+	}
+
+	for _, in := range []string{
+		"01-2025,USD,100\n",
+		"2025-01-01,US,100\n",
+		"2025-01-01,USD,-1\n",
+		"2025-01-01,USD\n",
+	} {
+		if _, err := ParseCSV(strings.NewReader(in)); err == nil {
+			t.Errorf("ParseCSV(%q): ожидалась ошибка", in)
+		}
+	}
+}
+
+func TestParseECB(t *testing.T) {
+	// Ежедневный файл ЕЦБ: даты словами, пробелы после запятых, завершающая запятая
+	daily := "Date, USD, JPY, ISK, \n17 October 2025, 1.1697, 176.29, N/A, \n"
+	rates, err := ParseECB(strings.NewReader(daily))
+	if err != nil || len(rates) != 2 {
+		t.Fatalf("ParseECB: %+v, %v", rates, err)
+	}
+	if r := rates[0]; r.Currency != "EUR" || r.Quote != "USD" || r.Date != "2025-10-17" || r.Source != "ecb" || !r.Rate.Equal(decimal.RequireFromString("1.1697")) {
+		t.Errorf("USD: %+v", r)
+	}
+
+	// Исторический файл ЕЦБ
+	hist := "Date,USD,JPY,\n2025-10-17,1.1697,176.29,\n2025-10-16,1.1681,175.9,\n"
+	if rates, err := ParseECB(strings.NewReader(hist)); err != nil || len(rates) != 4 || rates[2].Date != "2025-10-16" {
+		t.Errorf("исторический файл: %+v, %v", rates, err)
+	}
+
+	for _, in := range []string{
+		"date,currency,rate\n",
+		"Date,USD\n17.10.2025,1.1697\n",
+		"Date,USD\n2025-10-17,abc\n",
+		"Date,US\n",
+	} {
+		if _, err := ParseECB(strings.NewReader(in)); err == nil {
+			t.Errorf("ParseECB(%q): ожидалась ошибка", in)
+		}
+	}
+}
+
+func TestParseDetect(t *testing.T) {
+	encoded, _ := charmap.Windows1251.NewEncoder().String(cbrDaily)
+	tests := []struct {
+		in     string
+		source string
+	}{
+		{encoded, "cbr"},
+		{"Date,USD,JPY\n2025-10-17,1.1697,176.29\n", "ecb"},
+		{"\ufeffdate,currency,rate\n2025-01-01,USD,100\n", "csv"},
+		{"2025-01-01,USD,100\n", "csv"},
+	}
+	for _, tt := range tests {
+		rates, err := Parse(strings.NewReader(tt.in), "")
+		if err != nil || len(rates) == 0 || rates[0].Source != tt.source {
+			t.Errorf("Parse(%.20q): %+v, %v; ожидался формат %s", tt.in, rates, err, tt.source)
+		}
+	}
+	if _, err := ParseFormat("xls"); err == nil {
+		t.Error("ParseFormat(xls): ожидалась ошибка")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	encoded, _ := charmap.Windows1251.NewEncoder().String(cbrDaily)
+	xmlPath := filepath.Join(dir, "daily.xml")
+	csvPath := filepath.Join(dir, "rates.csv")
+	if err := os.WriteFile(xmlPath, []byte(encoded), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(csvPath, []byte("date,currency,rate\n2025-01-01,EUR,105\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(xmlPath, csvPath)
+	if err != nil || len(loaded) != 3 {
+		t.Fatalf("Load: %+v, %v", loaded, err)
+	}
+	if rate, _, err := NewTable(loaded...).Rate("EUR", date(2025, time.April, 1)); err != nil || !rate.Equal(decimal.NewFromInt(105)) {
+		t.Errorf("EUR из CSV: %v, %v", rate, err)
+	}
+	if _, err := Load(filepath.Join(dir, "missing.csv")); err == nil {
+		t.Error("отсутствующий файл: ожидалась ошибка")
+	}
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"subscription_service/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BatchError — ошибка пакетной операции: элементы с индексами Indexes нарушили ограничение Err.
+// Пакет выполняется в одной транзакции, поэтому при ошибке ни один элемент не сохранён.
+type BatchError struct {
+	Indexes []int
+	Err     error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("элементы пакета %v: %v", e.Indexes, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// flaggedRow — строка результата с дополнительной последней колонкой-признаком,
+// которую не читает scanSubscription
+type flaggedRow struct {
+	pgx.Row
+	flag *bool
+}
+
+func (r flaggedRow) Scan(dest ...any) error {
+	return r.Row.Scan(append(dest, r.flag)...)
+}
+
+// CreateSubscriptions создаёт пакет подписок в одной транзакции; запросы отправляются одним pgx.Batch.
+// Подписка с уже занятым составным ключом (в том числе занятым предыдущим элементом пакета)
+// в режиме ConflictSkip пропускается, в режиме ConflictUpdate получает новые цену, валюту, период оплаты и дату окончания,
+// а в режиме ConflictError отменяет весь пакет с BatchError, обёрнутой вокруг ErrAlreadyExists.
+// При нарушении ограничений таблицы возвращает BatchError с ErrInvalid.
+// Возвращает результаты в порядке элементов пакета.
+func (r *SubRepository) CreateSubscriptions(ctx context.Context, subs []model.Subscription, mode model.ConflictMode) ([]model.BatchResult, error) {
+	log.Printf("Пакетное создание подписок: %d шт., при конфликте %s", len(subs), mode)
+
+	onConflict := "ON CONFLICT DO NOTHING"
+	if mode == model.ConflictUpdate {
+		onConflict = `ON CONFLICT (user_id, service_name, (` + monthKeyExpr + `)) DO UPDATE
+        SET price = EXCLUDED.price, currency = EXCLUDED.currency, billing_period = EXCLUDED.billing_period, end_date = EXCLUDED.end_date, version = ` + nextVersion
+	}
+	query := `
+        INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date, billing_period, currency)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ` + onConflict + `
+        RETURNING ` + subscriptionColumns + `, xmax = 0`
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Printf("Ошибка при открытии транзакции: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, sub := range subs {
+		var end interface{}
+		if sub.EndDate != nil {
+			end = sub.EndDate.ToTime()
+		}
+		batch.Queue(query, sub.ServiceName, int64(sub.Price), sub.UserID, sub.StartDate.ToTime(), end, sub.BillingPeriod.String(), sub.Currency.String())
+	}
+
+	results := make([]model.BatchResult, len(subs))
+	var conflicts []int
+	br := tx.SendBatch(ctx, batch)
+	for i := range subs {
+		results[i].Index = i
+		var inserted bool
+		sub, err := scanSubscription(flaggedRow{Row: br.QueryRow(), flag: &inserted})
+		if err != nil {
+			err = mapError(err)
+			if errors.Is(err, ErrNotFound) {
+				// ON CONFLICT DO NOTHING не вернул строку — ключ уже занят
+				conflicts = append(conflicts, i)
+				results[i].Status = model.BatchSkipped
+				continue
+			}
+			br.Close()
+			log.Printf("Ошибка при пакетном создании подписки #%d: %v", i, err)
+			// К элементу относятся только нарушения ограничений; сбой соединения или базы отменяет пакет целиком
+			if errors.Is(err, ErrInvalid) || errors.Is(err, ErrAlreadyExists) {
+				return nil, &BatchError{Indexes: []int{i}, Err: err}
+			}
+			return nil, err
+		}
+		results[i].Status = model.BatchCreated
+		if !inserted {
+			results[i].Status = model.BatchUpdated
+		}
+		results[i].Subscription = &sub
+	}
+	if err := br.Close(); err != nil {
+		log.Printf("Ошибка при завершении пакета запросов: %v", err)
+		return nil, mapError(err)
+	}
+
+	if mode == model.ConflictError && len(conflicts) > 0 {
+		log.Printf("Пакет отменён: ключи уже заняты у элементов %v", conflicts)
+		return nil, &BatchError{Indexes: conflicts, Err: ErrAlreadyExists}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		err = mapError(err)
+		log.Printf("Ошибка при фиксации транзакции: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
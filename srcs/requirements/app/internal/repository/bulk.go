@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+
+	"subscription_service/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// errBulkKeyChange — массовое обновление не меняет поля составного ключа
+var errBulkKeyChange = &ConstraintError{Key: msgBulkKeyChange}
+
+// DeleteSubscriptionsByFilter удаляет в одной транзакции все подписки, подходящие под фильтр.
+// Подходящие строки блокируются, и если их число не равно expected (число из пробного запуска),
+// ничего не удаляется и возвращается ErrCountMismatch. Возвращает число удалённых подписок.
+func (r *SubRepository) DeleteSubscriptionsByFilter(ctx context.Context, filter model.SubscriptionFilter, expected int) (int, error) {
+	log.Printf("Массовое удаление подписок по фильтру %+v, ожидается %d", filter, expected)
+
+	return r.inBulkTx(ctx, filter, expected, func(tx pgx.Tx, ids []uuid.UUID) error {
+		_, err := tx.Exec(ctx, "DELETE FROM subscriptions WHERE id = ANY($1)", ids)
+		return err
+	})
+}
+
+// UpdateSubscriptionsByFilter применяет patch ко всем подпискам, подходящим под фильтр, в одной транзакции;
+// каждая изменённая подписка получает новую версию. Менять можно только цену, период оплаты и дату окончания,
+// для остальных полей возвращается ErrInvalid. Число подходящих подписок проверяется так же,
+// как в DeleteSubscriptionsByFilter. Возвращает число изменённых подписок.
+func (r *SubRepository) UpdateSubscriptionsByFilter(ctx context.Context, filter model.SubscriptionFilter, patch model.SubscriptionPatch, expected int) (int, error) {
+	log.Printf("Массовое обновление подписок по фильтру %+v: %+v, ожидается %d", filter, patch, expected)
+
+	if patch.ServiceName != nil || patch.StartDate != nil || patch.BillingDay != nil {
+		return 0, errBulkKeyChange
+	}
+	sets, args := patchSets(patch)
+	if len(sets) == 0 {
+		return 0, nil
+	}
+	sets = append(sets, "version = "+nextVersion)
+
+	return r.inBulkTx(ctx, filter, expected, func(tx pgx.Tx, ids []uuid.UUID) error {
+		query := "UPDATE subscriptions SET " + strings.Join(sets, ", ") + " WHERE id = ANY($" + strconv.Itoa(len(args)+1) + ")"
+		_, err := tx.Exec(ctx, query, append(args, ids)...)
+		return err
+	})
+}
+
+// inBulkTx выбирает с блокировкой подписки, подходящие под фильтр, сверяет их число с expected
+// и выполняет apply над их идентификаторами в той же транзакции
+func (r *SubRepository) inBulkTx(ctx context.Context, filter model.SubscriptionFilter, expected int, apply func(tx pgx.Tx, ids []uuid.UUID) error) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Printf("Ошибка при открытии транзакции: %v", err)
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	where, args := buildFilter(filter)
+	rows, err := tx.Query(ctx, "SELECT id FROM subscriptions"+where+" FOR UPDATE", args...)
+	if err != nil {
+		log.Printf("Ошибка при выборе подписок для массовой операции: %v", err)
+		return 0, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		log.Printf("Ошибка при чтении подписок для массовой операции: %v", err)
+		return 0, err
+	}
+	if len(ids) != expected {
+		log.Printf("Под фильтр подходит %d подписок, ожидалось %d", len(ids), expected)
+		return 0, ErrCountMismatch
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if err := apply(tx, ids); err != nil {
+		err = mapError(err)
+		log.Printf("Ошибка при выполнении массовой операции: %v", err)
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		err = mapError(err)
+		log.Printf("Ошибка при фиксации транзакции: %v", err)
+		return 0, err
+	}
+	log.Printf("Массовая операция затронула подписок: %d", len(ids))
+	return len(ids), nil
+}
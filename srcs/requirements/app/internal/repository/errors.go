@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"subscription_service/internal/i18n"
+)
+
+// Ошибки хранилища подписок. Реализации SubscriptionStore оборачивают в них исходные ошибки,
+// так что проверять их нужно через errors.Is.
+var (
+	// ErrNotFound — подписка не найдена
+	ErrNotFound = errors.New("подписка не найдена")
+	// ErrAlreadyExists — подписка с таким ключом уже существует
+	ErrAlreadyExists = errors.New("подписка уже существует")
+	// ErrInvalid — данные подписки нарушают ограничения хранилища (например, отрицательная цена)
+	ErrInvalid = errors.New("некорректные данные подписки")
+	// ErrVersionMismatch — подписка изменилась: её версия не совпадает с ожидаемой (If-Match)
+	ErrVersionMismatch = errors.New("версия подписки не совпадает с ожидаемой")
+	// ErrCountMismatch — число подписок, подходящих под фильтр массовой операции, не совпадает с ожидаемым
+	ErrCountMismatch = errors.New("число подходящих подписок не совпадает с ожидаемым")
+	// ErrLeaseLost — резерв ключа идемпотентности истёк и перешёл к другому запросу
+	ErrLeaseLost = errors.New("резерв ключа идемпотентности перешёл к другому запросу")
+)
+
+// Коды ошибок PostgreSQL (SQLSTATE), которые переводятся в ошибки хранилища
+const (
+	pgUniqueViolation   = "23505"
+	pgCheckViolation    = "23514"
+	pgNotNullViolation  = "23502"
+	pgNumericOutOfRange = "22003"
+	pgDatetimeOverflow  = "22008"
+)
+
+// Ключи каталога i18n с причинами нарушения ограничений
+const (
+	msgRequired        = "required"
+	msgExpectMoney     = "expect_money"
+	msgExpectCurrency  = "expect_currency"
+	msgExpectQuote     = "expect_quote"
+	msgExpectRate      = "expect_rate"
+	msgConstraint      = "constraint_violated"
+	msgValueOutOfRange = "value_out_of_range"
+	msgDateOutOfRange  = "date_out_of_range"
+	msgBulkKeyChange   = "bulk_key_change"
+)
+
+// ConstraintError — нарушение ограничения хранилища, обёрнутое вокруг ErrInvalid: поле и причина по ключу
+// каталога i18n, чтобы обработчики могли перевести её на язык запроса. Error возвращает причину на русском.
+type ConstraintError struct {
+	Field string // поле подписки или курса; пустое, если ограничение не относится к одному полю
+	Key   string // ключ каталога i18n с причиной
+	Args  []any  // аргументы сообщения
+}
+
+func (e *ConstraintError) Error() string {
+	reason := i18n.NewCatalog(i18n.RU).Message(i18n.RU, e.Key, e.Args...)
+	if e.Field != "" {
+		reason = e.Field + ": " + reason
+	}
+	return ErrInvalid.Error() + ": " + reason
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return ErrInvalid
+}
+
+// checkConstraints — поле и причина для CHECK-ограничений таблиц по их именам в PostgreSQL
+// (ограничение колонки называется <таблица>_<колонка>_check, ограничение нескольких колонок — <таблица>_check)
+var checkConstraints = map[string]ConstraintError{
+	"subscriptions_price_check":     {Field: "price", Key: msgExpectMoney},
+	"subscriptions_currency_check":  {Field: "currency", Key: msgExpectCurrency},
+	"currency_rates_currency_check": {Field: "currency", Key: msgExpectCurrency},
+	"currency_rates_check":          {Field: "quote", Key: msgExpectQuote},
+	"currency_rates_rate_check":     {Field: "rate", Key: msgExpectRate},
+}
+
+// constraintError — ConstraintError для нарушения ограничения PostgreSQL
+func constraintError(pgErr *pgconn.PgError) *ConstraintError {
+	switch pgErr.Code {
+	case pgCheckViolation:
+		if e, ok := checkConstraints[pgErr.ConstraintName]; ok {
+			return &e
+		}
+		return &ConstraintError{Key: msgConstraint, Args: []any{pgErr.ConstraintName}}
+	case pgNotNullViolation:
+		return &ConstraintError{Field: pgErr.ColumnName, Key: msgRequired}
+	case pgNumericOutOfRange:
+		return &ConstraintError{Key: msgValueOutOfRange}
+	default:
+		return &ConstraintError{Key: msgDateOutOfRange}
+	}
+}
+
+// mapError переводит ошибки pgx и PostgreSQL в ошибки хранилища (ErrNotFound, ErrAlreadyExists,
+// ErrInvalid в виде ConstraintError). Остальные ошибки возвращаются без изменений.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return fmt.Errorf("%w: %s", ErrAlreadyExists, pgErr.Detail)
+		case pgCheckViolation, pgNotNullViolation, pgNumericOutOfRange, pgDatetimeOverflow:
+			return constraintError(pgErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestMapErrorConstraints(t *testing.T) {
+	tests := []struct {
+		pgErr *pgconn.PgError
+		want  ConstraintError
+		text  string
+	}{
+		{&pgconn.PgError{Code: pgCheckViolation, ConstraintName: "subscriptions_price_check"},
+			ConstraintError{Field: "price", Key: msgExpectMoney},
+			"некорректные данные подписки: price: ожидается неотрицательная сумма не более чем с двумя знаками после точки, например 199.99"},
+		{&pgconn.PgError{Code: pgCheckViolation, ConstraintName: "currency_rates_check"},
+			ConstraintError{Field: "quote", Key: msgExpectQuote}, ""},
+		{&pgconn.PgError{Code: pgCheckViolation, ConstraintName: "subscriptions_extra_check"},
+			ConstraintError{Key: msgConstraint, Args: []any{"subscriptions_extra_check"}},
+			"некорректные данные подписки: нарушено ограничение subscriptions_extra_check"},
+		{&pgconn.PgError{Code: pgNotNullViolation, ColumnName: "service_name"},
+			ConstraintError{Field: "service_name", Key: msgRequired}, ""},
+		{&pgconn.PgError{Code: pgNumericOutOfRange}, ConstraintError{Key: msgValueOutOfRange}, ""},
+		{&pgconn.PgError{Code: pgDatetimeOverflow}, ConstraintError{Key: msgDateOutOfRange}, ""},
+	}
+	for _, tt := range tests {
+		err := mapError(fmt.Errorf("вставка: %w", tt.pgErr))
+		var got *ConstraintError
+		if !errors.Is(err, ErrInvalid) || !errors.As(err, &got) ||
+			got.Field != tt.want.Field || got.Key != tt.want.Key || fmt.Sprint(got.Args) != fmt.Sprint(tt.want.Args) {
+			t.Errorf("%s %s: получено %#v, ожидалось %+v", tt.pgErr.Code, tt.pgErr.ConstraintName, err, tt.want)
+			continue
+		}
+		if tt.text != "" && err.Error() != tt.text {
+			t.Errorf("%s: текст %q, ожидался %q", tt.pgErr.ConstraintName, err.Error(), tt.text)
+		}
+	}
+}
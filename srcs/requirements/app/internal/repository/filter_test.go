@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// filterCase — фильтр списка подписок, ожидаемое условие buildFilter и подписки, которые должны под него попасть
+type filterCase struct {
+	name   string
+	filter func(f *model.SubscriptionFilter)
+	clause string
+	want   []string
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// filterFixture — подписки одного пользователя, на которых проверяются фильтры
+func filterFixture(userID uuid.UUID) []model.Subscription {
+	mid, _ := model.ParseDate("2025-03-15")
+	return []model.Subscription{
+		{ServiceName: "Netflix", Price: 99900, UserID: userID, StartDate: month(2025, time.January), EndDate: ptr(month(2025, time.June))},
+		{ServiceName: "Netflix Premium", Price: 149900, UserID: userID, StartDate: mid},
+		{ServiceName: "Okko", Price: 29900, UserID: userID, StartDate: month(2025, time.May), EndDate: ptr(month(2025, time.December))},
+		{ServiceName: "Яндекс Плюс", Price: 0, UserID: userID, StartDate: month(2024, time.November), EndDate: ptr(month(2025, time.February))},
+	}
+}
+
+var filterCases = []filterCase{
+	{"подстрока без учёта регистра", func(f *model.SubscriptionFilter) { f.ServiceName = ptr("netflix") },
+		"service_name ILIKE $2", []string{"Netflix", "Netflix Premium"}},
+	{"точное название", func(f *model.SubscriptionFilter) { f.ServiceName, f.ServiceNameExact = ptr("Netflix"), true },
+		"service_name = $2", []string{"Netflix"}},
+	{"точное название с учётом регистра", func(f *model.SubscriptionFilter) { f.ServiceName, f.ServiceNameExact = ptr("netflix"), true },
+		"service_name = $2", nil},
+	{"min_price включительно", func(f *model.SubscriptionFilter) { f.MinPrice = ptr(model.Money(29900)) },
+		"price >= $2", []string{"Netflix", "Netflix Premium", "Okko"}},
+	{"max_price включительно", func(f *model.SubscriptionFilter) { f.MaxPrice = ptr(model.Money(29900)) },
+		"price <= $2", []string{"Okko", "Яндекс Плюс"}},
+	{"диапазон цен", func(f *model.SubscriptionFilter) {
+		f.MinPrice, f.MaxPrice = ptr(model.Money(1)), ptr(model.Money(99900))
+	}, "price >= $2 AND price <= $3", []string{"Netflix", "Okko"}},
+	{"active_on с подпиской с середины месяца", func(f *model.SubscriptionFilter) { f.ActiveOn = ptr(month(2025, time.March)) },
+		startMonthExpr + " <= $2 AND (end_date IS NULL OR end_date >= $2)", []string{"Netflix", "Netflix Premium"}},
+	{"active_on в месяц окончания", func(f *model.SubscriptionFilter) { f.ActiveOn = ptr(month(2025, time.June)) },
+		startMonthExpr + " <= $2", []string{"Netflix", "Netflix Premium", "Okko"}},
+	{"ends_before строго раньше", func(f *model.SubscriptionFilter) { f.EndsBefore = ptr(month(2025, time.June)) },
+		"end_date < $2", []string{"Яндекс Плюс"}},
+	{"ends_after строго позже", func(f *model.SubscriptionFilter) { f.EndsAfter = ptr(month(2025, time.June)) },
+		"end_date > $2", []string{"Okko"}},
+	{"ends_before и ends_after", func(f *model.SubscriptionFilter) {
+		f.EndsBefore, f.EndsAfter = ptr(month(2025, time.December)), ptr(month(2025, time.January))
+	}, "end_date < $2 AND end_date > $3", []string{"Netflix", "Яндекс Плюс"}},
+	{"бессрочные", func(f *model.SubscriptionFilter) { f.OpenEnded = ptr(true) },
+		"end_date IS NULL", []string{"Netflix Premium"}},
+	{"с датой окончания", func(f *model.SubscriptionFilter) { f.OpenEnded = ptr(false) },
+		"end_date IS NOT NULL", []string{"Netflix", "Okko", "Яндекс Плюс"}},
+	{"начало не раньше месяца", func(f *model.SubscriptionFilter) { f.StartFrom = ptr(month(2025, time.March)) },
+		"start_date >= $2", []string{"Netflix Premium", "Okko"}},
+	{"начало не позже месяца", func(f *model.SubscriptionFilter) { f.StartTo = ptr(month(2025, time.March)) },
+		startMonthExpr + " <= $2", []string{"Netflix", "Netflix Premium", "Яндекс Плюс"}},
+	// Все даты подписок filterFixture в прошлом, поэтому их состояние на текущий месяц не меняется со временем
+	{"действующие", func(f *model.SubscriptionFilter) { f.Status = ptr(model.StatusActive) },
+		"status = $2", []string{"Netflix Premium"}},
+	{"закончившиеся", func(f *model.SubscriptionFilter) { f.Status = ptr(model.StatusExpired) },
+		"status = $2", []string{"Netflix", "Okko", "Яндекс Плюс"}},
+}
+
+// placeholder — параметр SQL-запроса вида $N
+var placeholder = regexp.MustCompile(`\$\d+`)
+
+// checkFilterCases создаёт подписки filterFixture в store и проверяет, что каждый фильтр из filterCases
+// отбирает ожидаемые подписки, а buildFilter формирует для него ожидаемое условие
+func checkFilterCases(t *testing.T, store SubscriptionStore) {
+	ctx := context.Background()
+	userID := uuid.New()
+	fixture := filterFixture(userID)
+	for _, sub := range fixture {
+		if err := store.CreateSubscription(ctx, &sub); err != nil {
+			t.Fatalf("Создание подписки %s: %v", sub.ServiceName, err)
+		}
+	}
+	t.Cleanup(func() {
+		if _, err := store.DeleteSubscriptionsByFilter(ctx, model.SubscriptionFilter{UserID: &userID}, len(fixture)); err != nil {
+			t.Errorf("Удаление подписок: %v", err)
+		}
+	})
+
+	for _, tt := range filterCases {
+		filter := model.SubscriptionFilter{UserID: &userID}
+		tt.filter(&filter)
+
+		where, args := buildFilter(filter)
+		if !strings.Contains(where, tt.clause) {
+			t.Errorf("%s: условие %q не содержит %q", tt.name, where, tt.clause)
+		}
+		if used := slices.Compact(slices.Sorted(slices.Values(placeholder.FindAllString(where, -1)))); len(used) != len(args) {
+			t.Errorf("%s: в условии %q параметры %v, передано %d", tt.name, where, used, len(args))
+		}
+
+		subs, _, _, err := store.ListSubscriptions(ctx, filter, model.Page{Limit: 10})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, sub := range subs {
+			got = append(got, sub.ServiceName)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: получены %q, ожидались %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryRepositoryFilters(t *testing.T) {
+	checkFilterCases(t, NewMemoryRepository())
+}
+
+// Те же фильтры в PostgreSQL: условия buildFilter должны отбирать те же подписки, что и matchesFilter
+func TestSubRepositoryFilters(t *testing.T) {
+	dsn := os.Getenv("DSN")
+	if dsn == "" {
+		t.Skip("Переменная окружения DSN не установлена, тест с PostgreSQL пропущен")
+	}
+	db, err := storage.NewPostgres(context.Background(), dsn)
+	if err != nil {
+		t.Fatalf("Не удалось подключиться к базе данных: %v", err)
+	}
+	t.Cleanup(db.Close) // после удаления подписок в checkFilterCases
+
+	checkFilterCases(t, new(SubRepository).NewSubRepository(db))
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"subscription_service/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ReserveIdempotencyKey резервирует ключ идемпотентности в таблице idempotency_keys.
+// Истёкший ключ и ключ с истёкшим резервом незавершённого запроса перезаписываются.
+// Если ключ занят, возвращает существующую запись.
+func (r *SubRepository) ReserveIdempotencyKey(ctx context.Context, rec model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	query := `
+        INSERT INTO idempotency_keys (key, request_hash, expires_at, lease_until, lease_token)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (key) DO UPDATE
+        SET request_hash = EXCLUDED.request_hash, status = 0, headers = '{}', body = NULL,
+            created_at = now(), expires_at = EXCLUDED.expires_at,
+            lease_until = EXCLUDED.lease_until, lease_token = EXCLUDED.lease_token
+        WHERE idempotency_keys.expires_at <= now()
+           OR (idempotency_keys.status = 0 AND idempotency_keys.lease_until <= now())
+        RETURNING key
+    `
+	var key string
+	err := r.db.QueryRow(ctx, query, rec.Key, rec.RequestHash, rec.ExpiresAt, rec.LeaseUntil, rec.LeaseToken).Scan(&key)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("Ошибка при резервировании ключа идемпотентности: %v", err)
+		return nil, err
+	}
+
+	// Ключ занят действующей записью — возвращаем её
+	var existing model.IdempotencyRecord
+	query = "SELECT key, request_hash, status, headers, body, expires_at, lease_until FROM idempotency_keys WHERE key = $1"
+	err = r.db.QueryRow(ctx, query, rec.Key).Scan(&existing.Key, &existing.RequestHash, &existing.Status, &existing.Headers, &existing.Body, &existing.ExpiresAt, &existing.LeaseUntil)
+	if err != nil {
+		err = mapError(err)
+		log.Printf("Ошибка при получении ключа идемпотентности: %v", err)
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// CompleteIdempotencyKey сохраняет статус, заголовки и тело ответа для ключа, зарезервированного с токеном token
+func (r *SubRepository) CompleteIdempotencyKey(ctx context.Context, key, token string, status int, headers map[string]string, body []byte) error {
+	query := "UPDATE idempotency_keys SET status = $3, headers = $4, body = $5 WHERE key = $1 AND lease_token = $2 AND status = 0"
+	tag, err := r.db.Exec(ctx, query, key, token, status, headers, body)
+	if err != nil {
+		log.Printf("Ошибка при сохранении ответа для ключа идемпотентности: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey удаляет резерв ключа с токеном token, ответ для которого не сохранён
+func (r *SubRepository) ReleaseIdempotencyKey(ctx context.Context, key, token string) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND lease_token = $2 AND status = 0", key, token)
+	if err != nil {
+		log.Printf("Ошибка при снятии резерва ключа идемпотентности: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys удаляет ключи идемпотентности, истёкшие до before
+func (r *SubRepository) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= $1", before)
+	if err != nil {
+		log.Printf("Ошибка при удалении истёкших ключей идемпотентности: %v", err)
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"subscription_service/internal/model"
+)
+
+// iterateFunc — обход подписок по фильтру с сигнатурой SubscriptionStore.IterateSubscriptions.
+// Списки и отчёты строятся поверх обхода одинаково для SubRepository и MemoryRepository.
+type iterateFunc func(ctx context.Context, filter model.SubscriptionFilter, page model.Page, fn func(model.Subscription) error) error
+
+// collectPage собирает страницу списка: запрашивает на одну подписку больше page.Limit,
+// чтобы понять, есть ли следующая страница, и возвращает её курсор или nil
+func collectPage(ctx context.Context, iterate iterateFunc, filter model.SubscriptionFilter, page model.Page) ([]model.Subscription, *model.Cursor, error) {
+	limit := page.Limit
+	page.Limit = limit + 1
+
+	subs := []model.Subscription{}
+	err := iterate(ctx, filter, page, func(sub model.Subscription) error {
+		subs = append(subs, sub)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var next *model.Cursor
+	if len(subs) > limit {
+		subs = subs[:limit]
+		c := model.CursorAfter(subs[len(subs)-1], page.Sort)
+		next = &c
+	}
+	return subs, next, nil
+}
+
+// periodFilter — фильтр подписок, активных хотя бы в одном месяце периода [fromDate, toDate],
+// с опциональной фильтрацией по userID и названию сервиса (подстрока без учёта регистра)
+func periodFilter(userID *uuid.UUID, serviceName *string, fromDate, toDate model.MonthYear) model.SubscriptionFilter {
+	return model.SubscriptionFilter{UserID: userID, ServiceName: serviceName, StartTo: &toDate, ActiveSince: &fromDate}
+}
+
+// totalPrice считает стоимость каждой подписки за период [fromDate, toDate], их общую сумму
+// и, если задан groupBy, суммы по группам — за один проход по подпискам, так что итог и группы согласованы.
+// Подписки, не активные ни в одном месяце периода, в детализацию не попадают.
+func totalPrice(ctx context.Context, iterate iterateFunc, userID *uuid.UUID, serviceName *string, fromDate, toDate model.MonthYear, groupBy []model.GroupField, opts model.CostOptions) (model.Money, []model.SubscriptionCost, []model.SpendGroup, error) {
+	var total model.Money
+	costs := []model.SubscriptionCost{}
+	var grouper *model.SpendGrouper
+	if len(groupBy) > 0 {
+		grouper = model.NewSpendGrouper(fromDate, toDate, groupBy, opts)
+	}
+	err := iterate(ctx, periodFilter(userID, serviceName, fromDate, toDate), model.Page{}, func(sub model.Subscription) error {
+		cost, err := sub.CostInPeriod(fromDate, toDate, opts)
+		if err != nil {
+			return err
+		}
+		if cost.Months == 0 {
+			return nil
+		}
+		total += cost.Cost
+		costs = append(costs, cost)
+		if grouper != nil {
+			return grouper.Add(sub)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	var groups []model.SpendGroup
+	if grouper != nil {
+		groups = grouper.Groups()
+	}
+	return total, costs, groups, nil
+}
+
+// spendTimeline строит помесячную разбивку расходов за период, не держа подписки в памяти
+func spendTimeline(ctx context.Context, iterate iterateFunc, userID *uuid.UUID, serviceName *string, fromDate, toDate model.MonthYear, opts model.CostOptions) ([]model.MonthlySpend, error) {
+	timeline := model.NewTimeline(fromDate, toDate, opts)
+	err := iterate(ctx, periodFilter(userID, serviceName, fromDate, toDate), model.Page{}, func(sub model.Subscription) error {
+		return timeline.Add(sub)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return timeline.Months(), nil
+}
+
+// upcomingCharges собирает подписки пользователя, активные в месяцах интервала [from, to],
+// и строит по ним календарь ожидаемых списаний
+func upcomingCharges(ctx context.Context, iterate iterateFunc, userID uuid.UUID, from, to time.Time) ([]model.Charge, model.Subtotals, error) {
+	var subs []model.Subscription
+	err := iterate(ctx, periodFilter(&userID, nil, model.MonthYear(from).StartOfMonth(), model.MonthYear(to).StartOfMonth()), model.Page{}, func(sub model.Subscription) error {
+		subs = append(subs, sub)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	charges, totals := model.UpcomingCharges(subs, from, to)
+	return charges, totals, nil
+}
@@ -0,0 +1,494 @@
+package repository
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"subscription_service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// Ошибки ограничений таблицы subscriptions, которые в PostgreSQL проверяет сама база
+var (
+	errDuplicateKey  = fmt.Errorf("%w: подписка с такими user_id, service_name и месяцем start_date уже есть", ErrAlreadyExists)
+	errNegativePrice = &ConstraintError{Field: "price", Key: msgExpectMoney}
+)
+
+// subKey — составной ключ подписки (user_id, service_name, месяц start_date)
+type subKey struct {
+	userID      uuid.UUID
+	serviceName string
+	startDate   int64
+}
+
+// keyOf возвращает составной ключ подписки; дата сравнивается с точностью до месяца,
+// как в ключе MM-YYYY и в уникальном индексе subscriptions_month_key
+func keyOf(userID uuid.UUID, serviceName string, startDate model.MonthYear) subKey {
+	t := startDate.ToTime()
+	month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return subKey{userID: userID, serviceName: serviceName, startDate: month.Unix()}
+}
+
+// MemoryRepository — потокобезопасное хранилище подписок в памяти.
+// Повторяет семантику SubRepository (фильтры, ILIKE, сортировка, пагинация, расчёт стоимости),
+// что позволяет запускать сервис и тесты без PostgreSQL.
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	subs    map[uuid.UUID]model.Subscription
+	keys    map[subKey]uuid.UUID
+	version int64 // последняя выданная версия, аналог последовательности subscriptions_version_seq
+
+	idempotency map[string]model.IdempotencyRecord // ключи идемпотентности, аналог таблицы idempotency_keys
+
+	rates        []model.ExchangeRate // все версии курсов валют в порядке записи, аналог таблицы currency_rates
+	ratesSavedAt time.Time            // момент последней записи курсов
+}
+
+// NewMemoryRepository создаёт пустое хранилище подписок в памяти
+func NewMemoryRepository() *MemoryRepository {
+	log.Println("Создание экземпляра MemoryRepository")
+	return &MemoryRepository{
+		subs: map[uuid.UUID]model.Subscription{},
+		keys: map[subKey]uuid.UUID{},
+
+		idempotency: map[string]model.IdempotencyRecord{},
+	}
+}
+
+// cloneSubscription возвращает копию подписки, не разделяющую с оригиналом указатели и приостановки,
+// с состоянием на текущий месяц
+func cloneSubscription(sub model.Subscription) model.Subscription {
+	if sub.EndDate != nil {
+		end := *sub.EndDate
+		sub.EndDate = &end
+	}
+	if sub.CancelledAt != nil {
+		at := *sub.CancelledAt
+		sub.CancelledAt = &at
+	}
+	if sub.Pauses != nil {
+		pauses := make([]model.Pause, len(sub.Pauses))
+		for i, p := range sub.Pauses {
+			if p.To != nil {
+				to := *p.To
+				p.To = &to
+			}
+			pauses[i] = p
+		}
+		sub.Pauses = pauses
+	}
+	sub.FillComputed()
+	return sub
+}
+
+// put сохраняет подписку с новой версией, проверяя ограничения таблицы. Вызывается под блокировкой на запись.
+func (r *MemoryRepository) put(sub model.Subscription) error {
+	if sub.Price < 0 {
+		return errNegativePrice
+	}
+	sub.BillingPeriod = model.BillingPeriod(sub.BillingPeriod.String())
+	sub.Currency = model.Currency(sub.Currency.String())
+	key := keyOf(sub.UserID, sub.ServiceName, sub.StartDate)
+	if id, ok := r.keys[key]; ok && id != sub.ID {
+		return errDuplicateKey
+	}
+	if old, ok := r.subs[sub.ID]; ok {
+		delete(r.keys, keyOf(old.UserID, old.ServiceName, old.StartDate))
+	}
+	r.version++
+	sub.Version = r.version
+	r.subs[sub.ID] = cloneSubscription(sub)
+	r.keys[key] = sub.ID
+	return nil
+}
+
+// checkVersion возвращает ErrVersionMismatch, если ifVersion задан и не совпадает с версией подписки
+func checkVersion(sub model.Subscription, ifVersion *int64) error {
+	if ifVersion != nil && *ifVersion != sub.Version {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// remove удаляет подписку по идентификатору. Вызывается под блокировкой на запись.
+func (r *MemoryRepository) remove(id uuid.UUID) (model.Subscription, bool) {
+	sub, ok := r.subs[id]
+	if !ok {
+		return sub, false
+	}
+	delete(r.subs, id)
+	delete(r.keys, keyOf(sub.UserID, sub.ServiceName, sub.StartDate))
+	return sub, true
+}
+
+// CreateSubscription добавляет подписку и записывает в sub.ID и sub.Version назначенные идентификатор и версию
+func (r *MemoryRepository) CreateSubscription(ctx context.Context, sub *model.Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *sub
+	stored.ID = uuid.New()
+	if err := r.put(stored); err != nil {
+		return err
+	}
+	sub.ID = stored.ID
+	sub.Version = r.subs[stored.ID].Version
+	sub.FillComputed()
+	return nil
+}
+
+// GetSubscription возвращает подписку по составному ключу или ErrNotFound, если не найдено
+func (r *MemoryRepository) GetSubscription(ctx context.Context, userID uuid.UUID, serviceName string, startDate model.MonthYear) (*model.Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.keys[keyOf(userID, serviceName, startDate)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	sub := cloneSubscription(r.subs[id])
+	return &sub, nil
+}
+
+// GetSubscriptionByID возвращает подписку по идентификатору или ErrNotFound, если не найдено
+func (r *MemoryRepository) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sub, ok := r.subs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	sub = cloneSubscription(sub)
+	return &sub, nil
+}
+
+// ReplaceSubscription полностью заменяет поля подписки с идентификатором sub.ID.
+// Возвращает обновлённую подписку, ErrNotFound, если подписка не найдена, или ErrVersionMismatch.
+func (r *MemoryRepository) ReplaceSubscription(ctx context.Context, sub *model.Subscription, ifVersion *int64) (*model.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.subs[sub.ID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(current, ifVersion); err != nil {
+		return nil, err
+	}
+	// Приостановки и отмена меняются только переходами состояния (TransitionSubscription)
+	replaced := *sub
+	replaced.Pauses, replaced.CancelledAt = current.Pauses, current.CancelledAt
+	if err := r.put(replaced); err != nil {
+		return nil, err
+	}
+	updated := cloneSubscription(r.subs[sub.ID])
+	return &updated, nil
+}
+
+// PatchSubscription изменяет только заданные в patch поля подписки.
+// Возвращает обновлённую подписку, ErrNotFound, если подписка не найдена, или ErrVersionMismatch.
+func (r *MemoryRepository) PatchSubscription(ctx context.Context, id uuid.UUID, patch model.SubscriptionPatch, ifVersion *int64) (*model.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.patch(id, patch, ifVersion)
+}
+
+// PatchSubscriptionByKey изменяет только заданные в patch поля подписки, найденной по составному ключу.
+// Возвращает обновлённую подписку, ErrNotFound, ErrVersionMismatch или ErrAlreadyExists, если новый ключ занят.
+func (r *MemoryRepository) PatchSubscriptionByKey(ctx context.Context, userID uuid.UUID, serviceName string, startDate model.MonthYear, patch model.SubscriptionPatch, ifVersion *int64) (*model.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.keys[keyOf(userID, serviceName, startDate)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return r.patch(id, patch, ifVersion)
+}
+
+// patch применяет patch к подписке с идентификатором id. Вызывается под блокировкой на запись.
+// Пустой patch не меняет подписку и её версию.
+func (r *MemoryRepository) patch(id uuid.UUID, patch model.SubscriptionPatch, ifVersion *int64) (*model.Subscription, error) {
+	sub, ok := r.subs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(sub, ifVersion); err != nil {
+		return nil, err
+	}
+	if patch == (model.SubscriptionPatch{}) {
+		sub = cloneSubscription(sub)
+		return &sub, nil
+	}
+	if patch.ServiceName != nil {
+		sub.ServiceName = *patch.ServiceName
+	}
+	if patch.Price != nil {
+		sub.Price = *patch.Price
+	}
+	if patch.Currency != nil {
+		sub.Currency = *patch.Currency
+	}
+	if patch.BillingPeriod != nil {
+		sub.BillingPeriod = *patch.BillingPeriod
+	}
+	if patch.StartDate != nil {
+		sub.StartDate = *patch.StartDate
+	}
+	if patch.BillingDay != nil {
+		sub.StartDate = sub.StartDate.WithDay(*patch.BillingDay)
+	}
+	if patch.ClearEndDate {
+		sub.EndDate = nil
+	} else if patch.EndDate != nil {
+		sub.EndDate = patch.EndDate
+	}
+	if err := r.put(sub); err != nil {
+		return nil, err
+	}
+	updated := cloneSubscription(r.subs[id])
+	return &updated, nil
+}
+
+// DeleteSubscription удаляет подписку по составному ключу.
+// Если подписка не найдена, возвращает ErrNotFound; если версия не совпала — ErrVersionMismatch.
+func (r *MemoryRepository) DeleteSubscription(ctx context.Context, userID uuid.UUID, serviceName string, startDate model.MonthYear, ifVersion *int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.keys[keyOf(userID, serviceName, startDate)]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := checkVersion(r.subs[id], ifVersion); err != nil {
+		return err
+	}
+	r.remove(id)
+	return nil
+}
+
+// DeleteSubscriptionByID удаляет подписку по идентификатору.
+// Возвращает удалённую подписку, ErrNotFound, если подписка не найдена, или ErrVersionMismatch.
+func (r *MemoryRepository) DeleteSubscriptionByID(ctx context.Context, id uuid.UUID, ifVersion *int64) (*model.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.subs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(current, ifVersion); err != nil {
+		return nil, err
+	}
+	sub, _ := r.remove(id)
+	return &sub, nil
+}
+
+// ListSubscriptions возвращает страницу подписок по фильтру и общее количество подходящих записей.
+// Порядок и курсоры совпадают с SubRepository.ListSubscriptions.
+func (r *MemoryRepository) ListSubscriptions(ctx context.Context, filter model.SubscriptionFilter, page model.Page) ([]model.Subscription, *model.Cursor, int, error) {
+	total := len(r.filter(filter))
+	subs, next, err := collectPage(ctx, r.IterateSubscriptions, filter, page)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return subs, next, total, nil
+}
+
+// IterateSubscriptions вызывает fn для каждой подписки по фильтру в порядке page.Sort,
+// начиная с page.Cursor или page.Offset, не более page.Limit раз (0 — без ограничения).
+// Обход прекращается на первой ошибке fn, и она возвращается без изменений.
+// Подписки отбираются снимком до обхода, поэтому fn может обращаться к хранилищу.
+func (r *MemoryRepository) IterateSubscriptions(ctx context.Context, filter model.SubscriptionFilter, page model.Page, fn func(model.Subscription) error) error {
+	matched := r.filter(filter)
+
+	columns := sortColumns(page.Sort)
+	less := func(a, b model.Subscription) bool {
+		c := compareByColumns(a, b, columns)
+		if page.Sort.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	sort.Slice(matched, func(i, j int) bool { return less(matched[i], matched[j]) })
+
+	if page.Cursor != nil {
+		last := model.Subscription{
+			UserID:      page.Cursor.UserID,
+			ServiceName: page.Cursor.ServiceName,
+			StartDate:   model.MonthYear(page.Cursor.StartDate),
+			Price:       page.Cursor.Price,
+		}
+		i := sort.Search(len(matched), func(i int) bool { return less(last, matched[i]) })
+		matched = matched[i:]
+	} else if page.Offset > 0 {
+		if page.Offset >= len(matched) {
+			matched = nil
+		} else {
+			matched = matched[page.Offset:]
+		}
+	}
+
+	for i, sub := range matched {
+		if page.Limit > 0 && i >= page.Limit {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CalculateTotalPrice вычисляет общую стоимость подписок за период так же, как SubRepository.CalculateTotalPrice
+func (r *MemoryRepository) CalculateTotalPrice(ctx context.Context, userID *uuid.UUID, serviceName *string, fromDate, toDate model.MonthYear, groupBy []model.GroupField, opts model.CostOptions) (model.Money, []model.SubscriptionCost, []model.SpendGroup, error) {
+	return totalPrice(ctx, r.IterateSubscriptions, userID, serviceName, fromDate, toDate, groupBy, opts)
+}
+
+// SpendTimeline возвращает помесячную разбивку расходов за период [fromDate, toDate]
+func (r *MemoryRepository) SpendTimeline(ctx context.Context, userID *uuid.UUID, serviceName *string, fromDate, toDate model.MonthYear, opts model.CostOptions) ([]model.MonthlySpend, error) {
+	return spendTimeline(ctx, r.IterateSubscriptions, userID, serviceName, fromDate, toDate, opts)
+}
+
+// UpcomingCharges возвращает ожидаемые списания подписок пользователя в интервале [from, to]
+func (r *MemoryRepository) UpcomingCharges(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]model.Charge, model.Subtotals, error) {
+	return upcomingCharges(ctx, r.IterateSubscriptions, userID, from, to)
+}
+
+// filter возвращает копии подписок, подходящих под фильтр (в произвольном порядке)
+func (r *MemoryRepository) filter(filter model.SubscriptionFilter) []model.Subscription {
+	var pattern *regexp.Regexp
+	if filter.ServiceName != nil && !filter.ServiceNameExact {
+		pattern = ilikePattern("%" + *filter.ServiceName + "%")
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subs := []model.Subscription{}
+	for _, sub := range r.subs {
+		if matchesFilter(sub, filter, pattern) {
+			subs = append(subs, cloneSubscription(sub))
+		}
+	}
+	return subs
+}
+
+// matchesFilter проверяет подписку на соответствие фильтру — те же условия, что формирует buildFilter
+func matchesFilter(sub model.Subscription, filter model.SubscriptionFilter, pattern *regexp.Regexp) bool {
+	// Фильтры по дате начала сравнивают месяцы, день начала не учитывается
+	start := sub.StartDate.StartOfMonth().ToTime()
+	var end *time.Time
+	if sub.EndDate != nil {
+		t := sub.EndDate.ToTime()
+		end = &t
+	}
+
+	if filter.UserID != nil && sub.UserID != *filter.UserID {
+		return false
+	}
+	if filter.ServiceName != nil {
+		if filter.ServiceNameExact && sub.ServiceName != *filter.ServiceName {
+			return false
+		}
+		if !filter.ServiceNameExact && !pattern.MatchString(sub.ServiceName) {
+			return false
+		}
+	}
+	if filter.StartFrom != nil && start.Before(filter.StartFrom.ToTime()) {
+		return false
+	}
+	if filter.StartTo != nil && start.After(filter.StartTo.ToTime()) {
+		return false
+	}
+	if filter.MinPrice != nil && sub.Price < *filter.MinPrice {
+		return false
+	}
+	if filter.MaxPrice != nil && sub.Price > *filter.MaxPrice {
+		return false
+	}
+	if filter.ActiveOn != nil {
+		month := filter.ActiveOn.ToTime()
+		if start.After(month) || (end != nil && end.Before(month)) {
+			return false
+		}
+	}
+	// Сравнение с NULL в SQL ложно, поэтому бессрочные подписки не проходят ends_before/ends_after
+	if filter.EndsBefore != nil && (end == nil || !end.Before(filter.EndsBefore.ToTime())) {
+		return false
+	}
+	if filter.EndsAfter != nil && (end == nil || !end.After(filter.EndsAfter.ToTime())) {
+		return false
+	}
+	if filter.ActiveSince != nil && end != nil && end.Before(filter.ActiveSince.ToTime()) {
+		return false
+	}
+	if filter.OpenEnded != nil && *filter.OpenEnded != (end == nil) {
+		return false
+	}
+	if filter.Status != nil && sub.StatusAt(model.CurrentMonth()) != *filter.Status {
+		return false
+	}
+	return true
+}
+
+// compareByColumns сравнивает подписки по колонкам сортировки (см. sortColumns).
+// UUID сравниваются побайтово, как в PostgreSQL.
+func compareByColumns(a, b model.Subscription, columns []string) int {
+	for _, col := range columns {
+		var c int
+		switch col {
+		case "price":
+			c = cmp.Compare(a.Price, b.Price)
+		case "user_id":
+			c = bytes.Compare(a.UserID[:], b.UserID[:])
+		case "service_name":
+			c = strings.Compare(a.ServiceName, b.ServiceName)
+		case "start_date":
+			c = a.StartDate.ToTime().Compare(b.StartDate.ToTime())
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// ilikePattern преобразует шаблон SQL ILIKE (% — любая строка, _ — любой символ, \ — экранирование)
+// в регулярное выражение без учёта регистра
+func ilikePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	escaped := false
+	for _, ch := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case ch == '%':
+			b.WriteString(".*")
+		case ch == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+
+	"subscription_service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// CreateSubscriptions создаёт пакет подписок атомарно: весь пакет проверяется до изменений,
+// поэтому при ошибке хранилище остаётся прежним. Конфликты составного ключа разрешаются
+// так же, как в SubRepository.CreateSubscriptions.
+func (r *MemoryRepository) CreateSubscriptions(ctx context.Context, subs []model.Subscription, mode model.ConflictMode) ([]model.BatchResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := map[subKey]bool{}
+	var conflicts []int
+	for i, sub := range subs {
+		if sub.Price < 0 {
+			return nil, &BatchError{Indexes: []int{i}, Err: errNegativePrice}
+		}
+		key := keyOf(sub.UserID, sub.ServiceName, sub.StartDate)
+		if _, ok := r.keys[key]; ok || seen[key] {
+			conflicts = append(conflicts, i)
+		}
+		seen[key] = true
+	}
+	if mode == model.ConflictError && len(conflicts) > 0 {
+		return nil, &BatchError{Indexes: conflicts, Err: errDuplicateKey}
+	}
+
+	results := make([]model.BatchResult, len(subs))
+	for i, sub := range subs {
+		results[i] = model.BatchResult{Index: i, Status: model.BatchCreated}
+		if id, ok := r.keys[keyOf(sub.UserID, sub.ServiceName, sub.StartDate)]; ok {
+			if mode == model.ConflictSkip {
+				results[i].Status = model.BatchSkipped
+				continue
+			}
+			existing := r.subs[id]
+			existing.Price = sub.Price
+			existing.Currency = sub.Currency
+			existing.BillingPeriod = sub.BillingPeriod
+			existing.EndDate = sub.EndDate
+			sub = existing
+			results[i].Status = model.BatchUpdated
+		} else {
+			sub.ID = uuid.New()
+		}
+		// Ограничения проверены выше, поэтому put не возвращает ошибку
+		_ = r.put(sub)
+		stored := cloneSubscription(r.subs[sub.ID])
+		results[i].Subscription = &stored
+	}
+	return results, nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+
+	"subscription_service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// DeleteSubscriptionsByFilter удаляет все подписки, подходящие под фильтр, если их ровно expected;
+// иначе ничего не удаляет и возвращает ErrCountMismatch
+func (r *MemoryRepository) DeleteSubscriptionsByFilter(ctx context.Context, filter model.SubscriptionFilter, expected int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids, err := r.bulkTargets(filter, expected)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		r.remove(id)
+	}
+	return len(ids), nil
+}
+
+// UpdateSubscriptionsByFilter меняет цену, период оплаты и дату окончания всех подписок, подходящих под фильтр,
+// если их ровно expected; семантика та же, что у SubRepository.UpdateSubscriptionsByFilter
+func (r *MemoryRepository) UpdateSubscriptionsByFilter(ctx context.Context, filter model.SubscriptionFilter, patch model.SubscriptionPatch, expected int) (int, error) {
+	if patch.ServiceName != nil || patch.StartDate != nil || patch.BillingDay != nil {
+		return 0, errBulkKeyChange
+	}
+	if patch.Price != nil && *patch.Price < 0 {
+		return 0, errNegativePrice
+	}
+	if patch == (model.SubscriptionPatch{}) {
+		return 0, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids, err := r.bulkTargets(filter, expected)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		// Ключ не меняется, а цена проверена выше, поэтому patch не возвращает ошибку
+		_, _ = r.patch(id, patch, nil)
+	}
+	return len(ids), nil
+}
+
+// bulkTargets возвращает идентификаторы подписок, подходящих под фильтр, или ErrCountMismatch,
+// если их не ровно expected. Вызывается под блокировкой на запись.
+func (r *MemoryRepository) bulkTargets(filter model.SubscriptionFilter, expected int) ([]uuid.UUID, error) {
+	var pattern *regexp.Regexp
+	if filter.ServiceName != nil && !filter.ServiceNameExact {
+		pattern = ilikePattern("%" + *filter.ServiceName + "%")
+	}
+	var ids []uuid.UUID
+	for id, sub := range r.subs {
+		if matchesFilter(sub, filter, pattern) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) != expected {
+		return nil, ErrCountMismatch
+	}
+	return ids, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"subscription_service/internal/model"
+)
+
+// ReserveIdempotencyKey резервирует ключ идемпотентности; истёкший ключ и ключ с истёкшим резервом
+// незавершённого запроса перезаписываются. Если ключ занят, возвращает копию существующей записи.
+func (r *MemoryRepository) ReserveIdempotencyKey(ctx context.Context, rec model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.idempotency[rec.Key]; ok && existing.Reserved(time.Now()) {
+		return &existing, nil
+	}
+	r.idempotency[rec.Key] = model.IdempotencyRecord{
+		Key:         rec.Key,
+		RequestHash: rec.RequestHash,
+		ExpiresAt:   rec.ExpiresAt,
+		LeaseUntil:  rec.LeaseUntil,
+		LeaseToken:  rec.LeaseToken,
+	}
+	return nil, nil
+}
+
+// CompleteIdempotencyKey сохраняет ответ для ключа, зарезервированного с токеном token
+func (r *MemoryRepository) CompleteIdempotencyKey(ctx context.Context, key, token string, status int, headers map[string]string, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec, ok := r.idempotency[key]
+	if !ok || rec.LeaseToken != token || rec.Completed() {
+		return ErrLeaseLost
+	}
+	rec.Status = status
+	rec.Headers = make(map[string]string, len(headers))
+	for k, v := range headers {
+		rec.Headers[k] = v
+	}
+	rec.Body = append([]byte(nil), body...)
+	r.idempotency[key] = rec
+	return nil
+}
+
+// ReleaseIdempotencyKey удаляет резерв ключа с токеном token, ответ для которого не сохранён
+func (r *MemoryRepository) ReleaseIdempotencyKey(ctx context.Context, key, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec, ok := r.idempotency[key]
+	if !ok || rec.LeaseToken != token || rec.Completed() {
+		return ErrLeaseLost
+	}
+	delete(r.idempotency, key)
+	return nil
+}
+
+// PurgeIdempotencyKeys удаляет ключи идемпотентности, истёкшие до before
+func (r *MemoryRepository) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for key, rec := range r.idempotency {
+		if !rec.ExpiresAt.After(before) {
+			delete(r.idempotency, key)
+			n++
+		}
+	}
+	return n, nil
+}
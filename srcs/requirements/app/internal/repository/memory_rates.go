@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"subscription_service/internal/model"
+)
+
+// SaveRates сохраняет курсы новыми версиями; все версии одного вызова получают общий момент записи,
+// который позже момента любой предыдущей записи
+func (r *MemoryRepository) SaveRates(ctx context.Context, rates []model.ExchangeRate) ([]model.ExchangeRate, error) {
+	rates = lastRates(rates)
+	for _, rate := range rates {
+		if err := rate.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Момент записи — с точностью до микросекунд, как TIMESTAMPTZ
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if !now.After(r.ratesSavedAt) {
+		now = r.ratesSavedAt.Add(time.Microsecond)
+	}
+	latest := map[rateKey]model.ExchangeRate{}
+	for _, rate := range r.rates {
+		latest[rateKey{currency: rate.Currency, quote: rate.Quote, date: rate.Date}] = rate
+	}
+
+	var saved []model.ExchangeRate
+	for _, rate := range rates {
+		if last, ok := latest[rateKey{currency: rate.Currency, quote: rate.Quote, date: rate.Date}]; ok && last.Rate.Equal(rate.Rate) {
+			continue // курс совпадает с последней версией
+		}
+		rate.RecordedAt = now
+		saved = append(saved, rate)
+	}
+	if len(saved) > 0 {
+		r.rates = append(r.rates, saved...)
+		r.ratesSavedAt = now
+	}
+	return saved, nil
+}
+
+// ListRates возвращает курсы по фильтру
+func (r *MemoryRepository) ListRates(ctx context.Context, filter model.RateFilter) ([]model.ExchangeRate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var from, to, since string
+	if filter.From != nil {
+		from = filter.From.Format("2006-01-02")
+	}
+	if filter.To != nil {
+		to = filter.To.Format("2006-01-02")
+	}
+	// Для Since — дата курса каждой пары, действующего на Since, среди записанных не позже AsOf
+	effective := map[rateKey]string{}
+	if filter.Since != nil {
+		since = filter.Since.Format("2006-01-02")
+		for _, rate := range r.rates {
+			key := rateKey{currency: rate.Currency, quote: rate.Quote}
+			if rate.Date <= since && rate.Date > effective[key] && (filter.AsOf == nil || !rate.RecordedAt.After(*filter.AsOf)) {
+				effective[key] = rate.Date
+			}
+		}
+	}
+
+	var rates []model.ExchangeRate
+	latest := map[rateKey]int{}
+	for _, rate := range r.rates {
+		switch {
+		case filter.Currency != nil && rate.Currency != *filter.Currency,
+			filter.Quote != nil && rate.Quote != *filter.Quote,
+			from != "" && rate.Date < from,
+			to != "" && rate.Date > to,
+			since != "" && rate.Date < since && rate.Date != effective[rateKey{currency: rate.Currency, quote: rate.Quote}],
+			filter.AsOf != nil && rate.RecordedAt.After(*filter.AsOf):
+			continue
+		}
+		// Версии хранятся в порядке записи: без истории более поздняя версия заменяет предыдущую
+		key := rateKey{currency: rate.Currency, quote: rate.Quote, date: rate.Date}
+		if i, ok := latest[key]; ok && !filter.History {
+			rates[i] = rate
+			continue
+		}
+		latest[key] = len(rates)
+		rates = append(rates, rate)
+	}
+
+	sort.SliceStable(rates, func(i, j int) bool {
+		a, b := rates[i], rates[j]
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		if a.Quote != b.Quote {
+			return a.Quote < b.Quote
+		}
+		return a.Date < b.Date
+	})
+	return rates, nil
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+
+	"subscription_service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// TransitionSubscription изменяет состояние подписки функцией transition (отмена, приостановка,
+// возобновление) и сохраняет её дату окончания, приостановки и момент отмены.
+// Возвращает обновлённую подписку, ErrNotFound, ErrVersionMismatch или ошибку transition.
+func (r *MemoryRepository) TransitionSubscription(ctx context.Context, id uuid.UUID, ifVersion *int64, transition func(*model.Subscription) error) (*model.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.subs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(current, ifVersion); err != nil {
+		return nil, err
+	}
+	sub := cloneSubscription(current)
+	if err := transition(&sub); err != nil {
+		return nil, err
+	}
+	if err := r.put(sub); err != nil {
+		return nil, err
+	}
+	updated := cloneSubscription(r.subs[id])
+	return &updated, nil
+}
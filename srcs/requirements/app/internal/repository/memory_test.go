@@ -0,0 +1,476 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"subscription_service/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func month(year int, m time.Month) model.MonthYear {
+	return model.MonthYear(time.Date(year, m, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func TestMemoryRepositoryCRUD(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	userID := uuid.New()
+	start := month(2025, time.July)
+	sub := &model.Subscription{ServiceName: "Netflix", Price: 999, UserID: userID, StartDate: start}
+
+	if err := repo.CreateSubscription(ctx, sub); err != nil {
+		t.Fatalf("Создание подписки завершилось ошибкой: %v", err)
+	}
+	if sub.ID == uuid.Nil {
+		t.Fatal("После создания не назначен id подписки")
+	}
+
+	duplicate := &model.Subscription{ServiceName: "Netflix", Price: 1, UserID: userID, StartDate: start}
+	if err := repo.CreateSubscription(ctx, duplicate); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Создание подписки с тем же составным ключом: ошибка %v, ожидалась ErrAlreadyExists", err)
+	}
+	negative := &model.Subscription{ServiceName: "Okko", Price: -1, UserID: userID, StartDate: start}
+	if err := repo.CreateSubscription(ctx, negative); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Создание подписки с отрицательной ценой: ошибка %v, ожидалась ErrInvalid", err)
+	}
+
+	end, price := month(2025, time.December), model.Money(1099)
+	if _, err := repo.PatchSubscriptionByKey(ctx, userID, "Netflix", start, model.SubscriptionPatch{Price: &price, EndDate: &end}, nil); err != nil {
+		t.Fatalf("Обновление подписки завершилось ошибкой: %v", err)
+	}
+	got, err := repo.GetSubscription(ctx, userID, "Netflix", start)
+	if err != nil || got == nil {
+		t.Fatalf("Получение подписки: %+v, %v", got, err)
+	}
+	if got.Price != 1099 || got.EndDate == nil || !got.EndDate.ToTime().Equal(end.ToTime()) {
+		t.Errorf("После обновления получена подписка %+v", got)
+	}
+
+	// Изменение по устаревшей версии отклоняется
+	if got.Version == sub.Version {
+		t.Errorf("Версия не изменилась после обновления: %d", got.Version)
+	}
+	newStart := month(2025, time.August)
+	patch := model.SubscriptionPatch{StartDate: &newStart, ClearEndDate: true}
+	if _, err := repo.PatchSubscription(ctx, sub.ID, patch, &sub.Version); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Обновление по устаревшей версии: ошибка %v, ожидалась ErrVersionMismatch", err)
+	}
+
+	// Перенос даты начала через PATCH меняет составной ключ
+	patched, err := repo.PatchSubscription(ctx, sub.ID, patch, &got.Version)
+	if err != nil || patched == nil {
+		t.Fatalf("Частичное обновление подписки: %+v, %v", patched, err)
+	}
+	if patched.EndDate != nil || patched.Price != 1099 || patched.Version <= got.Version {
+		t.Errorf("После частичного обновления получена подписка %+v", patched)
+	}
+	if _, err := repo.DeleteSubscriptionByID(ctx, sub.ID, &got.Version); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Удаление по устаревшей версии: ошибка %v, ожидалась ErrVersionMismatch", err)
+	}
+	if old, _ := repo.GetSubscription(ctx, userID, "Netflix", start); old != nil {
+		t.Error("Подписка всё ещё доступна по старому составному ключу")
+	}
+	if moved, _ := repo.GetSubscription(ctx, userID, "Netflix", newStart); moved == nil || moved.ID != sub.ID {
+		t.Errorf("По новому составному ключу получена подписка %+v", moved)
+	}
+
+	deleted, err := repo.DeleteSubscriptionByID(ctx, sub.ID, nil)
+	if err != nil || deleted == nil {
+		t.Fatalf("Удаление подписки: %+v, %v", deleted, err)
+	}
+	if again, _ := repo.GetSubscriptionByID(ctx, sub.ID); again != nil {
+		t.Error("Подписка не удалена")
+	}
+	if _, err := repo.DeleteSubscriptionByID(ctx, sub.ID, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Повторное удаление: ошибка %v, ожидалась ErrNotFound", err)
+	}
+	if _, err := repo.PatchSubscriptionByKey(ctx, userID, "Netflix", newStart, model.SubscriptionPatch{Price: &price}, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Обновление удалённой подписки: ошибка %v, ожидалась ErrNotFound", err)
+	}
+}
+
+func TestMemoryRepositoryBillingDay(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	userID := uuid.New()
+	start, _ := model.ParseDate("2025-03-15")
+	sub := &model.Subscription{ServiceName: "Netflix", Price: 999, UserID: userID, StartDate: start}
+	if err := repo.CreateSubscription(ctx, sub); err != nil {
+		t.Fatalf("Создание подписки: %v", err)
+	}
+	if sub.BillingDay != 15 {
+		t.Errorf("billing_day = %d, ожидалось 15", sub.BillingDay)
+	}
+
+	// Фильтры по месяцам учитывают подписку, начатую в середине месяца
+	march := month(2025, time.March)
+	_, _, total, err := repo.ListSubscriptions(ctx, model.SubscriptionFilter{StartTo: &march, ActiveOn: &march}, model.Page{Limit: 10})
+	if err != nil || total != 1 {
+		t.Errorf("Подписка с 15-го числа в фильтре по марту: total %d, %v", total, err)
+	}
+
+	// Перенос дня списаний меняет дату начала внутри того же месяца
+	day := 31
+	updated, err := repo.PatchSubscription(ctx, sub.ID, model.SubscriptionPatch{BillingDay: &day}, nil)
+	if err != nil || updated.BillingDay != 31 || !updated.StartDate.ToTime().Equal(time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Перенос billing_day: %+v, %v", updated, err)
+	}
+	if _, err := repo.GetSubscription(ctx, userID, "Netflix", updated.StartDate); err != nil {
+		t.Errorf("Получение по составному ключу с полной датой: %v", err)
+	}
+	// Составной ключ сравнивает дату начала с точностью до месяца
+	if _, err := repo.GetSubscription(ctx, userID, "Netflix", march); err != nil {
+		t.Errorf("Получение по составному ключу с месяцем: %v", err)
+	}
+	other, _ := model.ParseDate("2025-03-01")
+	if err := repo.CreateSubscription(ctx, &model.Subscription{ServiceName: "Netflix", Price: 999, UserID: userID, StartDate: other}); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Вторая подписка в том же месяце: %v, ожидалась ErrAlreadyExists", err)
+	}
+}
+
+func TestMemoryRepositoryListAndTotals(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	userID := uuid.New()
+	names := []string{"Netflix", "Yandex Plus", "Okko", "NETFLIX Kids", "Spotify"}
+	for i, name := range names {
+		sub := &model.Subscription{ServiceName: name, Price: model.Money(10000 * (i + 1)), UserID: userID, StartDate: month(2025, time.January)}
+		if err := repo.CreateSubscription(ctx, sub); err != nil {
+			t.Fatalf("Создание подписки %q: %v", name, err)
+		}
+	}
+
+	// ILIKE по подстроке без учёта регистра
+	netflix := "netflix"
+	subs, _, total, err := repo.ListSubscriptions(ctx, model.SubscriptionFilter{ServiceName: &netflix}, model.Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("Получение списка: %v", err)
+	}
+	if total != 2 || len(subs) != 2 {
+		t.Errorf("По подстроке %q найдено %d (total %d), ожидалось 2", netflix, len(subs), total)
+	}
+
+	// Постраничный обход по курсору с сортировкой по убыванию цены
+	page := model.Page{Limit: 2, Sort: model.SortField{Column: "price", Desc: true}}
+	var prices []model.Money
+	for {
+		subs, next, total, err := repo.ListSubscriptions(ctx, model.SubscriptionFilter{}, page)
+		if err != nil {
+			t.Fatalf("Получение страницы: %v", err)
+		}
+		if total != len(names) {
+			t.Errorf("total = %d, ожидалось %d", total, len(names))
+		}
+		for _, s := range subs {
+			prices = append(prices, s.Price)
+		}
+		if next == nil {
+			break
+		}
+		page.Cursor = next
+	}
+	want := []model.Money{50000, 40000, 30000, 20000, 10000}
+	if len(prices) != len(want) {
+		t.Fatalf("Получены цены %v, ожидалось %v", prices, want)
+	}
+	for i := range want {
+		if prices[i] != want[i] {
+			t.Fatalf("Получены цены %v, ожидалось %v", prices, want)
+		}
+	}
+
+	// Общая стоимость за период: пять бессрочных подписок по 3 месяца
+	sum, costs, _, err := repo.CalculateTotalPrice(ctx, &userID, nil, month(2025, time.March), month(2025, time.May), nil, model.CostOptions{View: model.ViewCharges})
+	if err != nil {
+		t.Fatalf("Подсчёт стоимости: %v", err)
+	}
+	if sum != 450000 || len(costs) != len(names) {
+		t.Errorf("Общая стоимость %s по %d подпискам, ожидалось 4500.00 по %d", sum, len(costs), len(names))
+	}
+
+	// Группы считаются в том же проходе и в сумме дают общую стоимость
+	_, _, groups, err := repo.CalculateTotalPrice(ctx, &userID, nil, month(2025, time.March), month(2025, time.May), []model.GroupField{model.GroupByMonth}, model.CostOptions{View: model.ViewCharges})
+	var grouped model.Money
+	for _, g := range groups {
+		grouped += g.Total
+	}
+	if err != nil || len(groups) != 3 || grouped != sum {
+		t.Errorf("Группы по месяцам: %d групп на %s, ошибка %v; ожидалось 3 группы на %s", len(groups), grouped, err, sum)
+	}
+}
+
+func TestMemoryRepositoryIterateSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	userID := uuid.New()
+	ended := month(2025, time.February)
+	for i, name := range []string{"Okko", "Netflix", "Spotify", "Ivi"} {
+		sub := &model.Subscription{ServiceName: name, Price: model.Money(10000 * (i + 1)), UserID: userID, StartDate: month(2025, time.January)}
+		if name == "Ivi" {
+			sub.EndDate = &ended
+		}
+		if err := repo.CreateSubscription(ctx, sub); err != nil {
+			t.Fatalf("Создание подписки %q: %v", name, err)
+		}
+	}
+
+	// Без ограничения обходятся все подписки в порядке сортировки
+	var names []string
+	page := model.Page{Sort: model.SortField{Column: "service_name"}}
+	err := repo.IterateSubscriptions(ctx, model.SubscriptionFilter{}, page, func(sub model.Subscription) error {
+		names = append(names, sub.ServiceName)
+		return nil
+	})
+	if err != nil || strings.Join(names, ",") != "Ivi,Netflix,Okko,Spotify" {
+		t.Errorf("Обход: %v, ошибка %v", names, err)
+	}
+
+	// Курсор и ограничение
+	cursor := model.CursorAfter(model.Subscription{ServiceName: "Netflix", UserID: userID, StartDate: month(2025, time.January)}, page.Sort)
+	page.Cursor, page.Limit = &cursor, 1
+	names = nil
+	err = repo.IterateSubscriptions(ctx, model.SubscriptionFilter{}, page, func(sub model.Subscription) error {
+		names = append(names, sub.ServiceName)
+		return nil
+	})
+	if err != nil || strings.Join(names, ",") != "Okko" {
+		t.Errorf("Обход после курсора: %v, ошибка %v", names, err)
+	}
+
+	// Ошибка fn прерывает обход и возвращается без изменений
+	errStop := errors.New("stop")
+	calls := 0
+	err = repo.IterateSubscriptions(ctx, model.SubscriptionFilter{}, model.Page{}, func(sub model.Subscription) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Errorf("Прерывание обхода: ошибка %v после %d вызовов", err, calls)
+	}
+
+	// ActiveSince отбрасывает подписки, закончившиеся раньше месяца
+	since := month(2025, time.March)
+	count := 0
+	err = repo.IterateSubscriptions(ctx, model.SubscriptionFilter{ActiveSince: &since}, model.Page{}, func(sub model.Subscription) error {
+		count++
+		return nil
+	})
+	if err != nil || count != 3 {
+		t.Errorf("С ActiveSince найдено %d подписок, ожидалось 3 (ошибка %v)", count, err)
+	}
+}
+
+func TestMemoryRepositoryIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	now := time.Now()
+
+	rec := model.IdempotencyRecord{Key: "k1", RequestHash: "h1", ExpiresAt: now.Add(time.Hour), LeaseUntil: now.Add(time.Minute), LeaseToken: "t1"}
+	if existing, err := repo.ReserveIdempotencyKey(ctx, rec); err != nil || existing != nil {
+		t.Fatalf("Резервирование нового ключа: %v, %v", existing, err)
+	}
+	existing, err := repo.ReserveIdempotencyKey(ctx, rec)
+	if err != nil || existing == nil || existing.Completed() {
+		t.Fatalf("Повторное резервирование должно вернуть незавершённую запись: %v, %v", existing, err)
+	}
+
+	if err := repo.CompleteIdempotencyKey(ctx, "k1", "t1", 201, map[string]string{"ETag": `"1"`}, []byte("{}")); err != nil {
+		t.Fatalf("Сохранение ответа: %v", err)
+	}
+	existing, _ = repo.ReserveIdempotencyKey(ctx, rec)
+	if existing == nil || existing.Status != 201 || existing.Headers["ETag"] != `"1"` || string(existing.Body) != "{}" {
+		t.Fatalf("Сохранённый ответ: %+v", existing)
+	}
+
+	// Завершённый ключ не снимается, незавершённый — снимается
+	if err := repo.ReleaseIdempotencyKey(ctx, "k1", "t1"); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("Снятие резерва с завершённого ключа: %v, ожидалась ErrLeaseLost", err)
+	}
+	if existing, _ := repo.ReserveIdempotencyKey(ctx, rec); existing == nil {
+		t.Error("Завершённый ключ не должен сниматься")
+	}
+	rec2 := model.IdempotencyRecord{Key: "k2", RequestHash: "h2", ExpiresAt: now.Add(time.Hour), LeaseUntil: now.Add(time.Minute), LeaseToken: "t2"}
+	_, _ = repo.ReserveIdempotencyKey(ctx, rec2)
+	if err := repo.ReleaseIdempotencyKey(ctx, "k2", "t2"); err != nil {
+		t.Fatalf("Снятие резерва: %v", err)
+	}
+	if existing, _ := repo.ReserveIdempotencyKey(ctx, rec2); existing != nil {
+		t.Error("Незавершённый ключ после снятия должен резервироваться заново")
+	}
+
+	// Незавершённый ключ с истёкшим резервом (процесс упал, не сохранив ответ) занимает повтор запроса
+	stale := model.IdempotencyRecord{Key: "k3", RequestHash: "h3", ExpiresAt: now.Add(time.Hour), LeaseUntil: now.Add(-time.Second), LeaseToken: "stale"}
+	_, _ = repo.ReserveIdempotencyKey(ctx, stale)
+	retry := model.IdempotencyRecord{Key: "k3", RequestHash: "h3", ExpiresAt: now.Add(time.Hour), LeaseUntil: now.Add(time.Minute), LeaseToken: "retry"}
+	if existing, err := repo.ReserveIdempotencyKey(ctx, retry); err != nil || existing != nil {
+		t.Fatalf("Ключ с истёкшим резервом должен заниматься заново: %+v, %v", existing, err)
+	}
+	if existing, _ := repo.ReserveIdempotencyKey(ctx, retry); existing == nil || existing.LeaseUntil != retry.LeaseUntil {
+		t.Errorf("Повтор во время нового резерва должен получить запись с новым резервом: %+v", existing)
+	}
+
+	// Запрос, чей резерв перехватили, не снимает чужой резерв и не перезаписывает ответ повтора
+	if err := repo.ReleaseIdempotencyKey(ctx, "k3", "stale"); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Снятие перехваченного резерва: %v, ожидалась ErrLeaseLost", err)
+	}
+	if err := repo.CompleteIdempotencyKey(ctx, "k3", "retry", 201, nil, []byte(`"retry"`)); err != nil {
+		t.Fatalf("Сохранение ответа повтора: %v", err)
+	}
+	if err := repo.CompleteIdempotencyKey(ctx, "k3", "stale", 409, nil, []byte(`"stale"`)); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Сохранение ответа с перехваченным резервом: %v, ожидалась ErrLeaseLost", err)
+	}
+	if existing, _ := repo.ReserveIdempotencyKey(ctx, retry); existing == nil || existing.Status != 201 || string(existing.Body) != `"retry"` {
+		t.Errorf("Сохранённым должен остаться ответ повтора: %+v", existing)
+	}
+
+	// Истёкшие ключи удаляются
+	n, err := repo.PurgeIdempotencyKeys(ctx, now.Add(2*time.Hour))
+	if err != nil || n != 3 {
+		t.Errorf("Удалено %d ключей (%v), ожидалось 3", n, err)
+	}
+}
+
+func TestMemoryRepositoryCreateSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	userID := uuid.New()
+	sub := func(service string, price model.Money) model.Subscription {
+		return model.Subscription{ServiceName: service, Price: price, UserID: userID, StartDate: month(2025, time.May)}
+	}
+
+	results, err := repo.CreateSubscriptions(ctx, []model.Subscription{sub("Netflix", 500), sub("Okko", 300)}, model.ConflictError)
+	if err != nil || len(results) != 2 || results[0].Status != model.BatchCreated || results[0].Subscription.ID == uuid.Nil {
+		t.Fatalf("Создание пакета: %+v, %v", results, err)
+	}
+
+	// Повтор ключа внутри пакета тоже конфликт; при ошибке ничего не сохраняется
+	_, err = repo.CreateSubscriptions(ctx, []model.Subscription{sub("Kion", 100), sub("Kion", 200), sub("Okko", 1)}, model.ConflictError)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || !errors.Is(err, ErrAlreadyExists) || len(batchErr.Indexes) != 2 {
+		t.Fatalf("Конфликт в режиме error: %v", err)
+	}
+	if _, err := repo.GetSubscription(ctx, userID, "Kion", month(2025, time.May)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("После отменённого пакета подписка Kion: %v", err)
+	}
+	if _, err := repo.CreateSubscriptions(ctx, []model.Subscription{sub("Kion", 100), sub("Ivi", -1)}, model.ConflictSkip); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Отрицательная цена в пакете: %v", err)
+	}
+
+	results, err = repo.CreateSubscriptions(ctx, []model.Subscription{sub("Kion", 100), sub("Okko", 400)}, model.ConflictSkip)
+	if err != nil || results[0].Status != model.BatchCreated || results[1].Status != model.BatchSkipped {
+		t.Fatalf("Режим skip: %+v, %v", results, err)
+	}
+
+	results, err = repo.CreateSubscriptions(ctx, []model.Subscription{sub("Okko", 400)}, model.ConflictUpdate)
+	if err != nil || results[0].Status != model.BatchUpdated || results[0].Subscription.Price != 400 {
+		t.Fatalf("Режим update: %+v, %v", results, err)
+	}
+	if got, _ := repo.GetSubscription(ctx, userID, "Okko", month(2025, time.May)); got == nil || got.Price != 400 || got.Version != results[0].Subscription.Version {
+		t.Errorf("После обновления: %+v", got)
+	}
+}
+
+func TestMemoryRepositoryBulkByFilter(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	userID := uuid.New()
+	for _, name := range []string{"Okko", "Okko Plus", "Netflix"} {
+		if err := repo.CreateSubscription(ctx, &model.Subscription{ServiceName: name, Price: 100, UserID: userID, StartDate: month(2025, time.January)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	okko := "okko"
+	filter := model.SubscriptionFilter{UserID: &userID, ServiceName: &okko}
+
+	price := model.Money(20000)
+	if _, err := repo.UpdateSubscriptionsByFilter(ctx, filter, model.SubscriptionPatch{Price: &price}, 1); !errors.Is(err, ErrCountMismatch) {
+		t.Fatalf("Несовпадение числа подписок: %v", err)
+	}
+	if _, err := repo.UpdateSubscriptionsByFilter(ctx, filter, model.SubscriptionPatch{ServiceName: &okko}, 2); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Смена ключа при массовом обновлении: %v", err)
+	}
+	if n, err := repo.UpdateSubscriptionsByFilter(ctx, filter, model.SubscriptionPatch{Price: &price}, 2); err != nil || n != 2 {
+		t.Fatalf("Массовое обновление: %d, %v", n, err)
+	}
+	if sub, _ := repo.GetSubscription(ctx, userID, "Okko Plus", month(2025, time.January)); sub == nil || sub.Price != price {
+		t.Errorf("После массового обновления: %+v", sub)
+	}
+
+	if n, err := repo.DeleteSubscriptionsByFilter(ctx, filter, 2); err != nil || n != 2 {
+		t.Fatalf("Массовое удаление: %d, %v", n, err)
+	}
+	if _, costs, _, _ := repo.CalculateTotalPrice(ctx, &userID, nil, month(2025, time.January), month(2025, time.January), nil, model.CostOptions{View: model.ViewCharges}); len(costs) != 1 {
+		t.Errorf("После массового удаления осталось подписок: %d", len(costs))
+	}
+}
+
+func TestMemoryRepositoryRates(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	rate := func(currency model.Currency, date, value string) model.ExchangeRate {
+		return model.ExchangeRate{Currency: currency, Quote: "RUB", Date: date, Rate: decimal.RequireFromString(value), Source: "cbr"}
+	}
+
+	saved, err := repo.SaveRates(ctx, []model.ExchangeRate{
+		rate("USD", "2025-03-01", "90"),
+		rate("USD", "2025-03-01", "89.9865"), // повтор даты: учитывается последний
+		rate("EUR", "2025-03-01", "95"),
+	})
+	if err != nil || len(saved) != 2 || saved[0].RecordedAt.IsZero() {
+		t.Fatalf("Сохранение курсов: %+v, %v", saved, err)
+	}
+	firstSave := saved[0].RecordedAt
+
+	// Тот же курс не создаёт новую версию, исправленный — создаёт
+	saved, err = repo.SaveRates(ctx, []model.ExchangeRate{rate("USD", "2025-03-01", "89.98650"), rate("EUR", "2025-03-01", "96")})
+	if err != nil || len(saved) != 1 || saved[0].Currency != "EUR" || !saved[0].RecordedAt.After(firstSave) {
+		t.Fatalf("Исправление курса: %+v, %v", saved, err)
+	}
+
+	current, err := repo.ListRates(ctx, model.RateFilter{})
+	if err != nil || len(current) != 2 || current[0].Currency != "EUR" || !current[0].Rate.Equal(decimal.NewFromInt(96)) {
+		t.Errorf("Текущие курсы: %+v, %v", current, err)
+	}
+	// На момент первой записи курс евро был прежним
+	asOf, err := repo.ListRates(ctx, model.RateFilter{AsOf: &firstSave})
+	if err != nil || len(asOf) != 2 || !asOf[0].Rate.Equal(decimal.NewFromInt(95)) {
+		t.Errorf("Курсы на момент первой записи: %+v, %v", asOf, err)
+	}
+	eur := model.Currency("EUR")
+	history, err := repo.ListRates(ctx, model.RateFilter{Currency: &eur, History: true})
+	if err != nil || len(history) != 2 || !history[1].Rate.Equal(decimal.NewFromInt(96)) {
+		t.Errorf("История курса евро: %+v, %v", history, err)
+	}
+
+	// С Since остаются курсы с этой даты и последний курс каждой пары до неё
+	if _, err := repo.SaveRates(ctx, []model.ExchangeRate{
+		rate("USD", "2025-01-01", "100"), rate("USD", "2025-02-01", "95"), rate("USD", "2025-04-01", "88"),
+	}); err != nil {
+		t.Fatalf("Сохранение курсов доллара: %v", err)
+	}
+	usd := model.Currency("USD")
+	since, to := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC)
+	period, err := repo.ListRates(ctx, model.RateFilter{Currency: &usd, Since: &since, To: &to})
+	if err != nil || len(period) != 1 || period[0].Date != "2025-03-01" {
+		t.Errorf("Курсы доллара за март: %+v, %v", period, err)
+	}
+	since = time.Date(2025, time.February, 15, 0, 0, 0, 0, time.UTC)
+	period, err = repo.ListRates(ctx, model.RateFilter{Currency: &usd, Since: &since, To: &to})
+	if err != nil || len(period) != 2 || period[0].Date != "2025-02-01" || period[1].Date != "2025-03-01" {
+		t.Errorf("Курсы доллара с 15 февраля: %+v, %v", period, err)
+	}
+
+	if _, err := repo.SaveRates(ctx, []model.ExchangeRate{rate("USD", "2025-03-01", "0")}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Нулевой курс: ошибка %v, ожидалась ErrInvalid", err)
+	}
+}
@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	"subscription_service/internal/model"
@@ -26,8 +28,8 @@ func (r *SubRepository) NewSubRepository(db *pgxpool.Pool) *SubRepository {
 }
 
 // CreateSubscription добавляет новую запись о подписке в базу данных.
-// Принимает структуру подписки и контекст выполнения.
-// Возвращает ошибку, если произошёл сбой при выполнении запроса.
+// Принимает структуру подписки и контекст выполнения; записывает в sub.ID и sub.Version назначенные идентификатор и версию.
+// Возвращает ErrAlreadyExists, если подписка с таким ключом уже есть, и ErrInvalid при нарушении ограничений таблицы.
 func (r *SubRepository) CreateSubscription(ctx context.Context, sub *model.Subscription) error {
 	log.Printf("Создание подписки: %+v", sub)
 
@@ -40,123 +42,340 @@ func (r *SubRepository) CreateSubscription(ctx context.Context, sub *model.Subsc
 	}
 
 	query := `
-        INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date)
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date, billing_period, currency)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, version
     `
-	_, err := r.db.Exec(ctx, query, sub.ServiceName, sub.Price, sub.UserID, startDate, endDate)
+	err := r.db.QueryRow(ctx, query, sub.ServiceName, int64(sub.Price), sub.UserID, startDate, endDate, sub.BillingPeriod.String(), sub.Currency.String()).Scan(&sub.ID, &sub.Version)
 	if err != nil {
+		err = mapError(err)
 		log.Printf("Ошибка при создании подписки: %v", err)
+		return err
 	}
-	return err
+	sub.FillComputed()
+	return nil
 }
 
 // GetSubscription извлекает одну подписку по userID, имени сервиса и дате начала.
-// Возвращает объект подписки или nil, если не найдено.
+// Возвращает объект подписки или ErrNotFound, если подписка не найдена.
 // Также возвращает ошибку при сбое запроса.
 func (r *SubRepository) GetSubscription(ctx context.Context, userID uuid.UUID, serviceName string, startDate model.MonthYear) (*model.Subscription, error) {
 	log.Printf("Получение подписки по userID=%s, serviceName=%s, startDate=%s", userID, serviceName, startDate.ToTime().Format("2006-01-02"))
 
-	query := `
-        SELECT service_name, price, user_id, start_date, end_date
-        FROM subscriptions
-        WHERE user_id = $1 AND service_name = $2 AND start_date = $3
-    `
-
-	var sub model.Subscription
-	var start time.Time
-	var end *time.Time
+	query := "SELECT " + subscriptionColumns + " FROM subscriptions WHERE " + keyCondition(1)
 
-	err := r.db.QueryRow(ctx, query, userID, serviceName, startDate.ToTime()).Scan(
-		&sub.ServiceName, &sub.Price, &sub.UserID, &start, &end,
-	)
+	sub, err := scanSubscription(r.db.QueryRow(ctx, query, userID, serviceName, startDate.ToTime()))
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			log.Println("Подписка не найдена")
-			return nil, nil
-		}
+		err = mapError(err)
 		log.Printf("Ошибка при получении подписки: %v", err)
 		return nil, err
 	}
 
-	sub.StartDate = model.MonthYear(start)
-	if end != nil {
-		ym := model.MonthYear(*end)
-		sub.EndDate = &ym
-	}
 	log.Printf("Подписка успешно найдена: %+v", sub)
 	return &sub, nil
 }
 
-// UpdateSubscription обновляет цену и дату окончания подписки.
-// Поиск выполняется по userID, имени сервиса и дате начала.
-func (r *SubRepository) UpdateSubscription(ctx context.Context, userID uuid.UUID, serviceName string, startDate model.MonthYear, price int, endDate *model.MonthYear) error {
-	log.Printf("Обновление подписки userID=%s, serviceName=%s, startDate=%s, новая цена=%d, новая дата окончания=%v", userID, serviceName, startDate.ToTime().Format("2006-01-02"), price, endDate)
+// GetSubscriptionByID извлекает подписку по её идентификатору.
+// Возвращает объект подписки или ErrNotFound, если подписка не найдена.
+func (r *SubRepository) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	log.Printf("Получение подписки по id=%s", id)
 
-	query := `
-        UPDATE subscriptions
-        SET price = $1, end_date = $2
-        WHERE user_id = $3 AND service_name = $4 AND start_date = $5
-    `
+	query := "SELECT " + subscriptionColumns + " FROM subscriptions WHERE id = $1"
+
+	sub, err := scanSubscription(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		err = mapError(err)
+		log.Printf("Ошибка при получении подписки: %v", err)
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ReplaceSubscription полностью заменяет поля подписки с идентификатором sub.ID,
+// в том числе поля составного ключа (пользователь, сервис, дата начала).
+// Если ifVersion задан, замена выполняется только при совпадении текущей версии подписки.
+// Возвращает обновлённую подписку, ErrNotFound, если подписка не найдена, или ErrVersionMismatch.
+func (r *SubRepository) ReplaceSubscription(ctx context.Context, sub *model.Subscription, ifVersion *int64) (*model.Subscription, error) {
+	log.Printf("Замена подписки id=%s: %+v", sub.ID, sub)
 
 	var end interface{}
-	if endDate != nil {
-		end = endDate.ToTime()
+	if sub.EndDate != nil {
+		end = sub.EndDate.ToTime()
 	}
 
-	_, err := r.db.Exec(ctx, query, price, end, userID, serviceName, startDate.ToTime())
+	query := `
+        UPDATE subscriptions
+        SET service_name = $1, price = $2, user_id = $3, start_date = $4, end_date = $5, billing_period = $6, currency = $7,
+            version = ` + nextVersion + `
+        WHERE id = $8 AND ($9::bigint IS NULL OR version = $9)
+        RETURNING ` + subscriptionColumns
+
+	updated, err := scanSubscription(r.db.QueryRow(ctx, query, sub.ServiceName, int64(sub.Price), sub.UserID, sub.StartDate.ToTime(), end,
+		sub.BillingPeriod.String(), sub.Currency.String(), sub.ID, ifVersion))
 	if err != nil {
-		log.Printf("Ошибка при обновлении подписки: %v", err)
+		err = r.missingOrChanged(ctx, mapError(err), "id = $1", sub.ID)
+		log.Printf("Ошибка при замене подписки: %v", err)
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// querier — общее для пула подключений и транзакции подмножество методов pgx
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// missingOrChanged уточняет ErrNotFound условного изменения: если строка с условием where
+// существует, значит не совпала её версия, и возвращается ErrVersionMismatch. Остальные ошибки не меняются.
+func (r *SubRepository) missingOrChanged(ctx context.Context, err error, where string, args ...any) error {
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	var exists bool
+	if qerr := r.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM subscriptions WHERE "+where+")", args...).Scan(&exists); qerr != nil {
+		return qerr
+	}
+	if exists {
+		return ErrVersionMismatch
 	}
 	return err
 }
 
+// PatchSubscription частично обновляет подписку с указанным идентификатором:
+// изменяются только поля, заданные в patch. Если ifVersion задан, обновление выполняется
+// только при совпадении текущей версии подписки.
+// Возвращает обновлённую подписку, ErrNotFound, если подписка не найдена, или ErrVersionMismatch.
+func (r *SubRepository) PatchSubscription(ctx context.Context, id uuid.UUID, patch model.SubscriptionPatch, ifVersion *int64) (*model.Subscription, error) {
+	log.Printf("Частичное обновление подписки id=%s: %+v", id, patch)
+
+	updated, err := patchByID(ctx, r.db, id, patch, ifVersion)
+	if err != nil {
+		err = r.missingOrChanged(ctx, err, "id = $1", id)
+		log.Printf("Ошибка при частичном обновлении подписки: %v", err)
+		return nil, err
+	}
+	return updated, nil
+}
+
+// PatchSubscriptionByKey частично обновляет подписку, найденную по userID, имени сервиса и дате начала.
+// Поиск и обновление выполняются в одной транзакции с блокировкой строки, поэтому смена
+// service_name или start_date (перенос первичного ключа) не конфликтует с параллельными изменениями.
+// Если ifVersion задан, обновление выполняется только при совпадении текущей версии подписки.
+// Возвращает обновлённую подписку, ErrNotFound, если подписка не найдена, ErrVersionMismatch
+// или ErrAlreadyExists, если по новому ключу уже есть другая подписка.
+func (r *SubRepository) PatchSubscriptionByKey(ctx context.Context, userID uuid.UUID, serviceName string, startDate model.MonthYear, patch model.SubscriptionPatch, ifVersion *int64) (*model.Subscription, error) {
+	log.Printf("Частичное обновление подписки userID=%s, serviceName=%s, startDate=%s: %+v", userID, serviceName, startDate.ToTime().Format("2006-01-02"), patch)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Printf("Ошибка при открытии транзакции: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id uuid.UUID
+	var version int64
+	query := "SELECT id, version FROM subscriptions WHERE " + keyCondition(1) + " FOR UPDATE"
+	if err := tx.QueryRow(ctx, query, userID, serviceName, startDate.ToTime()).Scan(&id, &version); err != nil {
+		err = mapError(err)
+		log.Printf("Ошибка при поиске подписки для частичного обновления: %v", err)
+		return nil, err
+	}
+	if ifVersion != nil && *ifVersion != version {
+		log.Printf("Версия подписки id=%s изменилась: %d, ожидалась %d", id, version, *ifVersion)
+		return nil, ErrVersionMismatch
+	}
+
+	updated, err := patchByID(ctx, tx, id, patch, nil)
+	if err != nil {
+		log.Printf("Ошибка при частичном обновлении подписки: %v", err)
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		err = mapError(err)
+		log.Printf("Ошибка при фиксации транзакции: %v", err)
+		return nil, err
+	}
+	return updated, nil
+}
+
+// patchByID применяет patch к подписке с идентификатором id (и версией ifVersion, если она задана)
+// одним запросом UPDATE ... RETURNING. Пустой patch не изменяет подписку и возвращает её текущее состояние.
+// Если подходящей строки нет, возвращает ErrNotFound.
+func patchByID(ctx context.Context, q querier, id uuid.UUID, patch model.SubscriptionPatch, ifVersion *int64) (*model.Subscription, error) {
+	sets, args := patchSets(patch)
+
+	args = append(args, id, ifVersion)
+	where := " WHERE id = $" + strconv.Itoa(len(args)-1) +
+		" AND ($" + strconv.Itoa(len(args)) + "::bigint IS NULL OR version = $" + strconv.Itoa(len(args)) + ")"
+	query := "SELECT " + subscriptionColumns + " FROM subscriptions" + where
+	if len(sets) > 0 {
+		sets = append(sets, "version = "+nextVersion)
+		query = "UPDATE subscriptions SET " + strings.Join(sets, ", ") + where + " RETURNING " + subscriptionColumns
+	}
+
+	updated, err := scanSubscription(q.QueryRow(ctx, query, args...))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &updated, nil
+}
+
+// patchSets формирует присваивания SET для полей, заданных в patch, и их параметры ($1, $2, ...)
+func patchSets(patch model.SubscriptionPatch) ([]string, []interface{}) {
+	sets := []string{}
+	args := []interface{}{}
+	if patch.ServiceName != nil {
+		args = append(args, *patch.ServiceName)
+		sets = append(sets, "service_name = $"+strconv.Itoa(len(args)))
+	}
+	if patch.Price != nil {
+		args = append(args, int64(*patch.Price))
+		sets = append(sets, "price = $"+strconv.Itoa(len(args)))
+	}
+	if patch.Currency != nil {
+		args = append(args, patch.Currency.String())
+		sets = append(sets, "currency = $"+strconv.Itoa(len(args)))
+	}
+	if patch.BillingPeriod != nil {
+		args = append(args, patch.BillingPeriod.String())
+		sets = append(sets, "billing_period = $"+strconv.Itoa(len(args)))
+	}
+	if patch.StartDate != nil || patch.BillingDay != nil {
+		sets = append(sets, "start_date = "+startDateExpr(patch, &args))
+	}
+	if patch.ClearEndDate {
+		sets = append(sets, "end_date = NULL")
+	} else if patch.EndDate != nil {
+		args = append(args, patch.EndDate.ToTime())
+		sets = append(sets, "end_date = $"+strconv.Itoa(len(args)))
+	}
+	return sets, args
+}
+
+// startDateExpr — новое значение start_date для patch: заданная дата начала или текущая,
+// перенесённая на день patch.BillingDay (в коротких месяцах — на последний день месяца)
+func startDateExpr(patch model.SubscriptionPatch, args *[]interface{}) string {
+	start := "start_date"
+	if patch.StartDate != nil {
+		*args = append(*args, patch.StartDate.ToTime())
+		start = "$" + strconv.Itoa(len(*args)) + "::date"
+	}
+	if patch.BillingDay == nil {
+		return start
+	}
+	*args = append(*args, *patch.BillingDay)
+	month := "date_trunc('month', " + start + ")::date"
+	return "LEAST(" + month + " + ($" + strconv.Itoa(len(*args)) + "::int - 1), (" + month + " + interval '1 month - 1 day')::date)"
+}
+
+// DeleteSubscriptionByID удаляет подписку по идентификатору.
+// Если ifVersion задан, подписка удаляется только при совпадении её текущей версии.
+// Возвращает удалённую подписку, ErrNotFound, если подписка не найдена, или ErrVersionMismatch.
+func (r *SubRepository) DeleteSubscriptionByID(ctx context.Context, id uuid.UUID, ifVersion *int64) (*model.Subscription, error) {
+	log.Printf("Удаление подписки id=%s", id)
+
+	query := "DELETE FROM subscriptions WHERE id = $1 AND ($2::bigint IS NULL OR version = $2) RETURNING " + subscriptionColumns
+
+	deleted, err := scanSubscription(r.db.QueryRow(ctx, query, id, ifVersion))
+	if err != nil {
+		err = r.missingOrChanged(ctx, mapError(err), "id = $1", id)
+		log.Printf("Ошибка при удалении подписки: %v", err)
+		return nil, err
+	}
+	return &deleted, nil
+}
+
 // DeleteSubscription удаляет подписку по userID, имени сервиса и дате начала.
-func (r *SubRepository) DeleteSubscription(ctx context.Context, userID uuid.UUID, serviceName string, startDate model.MonthYear) error {
+// Если ifVersion задан, подписка удаляется только при совпадении её текущей версии.
+// Если подписка не найдена, возвращает ErrNotFound; если версия не совпала — ErrVersionMismatch.
+func (r *SubRepository) DeleteSubscription(ctx context.Context, userID uuid.UUID, serviceName string, startDate model.MonthYear, ifVersion *int64) error {
 	log.Printf("Удаление подписки userID=%s, serviceName=%s, startDate=%s", userID, serviceName, startDate.ToTime().Format("2006-01-02"))
 
 	query := `
         DELETE FROM subscriptions
-        WHERE user_id = $1 AND service_name = $2 AND start_date = $3
+        WHERE ` + keyCondition(1) + ` AND ($4::bigint IS NULL OR version = $4)
     `
-	_, err := r.db.Exec(ctx, query, userID, serviceName, startDate.ToTime())
+	tag, err := r.db.Exec(ctx, query, userID, serviceName, startDate.ToTime(), ifVersion)
 	if err != nil {
 		log.Printf("Ошибка при удалении подписки: %v", err)
+		return err
 	}
-	return err
+	if tag.RowsAffected() == 0 {
+		err = r.missingOrChanged(ctx, ErrNotFound, keyCondition(1), userID, serviceName, startDate.ToTime())
+		log.Printf("Подписка для удаления не найдена: %v", err)
+		return err
+	}
+	return nil
 }
 
-// ListSubscriptions возвращает список подписок с возможностью фильтрации по userID, имени сервиса и диапазону дат.
-// Если фильтры не заданы, возвращаются все записи.
-func (r *SubRepository) ListSubscriptions(ctx context.Context, userID *uuid.UUID, serviceName *string, startDate, endDate *model.MonthYear) ([]model.Subscription, error) {
-	log.Println("Получение списка подписок")
+// ListSubscriptions возвращает страницу подписок, отобранных по фильтру, и общее количество подходящих записей.
+// Если фильтры не заданы, учитываются все записи.
+// Пагинация выполняется по курсору (keyset по сортируемому полю и первичному ключу) или по смещению.
+// Возвращает курсор следующей страницы или nil, если страница последняя.
+func (r *SubRepository) ListSubscriptions(ctx context.Context, filter model.SubscriptionFilter, page model.Page) ([]model.Subscription, *model.Cursor, int, error) {
+	log.Printf("Получение списка подписок: limit=%d offset=%d sort=%q", page.Limit, page.Offset, page.Sort.String())
 
-	query := `
-        SELECT service_name, price, user_id, start_date, end_date
-        FROM subscriptions
-        WHERE 1=1
-    `
-	args := []interface{}{}
-	i := 1
+	where, args := buildFilter(filter)
 
-	if userID != nil {
-		query += " AND user_id = $" + strconv.Itoa(i)
-		args = append(args, *userID)
-		i++
+	var total int
+	countQuery := "SELECT COUNT(*) FROM subscriptions" + where
+	if err := r.db.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		log.Printf("Ошибка при подсчёте подписок: %v", err)
+		return nil, nil, 0, err
 	}
-	if serviceName != nil {
-		query += " AND service_name ILIKE $" + strconv.Itoa(i)
-		args = append(args, "%"+*serviceName+"%")
-		i++
+
+	subs, next, err := collectPage(ctx, r.IterateSubscriptions, filter, page)
+	if err != nil {
+		return nil, nil, 0, err
 	}
-	if startDate != nil {
-		query += " AND start_date >= $" + strconv.Itoa(i)
-		args = append(args, startDate.ToTime())
-		i++
+	log.Printf("Найдено подписок: %d из %d", len(subs), total)
+	return subs, next, total, nil
+}
+
+// IterateSubscriptions вызывает fn для каждой подписки, подходящей под фильтр, в порядке page.Sort,
+// начиная с page.Cursor или page.Offset; page.Limit > 0 ограничивает число подписок.
+// Строки читаются из результата запроса по одной, поэтому память не зависит от размера выборки;
+// соединение с базой занято до конца обхода. Ошибка fn прекращает обход и возвращается как есть.
+func (r *SubRepository) IterateSubscriptions(ctx context.Context, filter model.SubscriptionFilter, page model.Page, fn func(model.Subscription) error) error {
+	where, args := buildFilter(filter)
+
+	columns := sortColumns(page.Sort)
+	direction, cmp := "ASC", ">"
+	if page.Sort.Desc {
+		direction, cmp = "DESC", "<"
 	}
-	if endDate != nil {
-		query += " AND start_date <= $" + strconv.Itoa(i)
-		args = append(args, endDate.ToTime())
-		i++
+
+	query := "SELECT " + subscriptionColumns + " FROM subscriptions" + where
+
+	if page.Cursor != nil {
+		values := map[string]interface{}{
+			"price":        int64(page.Cursor.Price),
+			"user_id":      page.Cursor.UserID,
+			"service_name": page.Cursor.ServiceName,
+			"start_date":   page.Cursor.StartDate,
+		}
+		placeholders := make([]string, len(columns))
+		for i, col := range columns {
+			args = append(args, values[col])
+			placeholders[i] = "$" + strconv.Itoa(len(args))
+		}
+		query += " AND (" + strings.Join(columns, ", ") + ") " + cmp + " (" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	order := make([]string, len(columns))
+	for i, col := range columns {
+		order[i] = col + " " + direction
+	}
+	query += " ORDER BY " + strings.Join(order, ", ")
+
+	if page.Limit > 0 {
+		args = append(args, page.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+	if page.Cursor == nil && page.Offset > 0 {
+		args = append(args, page.Offset)
+		query += " OFFSET $" + strconv.Itoa(len(args))
 	}
 
 	log.Printf("SQL-запрос: %s\nПараметры: %+v", query, args)
@@ -164,61 +383,203 @@ func (r *SubRepository) ListSubscriptions(ctx context.Context, userID *uuid.UUID
 	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		log.Printf("Ошибка при выполнении запроса: %v", err)
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var subs []model.Subscription
 	for rows.Next() {
-		var sub model.Subscription
-		var startTime time.Time
-		var endTimePtr *time.Time
-
-		err := rows.Scan(&sub.ServiceName, &sub.Price, &sub.UserID, &startTime, &endTimePtr)
+		sub, err := scanSubscription(rows)
 		if err != nil {
 			log.Printf("Ошибка при сканировании строки: %v", err)
-			return nil, err
+			return err
 		}
-
-		sub.StartDate = model.MonthYear(startTime)
-		if endTimePtr != nil {
-			ym := model.MonthYear(*endTimePtr)
-			sub.EndDate = &ym
+		if err := fn(sub); err != nil {
+			return err
 		}
-		subs = append(subs, sub)
 	}
-	log.Printf("Найдено подписок: %d", len(subs))
-	return subs, nil
+	if err := rows.Err(); err != nil {
+		log.Printf("Ошибка при чтении результатов: %v", err)
+		return err
+	}
+	return nil
 }
 
-// CalculateTotalPrice вычисляет общую сумму подписок в указанном диапазоне дат.
-// Может фильтровать по userID и названию сервиса.
-func (r *SubRepository) CalculateTotalPrice(ctx context.Context, userID *uuid.UUID, serviceName *string, fromDate, toDate model.MonthYear) (int, error) {
-	log.Printf("Подсчёт общей стоимости подписок c %s по %s", fromDate.ToTime().Format("2006-01-02"), toDate.ToTime().Format("2006-01-02"))
+// sortColumns возвращает колонки сортировки списка: выбранное поле (если есть)
+// и первичный ключ (user_id, service_name, start_date) для однозначного порядка.
+func sortColumns(sort model.SortField) []string {
+	switch sort.Column {
+	case "price":
+		return []string{"price", "user_id", "service_name", "start_date"}
+	case "start_date":
+		return []string{"start_date", "user_id", "service_name"}
+	case "service_name":
+		return []string{"service_name", "user_id", "start_date"}
+	default:
+		return []string{"user_id", "service_name", "start_date"}
+	}
+}
 
-	query := `SELECT COALESCE(SUM(price), 0) FROM subscriptions WHERE start_date BETWEEN $1 AND $2`
-	args := []interface{}{fromDate.ToTime(), toDate.ToTime()}
-	i := 3
+// buildFilter формирует условие WHERE и его параметры по фильтру подписок.
+// Условие всегда непустое, так что к нему можно дописывать " AND ...".
+func buildFilter(filter model.SubscriptionFilter) (string, []interface{}) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
 
-	if userID != nil {
-		query += " AND user_id = $" + strconv.Itoa(i)
-		args = append(args, *userID)
-		i++
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		where += " AND user_id = $" + strconv.Itoa(len(args))
+	}
+	if filter.ServiceName != nil {
+		if filter.ServiceNameExact {
+			args = append(args, *filter.ServiceName)
+			where += " AND service_name = $" + strconv.Itoa(len(args))
+		} else {
+			args = append(args, "%"+*filter.ServiceName+"%")
+			where += " AND service_name ILIKE $" + strconv.Itoa(len(args))
+		}
+	}
+	if filter.StartFrom != nil {
+		args = append(args, filter.StartFrom.ToTime())
+		where += " AND start_date >= $" + strconv.Itoa(len(args))
+	}
+	if filter.StartTo != nil {
+		args = append(args, filter.StartTo.ToTime())
+		where += " AND " + startMonthExpr + " <= $" + strconv.Itoa(len(args))
+	}
+	if filter.MinPrice != nil {
+		args = append(args, int64(*filter.MinPrice))
+		where += " AND price >= $" + strconv.Itoa(len(args))
 	}
-	if serviceName != nil {
-		query += " AND service_name ILIKE $" + strconv.Itoa(i)
-		args = append(args, "%"+*serviceName+"%")
+	if filter.MaxPrice != nil {
+		args = append(args, int64(*filter.MaxPrice))
+		where += " AND price <= $" + strconv.Itoa(len(args))
 	}
+	if filter.ActiveOn != nil {
+		args = append(args, filter.ActiveOn.ToTime())
+		n := strconv.Itoa(len(args))
+		where += " AND " + startMonthExpr + " <= $" + n + " AND (end_date IS NULL OR end_date >= $" + n + ")"
+	}
+	if filter.EndsBefore != nil {
+		args = append(args, filter.EndsBefore.ToTime())
+		where += " AND end_date < $" + strconv.Itoa(len(args))
+	}
+	if filter.EndsAfter != nil {
+		args = append(args, filter.EndsAfter.ToTime())
+		where += " AND end_date > $" + strconv.Itoa(len(args))
+	}
+	if filter.ActiveSince != nil {
+		args = append(args, filter.ActiveSince.ToTime())
+		where += " AND (end_date IS NULL OR end_date >= $" + strconv.Itoa(len(args)) + ")"
+	}
+	if filter.OpenEnded != nil {
+		if *filter.OpenEnded {
+			where += " AND end_date IS NULL"
+		} else {
+			where += " AND end_date IS NOT NULL"
+		}
+	}
+	if filter.Status != nil {
+		// Состояние на текущий месяц хранится в колонке status (см. RefreshStatuses)
+		args = append(args, string(*filter.Status))
+		where += " AND status = $" + strconv.Itoa(len(args))
+	}
+	return where, args
+}
 
-	log.Printf("SQL-запрос: %s\nПараметры: %+v", query, args)
+// CalculateTotalPrice вычисляет общую стоимость подписок за период [fromDate, toDate].
+// Каждая подписка, пересекающаяся с периодом, учитывается за месяцы, в которых она активна внутри периода
+// (с учётом end_date и приостановок; без end_date — бессрочная): по списаниям её периода оплаты
+// или по нормализованной ежемесячной цене — в зависимости от opts.View; суммы пересчитываются в валюту opts.Currency
+// по курсу на первое число каждого месяца. Может фильтровать по userID и названию сервиса.
+// При непустом groupBy в том же проходе суммы группируются по указанным полям (название сервиса, пользователь, месяц).
+// Возвращает общую сумму, детализацию по каждой учтённой подписке и группы; без нужного курса — ошибку model.ErrNoRate.
+func (r *SubRepository) CalculateTotalPrice(ctx context.Context, userID *uuid.UUID, serviceName *string, fromDate, toDate model.MonthYear, groupBy []model.GroupField, opts model.CostOptions) (model.Money, []model.SubscriptionCost, []model.SpendGroup, error) {
+	log.Printf("Подсчёт общей стоимости подписок c %s по %s", fromDate.ToTime().Format("2006-01-02"), toDate.ToTime().Format("2006-01-02"))
 
-	var total int
-	err := r.db.QueryRow(ctx, query, args...).Scan(&total)
+	total, costs, groups, err := totalPrice(ctx, r.IterateSubscriptions, userID, serviceName, fromDate, toDate, groupBy, opts)
 	if err != nil {
 		log.Printf("Ошибка при подсчёте общей стоимости: %v", err)
+		return 0, nil, nil, err
+	}
+	log.Printf("Общая сумма подписок: %s (учтено подписок: %d, групп: %d)", total, len(costs), len(groups))
+	return total, costs, groups, nil
+}
+
+// SpendTimeline возвращает помесячную разбивку расходов на подписки за период [fromDate, toDate]:
+// по одному элементу на каждый календарный месяц, включая месяцы без расходов; стоимость распределяется
+// по месяцам и пересчитывается в валюту так же, как в CalculateTotalPrice. Может фильтровать по userID и названию сервиса.
+func (r *SubRepository) SpendTimeline(ctx context.Context, userID *uuid.UUID, serviceName *string, fromDate, toDate model.MonthYear, opts model.CostOptions) ([]model.MonthlySpend, error) {
+	log.Printf("Построение помесячной разбивки расходов c %s по %s", fromDate.ToTime().Format("2006-01-02"), toDate.ToTime().Format("2006-01-02"))
+
+	timeline, err := spendTimeline(ctx, r.IterateSubscriptions, userID, serviceName, fromDate, toDate, opts)
+	if err != nil {
+		log.Printf("Ошибка при построении разбивки расходов: %v", err)
+		return nil, err
+	}
+	log.Printf("Разбивка расходов построена: месяцев %d", len(timeline))
+	return timeline, nil
+}
+
+// UpcomingCharges возвращает ожидаемые списания подписок пользователя в интервале дней [from, to]:
+// каждая подписка, активная в месяцах интервала, разворачивается по своему периоду оплаты
+// и дню списаний (billing_day). Списания упорядочены по дате и идут с нарастающим итогом по каждой валюте.
+func (r *SubRepository) UpcomingCharges(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]model.Charge, model.Subtotals, error) {
+	log.Printf("Построение календаря списаний user_id=%s c %s по %s", userID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	charges, totals, err := upcomingCharges(ctx, r.IterateSubscriptions, userID, from, to)
+	if err != nil {
+		log.Printf("Ошибка при построении календаря списаний: %v", err)
+		return nil, nil, err
+	}
+	log.Printf("Календарь списаний построен: списаний %d, валют %d", len(charges), len(totals))
+	return charges, totals, nil
+}
+
+// startMonthExpr — месяц даты начала подписки; фильтры по месяцам не учитывают день начала
+const startMonthExpr = "date_trunc('month', start_date)::date"
+
+// monthKeyExpr — месяц даты начала в уникальном индексе subscriptions_month_key
+const monthKeyExpr = "date_trunc('month', start_date::timestamp)"
+
+// keyCondition — условие поиска подписки по составному ключу с параметрами $first, $first+1, $first+2:
+// дата начала сравнивается с точностью до месяца, как в ключе MM-YYYY
+func keyCondition(first int) string {
+	n := func(i int) string { return strconv.Itoa(first + i) }
+	return "user_id = $" + n(0) + " AND service_name = $" + n(1) + " AND " + monthKeyExpr + " = date_trunc('month', $" + n(2) + "::timestamp)"
+}
+
+// subscriptionColumns — список колонок подписки в порядке, который ожидает scanSubscription
+const subscriptionColumns = "id, service_name, price, currency, billing_period, user_id, start_date, end_date, version, cancelled_at, pauses"
+
+// nextVersion — выражение для новой версии подписки; каждое изменение строки получает следующее значение последовательности
+const nextVersion = "nextval('subscriptions_version_seq')"
+
+// scanSubscription считывает одну строку с колонками subscriptionColumns в модель подписки
+// и вычисляет её состояние на текущий месяц.
+func scanSubscription(row pgx.Row) (model.Subscription, error) {
+	var sub model.Subscription
+	var startTime time.Time
+	var endTimePtr *time.Time
+	var price int64
+	var currency, period string
+
+	if err := row.Scan(&sub.ID, &sub.ServiceName, &price, &currency, &period, &sub.UserID, &startTime, &endTimePtr, &sub.Version, &sub.CancelledAt, &sub.Pauses); err != nil {
+		return sub, err
+	}
+
+	sub.Price = model.Money(price)
+	sub.Currency = model.Currency(currency)
+	sub.BillingPeriod = model.BillingPeriod(period)
+	sub.StartDate = model.MonthYear(startTime)
+	if endTimePtr != nil {
+		ym := model.MonthYear(*endTimePtr)
+		sub.EndDate = &ym
+	}
+	if len(sub.Pauses) == 0 {
+		sub.Pauses = nil
 	}
-	log.Printf("Общая сумма подписок: %d", total)
-	return total, err
+	sub.FillComputed()
+	return sub, nil
 }
 
 // timeMustParse — вспомогательная функция для преобразования строки в time.Time.
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
 	"time"
@@ -17,7 +18,7 @@ func TestCreateGetUpdateDeleteSubscription(t *testing.T) {
 
     dsn := os.Getenv("DSN")
     if dsn == "" {
-        t.Fatal("Переменная окружения DSN не установлена")
+        t.Skip("Переменная окружения DSN не установлена, тест с PostgreSQL пропущен")
     }
 
     db, err := storage.NewPostgres(ctx, dsn)
@@ -35,7 +36,7 @@ func TestCreateGetUpdateDeleteSubscription(t *testing.T) {
 
     sub := &model.Subscription{
         ServiceName: serviceName,
-        Price:       999,
+        Price:       99900,
         UserID:      userID,
         StartDate:   startDate,
         EndDate:     &endDate,
@@ -55,13 +56,30 @@ func TestCreateGetUpdateDeleteSubscription(t *testing.T) {
         t.Fatal("Получена пустая подписка, ожидалась подписка")
     }
     if gotSub.Price != sub.Price {
-        t.Errorf("Получена цена %d, ожидалась %d", gotSub.Price, sub.Price)
+        t.Errorf("Получена цена %s, ожидалась %s", gotSub.Price, sub.Price)
+    }
+
+    // Повторное создание с тем же ключом
+    if err := repo.CreateSubscription(ctx, sub); !errors.Is(err, ErrAlreadyExists) {
+        t.Errorf("Повторное создание подписки: ошибка %v, ожидалась ErrAlreadyExists", err)
+    }
+
+    // GET по id
+    if sub.ID == uuid.Nil {
+        t.Fatal("После создания не назначен id подписки")
+    }
+    byID, err := repo.GetSubscriptionByID(ctx, sub.ID)
+    if err != nil {
+        t.Fatalf("Получение подписки по id завершилось ошибкой: %v", err)
+    }
+    if byID == nil || byID.ServiceName != serviceName || byID.UserID != userID {
+        t.Errorf("По id получена подписка %+v, ожидалась %+v", byID, sub)
     }
 
     // UPDATE
-    newPrice := 1099
+    newPrice := model.Money(109900)
     newEndDate := model.MonthYear(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
-    if err := repo.UpdateSubscription(ctx, userID, serviceName, startDate, newPrice, &newEndDate); err != nil {
+    if _, err := repo.PatchSubscriptionByKey(ctx, userID, serviceName, startDate, model.SubscriptionPatch{Price: &newPrice, EndDate: &newEndDate}, nil); err != nil {
         t.Fatalf("Обновление подписки завершилось ошибкой: %v", err)
     }
 
@@ -70,14 +88,14 @@ func TestCreateGetUpdateDeleteSubscription(t *testing.T) {
         t.Fatalf("Получение подписки после обновления завершилось ошибкой: %v", err)
     }
     if updatedSub.Price != newPrice {
-        t.Errorf("После обновления получена цена %d, ожидалась %d", updatedSub.Price, newPrice)
+        t.Errorf("После обновления получена цена %s, ожидалась %s", updatedSub.Price, newPrice)
     }
     if updatedSub.EndDate == nil || !updatedSub.EndDate.ToTime().Equal(newEndDate.ToTime()) {
         t.Errorf("После обновления получена дата окончания %v, ожидалась %v", updatedSub.EndDate, newEndDate)
     }
 
     // LIST (по userID)
-    subs, err := repo.ListSubscriptions(ctx, &userID, nil, nil, nil)
+    subs, _, _, err := repo.ListSubscriptions(ctx, model.SubscriptionFilter{UserID: &userID}, model.Page{Limit: 10})
     if err != nil {
         t.Fatalf("Получение списка подписок завершилось ошибкой: %v", err)
     }
@@ -85,17 +103,38 @@ func TestCreateGetUpdateDeleteSubscription(t *testing.T) {
         t.Error("Получен пустой список подписок, ожидался хотя бы один элемент")
     }
 
+    // PATCH по составному ключу с переносом даты начала
+    movedStart := model.MonthYear(time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC))
+    patched, err := repo.PatchSubscriptionByKey(ctx, userID, serviceName, startDate, model.SubscriptionPatch{StartDate: &movedStart}, &updatedSub.Version)
+    if err != nil {
+        t.Fatalf("Частичное обновление по составному ключу завершилось ошибкой: %v", err)
+    }
+    if patched.ID != sub.ID || patched.Price != newPrice || patched.EndDate == nil || patched.Version == updatedSub.Version {
+        t.Errorf("После переноса даты начала получена подписка %+v", patched)
+    }
+    startDate = movedStart
+
+    // Удаление по устаревшей версии отклоняется
+    if err := repo.DeleteSubscription(ctx, userID, serviceName, startDate, &updatedSub.Version); !errors.Is(err, ErrVersionMismatch) {
+        t.Errorf("Удаление по устаревшей версии: ошибка %v, ожидалась ErrVersionMismatch", err)
+    }
+
     // DELETE
-    if err := repo.DeleteSubscription(ctx, userID, serviceName, startDate); err != nil {
+    if err := repo.DeleteSubscription(ctx, userID, serviceName, startDate, nil); err != nil {
         t.Fatalf("Удаление подписки завершилось ошибкой: %v", err)
     }
 
     deletedSub, err := repo.GetSubscription(ctx, userID, serviceName, startDate)
-    if err != nil {
-        t.Fatalf("Получение подписки после удаления завершилось ошибкой: %v", err)
+    if !errors.Is(err, ErrNotFound) {
+        t.Fatalf("Получение подписки после удаления: ошибка %v, ожидалась ErrNotFound", err)
     }
     if deletedSub != nil {
         t.Error("Подписка не удалена, ожидалось nil")
     }
+
+    // Повторное удаление отсутствующей подписки
+    if err := repo.DeleteSubscription(ctx, userID, serviceName, startDate, nil); !errors.Is(err, ErrNotFound) {
+        t.Errorf("Повторное удаление: ошибка %v, ожидалась ErrNotFound", err)
+    }
 }
 
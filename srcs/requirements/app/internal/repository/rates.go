@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"subscription_service/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// rateColumns — колонки курса в порядке сканирования scanRate
+const rateColumns = "currency, quote, to_char(date, 'YYYY-MM-DD'), rate::text, source, recorded_at"
+
+// SaveRates сохраняет курсы новыми версиями в таблицу currency_rates в одной транзакции;
+// моментом записи всех версий служит время начала транзакции
+func (r *SubRepository) SaveRates(ctx context.Context, rates []model.ExchangeRate) ([]model.ExchangeRate, error) {
+	rates = lastRates(rates)
+	log.Printf("Сохранение курсов валют: %d", len(rates))
+	query := `
+        INSERT INTO currency_rates (currency, quote, date, rate, source)
+        SELECT $1::text, $2::text, $3::date, $4::numeric, $5::text
+        WHERE NOT EXISTS (
+            SELECT 1 FROM (
+                SELECT rate FROM currency_rates
+                WHERE currency = $1 AND quote = $2 AND date = $3
+                ORDER BY recorded_at DESC LIMIT 1
+            ) last WHERE last.rate = $4::numeric
+        )
+        RETURNING recorded_at
+    `
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Printf("Ошибка при открытии транзакции: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, rate := range rates {
+		batch.Queue(query, rate.Currency.String(), rate.Quote.String(), rate.Date, rate.Rate.String(), rate.Source)
+	}
+
+	var saved []model.ExchangeRate
+	br := tx.SendBatch(ctx, batch)
+	for _, rate := range rates {
+		err := br.QueryRow().Scan(&rate.RecordedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue // курс совпадает с последней версией
+		}
+		if err != nil {
+			br.Close()
+			err = mapError(err)
+			log.Printf("Ошибка при сохранении курса %s/%s на %s: %v", rate.Currency, rate.Quote, rate.Date, err)
+			return nil, err
+		}
+		saved = append(saved, rate)
+	}
+	if err := br.Close(); err != nil {
+		log.Printf("Ошибка при завершении пакета запросов: %v", err)
+		return nil, mapError(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Ошибка при фиксации транзакции: %v", err)
+		return nil, mapError(err)
+	}
+	log.Printf("Сохранено новых версий курсов: %d из %d", len(saved), len(rates))
+	return saved, nil
+}
+
+// ListRates возвращает курсы из таблицы currency_rates по фильтру
+func (r *SubRepository) ListRates(ctx context.Context, filter model.RateFilter) ([]model.ExchangeRate, error) {
+	where := " WHERE 1=1"
+	var args []interface{}
+	if filter.Currency != nil {
+		args = append(args, filter.Currency.String())
+		where += " AND currency = $" + strconv.Itoa(len(args))
+	}
+	if filter.Quote != nil {
+		args = append(args, filter.Quote.String())
+		where += " AND quote = $" + strconv.Itoa(len(args))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		where += " AND date >= $" + strconv.Itoa(len(args))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		where += " AND date <= $" + strconv.Itoa(len(args))
+	}
+	recorded := ""
+	if filter.AsOf != nil {
+		args = append(args, *filter.AsOf)
+		recorded = " AND recorded_at <= $" + strconv.Itoa(len(args))
+		where += recorded
+	}
+	if filter.Since != nil {
+		// Курс, действующий на дату Since, мог быть установлен раньше неё: берём последнюю дату пары не позже Since
+		args = append(args, *filter.Since)
+		n := strconv.Itoa(len(args))
+		where += " AND (date >= $" + n + " OR date = (SELECT max(p.date) FROM currency_rates p" +
+			" WHERE p.currency = currency_rates.currency AND p.quote = currency_rates.quote AND p.date <= $" + n +
+			strings.ReplaceAll(recorded, "recorded_at", "p.recorded_at") + "))"
+	}
+
+	// Без истории из версий курса на дату остаётся последняя
+	query := "SELECT DISTINCT ON (currency, quote, date) " + rateColumns + " FROM currency_rates" + where +
+		" ORDER BY currency, quote, date, recorded_at DESC"
+	if filter.History {
+		query = "SELECT " + rateColumns + " FROM currency_rates" + where + " ORDER BY currency, quote, date, recorded_at"
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		log.Printf("Ошибка при получении курсов валют: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []model.ExchangeRate
+	for rows.Next() {
+		rate, err := scanRate(rows)
+		if err != nil {
+			log.Printf("Ошибка при чтении курса валюты: %v", err)
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Ошибка при чтении курсов валют: %v", err)
+		return nil, err
+	}
+	return rates, nil
+}
+
+// scanRate читает курс из строки с колонками rateColumns; значение передаётся текстом без потери точности
+func scanRate(row pgx.Row) (model.ExchangeRate, error) {
+	var (
+		rate            model.ExchangeRate
+		currency, quote string
+		value           string
+	)
+	if err := row.Scan(&currency, &quote, &rate.Date, &value, &rate.Source, &rate.RecordedAt); err != nil {
+		return rate, err
+	}
+	rate.Currency, rate.Quote = model.Currency(currency), model.Currency(quote)
+	v, err := decimal.NewFromString(value)
+	if err != nil {
+		return rate, err
+	}
+	rate.Rate = v
+	return rate, nil
+}
+
+// rateKey — курс пары валют на дату
+type rateKey struct {
+	currency, quote model.Currency
+	date            string
+}
+
+// lastRates оставляет по одному курсу на пару и дату — последний из переданных, на месте первого
+func lastRates(rates []model.ExchangeRate) []model.ExchangeRate {
+	index := make(map[rateKey]int, len(rates))
+	result := make([]model.ExchangeRate, 0, len(rates))
+	for _, rate := range rates {
+		key := rateKey{currency: rate.Currency, quote: rate.Quote, date: rate.Date}
+		if i, ok := index[key]; ok {
+			result[i] = rate
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, rate)
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"log"
+
+	"subscription_service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// statusExpr — состояние подписки в месяце $n, вычисленное функцией subscription_status
+// (те же правила, что model.Subscription.StatusAt; триггер subscriptions_status сохраняет его в колонку status)
+func statusExpr(n string) string {
+	return "subscription_status(start_date, end_date, cancelled_at, pauses, $" + n + ")"
+}
+
+// RefreshStatuses пересчитывает колонку status на месяц month у подписок, состояние которых изменилось
+// со сменой месяца. Версия подписки не меняется: её данные остаются прежними.
+func (r *SubRepository) RefreshStatuses(ctx context.Context, month model.MonthYear) (int64, error) {
+	query := "UPDATE subscriptions SET status = " + statusExpr("1") + " WHERE status <> " + statusExpr("1")
+	tag, err := r.db.Exec(ctx, query, month.ToTime())
+	if err != nil {
+		log.Printf("Ошибка при пересчёте состояния подписок: %v", err)
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// pausesParam — приостановки для записи в колонку pauses (NOT NULL, поэтому nil записывается как пустой массив)
+func pausesParam(pauses []model.Pause) []model.Pause {
+	if pauses == nil {
+		return []model.Pause{}
+	}
+	return pauses
+}
+
+// TransitionSubscription изменяет состояние подписки функцией transition (отмена, приостановка,
+// возобновление) в одной транзакции с блокировкой строки и сохраняет её дату окончания,
+// приостановки и момент отмены. Если ifVersion задан, изменение выполняется только при совпадении версии.
+// Возвращает обновлённую подписку, ErrNotFound, ErrVersionMismatch или ошибку transition.
+func (r *SubRepository) TransitionSubscription(ctx context.Context, id uuid.UUID, ifVersion *int64, transition func(*model.Subscription) error) (*model.Subscription, error) {
+	log.Printf("Изменение состояния подписки id=%s", id)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Printf("Ошибка при открытии транзакции: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	sub, err := scanSubscription(tx.QueryRow(ctx, "SELECT "+subscriptionColumns+" FROM subscriptions WHERE id = $1 FOR UPDATE", id))
+	if err != nil {
+		err = mapError(err)
+		log.Printf("Ошибка при поиске подписки для изменения состояния: %v", err)
+		return nil, err
+	}
+	if ifVersion != nil && *ifVersion != sub.Version {
+		log.Printf("Версия подписки id=%s изменилась: %d, ожидалась %d", id, sub.Version, *ifVersion)
+		return nil, ErrVersionMismatch
+	}
+	if err := transition(&sub); err != nil {
+		log.Printf("Переход состояния подписки id=%s отклонён: %v", id, err)
+		return nil, err
+	}
+
+	var end interface{}
+	if sub.EndDate != nil {
+		end = sub.EndDate.ToTime()
+	}
+	query := "UPDATE subscriptions SET end_date = $1, cancelled_at = $2, pauses = $3, version = " + nextVersion +
+		" WHERE id = $4 RETURNING " + subscriptionColumns
+	updated, err := scanSubscription(tx.QueryRow(ctx, query, end, sub.CancelledAt, pausesParam(sub.Pauses), id))
+	if err != nil {
+		err = mapError(err)
+		log.Printf("Ошибка при изменении состояния подписки: %v", err)
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		err = mapError(err)
+		log.Printf("Ошибка при фиксации транзакции: %v", err)
+		return nil, err
+	}
+	log.Printf("Состояние подписки id=%s: %s", id, updated.Status)
+	return &updated, nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"subscription_service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionStore — хранилище подписок, от которого зависят HTTP-обработчики.
+// Реализации: SubRepository (PostgreSQL) и MemoryRepository (в памяти, для тестов и запуска без БД).
+// Изменяющие методы с параметром ifVersion выполняются только при совпадении версии подписки
+// (nil — без проверки) и иначе возвращают ErrVersionMismatch.
+type SubscriptionStore interface {
+	CreateSubscription(ctx context.Context, sub *model.Subscription) error
+	CreateSubscriptions(ctx context.Context, subs []model.Subscription, mode model.ConflictMode) ([]model.BatchResult, error)
+	GetSubscription(ctx context.Context, userID uuid.UUID, serviceName string, startDate model.MonthYear) (*model.Subscription, error)
+	DeleteSubscription(ctx context.Context, userID uuid.UUID, serviceName string, startDate model.MonthYear, ifVersion *int64) error
+	ListSubscriptions(ctx context.Context, filter model.SubscriptionFilter, page model.Page) ([]model.Subscription, *model.Cursor, int, error)
+	// IterateSubscriptions вызывает fn для каждой подписки по фильтру в порядке страницы page
+	// (Limit 0 — без ограничения), не собирая их в память; ошибка fn прерывает обход и возвращается
+	IterateSubscriptions(ctx context.Context, filter model.SubscriptionFilter, page model.Page, fn func(model.Subscription) error) error
+	// CalculateTotalPrice считает стоимость подписок за период и, если задан groupBy, суммы по группам за тот же проход
+	CalculateTotalPrice(ctx context.Context, userID *uuid.UUID, serviceName *string, fromDate, toDate model.MonthYear, groupBy []model.GroupField, opts model.CostOptions) (model.Money, []model.SubscriptionCost, []model.SpendGroup, error)
+
+	GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
+	ReplaceSubscription(ctx context.Context, sub *model.Subscription, ifVersion *int64) (*model.Subscription, error)
+	PatchSubscription(ctx context.Context, id uuid.UUID, patch model.SubscriptionPatch, ifVersion *int64) (*model.Subscription, error)
+	PatchSubscriptionByKey(ctx context.Context, userID uuid.UUID, serviceName string, startDate model.MonthYear, patch model.SubscriptionPatch, ifVersion *int64) (*model.Subscription, error)
+	DeleteSubscriptionByID(ctx context.Context, id uuid.UUID, ifVersion *int64) (*model.Subscription, error)
+	// TransitionSubscription применяет к подписке переход состояния transition (см. model.Subscription.Cancel,
+	// Pause, Resume) и сохраняет результат; ошибка transition возвращается без изменений
+	TransitionSubscription(ctx context.Context, id uuid.UUID, ifVersion *int64, transition func(*model.Subscription) error) (*model.Subscription, error)
+
+	DeleteSubscriptionsByFilter(ctx context.Context, filter model.SubscriptionFilter, expected int) (int, error)
+	UpdateSubscriptionsByFilter(ctx context.Context, filter model.SubscriptionFilter, patch model.SubscriptionPatch, expected int) (int, error)
+
+	SpendTimeline(ctx context.Context, userID *uuid.UUID, serviceName *string, fromDate, toDate model.MonthYear, opts model.CostOptions) ([]model.MonthlySpend, error)
+	// UpcomingCharges возвращает ожидаемые списания подписок пользователя в интервале дней [from, to]
+	// с нарастающим итогом по каждой валюте (см. model.UpcomingCharges) и их суммы по валютам
+	UpcomingCharges(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]model.Charge, model.Subtotals, error)
+}
+
+// IdempotencyStore — хранилище ключей идемпотентности (заголовок Idempotency-Key).
+// Реализации: SubRepository (таблица idempotency_keys) и MemoryRepository.
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey резервирует ключ rec.Key за запросом rec.RequestHash до rec.ExpiresAt;
+	// пока ответ не сохранён, резерв действует до rec.LeaseUntil.
+	// Если ключ уже занят (model.IdempotencyRecord.Reserved), ничего не меняет и возвращает существующую запись;
+	// nil означает, что ключ зарезервирован за текущим запросом.
+	ReserveIdempotencyKey(ctx context.Context, rec model.IdempotencyRecord) (*model.IdempotencyRecord, error)
+	// CompleteIdempotencyKey сохраняет ответ на запрос, зарезервировавший ключ с токеном token.
+	// Если резерв перешёл к другому запросу, ничего не меняет и возвращает ErrLeaseLost.
+	CompleteIdempotencyKey(ctx context.Context, key, token string, status int, headers map[string]string, body []byte) error
+	// ReleaseIdempotencyKey снимает резерв с токеном token с ключа, ответ на запрос по которому так и не был сохранён.
+	// Если резерв перешёл к другому запросу, ничего не меняет и возвращает ErrLeaseLost.
+	ReleaseIdempotencyKey(ctx context.Context, key, token string) error
+	// PurgeIdempotencyKeys удаляет ключи, истёкшие до before, и возвращает их количество
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error)
+}
+
+// RateStore — история курсов валют. Курс на дату не перезаписывается: исправление сохраняется
+// новой версией с моментом записи RecordedAt, поэтому отчёт можно пересчитать по курсам,
+// известным на любой прошлый момент (model.RateFilter.AsOf).
+// Реализации: SubRepository (таблица currency_rates) и MemoryRepository.
+type RateStore interface {
+	// SaveRates сохраняет курсы новыми версиями с общим моментом записи. Курс, совпадающий
+	// с последней версией курса пары на ту же дату, не сохраняется; из повторов пары и даты
+	// в rates учитывается последний. Возвращает сохранённые версии.
+	SaveRates(ctx context.Context, rates []model.ExchangeRate) ([]model.ExchangeRate, error)
+	// ListRates возвращает курсы по фильтру в порядке валюты, валюты курса и даты
+	// (версии одной даты — в порядке записи)
+	ListRates(ctx context.Context, filter model.RateFilter) ([]model.ExchangeRate, error)
+}
+
+// StatusRefresher — хранилище, которое хранит состояние подписок на текущий месяц (для фильтра по состоянию).
+// Состояние пересчитывается при каждой записи подписки, а со сменой месяца его нужно пересчитать
+// для всех подписок. Реализация: SubRepository (колонка status); MemoryRepository вычисляет состояние при чтении.
+type StatusRefresher interface {
+	// RefreshStatuses пересчитывает сохранённое состояние подписок на месяц month
+	// и возвращает число подписок, состояние которых изменилось
+	RefreshStatuses(ctx context.Context, month model.MonthYear) (int64, error)
+}
+
+var (
+	_ SubscriptionStore = (*SubRepository)(nil)
+	_ SubscriptionStore = (*MemoryRepository)(nil)
+	_ IdempotencyStore  = (*SubRepository)(nil)
+	_ IdempotencyStore  = (*MemoryRepository)(nil)
+	_ RateStore         = (*SubRepository)(nil)
+	_ RateStore         = (*MemoryRepository)(nil)
+	_ StatusRefresher   = (*SubRepository)(nil)
+)
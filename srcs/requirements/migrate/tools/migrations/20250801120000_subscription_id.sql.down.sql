@@ -0,0 +1,2 @@
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_id_key;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS id;
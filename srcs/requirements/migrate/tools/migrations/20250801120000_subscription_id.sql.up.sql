@@ -0,0 +1,4 @@
+-- Суррогатный идентификатор подписки: позволяет адресовать подписку одним UUID
+-- и менять поля составного ключа (например, дату начала).
+ALTER TABLE subscriptions ADD COLUMN id UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_id_key UNIQUE (id);
@@ -0,0 +1,2 @@
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS version;
+DROP SEQUENCE IF EXISTS subscriptions_version_seq;
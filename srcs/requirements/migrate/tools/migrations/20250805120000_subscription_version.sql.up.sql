@@ -0,0 +1,4 @@
+-- Версия подписки для оптимистичной блокировки (ETag / If-Match).
+-- Значения берутся из общей последовательности и не повторяются даже у удалённой и созданной заново подписки.
+CREATE SEQUENCE IF NOT EXISTS subscriptions_version_seq;
+ALTER TABLE subscriptions ADD COLUMN version BIGINT NOT NULL DEFAULT nextval('subscriptions_version_seq');
@@ -0,0 +1,14 @@
+-- Ключи идемпотентности запросов (заголовок Idempotency-Key).
+-- status = 0 — запрос ещё выполняется; после выполнения сохраняются статус, заголовки и тело ответа,
+-- которые возвращаются повторным запросам с тем же ключом до истечения expires_at.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key TEXT PRIMARY KEY,
+    request_hash TEXT NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    headers JSONB NOT NULL DEFAULT '{}',
+    body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS pauses;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS cancelled_at;
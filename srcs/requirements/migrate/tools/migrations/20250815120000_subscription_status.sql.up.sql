@@ -0,0 +1,5 @@
+-- Переходы состояния подписки: момент отмены и приостановки.
+-- Само состояние (active, upcoming, expired, paused, cancelled) вычисляется по датам на текущий месяц.
+-- Приостановки — JSON-массив объектов {"from": "MM-YYYY", "to": "MM-YYYY"}, to отсутствует у незавершённой.
+ALTER TABLE subscriptions ADD COLUMN cancelled_at TIMESTAMPTZ;
+ALTER TABLE subscriptions ADD COLUMN pauses JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,3 @@
+-- Период оплаты подписки: price списывается один раз за период.
+-- Значения: week, month, quarter, year или Nm (раз в N месяцев); существующие подписки ежемесячные.
+ALTER TABLE subscriptions ADD COLUMN billing_period TEXT NOT NULL DEFAULT 'month';
@@ -0,0 +1,3 @@
+-- Валюта цены подписки (код ISO 4217); существующие подписки в рублях.
+ALTER TABLE subscriptions ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB'
+    CHECK (currency ~ '^[A-Z]{3}$');
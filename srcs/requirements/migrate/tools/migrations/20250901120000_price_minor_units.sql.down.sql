@@ -0,0 +1,3 @@
+-- Копейки отбрасываются с округлением до целых единиц валюты
+COMMENT ON COLUMN subscriptions.price IS NULL;
+ALTER TABLE subscriptions ALTER COLUMN price TYPE INTEGER USING round(price / 100.0)::integer;
@@ -0,0 +1,4 @@
+-- Цена хранится в сотых долях валюты (копейках, центах), чтобы принимать суммы вида 199.99
+-- и складывать их без округлений; существующие цены в целых рублях умножаются на 100.
+ALTER TABLE subscriptions ALTER COLUMN price TYPE BIGINT USING price::bigint * 100;
+COMMENT ON COLUMN subscriptions.price IS 'цена за период оплаты в сотых долях валюты';
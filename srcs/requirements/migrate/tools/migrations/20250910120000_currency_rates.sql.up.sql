@@ -0,0 +1,12 @@
+-- История курсов валют: одна единица currency стоит rate единиц quote на дату date.
+-- Курс не перезаписывается: исправление добавляет строку с более поздним recorded_at,
+-- поэтому отчёт можно пересчитать по курсам, известным на любой прошлый момент.
+CREATE TABLE IF NOT EXISTS currency_rates (
+    currency TEXT NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    quote TEXT NOT NULL DEFAULT 'RUB' CHECK (quote ~ '^[A-Z]{3}$' AND quote <> currency),
+    date DATE NOT NULL,
+    rate NUMERIC NOT NULL CHECK (rate > 0),
+    source TEXT NOT NULL DEFAULT '',
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (currency, quote, date, recorded_at)
+);
@@ -0,0 +1,4 @@
+-- Составной ключ подписки в API задаёт месяц начала (MM-YYYY), а день списаний хранится в той же колонке start_date:
+-- подписка на сервис у пользователя уникальна в пределах месяца начала.
+CREATE UNIQUE INDEX IF NOT EXISTS subscriptions_month_key
+    ON subscriptions (user_id, service_name, (date_trunc('month', start_date::timestamp)));
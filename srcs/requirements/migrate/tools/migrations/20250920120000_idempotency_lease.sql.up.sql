@@ -0,0 +1,7 @@
+-- Резерв ключа за выполняющимся запросом (status = 0) действует до lease_until: если процесс упал,
+-- не сохранив ответ, после lease_until ключ может занять повтор запроса, не дожидаясь expires_at.
+-- lease_token — случайный токен резерва: ответ сохраняет и резерв снимает только запрос, который им владеет,
+-- поэтому запрос, чей резерв перехватили, не перезаписывает ответ повтора.
+-- Уже зависшие резервы освобождаются сразу.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS lease_until TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS lease_token TEXT NOT NULL DEFAULT '';
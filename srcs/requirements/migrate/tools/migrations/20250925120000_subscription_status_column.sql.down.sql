@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS subscriptions_user_status_idx;
+DROP TRIGGER IF EXISTS subscriptions_status ON subscriptions;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS status;
+DROP FUNCTION IF EXISTS subscriptions_set_status();
+DROP FUNCTION IF EXISTS subscription_status(DATE, DATE, TIMESTAMPTZ, JSONB, DATE);
@@ -0,0 +1,37 @@
+-- Состояние подписки на текущий месяц (UTC) хранится в колонке status, чтобы фильтр по состоянию шёл
+-- по индексу, а не разбирал приостановки каждой строки. Состояние вычисляет subscription_status
+-- по тем же правилам, что model.Subscription.StatusAt: триггер пересчитывает его при каждой записи дат,
+-- отмены или приостановок, а сервис — в начале каждого месяца, когда состояние меняется само
+-- (подписка началась или закончилась, приостановка началась или кончилась).
+CREATE OR REPLACE FUNCTION subscription_status(start_date DATE, end_date DATE, cancelled_at TIMESTAMPTZ, pauses JSONB, month DATE)
+RETURNS TEXT LANGUAGE sql STABLE AS $$
+    SELECT CASE
+        WHEN date_trunc('month', start_date)::date > month THEN 'upcoming'
+        WHEN end_date < month AND cancelled_at IS NULL THEN 'expired'
+        WHEN end_date < month THEN 'cancelled'
+        WHEN EXISTS (
+            SELECT 1 FROM jsonb_array_elements(pauses) p
+            WHERE to_date(p->>'from', 'MM-YYYY') <= month AND (p->>'to' IS NULL OR to_date(p->>'to', 'MM-YYYY') >= month)
+        ) THEN 'paused'
+        ELSE 'active'
+    END
+$$;
+
+CREATE OR REPLACE FUNCTION subscriptions_set_status() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.status := subscription_status(NEW.start_date, NEW.end_date, NEW.cancelled_at, NEW.pauses,
+        date_trunc('month', now() AT TIME ZONE 'UTC')::date);
+    RETURN NEW;
+END
+$$;
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'upcoming', 'expired', 'paused', 'cancelled'));
+UPDATE subscriptions
+SET status = subscription_status(start_date, end_date, cancelled_at, pauses, date_trunc('month', now() AT TIME ZONE 'UTC')::date);
+
+CREATE TRIGGER subscriptions_status
+    BEFORE INSERT OR UPDATE OF start_date, end_date, cancelled_at, pauses ON subscriptions
+    FOR EACH ROW EXECUTE FUNCTION subscriptions_set_status();
+
+CREATE INDEX IF NOT EXISTS subscriptions_user_status_idx ON subscriptions (user_id, status);